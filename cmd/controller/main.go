@@ -16,34 +16,47 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"os"
+
 	"github.com/azure/gpu-provisioner/pkg/cloudprovider"
 	"github.com/azure/gpu-provisioner/pkg/controllers"
+	"github.com/azure/gpu-provisioner/pkg/controllers/middleware"
 	"github.com/azure/gpu-provisioner/pkg/operator"
+	"github.com/samber/lo"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider/metrics"
 	karpentercontrollers "sigs.k8s.io/karpenter/pkg/controllers"
 	karpenteroperator "sigs.k8s.io/karpenter/pkg/operator"
 )
 
 func main() {
+	// the diagnosis runs before karpenter parses the flags, which rejects unknown flags.
+	if lo.Contains(os.Args[1:], operator.DiagnoseFlag) {
+		os.Exit(operator.Diagnose(context.Background(), os.Stdout))
+	}
 	ctx, op := operator.NewOperator(karpenteroperator.NewOperator())
 	azureCloudProvider := cloudprovider.New(
 		op.InstanceProvider,
 		op.GetClient(),
-	)
+		op.EventRecorder,
+	).WithNodeClient(op.GetNodeClient())
 
 	cloudProvider := metrics.Decorate(azureCloudProvider)
 
 	op.
-		WithControllers(ctx, karpentercontrollers.NewControllers(
+		WithControllers(ctx, middleware.WrapControllers(karpentercontrollers.NewControllers(
 			ctx,
 			op.Manager,
 			op.Clock,
 			op.GetClient(),
 			op.EventRecorder,
 			cloudProvider,
-		)...).
+		)...)...).
 		WithControllers(ctx, controllers.NewControllers(
 			op.GetClient(),
 			cloudProvider,
+			op.InstanceProvider,
+			op.EventRecorder,
+			azureCloudProvider,
 		)...).Start(ctx, cloudProvider)
 }
@@ -0,0 +1,93 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// import proposes nodeclaims for gpu agent pools created out of kaito, and imports the agent pools on confirmation
+// so they are managed by gpu-provisioner through the nodeclaims from then on.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/azure/gpu-provisioner/pkg/operator"
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/yaml"
+)
+
+func main() {
+	workspace := flag.String("workspace", "", "the kaito workspace the imported nodeclaims belong to")
+	yes := flag.Bool("yes", false, "import all proposed agent pools without confirmation")
+	flag.Parse()
+	if *workspace == "" {
+		fmt.Fprintln(os.Stderr, "--workspace is required")
+		os.Exit(2)
+	}
+	if err := run(context.Background(), *workspace, *yes); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, workspace string, yes bool) error {
+	azConfig, err := operator.GetAzConfig()
+	if err != nil {
+		return fmt.Errorf("getting azure config, %w", err)
+	}
+	azClient, err := instance.CreateAzClient(azConfig)
+	if err != nil {
+		return fmt.Errorf("creating azure client, %w", err)
+	}
+	kubeClient, err := client.New(config.GetConfigOrDie(), client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		return fmt.Errorf("creating kube client, %w", err)
+	}
+	provider := instance.NewProvider(azClient, kubeClient, azConfig.ResourceGroup, azConfig.ClusterName)
+
+	candidates, err := provider.ImportCandidates(ctx, workspace)
+	if err != nil {
+		return fmt.Errorf("scanning agent pools, %w", err)
+	}
+	if len(candidates) == 0 {
+		fmt.Println("no agent pool can be imported")
+		return nil
+	}
+	stdin := bufio.NewReader(os.Stdin)
+	for _, candidate := range candidates {
+		manifest, err := yaml.Marshal(candidate.NodeClaim)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("---\n%s", manifest)
+		if !yes {
+			fmt.Printf("import agent pool %s with the nodeclaim above? [y/N] ", candidate.AgentPoolName)
+			answer, _ := stdin.ReadString('\n')
+			if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+				continue
+			}
+		}
+		if err := provider.Import(ctx, candidate); err != nil {
+			return fmt.Errorf("importing agent pool %s, %w", candidate.AgentPoolName, err)
+		}
+		fmt.Printf("imported agent pool %s\n", candidate.AgentPoolName)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// skugen generates the sku bundle consumed by air-gapped deployments through SKU_BUNDLE_PATH. it queries the
+// resource SKUs and retail prices of a region, so it must run where the APIs are reachable.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/azure/gpu-provisioner/pkg/operator"
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+)
+
+func main() {
+	location := flag.String("location", "", "the region of the bundle, defaults to the location of the cluster")
+	output := flag.String("output", "", "the file the bundle is written to, defaults to stdout")
+	flag.Parse()
+	if err := run(context.Background(), *location, *output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, location, output string) error {
+	azConfig, err := operator.GetAzConfig()
+	if err != nil {
+		return fmt.Errorf("getting azure config, %w", err)
+	}
+	azClient, err := instance.CreateAzClient(azConfig)
+	if err != nil {
+		return fmt.Errorf("creating azure client, %w", err)
+	}
+	bundle, err := instance.GenerateSKUBundle(ctx, azClient, location)
+	if err != nil {
+		return fmt.Errorf("generating sku bundle, %w", err)
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = fmt.Println(string(data))
+		return err
+	}
+	if err := os.WriteFile(output, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d skus and %d prices of %s to %s\n", len(bundle.SKUs), len(bundle.Prices), bundle.Location, output)
+	return nil
+}
@@ -0,0 +1,143 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// benchgate reads `go test -bench -benchmem` output from stdin and fails if a benchmark regressed beyond the
+// threshold compared to the stored baseline. allocations are compared by default as they are stable across
+// machines, time is only compared if --time-threshold is set.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Result is the per-op cost of a benchmark.
+type Result struct {
+	NsPerOp     float64 `json:"nsPerOp"`
+	BytesPerOp  float64 `json:"bytesPerOp"`
+	AllocsPerOp float64 `json:"allocsPerOp"`
+}
+
+// procsSuffix is the GOMAXPROCS suffix go test appends to benchmark names, e.g. BenchmarkFoo-8.
+var procsSuffix = regexp.MustCompile(`-\d+$`)
+
+func main() {
+	baselinePath := flag.String("baseline", "hack/benchgate/baseline.json", "the file the baseline is stored in")
+	threshold := flag.Float64("threshold", 0.2, "the tolerated relative increase of B/op and allocs/op")
+	timeThreshold := flag.Float64("time-threshold", 0, "the tolerated relative increase of ns/op, 0 disables comparing time")
+	update := flag.Bool("update", false, "store the results as the baseline instead of comparing")
+	flag.Parse()
+
+	results, err := parse(io.TeeReader(os.Stdin, os.Stdout))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "no benchmark result found, run go test with -bench and -benchmem")
+		os.Exit(1)
+	}
+	if *update {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*baselinePath, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	data, err := os.ReadFile(*baselinePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	baseline := map[string]Result{}
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		fmt.Fprintf(os.Stderr, "parsing baseline, %s\n", err)
+		os.Exit(1)
+	}
+	regressions := compare(baseline, results, *threshold, *timeThreshold)
+	for _, regression := range regressions {
+		fmt.Fprintln(os.Stderr, regression)
+	}
+	if len(regressions) != 0 {
+		os.Exit(1)
+	}
+}
+
+// parse returns the results of the benchmark lines of go test output by benchmark name.
+func parse(r io.Reader) (map[string]Result, error) {
+	results := map[string]Result{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		result := Result{}
+		// fields after the iteration count are value and unit pairs.
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %q of %s, %w", fields[i], fields[0], err)
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				result.NsPerOp = value
+			case "B/op":
+				result.BytesPerOp = value
+			case "allocs/op":
+				result.AllocsPerOp = value
+			}
+		}
+		results[procsSuffix.ReplaceAllString(fields[0], "")] = result
+	}
+	return results, scanner.Err()
+}
+
+// compare returns a description of each regression of results beyond the thresholds, benchmarks missing in the
+// baseline are new and not compared.
+func compare(baseline, results map[string]Result, threshold, timeThreshold float64) []string {
+	var regressions []string
+	exceeds := func(name, metric string, base, current, threshold float64) {
+		if base > 0 && current > base*(1+threshold) {
+			regressions = append(regressions, fmt.Sprintf("%s regressed %s from %g to %g (+%.0f%%, threshold %.0f%%)",
+				name, metric, base, current, (current/base-1)*100, threshold*100))
+		}
+	}
+	for name, current := range results {
+		base, ok := baseline[name]
+		if !ok {
+			continue
+		}
+		exceeds(name, "B/op", base.BytesPerOp, current.BytesPerOp, threshold)
+		exceeds(name, "allocs/op", base.AllocsPerOp, current.AllocsPerOp, threshold)
+		if timeThreshold > 0 {
+			exceeds(name, "ns/op", base.NsPerOp, current.NsPerOp, timeThreshold)
+		}
+	}
+	sort.Strings(regressions)
+	return regressions
+}
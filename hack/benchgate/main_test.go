@@ -0,0 +1,68 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const benchOutput = `goos: linux
+goarch: amd64
+pkg: github.com/azure/gpu-provisioner/pkg/providers/instance
+BenchmarkNewAgentPoolObject-8   	  172881	      6767 ns/op	    3032 B/op	      99 allocs/op
+BenchmarkAgentPoolSpecHash      	  356895	      3256 ns/op	    1512 B/op	      70 allocs/op
+PASS
+`
+
+func TestParse(t *testing.T) {
+	results, err := parse(strings.NewReader(benchOutput))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Result{
+		"BenchmarkNewAgentPoolObject": {NsPerOp: 6767, BytesPerOp: 3032, AllocsPerOp: 99},
+		"BenchmarkAgentPoolSpecHash":  {NsPerOp: 3256, BytesPerOp: 1512, AllocsPerOp: 70},
+	}, results)
+}
+
+func TestCompare(t *testing.T) {
+	baseline := map[string]Result{"BenchmarkFoo": {NsPerOp: 1000, BytesPerOp: 100, AllocsPerOp: 10}}
+	testcases := map[string]struct {
+		result        Result
+		timeThreshold float64
+		expected      []string
+	}{
+		"within threshold": {
+			result: Result{NsPerOp: 5000, BytesPerOp: 110, AllocsPerOp: 12},
+		},
+		"allocations regressed": {
+			result:   Result{NsPerOp: 1000, BytesPerOp: 100, AllocsPerOp: 13},
+			expected: []string{"BenchmarkFoo regressed allocs/op from 10 to 13 (+30%, threshold 20%)"},
+		},
+		"time regressed": {
+			result:        Result{NsPerOp: 1500, BytesPerOp: 100, AllocsPerOp: 10},
+			timeThreshold: 0.2,
+			expected:      []string{"BenchmarkFoo regressed ns/op from 1000 to 1500 (+50%, threshold 20%)"},
+		},
+	}
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			results := map[string]Result{"BenchmarkFoo": tc.result, "BenchmarkNew": {AllocsPerOp: 100}}
+			assert.Equal(t, tc.expected, compare(baseline, results, 0.2, tc.timeThreshold))
+		})
+	}
+}
@@ -51,6 +51,15 @@ type Config struct {
 
 	UserAssignedIdentityID string `json:"userAssignedIdentityID" yaml:"userAssignedIdentityID"`
 
+	// ResourceManagerEndpoint and ResourceManagerAudience override the ARM endpoint and the audience of ARM tokens
+	// of the public cloud, e.g. for dogfood or preview ARM endpoints.
+	ResourceManagerEndpoint string `json:"resourceManagerEndpoint,omitempty" yaml:"resourceManagerEndpoint,omitempty"`
+	ResourceManagerAudience string `json:"resourceManagerAudience,omitempty" yaml:"resourceManagerAudience,omitempty"`
+
+	// AuxiliaryTenantIDs are the tenants whose tokens are attached to ARM requests for cross-tenant resources,
+	// e.g. shared image galleries or capacity reservation groups in another tenant.
+	AuxiliaryTenantIDs []string `json:"auxiliaryTenantIDs,omitempty" yaml:"auxiliaryTenantIDs,omitempty"`
+
 	//Configs only for AKS
 	ClusterName string `json:"clusterName" yaml:"clusterName"`
 	// enableDynamicSKUCache defines whether to enable dynamic instance workflow for instance information check
@@ -80,6 +89,13 @@ func (cfg *Config) BaseVars() {
 	cfg.ClusterName = os.Getenv("AZURE_CLUSTER_NAME")
 	cfg.SubscriptionID = os.Getenv("ARM_SUBSCRIPTION_ID")
 	cfg.DeploymentMode = os.Getenv("DEPLOYMENT_MODE")
+	cfg.ResourceManagerEndpoint = os.Getenv("ARM_RESOURCE_MANAGER_ENDPOINT")
+	cfg.ResourceManagerAudience = os.Getenv("ARM_RESOURCE_MANAGER_AUDIENCE")
+	for _, tenantID := range strings.Split(os.Getenv("AZURE_AUXILIARY_TENANT_IDS"), ",") {
+		if tenantID = strings.TrimSpace(tenantID); tenantID != "" {
+			cfg.AuxiliaryTenantIDs = append(cfg.AuxiliaryTenantIDs, tenantID)
+		}
+	}
 }
 
 // BuildAzureConfig returns a Config object for the Azure clients
@@ -102,6 +118,18 @@ func BuildAzureConfig() (*Config, error) {
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
+	// strict mode catches typos in the names of environment variables, which silently fall back to the defaults.
+	if strictConfig := os.Getenv("STRICT_CONFIG"); strictConfig != "" {
+		strict, err := strconv.ParseBool(strictConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse STRICT_CONFIG %q: %w", strictConfig, err)
+		}
+		if strict {
+			if err := ValidateEnvVars(os.Environ()); err != nil {
+				return nil, err
+			}
+		}
+	}
 	return cfg, nil
 }
 
@@ -122,6 +150,8 @@ func (cfg *Config) TrimSpace() {
 	cfg.SubscriptionID = strings.TrimSpace(cfg.SubscriptionID)
 	cfg.ResourceGroup = strings.TrimSpace(cfg.ResourceGroup)
 	cfg.ClusterName = strings.TrimSpace(cfg.ClusterName)
+	cfg.ResourceManagerEndpoint = strings.TrimSpace(cfg.ResourceManagerEndpoint)
+	cfg.ResourceManagerAudience = strings.TrimSpace(cfg.ResourceManagerAudience)
 }
 
 // nolint: gocyclo
@@ -17,6 +17,7 @@ package auth
 
 import (
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/Azure/go-autorest/autorest/azure"
@@ -43,11 +44,16 @@ func TestBaseVars(t *testing.T) {
 		"AZURE_CLUSTER_NAME":  "cluster-789",
 		"ARM_SUBSCRIPTION_ID": "sub-abc",
 		"DEPLOYMENT_MODE":     "mode1",
+
+		"ARM_RESOURCE_MANAGER_ENDPOINT": "https://api-dogfood.resources.windows-int.net/",
+		"ARM_RESOURCE_MANAGER_AUDIENCE": "https://management.core.windows.net/",
+		"AZURE_AUXILIARY_TENANT_IDS":    "tenant-aux1, ,tenant-aux2",
 	}
 	setEnvVars(envs)
 	defer unsetEnvVars([]string{
 		"LOCATION", "ARM_RESOURCE_GROUP", "AZURE_TENANT_ID", "AZURE_CLIENT_ID",
 		"AZURE_CLUSTER_NAME", "ARM_SUBSCRIPTION_ID", "DEPLOYMENT_MODE",
+		"ARM_RESOURCE_MANAGER_ENDPOINT", "ARM_RESOURCE_MANAGER_AUDIENCE", "AZURE_AUXILIARY_TENANT_IDS",
 	})
 
 	cfg := &Config{}
@@ -74,6 +80,15 @@ func TestBaseVars(t *testing.T) {
 	if cfg.DeploymentMode != "mode1" {
 		t.Errorf("expected DeploymentMode to be 'mode1', got %s", cfg.DeploymentMode)
 	}
+	if cfg.ResourceManagerEndpoint != "https://api-dogfood.resources.windows-int.net/" {
+		t.Errorf("expected ResourceManagerEndpoint to be 'https://api-dogfood.resources.windows-int.net/', got %s", cfg.ResourceManagerEndpoint)
+	}
+	if cfg.ResourceManagerAudience != "https://management.core.windows.net/" {
+		t.Errorf("expected ResourceManagerAudience to be 'https://management.core.windows.net/', got %s", cfg.ResourceManagerAudience)
+	}
+	if !reflect.DeepEqual(cfg.AuxiliaryTenantIDs, []string{"tenant-aux1", "tenant-aux2"}) {
+		t.Errorf("expected AuxiliaryTenantIDs to be [tenant-aux1 tenant-aux2], got %v", cfg.AuxiliaryTenantIDs)
+	}
 }
 
 func TestBuildAzureConfig_EnableDynamicSKUCache(t *testing.T) {
@@ -108,8 +108,12 @@ func NewCredential(cfg *Config, authorizer autorest.Authorizer) (azcore.TokenCre
 
 // GetToken implements the TokenCredential interface
 func (c *ClientAssertionCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
-	// get the token from the confidential client
-	token, err := c.client.AcquireTokenByCredential(ctx, opts.Scopes)
+	// get the token from the confidential client, tokens of auxiliary tenants are requested with the tenant id.
+	var acquireOpts []confidential.AcquireByCredentialOption
+	if opts.TenantID != "" {
+		acquireOpts = append(acquireOpts, confidential.WithTenantID(opts.TenantID))
+	}
+	token, err := c.client.AcquireTokenByCredential(ctx, opts.Scopes, acquireOpts...)
 	if err != nil {
 		return azcore.AccessToken{}, err
 	}
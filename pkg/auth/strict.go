@@ -0,0 +1,120 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// strictPrefixes are the prefixes of environment variables checked in strict mode, a typo in their names silently
+// falls back to the default value.
+var strictPrefixes = []string{"AZURE_", "ARM_"}
+
+// KnownEnvVars are the environment variables with the strict prefixes read by gpu-provisioner, including the ones
+// read by the azure sdk and injected by the workload identity webhook.
+var KnownEnvVars = sets.New(
+	// gpu-provisioner
+	"ARM_BURST",
+	"ARM_QPS",
+	"ARM_REQUEST_TIMEOUT",
+	"ARM_RESOURCE_GROUP",
+	"ARM_RESOURCE_MANAGER_AUDIENCE",
+	"ARM_RESOURCE_MANAGER_ENDPOINT",
+	"ARM_SUBSCRIPTION_ID",
+	"AZURE_AUXILIARY_TENANT_IDS",
+	"AZURE_CLUSTER_NAME",
+	"AZURE_ENABLE_DYNAMIC_SKU_CACHE",
+	// azure sdk credentials and workload identity webhook
+	"AZURE_ADDITIONALLY_ALLOWED_TENANTS",
+	"AZURE_AUTHORITY_HOST",
+	"AZURE_CLIENT_CERTIFICATE_PASSWORD",
+	"AZURE_CLIENT_CERTIFICATE_PATH",
+	"AZURE_CLIENT_ID",
+	"AZURE_CLIENT_SECRET",
+	"AZURE_CLIENT_SEND_CERTIFICATE_CHAIN",
+	"AZURE_FEDERATED_TOKEN_FILE",
+	"AZURE_PASSWORD",
+	"AZURE_REGIONAL_AUTHORITY_NAME",
+	"AZURE_TENANT_ID",
+	"AZURE_USERNAME",
+	// azure sdk logging
+	"AZURE_GO_SDK_LOG_FILE",
+	"AZURE_GO_SDK_LOG_LEVEL",
+	"AZURE_SDK_GO_LOGGING",
+)
+
+// ValidateEnvVars returns an error listing the environment variables with the strict prefixes which are not known,
+// with the closest known name as a suggestion. environ is in the form of os.Environ.
+func ValidateEnvVars(environ []string) error {
+	var unknown []string
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if KnownEnvVars.Has(name) || isServiceLink(name) || !slices.ContainsFunc(strictPrefixes, func(prefix string) bool { return strings.HasPrefix(name, prefix) }) {
+			continue
+		}
+		if suggestion := closestKnownEnvVar(name); suggestion != "" {
+			name = fmt.Sprintf("%s(did you mean %s?)", name, suggestion)
+		}
+		unknown = append(unknown, name)
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	slices.Sort(unknown)
+	return fmt.Errorf("unknown environment variables %s, unset them or disable STRICT_CONFIG", strings.Join(unknown, ", "))
+}
+
+// isServiceLink returns true for the environment variables kubelet injects for the services in the namespace of the
+// pod, e.g. AZURE_PROXY_SERVICE_HOST for service azure-proxy.
+func isServiceLink(name string) bool {
+	return strings.Contains(name, "_SERVICE_") || strings.Contains(name, "_PORT")
+}
+
+// closestKnownEnvVar returns the known environment variable within 2 edits of name, empty if there is none.
+func closestKnownEnvVar(name string) string {
+	closest, distance := "", 3
+	for _, known := range sets.List(KnownEnvVars) {
+		if d := editDistance(name, known); d < distance {
+			closest, distance = known, d
+		}
+	}
+	return closest
+}
+
+// editDistance returns the levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr := make([]int, len(b)+1)
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+	return prev[len(b)]
+}
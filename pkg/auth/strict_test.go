@@ -0,0 +1,84 @@
+/*
+	Copyright (c) Microsoft Corporation.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package auth
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidateEnvVars(t *testing.T) {
+	testCases := []struct {
+		name          string
+		environ       []string
+		expectedError string
+	}{
+		{
+			name:    "known and unrelated environment variables",
+			environ: []string{"AZURE_CLIENT_ID=id", "ARM_SUBSCRIPTION_ID=sub", "GC_INTERVAL=1m", "PATH=/usr/bin"},
+		},
+		{
+			name:    "service links",
+			environ: []string{"AZURE_PROXY_SERVICE_HOST=10.0.0.1", "AZURE_PROXY_PORT=tcp://10.0.0.1:80"},
+		},
+		{
+			name:          "typo with suggestion",
+			environ:       []string{"AZURE_CLUSTER_NAM=cluster"},
+			expectedError: "AZURE_CLUSTER_NAM(did you mean AZURE_CLUSTER_NAME?)",
+		},
+		{
+			name:          "unknown without suggestion",
+			environ:       []string{"AZURE_PROVIDER_TYP=arc", "ARM_QPS=10"},
+			expectedError: "unknown environment variables AZURE_PROVIDER_TYP,",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateEnvVars(tc.environ)
+			if tc.expectedError == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Errorf("expected error containing %q, got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestBuildAzureConfig_StrictConfig(t *testing.T) {
+	setEnvVars(map[string]string{
+		"ARM_SUBSCRIPTION_ID": "sub-abc",
+		"AZURE_TENANT_ID":     "tenant-123",
+		"ARM_RESOURCE_GROUPS": "rg",
+		"STRICT_CONFIG":       "true",
+	})
+	defer unsetEnvVars([]string{"ARM_SUBSCRIPTION_ID", "AZURE_TENANT_ID", "ARM_RESOURCE_GROUPS", "STRICT_CONFIG"})
+
+	_, err := BuildAzureConfig()
+	if err == nil || !strings.Contains(err.Error(), "did you mean ARM_RESOURCE_GROUP?") {
+		t.Errorf("expected error for unknown ARM_RESOURCE_GROUPS, got %v", err)
+	}
+
+	os.Setenv("STRICT_CONFIG", "false")
+	if _, err := BuildAzureConfig(); err != nil {
+		t.Errorf("expected no error without strict mode, got %v", err)
+	}
+}
@@ -17,10 +17,16 @@ package auth
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/azure/gpu-provisioner/pkg/utils/project"
 )
 
+// GetUserAgentExtension returns the user agent extension of Azure requests, it can be overridden by the
+// USER_AGENT_EXTENSION env.
 func GetUserAgentExtension() string {
+	if userAgent := os.Getenv("USER_AGENT_EXTENSION"); userAgent != "" {
+		return userAgent
+	}
 	return fmt.Sprintf("gpu-provisioner-aks/v%s", project.Version)
 }
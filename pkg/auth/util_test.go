@@ -0,0 +1,34 @@
+/*
+	Copyright (c) Microsoft Corporation.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetUserAgentExtension(t *testing.T) {
+	if userAgent := GetUserAgentExtension(); !strings.HasPrefix(userAgent, "gpu-provisioner-aks/v") {
+		t.Errorf("expected default user agent extension, got %s", userAgent)
+	}
+
+	setEnvVars(map[string]string{"USER_AGENT_EXTENSION": "sovereign-cloud"})
+	defer unsetEnvVars([]string{"USER_AGENT_EXTENSION"})
+
+	if userAgent := GetUserAgentExtension(); userAgent != "sovereign-cloud" {
+		t.Errorf("expected user agent extension to be 'sovereign-cloud', got %s", userAgent)
+	}
+}
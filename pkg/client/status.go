@@ -0,0 +1,139 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package client provides helpers for controllers like kaito to follow the nodeclaims gpu-provisioner provisions.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// Informers is the subset of a controller-runtime cache used by StatusClient, e.g. the cache of a manager.
+type Informers interface {
+	client.Reader
+	GetInformer(ctx context.Context, obj client.Object, opts ...ctrlcache.InformerGetOption) (ctrlcache.Informer, error)
+}
+
+// StatusClient waits for provisioning outcomes of nodeclaims by watching them through the nodeclaim informer, so
+// callers block on the informer events instead of polling the API server or ARM.
+type StatusClient struct {
+	informers Informers
+}
+
+func NewStatusClient(informers Informers) *StatusClient {
+	return &StatusClient{informers: informers}
+}
+
+// NodeClaimDeletedError is returned when the nodeclaim is deleted while waiting for its instance to be ready.
+type NodeClaimDeletedError struct {
+	Name string
+}
+
+func (e *NodeClaimDeletedError) Error() string {
+	return fmt.Sprintf("nodeclaim %s is deleted", e.Name)
+}
+
+func IsNodeClaimDeletedError(err error) bool {
+	var ncErr *NodeClaimDeletedError
+	return errors.As(err, &ncErr)
+}
+
+// WaitForInstanceReady blocks until the node of the nodeclaim is ready with gpus allocatable, the nodeclaim is
+// deleted or ctx is done. the ready nodeclaim is returned.
+func (c *StatusClient) WaitForInstanceReady(ctx context.Context, name string) (*karpenterv1.NodeClaim, error) {
+	var ready *karpenterv1.NodeClaim
+	err := c.waitFor(ctx, name, func(nodeClaim *karpenterv1.NodeClaim) (bool, error) {
+		if nodeClaim == nil || !nodeClaim.DeletionTimestamp.IsZero() {
+			return false, &NodeClaimDeletedError{Name: name}
+		}
+		if !nodeClaim.StatusConditions().Get(karpenterv1.ConditionTypeNodeReady).IsTrue() {
+			return false, nil
+		}
+		ready = nodeClaim
+		return true, nil
+	})
+	return ready, err
+}
+
+// WaitForDeletion blocks until the nodeclaim is gone, i.e. its instance is deleted and the finalizer is removed, or
+// ctx is done.
+func (c *StatusClient) WaitForDeletion(ctx context.Context, name string) error {
+	return c.waitFor(ctx, name, func(nodeClaim *karpenterv1.NodeClaim) (bool, error) {
+		return nodeClaim == nil, nil
+	})
+}
+
+// waitFor evaluates done with the nodeclaim on every informer event of it until done returns true or an error. the
+// nodeclaim is nil once it's deleted.
+func (c *StatusClient) waitFor(ctx context.Context, name string, done func(*karpenterv1.NodeClaim) (bool, error)) error {
+	informer, err := c.informers.GetInformer(ctx, &karpenterv1.NodeClaim{})
+	if err != nil {
+		return fmt.Errorf("getting nodeclaim informer, %w", err)
+	}
+	// events only signal that the nodeclaim may have changed, the latest state is read from the cache, so a slow
+	// consumer never acts on a stale nodeclaim.
+	changed := make(chan struct{}, 1)
+	notify := func(obj interface{}) {
+		if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if accessor, err := meta.Accessor(obj); err == nil && accessor.GetName() != name {
+			return
+		}
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	registration, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    notify,
+		UpdateFunc: func(_, obj interface{}) { notify(obj) },
+		DeleteFunc: notify,
+	})
+	if err != nil {
+		return fmt.Errorf("watching nodeclaims, %w", err)
+	}
+	defer func() { _ = informer.RemoveEventHandler(registration) }()
+	if !toolscache.WaitForCacheSync(ctx.Done(), registration.HasSynced) {
+		return ctx.Err()
+	}
+
+	for {
+		nodeClaim := &karpenterv1.NodeClaim{}
+		if err := c.informers.Get(ctx, types.NamespacedName{Name: name}, nodeClaim); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return fmt.Errorf("getting nodeclaim %s, %w", name, err)
+			}
+			nodeClaim = nil
+		}
+		if ok, err := done(nodeClaim); ok || err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+type fakeRegistration struct{}
+
+func (fakeRegistration) HasSynced() bool { return true }
+
+// fakeInformer delivers events to the registered handlers on demand.
+type fakeInformer struct {
+	mu         sync.Mutex
+	handlers   []toolscache.ResourceEventHandler
+	registered chan struct{}
+}
+
+func (f *fakeInformer) AddEventHandler(handler toolscache.ResourceEventHandler) (toolscache.ResourceEventHandlerRegistration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers = append(f.handlers, handler)
+	f.registered <- struct{}{}
+	return fakeRegistration{}, nil
+}
+
+func (f *fakeInformer) AddEventHandlerWithResyncPeriod(handler toolscache.ResourceEventHandler, _ time.Duration) (toolscache.ResourceEventHandlerRegistration, error) {
+	return f.AddEventHandler(handler)
+}
+
+func (f *fakeInformer) RemoveEventHandler(_ toolscache.ResourceEventHandlerRegistration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers = nil
+	return nil
+}
+
+func (f *fakeInformer) AddIndexers(_ toolscache.Indexers) error { return nil }
+func (f *fakeInformer) HasSynced() bool                         { return true }
+func (f *fakeInformer) IsStopped() bool                         { return false }
+
+func (f *fakeInformer) update(obj interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, handler := range f.handlers {
+		handler.OnUpdate(obj, obj)
+	}
+}
+
+type fakeInformers struct {
+	client.Client
+	informer *fakeInformer
+}
+
+func (f *fakeInformers) GetInformer(_ context.Context, _ client.Object, _ ...ctrlcache.InformerGetOption) (ctrlcache.Informer, error) {
+	return f.informer, nil
+}
+
+func newNodeClaim(name string) *karpenterv1.NodeClaim {
+	return &karpenterv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func TestWaitForInstanceReady(t *testing.T) {
+	testCases := []struct {
+		name          string
+		change        func(ctx context.Context, kubeClient client.Client, nodeClaim *karpenterv1.NodeClaim)
+		expectedReady bool
+		expectedError func(err error) bool
+	}{
+		{
+			name: "Successfully wait for node ready",
+			change: func(ctx context.Context, kubeClient client.Client, nodeClaim *karpenterv1.NodeClaim) {
+				nodeClaim.StatusConditions().SetTrue(karpenterv1.ConditionTypeNodeReady)
+				assert.NoError(t, kubeClient.Status().Update(ctx, nodeClaim))
+			},
+			expectedReady: true,
+		},
+		{
+			name: "Fail to wait for node ready because nodeclaim is deleted",
+			change: func(ctx context.Context, kubeClient client.Client, nodeClaim *karpenterv1.NodeClaim) {
+				assert.NoError(t, kubeClient.Delete(ctx, nodeClaim))
+			},
+			expectedError: IsNodeClaimDeletedError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			nodeClaim := newNodeClaim("nodeclaim-test")
+			kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithStatusSubresource(&karpenterv1.NodeClaim{}).WithObjects(nodeClaim).Build()
+			informer := &fakeInformer{registered: make(chan struct{}, 1)}
+			statusClient := NewStatusClient(&fakeInformers{Client: kubeClient, informer: informer})
+
+			type result struct {
+				nodeClaim *karpenterv1.NodeClaim
+				err       error
+			}
+			results := make(chan result, 1)
+			go func() {
+				ready, err := statusClient.WaitForInstanceReady(ctx, nodeClaim.Name)
+				results <- result{nodeClaim: ready, err: err}
+			}()
+
+			<-informer.registered
+			tc.change(ctx, kubeClient, nodeClaim)
+			informer.update(nodeClaim)
+
+			r := <-results
+			if tc.expectedError != nil {
+				assert.True(t, tc.expectedError(r.err))
+				return
+			}
+			assert.NoError(t, r.err)
+			assert.Equal(t, tc.expectedReady, r.nodeClaim.StatusConditions().Get(karpenterv1.ConditionTypeNodeReady).IsTrue())
+		})
+	}
+}
+
+func TestWaitForDeletion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	nodeClaim := newNodeClaim("nodeclaim-test")
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(nodeClaim).Build()
+	informer := &fakeInformer{registered: make(chan struct{}, 1)}
+	statusClient := NewStatusClient(&fakeInformers{Client: kubeClient, informer: informer})
+
+	errs := make(chan error, 1)
+	go func() { errs <- statusClient.WaitForDeletion(ctx, nodeClaim.Name) }()
+
+	<-informer.registered
+	// events of other nodeclaims are ignored.
+	informer.update(newNodeClaim("nodeclaim-other"))
+	assert.NoError(t, kubeClient.Delete(ctx, nodeClaim))
+	informer.update(toolscache.DeletedFinalStateUnknown{Key: nodeClaim.Name, Obj: nodeClaim})
+	assert.NoError(t, <-errs)
+
+	// deleted nodeclaims return immediately.
+	go func() { errs <- statusClient.WaitForDeletion(ctx, nodeClaim.Name) }()
+	<-informer.registered
+	assert.NoError(t, <-errs)
+}
+
+func TestWaitForDeletionTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	nodeClaim := newNodeClaim("nodeclaim-test")
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(nodeClaim).Build()
+	informer := &fakeInformer{registered: make(chan struct{}, 1)}
+	statusClient := NewStatusClient(&fakeInformers{Client: kubeClient, informer: informer})
+
+	assert.ErrorIs(t, statusClient.WaitForDeletion(ctx, nodeClaim.Name), context.DeadlineExceeded)
+}
@@ -18,50 +18,127 @@ package cloudprovider
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/awslabs/operatorpkg/status"
 	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/azure/gpu-provisioner/pkg/utils"
 	"github.com/samber/lo"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/events"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
 )
 
 var _ cloudprovider.CloudProvider = &CloudProvider{}
 
+// SpecDrifted means the spec of agent pool has been changed since it was created from nodeclaim.
+const SpecDrifted cloudprovider.DriftReason = "SpecDrifted"
+
+// ZoneDrifted means the node is not in the availability zone of its agent pool.
+const ZoneDrifted cloudprovider.DriftReason = "ZoneDrifted"
+
+// CreateOperationIDAnnotationKey records the id of the ARM operation which created the agent pool on the nodeclaim,
+// it's used to look up the operation when investigating provisioning issues with Azure support.
+const CreateOperationIDAnnotationKey = "kaito.sh/create-operation-id"
+
 type CloudProvider struct {
-	instanceProvider *instance.Provider
+	instanceProvider instance.InstanceProvider
 	kubeClient       client.Client
+	// nodeClient looks up and changes nodes and their pods, it's the kube client of the provisioned cluster which
+	// differs from kubeClient when gpu-provisioner runs in a management cluster.
+	nodeClient client.Client
+	recorder   events.Recorder
+	// workloadSafetyCheck enables a warning event before deleting an agent pool which runs pods not owned by the workspace.
+	workloadSafetyCheck bool
+	// quotaPreemption enables deleting idle agent pools of lower priority nodeclaims when the quota is exhausted.
+	quotaPreemption bool
+	// logSpecDiff logs why the agent pool of a nodeclaim is considered drifted.
+	logSpecDiff bool
+	health      *OperationHealth
 }
 
-func New(instanceProvider *instance.Provider, kubeClient client.Client) *CloudProvider {
+func New(instanceProvider instance.InstanceProvider, kubeClient client.Client, recorder events.Recorder) *CloudProvider {
 	return &CloudProvider{
-		instanceProvider: instanceProvider,
-		kubeClient:       kubeClient,
+		instanceProvider:    instanceProvider,
+		kubeClient:          kubeClient,
+		nodeClient:          kubeClient,
+		recorder:            recorder,
+		workloadSafetyCheck: utils.WithDefaultBool("WORKLOAD_SAFETY_CHECK", false),
+		// preemption deletes running agent pools, so it's opt-in.
+		quotaPreemption: utils.WithDefaultBool("ENABLE_QUOTA_PREEMPTION", false),
+		logSpecDiff:     utils.WithDefaultBool("LOG_SPEC_DIFF", false),
+		health:          Health,
 	}
 }
 
+// WithNodeClient makes the cloud provider look up nodes of agent pools and their pods with the node client, e.g. a
+// client of the provisioned cluster when gpu-provisioner runs in a management cluster.
+func (c *CloudProvider) WithNodeClient(nodeClient client.Client) *CloudProvider {
+	c.nodeClient = nodeClient
+	return c
+}
+
 // Create a node given the constraints.
-func (c *CloudProvider) Create(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (*karpenterv1.NodeClaim, error) {
+func (c *CloudProvider) Create(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (_ *karpenterv1.NodeClaim, err error) {
 	klog.InfoS("Create", "nodeClaim", klog.KObj(nodeClaim))
+	defer func() { c.health.Observe(operationCreate, nodeClaim.Name, err) }()
+
+	for _, requirement := range unmetFlexibility(nodeClaim) {
+		klog.InfoS("nodeclaim flexibility requirement can't be met", "nodeClaim", klog.KObj(nodeClaim), "requirement", requirement.Key)
+		c.recorder.Publish(FlexibilityNotMetEvent(nodeClaim, requirement))
+	}
 
-	instance, err := c.instanceProvider.Create(ctx, nodeClaim)
+	result, err := c.instanceProvider.Create(ctx, nodeClaim)
+	if hint := instance.RemediationOf(err); hint != "" {
+		c.recorder.Publish(RemediationRequiredEvent(nodeClaim, hint))
+	}
+	if instance.IsCapacityTypeNotSupportedError(err) || instance.IsPriceCapError(err) || instance.IsSKURequirementsNotMetError(err) || instance.IsSKUUnavailableError(err) {
+		// the nodeclaim can never be launched, so it's reported as insufficient capacity and not retried.
+		return nil, cloudprovider.NewInsufficientCapacityError(fmt.Errorf("creating instance, %w", err))
+	}
+	if err != nil && c.quotaPreemption && instance.IsQuotaExceededError(err) {
+		victim, preemptErr := c.preemptForQuota(ctx, nodeClaim)
+		if preemptErr != nil {
+			klog.ErrorS(preemptErr, "failed to preempt nodeclaims for quota", "nodeClaim", klog.KObj(nodeClaim))
+		} else if victim != nil {
+			// the creation is retried and succeeds once the agent pool of the preempted nodeclaim is deleted.
+			return nil, fmt.Errorf("creating instance, preempted nodeclaim %s to free quota, %w", victim.Name, err)
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("creating instance, %w", err)
 	}
-	nc := c.instanceToNodeClaim(ctx, instance)
-	nc.Labels = lo.Assign(nc.Labels, instance.Labels)
+	c.recordCreateResult(nodeClaim, result)
+	nc := c.instanceToNodeClaim(ctx, result.Instance)
+	nc.Labels = lo.Assign(nc.Labels, result.Labels)
+	if result.OperationID != "" {
+		nc.Annotations[CreateOperationIDAnnotationKey] = result.OperationID
+	}
 	return nc, nil
 }
 
+// recordCreateResult publishes the outcome of creating the instance of the nodeclaim as an event and metrics.
+func (c *CloudProvider) recordCreateResult(nodeClaim *karpenterv1.NodeClaim, result *instance.CreateResult) {
+	klog.InfoS("instance created", "nodeClaim", klog.KObj(nodeClaim), "agentpool", result.AgentPoolName, "vmSize", result.VMSize,
+		"capacityType", result.CapacityType, "zone", result.Zone, "operationID", result.OperationID, "resumed", result.Resumed,
+		"createDuration", result.CreateDuration, "registrationDuration", result.RegistrationDuration)
+	InstanceCreateDuration.WithLabelValues(lo.Ternary(result.Resumed, phaseResume, phaseCreate), result.VMSize, result.CapacityType).Observe(result.CreateDuration.Seconds())
+	InstanceCreateDuration.WithLabelValues(phaseRegistration, result.VMSize, result.CapacityType).Observe(result.RegistrationDuration.Seconds())
+	c.recorder.Publish(InstanceCreatedEvent(nodeClaim, result))
+}
+
 func (c *CloudProvider) List(ctx context.Context) ([]*karpenterv1.NodeClaim, error) {
 	nodeClaims := []*karpenterv1.NodeClaim{}
 	instances, err := c.instanceProvider.List(ctx)
+	c.health.Observe(operationList, "", err)
 	if err != nil {
 		return nil, err
 	}
@@ -85,18 +162,134 @@ func (c *CloudProvider) Get(ctx context.Context, providerID string) (*karpenterv
 	return c.instanceToNodeClaim(ctx, instance), err
 }
 
-func (c *CloudProvider) Delete(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) error {
+func (c *CloudProvider) Delete(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (err error) {
 	klog.InfoS("Delete", "nodeClaim", klog.KObj(nodeClaim))
-	return c.instanceProvider.Delete(ctx, nodeClaim.Name)
+	defer func() {
+		c.health.Observe(operationDelete, nodeClaim.Name, cloudprovider.IgnoreNodeClaimNotFoundError(err))
+	}()
+	frozen, err := c.provisioningFrozen(ctx)
+	if err != nil {
+		return fmt.Errorf("checking provisioning freeze, %w", err)
+	}
+	if frozen {
+		// the nodes are only tainted, the deletion is retried by the caller and goes through once the freeze is lifted.
+		if err := c.taintPendingRemoval(ctx, nodeClaim); err != nil {
+			return fmt.Errorf("tainting nodes pending removal, %w", err)
+		}
+		c.recorder.Publish(DeletionFrozenEvent(nodeClaim))
+		return fmt.Errorf("deleting agentpool %s is frozen by annotation %s of namespace %s", nodeClaim.Name, ProvisioningFreezeAnnotationKey, FreezeNamespace)
+	}
+	// the agent pool is deleted only after its nodes are cordoned and drained, the deletion is retried by the caller.
+	nodes, err := c.undrainedNodes(ctx, nodeClaim)
+	if err != nil {
+		return fmt.Errorf("draining nodes, %w", err)
+	}
+	if len(nodes) != 0 {
+		// leaked agent pools deleted by garbage collection have no nodeclaim, nobody drains their nodes.
+		if exists, err := c.nodeClaimExists(ctx, nodeClaim); err != nil {
+			return fmt.Errorf("getting nodeclaim, %w", err)
+		} else if !exists {
+			klog.InfoS("deleting agentpool without nodeclaim, nodes are not drained", "nodeClaim", klog.KObj(nodeClaim), "nodes", nodes)
+			nodes = nil
+		}
+	}
+	if len(nodes) != 0 {
+		if remaining, ok := GracePeriodOverrideRemaining(nodeClaim); !ok || remaining > 0 {
+			return fmt.Errorf("waiting for nodes %v of agentpool %s to be drained", nodes, nodeClaim.Name)
+		}
+		klog.InfoS("drain grace period overridden", "audit", true, "nodeClaim", klog.KObj(nodeClaim), "nodes", nodes,
+			"override", nodeClaim.Annotations[TerminationGracePeriodOverrideAnnotationKey])
+		c.recorder.Publish(DrainOverriddenEvent(nodeClaim, nodes))
+	}
+	// nodeclaims which failed to launch have no provider id, their agent pools are deleted by name.
+	err = c.instanceProvider.Delete(ctx, lo.Ternary(nodeClaim.Status.ProviderID != "", nodeClaim.Status.ProviderID, instance.AgentPoolName(nodeClaim)))
+	if err != nil && !cloudprovider.IsNodeClaimNotFoundError(err) {
+		return err
+	}
+	// the Node objects are deleted only after ARM has confirmed the agent pool is gone.
+	if nodeErr := c.deleteNodes(ctx, nodeClaim); nodeErr != nil {
+		return fmt.Errorf("deleting nodes, %w", nodeErr)
+	}
+	return err
 }
 
 func (c *CloudProvider) IsDrifted(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (cloudprovider.DriftReason, error) {
 	klog.V(5).InfoS("IsDrifted", "nodeclaim", klog.KObj(nodeClaim))
+	if len(nodeClaim.Status.ProviderID) == 0 {
+		return cloudprovider.DriftReason(""), nil
+	}
+
+	ins, err := c.instanceProvider.Get(ctx, nodeClaim.Status.ProviderID)
+	if err != nil {
+		return cloudprovider.DriftReason(""), fmt.Errorf("getting instance, %w", err)
+	}
+	if ins == nil {
+		return cloudprovider.DriftReason(""), nil
+	}
+	// agent pools created by older releases have no spec hash, they are never considered as spec drifted.
+	if specHash, ok := nodeClaim.Annotations[instance.SpecHashAnnotationKey]; ok && ins.SpecHash != specHash {
+		if c.logSpecDiff {
+			diff, err := c.instanceProvider.SpecDiff(ctx, nodeClaim)
+			klog.InfoS("agent pool spec diff", "decision", SpecDrifted, "nodeclaim", klog.KObj(nodeClaim), "diff", diff, "error", err)
+		}
+		return SpecDrifted, nil
+	}
+	if ins.Zone != nil && len(nodeClaim.Status.NodeName) != 0 {
+		node := &corev1.Node{}
+		if err := c.nodeClient.Get(ctx, client.ObjectKey{Name: nodeClaim.Status.NodeName}, node); err != nil {
+			return cloudprovider.DriftReason(""), client.IgnoreNotFound(err)
+		}
+		if nodeZone, ok := node.Labels[corev1.LabelTopologyZone]; ok && !instance.ZoneMatches(nodeZone, *ins.Zone) {
+			if c.logSpecDiff {
+				klog.InfoS("agent pool spec diff", "decision", ZoneDrifted, "nodeclaim", klog.KObj(nodeClaim), "diff", []string{fmt.Sprintf("zone: %q -> %q", nodeZone, *ins.Zone)})
+			}
+			return ZoneDrifted, nil
+		}
+	}
 	return cloudprovider.DriftReason(""), nil
 }
 
+// GetInstanceTypes returns the instance types in the region of the cluster, the instance types are labeled with the
+// sku labels(e.g. local storage sizes) which nodeclaims can select instance types by.
 func (c *CloudProvider) GetInstanceTypes(ctx context.Context, nodePool *karpenterv1.NodePool) ([]*cloudprovider.InstanceType, error) {
-	return []*cloudprovider.InstanceType{}, nil
+	skus, err := c.instanceProvider.SKUs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting skus, %w", err)
+	}
+	instanceTypes := make([]*cloudprovider.InstanceType, 0, len(skus))
+	for _, sku := range skus {
+		instanceTypes = append(instanceTypes, skuToInstanceType(sku))
+	}
+	slices.SortFunc(instanceTypes, func(a, b *cloudprovider.InstanceType) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return instanceTypes, nil
+}
+
+func skuToInstanceType(sku instance.SKU) *cloudprovider.InstanceType {
+	requirements := scheduling.NewRequirements(
+		scheduling.NewRequirement(corev1.LabelInstanceTypeStable, corev1.NodeSelectorOpIn, sku.Name),
+	)
+	for key, value := range sku.Labels() {
+		requirements.Add(scheduling.NewRequirement(key, corev1.NodeSelectorOpIn, value))
+	}
+	capacity := corev1.ResourceList{}
+	if sku.RDMAEnabled {
+		// the RDMA device plugin shares the InfiniBand device with pods on the node.
+		capacity[instance.ResourceRDMAIB] = resource.MustParse("1")
+	}
+	return &cloudprovider.InstanceType{
+		Name:         sku.Name,
+		Requirements: requirements,
+		Offerings: cloudprovider.Offerings{
+			{
+				Requirements: scheduling.NewRequirements(scheduling.NewRequirement(karpenterv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpenterv1.CapacityTypeOnDemand)),
+				Available:    true,
+			},
+		},
+		Capacity: capacity,
+		Overhead: &cloudprovider.InstanceTypeOverhead{},
+	}
 }
 
 // Name returns the CloudProvider implementation name.
@@ -114,10 +307,13 @@ func (c *CloudProvider) instanceToNodeClaim(ctx context.Context, instanceObj *in
 		return nodeClaim
 	}
 
-	labels := instanceObj.Labels
-	annotations := map[string]string{}
+	// labels of instance are copied, so the conversion never changes the instance.
+	labels := lo.Assign(instanceObj.Labels)
+	annotations := placementAnnotations(instanceObj)
 
-	nodeClaim.Name = lo.FromPtr(instanceObj.Name)
+	// agent pools not named after their nodeclaims record the nodeclaim name, so they are matched with nodeclaims
+	// by name(e.g. for garbage collection) whatever the naming is.
+	nodeClaim.Name = instance.NodeClaimName(instanceObj)
 
 	if instanceObj.CapacityType != nil {
 		labels[karpenterv1.CapacityTypeLabelKey] = *instanceObj.CapacityType
@@ -131,6 +327,15 @@ func (c *CloudProvider) instanceToNodeClaim(ctx context.Context, instanceObj *in
 		labels[karpenterv1.NodePoolLabelKey] = *instanceObj.Tags[karpenterv1.NodePoolLabelKey]
 	}
 
+	if instanceObj.Tags[instance.SpecHashTagKey] != nil {
+		annotations[instance.SpecHashAnnotationKey] = *instanceObj.Tags[instance.SpecHashTagKey]
+	}
+
+	// agent pools protected by tag are never disrupted by gpu-provisioner, e.g. garbage collected.
+	if strings.EqualFold(lo.FromPtr(instanceObj.Tags[instance.GCTagKey]), instance.GCTagDisabled) {
+		annotations[karpenterv1.DoNotDisruptAnnotationKey] = "true"
+	}
+
 	nodeClaim.Labels = labels
 	nodeClaim.Annotations = annotations
 	if timestamp, ok := labels[instance.NodeClaimCreationLabel]; ok {
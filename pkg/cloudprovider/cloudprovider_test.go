@@ -23,18 +23,26 @@ import (
 	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
 	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
 	"github.com/azure/gpu-provisioner/pkg/providers/instance"
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/mock/gomock"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/events"
 )
 
 func TestCreate(t *testing.T) {
@@ -42,23 +50,13 @@ func TestCreate(t *testing.T) {
 		nodeClaim         *karpenterv1.NodeClaim
 		mockAgentPoolResp func(nodeClaim *karpenterv1.NodeClaim, mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error)
 		expectedError     bool
+		expectedEvent     string
 	}{
 		"successfully create instance": {
-			nodeClaim: fake.GetNodeClaimObj("agentpool0", map[string]string{"test": "test"}, []v1.Taint{},
-				karpenterv1.ResourceRequirements{
-					Requests: v1.ResourceList{
-						v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(30*1024*1024*1024, resource.DecimalSI)),
-					},
-				},
-				[]v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
+			nodeClaim: fixture.NodeClaim().WithName("agentpool0").WithLabels(map[string]string{"test": "test"}).
+				WithStorage(30).WithInstanceType("Standard_NC6s_v3").Build(),
 			mockAgentPoolResp: func(nodeClaim *karpenterv1.NodeClaim, mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error) {
-				ap := fake.CreateAgentPoolObjWithNodeClaim(nodeClaim)
+				ap := fixture.AgentPoolFor(nodeClaim).Build()
 
 				createResp := armcontainerservice.AgentPoolsClientCreateOrUpdateResponse{
 					AgentPool: ap,
@@ -79,15 +77,14 @@ func TestCreate(t *testing.T) {
 			expectedError: false,
 		},
 		"failed to create instance": {
-			nodeClaim: fake.GetNodeClaimObj("invalid-nodeclaim-name", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-				{
-					Key:      "node.kubernetes.io/instance-type",
-					Operator: "In",
-					Values:   []string{"Standard_NC6s_v3"},
-				},
-			}),
+			nodeClaim:     fixture.NodeClaim().WithName("invalid-nodeclaim-name").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
 			expectedError: true,
 		},
+		"unsupported capacity type publishes remediation": {
+			nodeClaim:     fixture.NodeClaim().WithInstanceType("Standard_NC6s_v3").WithSpot().Build(),
+			expectedError: true,
+			expectedEvent: "ENABLE_SPOT_AGENTPOOLS",
+		},
 	}
 
 	for k, tc := range testcases {
@@ -119,18 +116,25 @@ func TestCreate(t *testing.T) {
 			instanceProvider := instance.NewProvider(mockAzClient, mockK8sClient, "testRG", "testCluster")
 
 			// create cloud provider and call create function
-			cloudProvider := New(instanceProvider, nil)
+			fakeRecorder := record.NewFakeRecorder(10)
+			cloudProvider := New(instanceProvider, nil, events.NewRecorder(fakeRecorder))
 			nc, err := cloudProvider.Create(context.Background(), tc.nodeClaim)
 
 			if tc.expectedError {
 				assert.Error(t, err, "expect error but got nil")
+				if tc.expectedEvent != "" {
+					assert.Contains(t, <-fakeRecorder.Events, tc.expectedEvent)
+				}
 			} else if !tc.expectedError {
 				assert.NoError(t, err, "Not expected to return error")
+				assert.Contains(t, <-fakeRecorder.Events, "InstanceCreated")
 			}
 
 			if nc != nil {
 				assert.Equal(t, nc.Name, tc.nodeClaim.Name, "nodeclaim name is not the same")
 				assert.NotEmpty(t, nc.Status.ProviderID, "provider id is not empty")
+				assert.Equal(t, tc.nodeClaim.Name, nc.Annotations[instance.AgentPoolNameAnnotationKey])
+				assert.Equal(t, "Standard_NC6s_v3", nc.Annotations[SKUAnnotationKey])
 			}
 		})
 	}
@@ -144,25 +148,13 @@ func TestList(t *testing.T) {
 	}{
 		"successfully list instances": {
 			nodeClaims: []*karpenterv1.NodeClaim{
-				fake.GetNodeClaimObj("agentpool1", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
-				fake.GetNodeClaimObj("agentpool2", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
+				fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
+				fixture.NodeClaim().WithName("agentpool2").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
 			},
 			mockAgentPoolResp: func(nodeClaims []*karpenterv1.NodeClaim) *runtime.Pager[armcontainerservice.AgentPoolsClientListResponse] {
 				var agentPools []*armcontainerservice.AgentPool
 				for i := range nodeClaims {
-					ap := fake.CreateAgentPoolObjWithNodeClaim(nodeClaims[i])
+					ap := fixture.AgentPoolFor(nodeClaims[i]).Build()
 					agentPools = append(agentPools, &ap)
 				}
 				return runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
@@ -180,6 +172,26 @@ func TestList(t *testing.T) {
 			},
 			expectedError: false,
 		},
+		"agent pools not named after nodeclaims are listed by nodeclaim name": {
+			nodeClaims: []*karpenterv1.NodeClaim{
+				fixture.NodeClaim().WithName("workspace-falcon-7b").WithInstanceType("Standard_NC6s_v3").Build(),
+			},
+			mockAgentPoolResp: func(nodeClaims []*karpenterv1.NodeClaim) *runtime.Pager[armcontainerservice.AgentPoolsClientListResponse] {
+				ap := fixture.AgentPoolFor(nodeClaims[0]).Build()
+				ap.Name = to.Ptr("gpu0")
+				ap.Properties.Tags = map[string]*string{instance.NodeClaimTagKey: to.Ptr(nodeClaims[0].Name)}
+				return runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
+					More: func(page armcontainerservice.AgentPoolsClientListResponse) bool {
+						return false
+					},
+					Fetcher: func(ctx context.Context, page *armcontainerservice.AgentPoolsClientListResponse) (armcontainerservice.AgentPoolsClientListResponse, error) {
+						return armcontainerservice.AgentPoolsClientListResponse{
+							AgentPoolListResult: armcontainerservice.AgentPoolListResult{Value: []*armcontainerservice.AgentPool{&ap}},
+						}, nil
+					},
+				})
+			},
+		},
 		"failed to list instances": {
 			mockAgentPoolResp: func(nodeClaims []*karpenterv1.NodeClaim) *runtime.Pager[armcontainerservice.AgentPoolsClientListResponse] {
 				return runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
@@ -216,7 +228,7 @@ func TestList(t *testing.T) {
 			instanceProvider := instance.NewProvider(mockAzClient, mockK8sClient, "testRG", "testCluster")
 
 			// create cloud provider and call list function
-			cloudProvider := New(instanceProvider, nil)
+			cloudProvider := New(instanceProvider, nil, nil)
 			nodeClaims, err := cloudProvider.List(context.Background())
 
 			if tc.expectedError {
@@ -242,41 +254,23 @@ func TestGet(t *testing.T) {
 		IsNodeClaimNotFoundError bool
 	}{
 		"successfully get instance": {
-			nodeClaim: fake.GetNodeClaimObj("agentpool1", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-				{
-					Key:      "node.kubernetes.io/instance-type",
-					Operator: "In",
-					Values:   []string{"Standard_NC6s_v3"},
-				},
-			}),
+			nodeClaim: fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
 			mockAgentPoolResp: func(nodeClaim *karpenterv1.NodeClaim) (armcontainerservice.AgentPoolsClientGetResponse, error) {
-				return armcontainerservice.AgentPoolsClientGetResponse{AgentPool: fake.CreateAgentPoolObjWithNodeClaim(nodeClaim)}, nil
+				return armcontainerservice.AgentPoolsClientGetResponse{AgentPool: fixture.AgentPoolFor(nodeClaim).Build()}, nil
 			},
 			expectedError: nil,
 		},
 		"failed to get instance": {
-			nodeClaim: fake.GetNodeClaimObj("agentpool1", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-				{
-					Key:      "node.kubernetes.io/instance-type",
-					Operator: "In",
-					Values:   []string{"Standard_NC6s_v3"},
-				},
-			}),
+			nodeClaim: fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
 			mockAgentPoolResp: func(nodeClaim *karpenterv1.NodeClaim) (armcontainerservice.AgentPoolsClientGetResponse, error) {
-				return armcontainerservice.AgentPoolsClientGetResponse{AgentPool: fake.CreateAgentPoolObjWithNodeClaim(nodeClaim)}, fmt.Errorf("internal server error")
+				return armcontainerservice.AgentPoolsClientGetResponse{AgentPool: fixture.AgentPoolFor(nodeClaim).Build()}, fmt.Errorf("internal server error")
 			},
 			expectedError: errors.New("internal server error"),
 		},
 		"instance doesn't exist": {
-			nodeClaim: fake.GetNodeClaimObj("agentpool1", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-				{
-					Key:      "node.kubernetes.io/instance-type",
-					Operator: "In",
-					Values:   []string{"Standard_NC6s_v3"},
-				},
-			}),
+			nodeClaim: fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
 			mockAgentPoolResp: func(nodeClaim *karpenterv1.NodeClaim) (armcontainerservice.AgentPoolsClientGetResponse, error) {
-				return armcontainerservice.AgentPoolsClientGetResponse{AgentPool: fake.CreateAgentPoolObjWithNodeClaim(nodeClaim)}, fmt.Errorf("Agent Pool not found")
+				return armcontainerservice.AgentPoolsClientGetResponse{AgentPool: fixture.AgentPoolFor(nodeClaim).Build()}, fmt.Errorf("Agent Pool not found")
 			},
 			IsNodeClaimNotFoundError: true,
 		},
@@ -299,7 +293,7 @@ func TestGet(t *testing.T) {
 			instanceProvider := instance.NewProvider(mockAzClient, nil, "testRG", "testCluster")
 
 			// create cloud provider and call list function
-			cloudProvider := New(instanceProvider, nil)
+			cloudProvider := New(instanceProvider, nil, nil)
 			nodeClaim, err := cloudProvider.Get(context.Background(), tc.nodeClaim.Status.ProviderID)
 
 			if tc.IsNodeClaimNotFoundError {
@@ -316,6 +310,94 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestIsDrifted(t *testing.T) {
+	testcases := map[string]struct {
+		specHash            func(currentHash string) (string, bool)
+		zones               []*string
+		nodeZone            string
+		expectedDriftReason cloudprovider.DriftReason
+	}{
+		"agent pool spec is not changed": {
+			specHash: func(currentHash string) (string, bool) {
+				return currentHash, true
+			},
+			expectedDriftReason: "",
+		},
+		"agent pool spec is changed": {
+			specHash: func(currentHash string) (string, bool) {
+				return "stale", true
+			},
+			expectedDriftReason: SpecDrifted,
+		},
+		"nodeclaim created by older release has no spec hash": {
+			specHash: func(currentHash string) (string, bool) {
+				return "", false
+			},
+			expectedDriftReason: "",
+		},
+		"node is in the zone of agent pool": {
+			specHash: func(currentHash string) (string, bool) {
+				return currentHash, true
+			},
+			zones:               []*string{lo.ToPtr("1")},
+			nodeZone:            "eastus-1",
+			expectedDriftReason: "",
+		},
+		"node is not in the zone of agent pool": {
+			specHash: func(currentHash string) (string, bool) {
+				return currentHash, true
+			},
+			zones:               []*string{lo.ToPtr("1")},
+			nodeZone:            "eastus-2",
+			expectedDriftReason: ZoneDrifted,
+		},
+		"agent pool spans multiple zones": {
+			specHash: func(currentHash string) (string, bool) {
+				return currentHash, true
+			},
+			zones:               []*string{lo.ToPtr("1"), lo.ToPtr("2")},
+			nodeZone:            "eastus-2",
+			expectedDriftReason: "",
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			nodeClaim := fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build()
+			nodeClaim.Status.NodeName = "aks-agentpool1-20562481-vmss000000"
+			agentPool := fixture.AgentPoolFor(nodeClaim).Build()
+			agentPool.Properties.AvailabilityZones = tc.zones
+			// agent pool is served from cache after the first Get
+			agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+			agentPoolMocks.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), nodeClaim.Name, gomock.Any()).
+				Return(armcontainerservice.AgentPoolsClientGetResponse{AgentPool: agentPool}, nil)
+
+			node := &v1.Node{}
+			node.Name = nodeClaim.Status.NodeName
+			node.Labels = map[string]string{v1.LabelTopologyZone: tc.nodeZone}
+			kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(node).Build()
+
+			mockAzClient := instance.NewAZClientFromAPI(agentPoolMocks)
+			instanceProvider := instance.NewProvider(mockAzClient, nil, "testRG", "testCluster")
+			ins, err := instanceProvider.Get(context.Background(), nodeClaim.Status.ProviderID)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, ins.SpecHash)
+
+			if specHash, ok := tc.specHash(ins.SpecHash); ok {
+				nodeClaim.Annotations = map[string]string{instance.SpecHashAnnotationKey: specHash}
+			}
+
+			cloudProvider := New(instanceProvider, kubeClient, nil)
+			reason, err := cloudProvider.IsDrifted(context.Background(), nodeClaim)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedDriftReason, reason)
+		})
+	}
+}
+
 func TestDelete(t *testing.T) {
 	testcases := map[string]struct {
 		nodeClaim         *karpenterv1.NodeClaim
@@ -323,13 +405,7 @@ func TestDelete(t *testing.T) {
 		expectedError     error
 	}{
 		"successfully delete instance": {
-			nodeClaim: fake.GetNodeClaimObj("agentpool1", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-				{
-					Key:      "node.kubernetes.io/instance-type",
-					Operator: "In",
-					Values:   []string{"Standard_NC6s_v3"},
-				},
-			}),
+			nodeClaim: fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
 			mockAgentPoolResp: func(mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientDeleteResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientDeleteResponse], error) {
 				delResp := armcontainerservice.AgentPoolsClientDeleteResponse{}
 				resp := http.Response{Status: "200 OK", StatusCode: http.StatusOK, Body: http.NoBody}
@@ -348,13 +424,7 @@ func TestDelete(t *testing.T) {
 			expectedError: nil,
 		},
 		"failed to delete instance": {
-			nodeClaim: fake.GetNodeClaimObj("agentpool1", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-				{
-					Key:      "node.kubernetes.io/instance-type",
-					Operator: "In",
-					Values:   []string{"Standard_NC6s_v3"},
-				},
-			}),
+			nodeClaim: fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
 			mockAgentPoolResp: func(mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientDeleteResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientDeleteResponse], error) {
 				return nil, errors.New("internal server error")
 			},
@@ -377,10 +447,12 @@ func TestDelete(t *testing.T) {
 
 			// prepare instance provider
 			mockAzClient := instance.NewAZClientFromAPI(agentPoolMocks)
-			instanceProvider := instance.NewProvider(mockAzClient, nil, "testRG", "testCluster")
+			mockK8sClient := fake.NewClient()
+			mockK8sClient.On("Get", mock.IsType(context.Background()), mock.Anything, mock.IsType(&karpenterv1.NodeClaim{}), mock.Anything).Return(apierrors.NewNotFound(schema.GroupResource{Group: "karpenter.sh", Resource: "nodeclaims"}, tc.nodeClaim.Name))
+			instanceProvider := instance.NewProvider(mockAzClient, mockK8sClient, "testRG", "testCluster")
 
-			// create cloud provider and call list function
-			cloudProvider := New(instanceProvider, nil)
+			// create cloud provider and call delete function
+			cloudProvider := New(instanceProvider, fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).Build(), nil)
 			err := cloudProvider.Delete(context.Background(), tc.nodeClaim)
 
 			if tc.expectedError != nil {
@@ -391,3 +463,31 @@ func TestDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestSKUToInstanceType(t *testing.T) {
+	testcases := map[string]struct {
+		sku              instance.SKU
+		expectedCapacity v1.ResourceList
+	}{
+		"instance type without rdma": {
+			sku:              instance.SKU{Name: "Standard_NC24ads_A100_v4", TempDiskGiB: 64, NVMeGiB: 894},
+			expectedCapacity: v1.ResourceList{},
+		},
+		"instance type with rdma": {
+			sku:              instance.SKU{Name: "Standard_ND96asr_v4", TempDiskGiB: 2900, RDMAEnabled: true},
+			expectedCapacity: v1.ResourceList{instance.ResourceRDMAIB: resource.MustParse("1")},
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			instanceType := skuToInstanceType(tc.sku)
+			assert.Equal(t, tc.sku.Name, instanceType.Name)
+			assert.Equal(t, tc.expectedCapacity, instanceType.Capacity)
+			for key, value := range tc.sku.Labels() {
+				assert.True(t, instanceType.Requirements.Get(key).Has(value), "expect requirement %s=%s", key, value)
+			}
+			assert.True(t, instanceType.Requirements.Get(v1.LabelInstanceTypeStable).Has(tc.sku.Name))
+		})
+	}
+}
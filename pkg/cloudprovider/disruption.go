@@ -0,0 +1,107 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"time"
+
+	"k8s.io/klog/v2"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/events"
+)
+
+// DisruptionReason is why the instance of a nodeclaim is deleted. deletions are labeled with it in events, metrics
+// and audit logs, so they can be sliced by cause.
+type DisruptionReason string
+
+const (
+	// DisruptionReasonGCOrphan is used when the garbage collection deletes an agent pool without nodeclaim.
+	DisruptionReasonGCOrphan DisruptionReason = "gc-orphan"
+	// DisruptionReasonExpired is used when the nodeclaim is deleted after its expireAfter.
+	DisruptionReasonExpired DisruptionReason = "expired"
+	// DisruptionReasonDrifted is used when the nodeclaim is deleted while it's drifted.
+	DisruptionReasonDrifted DisruptionReason = "drifted"
+	// DisruptionReasonRepaired is used when the nodeclaim is deleted to replace an unhealthy node.
+	DisruptionReasonRepaired DisruptionReason = "repaired"
+	// DisruptionReasonConsolidated is used when the nodeclaim is deleted while it's consolidatable.
+	DisruptionReasonConsolidated DisruptionReason = "consolidated"
+	// DisruptionReasonPreempted is used when the nodeclaim is deleted to free quota for a higher priority nodeclaim.
+	DisruptionReasonPreempted DisruptionReason = "preempted"
+	// DisruptionReasonUserRequested is used for any other deletion, e.g. kaito deleting the workspace.
+	DisruptionReasonUserRequested DisruptionReason = "user-requested"
+)
+
+// DisruptionReasons are all the disruption reasons.
+var DisruptionReasons = []DisruptionReason{
+	DisruptionReasonGCOrphan,
+	DisruptionReasonExpired,
+	DisruptionReasonDrifted,
+	DisruptionReasonRepaired,
+	DisruptionReasonConsolidated,
+	DisruptionReasonPreempted,
+	DisruptionReasonUserRequested,
+}
+
+// DisruptionReasonAnnotationKey lets the deleter of a nodeclaim declare the disruption reason, e.g. repair logic,
+// it takes precedence over the reason inferred from the nodeclaim status.
+const DisruptionReasonAnnotationKey = "kaito.sh/disruption-reason"
+
+// DisruptionReasonOf returns why the nodeclaim is deleted.
+func DisruptionReasonOf(nodeClaim *karpenterv1.NodeClaim) DisruptionReason {
+	if reason := DisruptionReason(nodeClaim.Annotations[DisruptionReasonAnnotationKey]); isDisruptionReason(reason) {
+		return reason
+	}
+	conditions := nodeClaim.StatusConditions()
+	switch {
+	case expired(nodeClaim):
+		return DisruptionReasonExpired
+	case conditions.Get(karpenterv1.ConditionTypeDrifted).IsTrue():
+		return DisruptionReasonDrifted
+	case conditions.Get(karpenterv1.ConditionTypeConsolidatable).IsTrue():
+		return DisruptionReasonConsolidated
+	}
+	return DisruptionReasonUserRequested
+}
+
+func isDisruptionReason(reason DisruptionReason) bool {
+	for _, r := range DisruptionReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// expired returns true if the nodeclaim was deleted after its expireAfter elapsed.
+func expired(nodeClaim *karpenterv1.NodeClaim) bool {
+	if nodeClaim.Spec.ExpireAfter.Duration == nil || nodeClaim.CreationTimestamp.IsZero() {
+		return false
+	}
+	deletedAt := time.Now()
+	if nodeClaim.DeletionTimestamp != nil {
+		deletedAt = nodeClaim.DeletionTimestamp.Time
+	}
+	return !deletedAt.Before(nodeClaim.CreationTimestamp.Add(*nodeClaim.Spec.ExpireAfter.Duration))
+}
+
+// RecordDisruption records the deletion of the instance of the nodeclaim with the reason as an audit log, a metric and
+// an event. it should be called once per deleted instance.
+func RecordDisruption(recorder events.Recorder, nodeClaim *karpenterv1.NodeClaim, reason DisruptionReason) {
+	klog.InfoS("instance deleted", "audit", true, "nodeClaim", klog.KObj(nodeClaim), "providerID", nodeClaim.Status.ProviderID,
+		"reason", reason, "age", time.Since(nodeClaim.CreationTimestamp.Time).Round(time.Second))
+	InstanceDeletionsTotal.WithLabelValues(string(reason)).Inc()
+	recorder.Publish(InstanceDeletedEvent(nodeClaim, reason))
+}
@@ -0,0 +1,136 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	podutil "sigs.k8s.io/karpenter/pkg/utils/pod"
+)
+
+// TerminationGracePeriodOverrideAnnotationKey overrides the time the nodes of a deleted nodeclaim are given to drain,
+// e.g. "0s" deletes the agent pool right away in an emergency like runaway costs. the override is a duration since
+// the deletion of the nodeclaim.
+const TerminationGracePeriodOverrideAnnotationKey = "kaito.sh/termination-grace-period-override"
+
+// GracePeriodOverrideRemaining returns the time left until the overridden drain grace period of the deleted nodeclaim
+// elapses, false if the nodeclaim is not deleted or has no valid override.
+func GracePeriodOverrideRemaining(nodeClaim *karpenterv1.NodeClaim) (time.Duration, bool) {
+	value, ok := nodeClaim.Annotations[TerminationGracePeriodOverrideAnnotationKey]
+	if !ok || nodeClaim.DeletionTimestamp.IsZero() {
+		return 0, false
+	}
+	gracePeriod, err := time.ParseDuration(value)
+	if err != nil || gracePeriod < 0 {
+		klog.ErrorS(err, "invalid termination grace period override", "nodeClaim", klog.KObj(nodeClaim), "value", value)
+		return 0, false
+	}
+	return max(time.Until(nodeClaim.DeletionTimestamp.Add(gracePeriod)), 0), true
+}
+
+// undrainedNodes returns the nodes of the agent pool which are not cordoned and drained yet, the agent pool must not
+// be deleted before they are. the nodes are cordoned and drained by karpenter node termination, which calls Delete
+// again once the drain is finished, so only the drain status is checked here. nodes without the karpenter
+// termination finalizer are not drained by anyone, they don't block the agent pool deletion. nothing is changed by
+// the check, so the deletion resumes where it stopped after a restart.
+func (c *CloudProvider) undrainedNodes(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) ([]string, error) {
+	nodeList, err := c.agentPoolNodes(ctx, nodeClaim)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []string
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		if !controllerutil.ContainsFinalizer(node, karpenterv1.TerminationFinalizer) {
+			continue
+		}
+		drained, err := c.isDrained(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+		if !drained {
+			nodes = append(nodes, node.Name)
+		}
+	}
+	return nodes, nil
+}
+
+// nodeClaimExists returns false if the nodeclaim is not found in the cluster, e.g. the nodeclaim converted from a
+// leaked agent pool by garbage collection.
+func (c *CloudProvider) nodeClaimExists(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (bool, error) {
+	if err := c.kubeClient.Get(ctx, client.ObjectKey{Name: nodeClaim.Name}, &karpenterv1.NodeClaim{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// deleteNodes deletes the Node objects of the agent pool, it's called only after ARM has confirmed the agent pool is
+// gone. nodes with the karpenter termination finalizer are released by karpenter node termination once Get returns
+// NodeClaimNotFound, the others would be left behind otherwise.
+func (c *CloudProvider) deleteNodes(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) error {
+	nodeList, err := c.agentPoolNodes(ctx, nodeClaim)
+	if err != nil {
+		return err
+	}
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		if !node.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := c.nodeClient.Delete(ctx, node); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("deleting node %s, %w", node.Name, err)
+		}
+	}
+	return nil
+}
+
+// agentPoolNodes returns the nodes of the agent pool of the nodeclaim.
+func (c *CloudProvider) agentPoolNodes(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (*corev1.NodeList, error) {
+	apName := instance.AgentPoolName(nodeClaim)
+	nodeList := &corev1.NodeList{}
+	if err := c.nodeClient.List(ctx, nodeList, client.MatchingLabels{"agentpool": apName, "kubernetes.azure.com/agentpool": apName}); err != nil {
+		return nil, err
+	}
+	return nodeList, nil
+}
+
+// isDrained returns true if the node is cordoned and no pod is waiting to be evicted, which is the same condition
+// karpenter node termination waits for before terminating the instance.
+func (c *CloudProvider) isDrained(ctx context.Context, node *corev1.Node) (bool, error) {
+	if !node.Spec.Unschedulable && !lo.ContainsBy(node.Spec.Taints, func(t corev1.Taint) bool { return t.MatchTaint(&karpenterv1.DisruptedNoScheduleTaint) }) {
+		return false, nil
+	}
+	podList := &corev1.PodList{}
+	if err := c.nodeClient.List(ctx, podList, client.MatchingFields{"spec.nodeName": node.Name}); err != nil {
+		return false, fmt.Errorf("listing pods of node %s, %w", node.Name, err)
+	}
+	return !lo.ContainsBy(podList.Items, func(pod corev1.Pod) bool { return podutil.IsWaitingEviction(&pod, clock.RealClock{}) }), nil
+}
@@ -0,0 +1,274 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/events"
+)
+
+func TestUndrainedNodes(t *testing.T) {
+	testcases := map[string]struct {
+		terminating       bool
+		unmanaged         bool
+		taints            []v1.Taint
+		pod               *v1.Pod
+		expectedUndrained bool
+	}{
+		"node is not terminating": {
+			expectedUndrained: true,
+		},
+		"node without termination finalizer": {
+			unmanaged:         true,
+			expectedUndrained: false,
+		},
+		"terminating node is not cordoned": {
+			terminating:       true,
+			expectedUndrained: true,
+		},
+		"cordoned node runs a workload pod": {
+			terminating:       true,
+			taints:            []v1.Taint{karpenterv1.DisruptedNoScheduleTaint},
+			pod:               testPod(nil, []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "rs"}}, v1.PodRunning),
+			expectedUndrained: true,
+		},
+		"cordoned node runs a completed pod": {
+			terminating:       true,
+			taints:            []v1.Taint{karpenterv1.DisruptedNoScheduleTaint},
+			pod:               testPod(nil, nil, v1.PodSucceeded),
+			expectedUndrained: false,
+		},
+		"cordoned node runs a static pod": {
+			terminating:       true,
+			taints:            []v1.Taint{karpenterv1.DisruptedNoScheduleTaint},
+			pod:               testPod(nil, []metav1.OwnerReference{{APIVersion: "v1", Kind: "Node", Name: "node"}}, v1.PodRunning),
+			expectedUndrained: false,
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			nodeClaim := fixture.NodeClaim().Build()
+			node := &fake.CreateNodeListWithNodeClaim([]*karpenterv1.NodeClaim{nodeClaim}).Items[0]
+			node.Finalizers = []string{"test"}
+			if !tc.unmanaged {
+				node.Finalizers = append(node.Finalizers, karpenterv1.TerminationFinalizer)
+			}
+			node.Spec.Taints = tc.taints
+			if tc.terminating {
+				node.DeletionTimestamp = &metav1.Time{Time: metav1.Now().Time}
+			}
+			objects := []k8sruntime.Object{node}
+			if tc.pod != nil {
+				tc.pod.Spec.NodeName = node.Name
+				objects = append(objects, tc.pod)
+			}
+
+			fakeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithRuntimeObjects(objects...).
+				WithIndex(&v1.Pod{}, "spec.nodeName", func(o client.Object) []string {
+					return []string{o.(*v1.Pod).Spec.NodeName}
+				}).
+				Build()
+
+			cloudProvider := New(nil, fakeClient, nil)
+			nodes, err := cloudProvider.undrainedNodes(context.Background(), nodeClaim)
+			assert.NoError(t, err, "expect no error but got one")
+			assert.Equal(t, tc.expectedUndrained, len(nodes) != 0, "unexpected undrained nodes %v", nodes)
+
+			// the drain check never deletes nodes, they're deleted only after the agent pool is gone.
+			stored := &v1.Node{}
+			assert.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(node), stored))
+			assert.Equal(t, tc.terminating, !stored.DeletionTimestamp.IsZero(), "unexpected node deletion timestamp")
+		})
+	}
+}
+
+func TestGracePeriodOverrideRemaining(t *testing.T) {
+	testcases := map[string]struct {
+		annotations       map[string]string
+		deletedAgo        *time.Duration
+		expectedOverride  bool
+		expectedRemaining bool
+	}{
+		"nodeclaim without override": {
+			deletedAgo: lo.ToPtr(time.Minute),
+		},
+		"nodeclaim is not deleted": {
+			annotations: map[string]string{TerminationGracePeriodOverrideAnnotationKey: "0s"},
+		},
+		"invalid override": {
+			annotations: map[string]string{TerminationGracePeriodOverrideAnnotationKey: "soon"},
+			deletedAgo:  lo.ToPtr(time.Minute),
+		},
+		"override elapsed": {
+			annotations:      map[string]string{TerminationGracePeriodOverrideAnnotationKey: "30s"},
+			deletedAgo:       lo.ToPtr(time.Minute),
+			expectedOverride: true,
+		},
+		"override not elapsed": {
+			annotations:       map[string]string{TerminationGracePeriodOverrideAnnotationKey: "10m"},
+			deletedAgo:        lo.ToPtr(time.Minute),
+			expectedOverride:  true,
+			expectedRemaining: true,
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			nodeClaim := fixture.NodeClaim().WithAnnotations(tc.annotations).Build()
+			if tc.deletedAgo != nil {
+				nodeClaim.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(-*tc.deletedAgo)}
+			}
+			remaining, ok := GracePeriodOverrideRemaining(nodeClaim)
+			assert.Equal(t, tc.expectedOverride, ok, "unexpected override")
+			assert.Equal(t, tc.expectedRemaining, remaining > 0, "unexpected remaining grace period %s", remaining)
+		})
+	}
+}
+
+// deletingInstanceProvider is an instance provider which records the deleted instances.
+type deletingInstanceProvider struct {
+	instance.InstanceProvider
+	deleted []string
+	err     error
+}
+
+func (p *deletingInstanceProvider) Delete(_ context.Context, id string) error {
+	p.deleted = append(p.deleted, id)
+	return p.err
+}
+
+func TestDeleteWaitsForDrain(t *testing.T) {
+	testcases := map[string]struct {
+		annotations     map[string]string
+		leaked          bool
+		expectedDeleted bool
+		expectedEvent   string
+	}{
+		"wait for the nodes to be drained": {
+			expectedDeleted: false,
+		},
+		"delete without draining after the overridden grace period": {
+			annotations:     map[string]string{TerminationGracePeriodOverrideAnnotationKey: "0s"},
+			expectedDeleted: true,
+			expectedEvent:   "DrainOverridden",
+		},
+		"delete leaked instance without nodeclaim without draining": {
+			leaked:          true,
+			expectedDeleted: true,
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			nodeClaim := fixture.NodeClaim().WithAnnotations(tc.annotations).Deleting().Build()
+			nodeClaim.Namespace = ""
+			nodeClaim.Finalizers = []string{karpenterv1.TerminationFinalizer}
+			node := &fake.CreateNodeListWithNodeClaim([]*karpenterv1.NodeClaim{nodeClaim}).Items[0]
+			node.Finalizers = []string{karpenterv1.TerminationFinalizer}
+
+			objects := []k8sruntime.Object{node}
+			if !tc.leaked {
+				objects = append(objects, nodeClaim)
+			}
+			fakeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objects...).Build()
+			instanceProvider := &deletingInstanceProvider{}
+			fakeRecorder := record.NewFakeRecorder(10)
+			cloudProvider := New(instanceProvider, fakeClient, events.NewRecorder(fakeRecorder))
+
+			err := cloudProvider.Delete(context.Background(), nodeClaim)
+			assert.Equal(t, tc.expectedDeleted, err == nil, "unexpected error %v", err)
+			assert.Equal(t, tc.expectedDeleted, len(instanceProvider.deleted) == 1, "unexpected deleted instances")
+			if tc.expectedEvent != "" {
+				assert.Contains(t, <-fakeRecorder.Events, tc.expectedEvent)
+			}
+		})
+	}
+}
+
+func TestDeleteRemovesNodesAfterAgentPool(t *testing.T) {
+	testcases := map[string]struct {
+		deleteErr           error
+		expectedError       bool
+		expectedNodeDeleted bool
+	}{
+		"nodes are deleted once the agent pool is deleted": {
+			expectedNodeDeleted: true,
+		},
+		"nodes are deleted if the agent pool is not found": {
+			deleteErr:           cloudprovider.NewNodeClaimNotFoundError(errors.New("not found")),
+			expectedError:       true,
+			expectedNodeDeleted: true,
+		},
+		"nodes are kept if deleting the agent pool fails": {
+			deleteErr:     errors.New("internal server error"),
+			expectedError: true,
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			nodeClaim := fixture.NodeClaim().Deleting().Build()
+			// the node is drained, but it isn't removed by karpenter since it has no termination finalizer.
+			node := &fake.CreateNodeListWithNodeClaim([]*karpenterv1.NodeClaim{nodeClaim}).Items[0]
+
+			fakeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(node).Build()
+			instanceProvider := &deletingInstanceProvider{err: tc.deleteErr}
+			cloudProvider := New(instanceProvider, fakeClient, events.NewRecorder(record.NewFakeRecorder(10)))
+
+			err := cloudProvider.Delete(context.Background(), nodeClaim)
+			assert.Equal(t, tc.expectedError, err != nil, "unexpected error %v", err)
+			assert.Len(t, instanceProvider.deleted, 1)
+			err = fakeClient.Get(context.Background(), client.ObjectKeyFromObject(node), &v1.Node{})
+			assert.Equal(t, tc.expectedNodeDeleted, apierrors.IsNotFound(err), "unexpected node %v", err)
+		})
+	}
+}
+
+func TestUndrainedNodesWithNodeClient(t *testing.T) {
+	nodeClaim := fixture.NodeClaim().Build()
+	node := &fake.CreateNodeListWithNodeClaim([]*karpenterv1.NodeClaim{nodeClaim}).Items[0]
+	node.Finalizers = []string{karpenterv1.TerminationFinalizer}
+
+	// the node only exists in the provisioned cluster.
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	nodeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(node).Build()
+	cloudProvider := New(nil, kubeClient, nil).WithNodeClient(nodeClient)
+
+	nodes, err := cloudProvider.undrainedNodes(context.Background(), nodeClaim)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{node.Name}, nodes)
+}
@@ -0,0 +1,112 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/events"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+)
+
+func WorkloadDisruptionEvent(nodeClaim *v1.NodeClaim, pods []*corev1.Pod) events.Event {
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           corev1.EventTypeWarning,
+		Reason:         "WorkloadDisruption",
+		Message:        fmt.Sprintf("Deleting agentpool %s disrupts %d pod(s) not owned by the workspace, e.g. %s", nodeClaim.Name, len(pods), klog.KObj(pods[0])),
+		DedupeValues:   []string{nodeClaim.Name},
+	}
+}
+
+func FlexibilityNotMetEvent(nodeClaim *v1.NodeClaim, requirement *scheduling.Requirement) events.Event {
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           corev1.EventTypeWarning,
+		Reason:         "FlexibilityNotMet",
+		Message:        fmt.Sprintf("Requirement %s allows %d value(s), less than minValues %d, launching without the requested flexibility", requirement.Key, requirement.Len(), lo.FromPtr(requirement.MinValues)),
+		DedupeValues:   []string{nodeClaim.Name, requirement.Key},
+	}
+}
+
+func RemediationRequiredEvent(nodeClaim *v1.NodeClaim, hint string) events.Event {
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           corev1.EventTypeWarning,
+		Reason:         "RemediationRequired",
+		Message:        fmt.Sprintf("Launching agentpool %s requires an action, %s", nodeClaim.Name, hint),
+		DedupeValues:   []string{nodeClaim.Name, hint},
+	}
+}
+
+func DeletionFrozenEvent(nodeClaim *v1.NodeClaim) events.Event {
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           corev1.EventTypeWarning,
+		Reason:         "DeletionFrozen",
+		Message:        fmt.Sprintf("Deleting agentpool %s is frozen, nodes are tainted with %s until the freeze is lifted", nodeClaim.Name, PendingRemovalTaintKey),
+		DedupeValues:   []string{nodeClaim.Name},
+	}
+}
+
+func DrainOverriddenEvent(nodeClaim *v1.NodeClaim, nodes []string) events.Event {
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           corev1.EventTypeWarning,
+		Reason:         "DrainOverridden",
+		Message:        fmt.Sprintf("Deleting agentpool %s without draining node(s) %v, grace period is overridden by annotation %s", nodeClaim.Name, nodes, TerminationGracePeriodOverrideAnnotationKey),
+		DedupeValues:   []string{nodeClaim.Name},
+	}
+}
+
+func InstanceCreatedEvent(nodeClaim *v1.NodeClaim, result *instance.CreateResult) events.Event {
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           corev1.EventTypeNormal,
+		Reason:         "InstanceCreated",
+		Message: fmt.Sprintf("%s agentpool %s with %s %s capacity%s in %s, node registered in %s",
+			lo.Ternary(result.Resumed, "Resumed", "Created"), result.AgentPoolName, result.VMSize, result.CapacityType, lo.Ternary(result.Zone == "", "", " in zone "+result.Zone),
+			result.CreateDuration.Round(time.Second), result.RegistrationDuration.Round(time.Second)),
+		DedupeValues: []string{nodeClaim.Name},
+	}
+}
+
+func InstanceDeletedEvent(nodeClaim *v1.NodeClaim, reason DisruptionReason) events.Event {
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           corev1.EventTypeNormal,
+		Reason:         "InstanceDeleted",
+		Message:        fmt.Sprintf("Deleted agentpool %s, disruption reason %s", nodeClaim.Name, reason),
+		DedupeValues:   []string{nodeClaim.Name},
+	}
+}
+
+func PreemptedEvent(victim *v1.NodeClaim, nodeClaim *v1.NodeClaim) events.Event {
+	return events.Event{
+		InvolvedObject: victim,
+		Type:           corev1.EventTypeWarning,
+		Reason:         "Preempted",
+		Message:        fmt.Sprintf("Preempted idle agentpool %s to free quota for higher priority nodeclaim %s", victim.Name, nodeClaim.Name),
+		DedupeValues:   []string{victim.Name},
+	}
+}
@@ -0,0 +1,33 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+)
+
+// unmetFlexibility returns the requirements of nodeclaim whose minValues exceed the number of values they allow.
+// kaito creates nodeclaims directly instead of through the karpenter scheduler, so minValues are not validated
+// before launch.
+func unmetFlexibility(nodeClaim *karpenterv1.NodeClaim) []*scheduling.Requirement {
+	requirements := scheduling.NewNodeSelectorRequirementsWithMinValues(nodeClaim.Spec.Requirements...)
+	return lo.Filter(requirements.Values(), func(r *scheduling.Requirement, _ int) bool {
+		return r.MinValues != nil && r.Operator() == corev1.NodeSelectorOpIn && r.Len() < *r.MinValues
+	})
+}
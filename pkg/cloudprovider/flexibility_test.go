@@ -0,0 +1,55 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"testing"
+
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+)
+
+func TestUnmetFlexibility(t *testing.T) {
+	testcases := map[string]struct {
+		instanceTypes []string
+		minValues     int
+		expectedKeys  []string
+	}{
+		"minValues are met": {
+			instanceTypes: []string{"Standard_NC6s_v3", "Standard_NC12s_v3"},
+			minValues:     2,
+			expectedKeys:  []string{},
+		},
+		"minValues are not met": {
+			instanceTypes: []string{"Standard_NC6s_v3"},
+			minValues:     2,
+			expectedKeys:  []string{v1.LabelInstanceTypeStable},
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			nodeClaim := fixture.NodeClaim().WithName("agentpool1").WithInstanceType(tc.instanceTypes...).Build()
+			nodeClaim.Spec.Requirements[0].MinValues = lo.ToPtr(tc.minValues)
+
+			keys := lo.Map(unmetFlexibility(nodeClaim), func(r *scheduling.Requirement, _ int) string { return r.Key })
+			assert.ElementsMatch(t, tc.expectedKeys, keys)
+		})
+	}
+}
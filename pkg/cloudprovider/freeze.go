@@ -0,0 +1,70 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+const (
+	// ProvisioningFreezeAnnotationKey on the FreezeNamespace namespace freezes the deletion of agent pools cluster-wide,
+	// nodes pending removal are tainted with PendingRemovalTaintKey instead until the annotation is removed.
+	ProvisioningFreezeAnnotationKey = "kaito.sh/provisioning-freeze"
+	FreezeNamespace                 = "kube-system"
+
+	PendingRemovalTaintKey = "kaito.sh/pending-removal"
+)
+
+var PendingRemovalNoScheduleTaint = corev1.Taint{
+	Key:    PendingRemovalTaintKey,
+	Effect: corev1.TaintEffectNoSchedule,
+}
+
+// provisioningFrozen returns true if the ProvisioningFreezeAnnotationKey annotation of FreezeNamespace is "true".
+func (c *CloudProvider) provisioningFrozen(ctx context.Context) (bool, error) {
+	ns := &corev1.Namespace{}
+	if err := c.kubeClient.Get(ctx, client.ObjectKey{Name: FreezeNamespace}, ns); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return ns.Annotations[ProvisioningFreezeAnnotationKey] == "true", nil
+}
+
+// taintPendingRemoval adds the PendingRemovalNoScheduleTaint to the nodes of the agent pool, so no new pods land on
+// nodes which are going to be removed once the freeze is lifted.
+func (c *CloudProvider) taintPendingRemoval(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) error {
+	nodeList, err := c.agentPoolNodes(ctx, nodeClaim)
+	if err != nil {
+		return err
+	}
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		if lo.ContainsBy(node.Spec.Taints, func(t corev1.Taint) bool { return t.MatchTaint(&PendingRemovalNoScheduleTaint) }) {
+			continue
+		}
+		stored := node.DeepCopy()
+		node.Spec.Taints = append(node.Spec.Taints, PendingRemovalNoScheduleTaint)
+		if err := c.nodeClient.Patch(ctx, node, client.MergeFrom(stored)); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("tainting node %s, %w", node.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/events"
+)
+
+func TestDeleteWithProvisioningFreeze(t *testing.T) {
+	nodeClaim := fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).Build()
+	nodeList := fake.CreateNodeListWithNodeClaim([]*karpenterv1.NodeClaim{nodeClaim})
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        FreezeNamespace,
+			Annotations: map[string]string{ProvisioningFreezeAnnotationKey: "true"},
+		},
+	}
+	fakeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, &nodeList.Items[0]).Build()
+	fakeRecorder := record.NewFakeRecorder(10)
+
+	// the instance provider is not called while deletion is frozen
+	cloudProvider := New(nil, fakeClient, events.NewRecorder(fakeRecorder))
+	err := cloudProvider.Delete(context.Background(), nodeClaim)
+	assert.ErrorContains(t, err, "frozen")
+	assert.Len(t, fakeRecorder.Events, 1)
+
+	node := &v1.Node{}
+	assert.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(&nodeList.Items[0]), node))
+	assert.Equal(t, 1, lo.CountBy(node.Spec.Taints, func(t v1.Taint) bool { return t.MatchTaint(&PendingRemovalNoScheduleTaint) }))
+
+	// tainting again is a no-op
+	assert.Error(t, cloudProvider.Delete(context.Background(), nodeClaim))
+	assert.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(&nodeList.Items[0]), node))
+	assert.Equal(t, 1, lo.CountBy(node.Spec.Taints, func(t v1.Taint) bool { return t.MatchTaint(&PendingRemovalNoScheduleTaint) }))
+}
+
+func TestProvisioningFrozen(t *testing.T) {
+	testcases := map[string]struct {
+		ns       *v1.Namespace
+		expected bool
+	}{
+		"namespace not found": {},
+		"namespace without annotation": {
+			ns: &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: FreezeNamespace}},
+		},
+		"freeze annotation is not true": {
+			ns: &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: FreezeNamespace, Annotations: map[string]string{ProvisioningFreezeAnnotationKey: "false"}}},
+		},
+		"freeze annotation is true": {
+			ns:       &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: FreezeNamespace, Annotations: map[string]string{ProvisioningFreezeAnnotationKey: "true"}}},
+			expected: true,
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			builder := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme)
+			if tc.ns != nil {
+				builder = builder.WithObjects(tc.ns)
+			}
+			cloudProvider := New(nil, builder.Build(), nil)
+			frozen, err := cloudProvider.provisioningFrozen(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, frozen)
+		})
+	}
+}
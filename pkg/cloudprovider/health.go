@@ -0,0 +1,111 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"sync"
+	"time"
+
+	"github.com/azure/gpu-provisioner/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+const (
+	operationList   = "list"
+	operationCreate = "create"
+	operationDelete = "delete"
+)
+
+// Health is the operation health of the cloudprovider, its gauges are registered with the other cloudprovider metrics.
+var Health = NewOperationHealth(utils.WithDefaultInt("RECENT_ERRORS_SIZE", 50), clock.RealClock{})
+
+// ErrorRecord is a failed cloudprovider operation kept for triage.
+type ErrorRecord struct {
+	Operation string    `json:"operation"`
+	NodeClaim string    `json:"nodeClaim,omitempty"`
+	Error     string    `json:"error"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// OperationHealth tracks when each cloudprovider operation last succeeded and keeps the most recent errors in a
+// bounded ring, so a stuck provider can be told apart from a failing one without searching the logs.
+type OperationHealth struct {
+	mu          sync.Mutex
+	clock       clock.PassiveClock
+	lastSuccess map[string]time.Time
+	// errors is a ring of at most size records, next is the index the next error is written to.
+	errors []ErrorRecord
+	next   int
+	size   int
+
+	sinceLastSuccess *prometheus.Desc
+}
+
+func NewOperationHealth(size int, clk clock.PassiveClock) *OperationHealth {
+	return &OperationHealth{
+		clock:       clk,
+		lastSuccess: map[string]time.Time{},
+		size:        max(size, 1),
+		sinceLastSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(metrics.Namespace, "cloudprovider", "seconds_since_last_success"),
+			"Seconds since the cloudprovider operation last succeeded. Labeled by operation(list, create or delete), it's absent until the operation succeeds once.",
+			[]string{"operation"}, nil,
+		),
+	}
+}
+
+// Observe records the outcome of the operation on the nodeclaim, nodeClaim is empty for operations on all instances.
+func (h *OperationHealth) Observe(operation string, nodeClaim string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil {
+		h.lastSuccess[operation] = h.clock.Now()
+		return
+	}
+	record := ErrorRecord{Operation: operation, NodeClaim: nodeClaim, Error: err.Error(), Timestamp: h.clock.Now()}
+	if len(h.errors) < h.size {
+		h.errors = append(h.errors, record)
+	} else {
+		h.errors[h.next] = record
+	}
+	h.next = (h.next + 1) % h.size
+}
+
+// RecentErrors returns the kept errors, the oldest first.
+func (h *OperationHealth) RecentErrors() []ErrorRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.errors) < h.size {
+		return append([]ErrorRecord{}, h.errors...)
+	}
+	return append(append([]ErrorRecord{}, h.errors[h.next:]...), h.errors[:h.next]...)
+}
+
+func (h *OperationHealth) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.sinceLastSuccess
+}
+
+// Collect computes the age of the last success at scrape time, so the gauge keeps growing while the operation fails.
+func (h *OperationHealth) Collect(ch chan<- prometheus.Metric) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.clock.Now()
+	for operation, at := range h.lastSuccess {
+		ch <- prometheus.MustNewConstMetric(h.sinceLastSuccess, prometheus.GaugeValue, now.Sub(at).Seconds(), operation)
+	}
+}
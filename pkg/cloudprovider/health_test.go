@@ -0,0 +1,78 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestOperationHealthRecentErrors(t *testing.T) {
+	testcases := map[string]struct {
+		errors         int
+		expectedErrors []string
+	}{
+		"no error": {
+			errors:         0,
+			expectedErrors: []string{},
+		},
+		"errors within the size": {
+			errors:         2,
+			expectedErrors: []string{"error 0", "error 1"},
+		},
+		"oldest errors are dropped": {
+			errors:         5,
+			expectedErrors: []string{"error 2", "error 3", "error 4"},
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			h := NewOperationHealth(3, clocktesting.NewFakePassiveClock(time.Now()))
+			for i := 0; i < tc.errors; i++ {
+				h.Observe(operationCreate, "ws1", fmt.Errorf("error %d", i))
+				// successes are not kept as errors
+				h.Observe(operationList, "", nil)
+			}
+			assert.Equal(t, tc.expectedErrors, lo.Map(h.RecentErrors(), func(record ErrorRecord, _ int) string { return record.Error }))
+		})
+	}
+}
+
+func TestOperationHealthSinceLastSuccess(t *testing.T) {
+	clk := clocktesting.NewFakePassiveClock(time.Now())
+	h := NewOperationHealth(3, clk)
+	h.Observe(operationList, "", nil)
+	h.Observe(operationDelete, "ws1", errors.New("internal server error"))
+	clk.SetTime(clk.Now().Add(90 * time.Second))
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(h)
+	families, err := registry.Gather()
+	assert.NoError(t, err, "expect no error but got one")
+	assert.Len(t, families, 1)
+	// operations which never succeeded have no gauge.
+	assert.Len(t, families[0].Metric, 1)
+	assert.Equal(t, operationList, families[0].Metric[0].Label[0].GetValue())
+	assert.Equal(t, 90.0, families[0].Metric[0].Gauge.GetValue())
+}
@@ -0,0 +1,58 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+const (
+	phaseLabel        = "phase"
+	instanceTypeLabel = "instance_type"
+	capacityTypeLabel = "capacity_type"
+	reasonLabel       = "reason"
+
+	phaseCreate       = "create"
+	phaseResume       = "resume"
+	phaseRegistration = "registration"
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(InstanceCreateDuration, InstanceDeletionsTotal, Health)
+}
+
+var InstanceCreateDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "cloudprovider",
+		Name:      "instance_create_duration_seconds",
+		Help:      "Duration of creating instances. Labeled by phase(agent pool creation, deallocated agent pool resumption or node registration), instance type and capacity type.",
+		Buckets:   metrics.DurationBuckets(),
+	},
+	[]string{phaseLabel, instanceTypeLabel, capacityTypeLabel},
+)
+
+var InstanceDeletionsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "cloudprovider",
+		Name:      "instance_deletions_total",
+		Help:      "Number of deleted instances. Labeled by disruption reason.",
+	},
+	[]string{reasonLabel},
+)
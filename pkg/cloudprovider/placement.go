@@ -0,0 +1,44 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/samber/lo"
+)
+
+// annotations recording where the agent pool of a nodeclaim is placed in azure, so `kubectl describe` of the nodeclaim
+// and its node shows the placement without looking up the agent pool. the agent pool name is recorded by
+// instance.AgentPoolNameAnnotationKey.
+const (
+	AgentPoolIDAnnotationKey  = "kaito.sh/agentpool-id"
+	SKUAnnotationKey          = "kaito.sh/sku"
+	ZoneAnnotationKey         = "kaito.sh/zone"
+	CapacityTypeAnnotationKey = "kaito.sh/capacity-type"
+)
+
+// placementAnnotations returns the placement annotations of the instance, annotations of unknown values are omitted,
+// e.g. the zone of agent pools not pinned to exactly one zone.
+func placementAnnotations(instanceObj *instance.Instance) map[string]string {
+	annotations := map[string]string{
+		instance.AgentPoolNameAnnotationKey: lo.FromPtr(instanceObj.Name),
+		AgentPoolIDAnnotationKey:            lo.FromPtr(instanceObj.AgentPoolID),
+		SKUAnnotationKey:                    lo.FromPtr(instanceObj.Type),
+		ZoneAnnotationKey:                   lo.FromPtr(instanceObj.Zone),
+		CapacityTypeAnnotationKey:           lo.FromPtr(instanceObj.CapacityType),
+	}
+	return lo.OmitByValues(annotations, []string{""})
+}
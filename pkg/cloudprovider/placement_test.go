@@ -0,0 +1,84 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlacementAnnotations(t *testing.T) {
+	apID := "/subscriptions/testSub/resourceGroups/testRG/providers/Microsoft.ContainerService/managedClusters/testCluster/agentPools/gpu0"
+	testcases := map[string]struct {
+		instance            *instance.Instance
+		expectedName        string
+		expectedAnnotations map[string]string
+	}{
+		"agent pool named after nodeclaim": {
+			instance: &instance.Instance{
+				Name:         to.Ptr("agentpool0"),
+				AgentPoolID:  to.Ptr(apID),
+				Type:         to.Ptr("Standard_NC6s_v3"),
+				Zone:         to.Ptr("eastus-1"),
+				CapacityType: to.Ptr("on-demand"),
+			},
+			expectedName: "agentpool0",
+			expectedAnnotations: map[string]string{
+				instance.AgentPoolNameAnnotationKey: "agentpool0",
+				AgentPoolIDAnnotationKey:            apID,
+				SKUAnnotationKey:                    "Standard_NC6s_v3",
+				ZoneAnnotationKey:                   "eastus-1",
+				CapacityTypeAnnotationKey:           "on-demand",
+			},
+		},
+		"agent pool with generated name": {
+			instance: &instance.Instance{
+				Name:         to.Ptr("gpu0"),
+				AgentPoolID:  to.Ptr(apID),
+				Type:         to.Ptr("Standard_NC6s_v3"),
+				CapacityType: to.Ptr("spot"),
+				Tags:         map[string]*string{instance.NodeClaimTagKey: to.Ptr("workspace-a")},
+			},
+			expectedName: "workspace-a",
+			expectedAnnotations: map[string]string{
+				instance.AgentPoolNameAnnotationKey: "gpu0",
+				AgentPoolIDAnnotationKey:            apID,
+				SKUAnnotationKey:                    "Standard_NC6s_v3",
+				CapacityTypeAnnotationKey:           "spot",
+			},
+		},
+		"unknown placement is omitted": {
+			instance:     &instance.Instance{Name: to.Ptr("agentpool0")},
+			expectedName: "agentpool0",
+			expectedAnnotations: map[string]string{
+				instance.AgentPoolNameAnnotationKey: "agentpool0",
+			},
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			cloudProvider := New(nil, nil, nil)
+			nodeClaim := cloudProvider.instanceToNodeClaim(context.Background(), tc.instance)
+			assert.Equal(t, tc.expectedName, nodeClaim.Name)
+			assert.Equal(t, tc.expectedAnnotations, nodeClaim.Annotations)
+		})
+	}
+}
@@ -0,0 +1,164 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+	nodeclaimutil "sigs.k8s.io/karpenter/pkg/utils/nodeclaim"
+)
+
+// PriorityAnnotationKey is the priority of the nodeclaims of a nodepool, set on the nodepool. nodeclaims of nodepools
+// without it, or without a nodepool object, have priority 0. when the quota of the instance type family is exhausted,
+// idle agent pools of lower priority nodepools are preempted for a higher priority nodeclaim if quota preemption is
+// enabled, as far as the disruption budgets of their nodepools allow.
+const PriorityAnnotationKey = "kaito.sh/priority"
+
+// priorityOf returns the priority of the nodepool, invalid priorities are treated as 0.
+func priorityOf(nodePool *karpenterv1.NodePool) int64 {
+	if nodePool == nil {
+		return 0
+	}
+	priority, err := strconv.ParseInt(nodePool.Annotations[PriorityAnnotationKey], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return priority
+}
+
+// allowedPreemptions returns how many more nodeclaims of the nodepool can be preempted under its disruption budgets,
+// idle nodes are disrupted as empty nodes. the nodeclaims of the nodepool being deleted count against the budgets.
+func allowedPreemptions(ctx context.Context, nodePool *karpenterv1.NodePool, nodeClaims []karpenterv1.NodeClaim) int {
+	if nodePool == nil {
+		return math.MaxInt32
+	}
+	owned := lo.Filter(nodeClaims, func(nc karpenterv1.NodeClaim, _ int) bool {
+		return nc.Labels[karpenterv1.NodePoolLabelKey] == nodePool.Name
+	})
+	deleting := lo.CountBy(owned, func(nc karpenterv1.NodeClaim) bool { return !nc.DeletionTimestamp.IsZero() })
+	// budgets which can't be evaluated allow no disruption.
+	return nodePool.MustGetAllowedDisruptions(ctx, clock.RealClock{}, len(owned))[karpenterv1.DisruptionReasonEmpty] - deleting
+}
+
+// preemptForQuota deletes the nodeclaim of one idle agent pool of a lower priority nodepool than the nodeclaim's, with
+// an instance type of the same quota family, so the nodeclaim can be launched with the freed quota when it's retried.
+// only one preemption is in flight at a time, nodeclaims annotated with do-not-disrupt and nodeclaims of nodepools
+// whose disruption budgets allow no more disruptions are never preempted. nil is returned if there is nothing to
+// preempt.
+func (c *CloudProvider) preemptForQuota(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (*karpenterv1.NodeClaim, error) {
+	nodeClaims, err := nodeclaimutil.AllKaitoNodeClaims(ctx, c.kubeClient)
+	if err != nil {
+		return nil, fmt.Errorf("listing nodeclaims, %w", err)
+	}
+	if lo.ContainsBy(nodeClaims, func(nc karpenterv1.NodeClaim) bool {
+		return !nc.DeletionTimestamp.IsZero() && DisruptionReasonOf(&nc) == DisruptionReasonPreempted
+	}) {
+		klog.InfoS("waiting for the preempted nodeclaim to be deleted", "nodeClaim", klog.KObj(nodeClaim))
+		return nil, nil
+	}
+	nodePoolList := &karpenterv1.NodePoolList{}
+	// the nodepool crd is optional, without it all nodeclaims have the default priority and no budgets.
+	if err := c.kubeClient.List(ctx, nodePoolList); err != nil && !meta.IsNoMatchError(err) {
+		return nil, fmt.Errorf("listing nodepools, %w", err)
+	}
+	nodePools := lo.SliceToMap(nodePoolList.Items, func(np karpenterv1.NodePool) (string, *karpenterv1.NodePool) {
+		return np.Name, &np
+	})
+	nodePoolOf := func(nc *karpenterv1.NodeClaim) *karpenterv1.NodePool {
+		return nodePools[nc.Labels[karpenterv1.NodePoolLabelKey]]
+	}
+
+	skus, err := c.instanceProvider.SKUs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting skus, %w", err)
+	}
+	families := map[string]bool{}
+	for _, instanceType := range scheduling.NewNodeSelectorRequirementsWithMinValues(nodeClaim.Spec.Requirements...).Get(corev1.LabelInstanceTypeStable).Values() {
+		if sku, ok := skus[instanceType]; ok && sku.Family != "" {
+			families[sku.Family] = true
+		}
+	}
+
+	priority := priorityOf(nodePoolOf(nodeClaim))
+	var candidates []*karpenterv1.NodeClaim
+	for i := range nodeClaims {
+		nc := &nodeClaims[i]
+		if nc.Name == nodeClaim.Name || !nc.DeletionTimestamp.IsZero() || priorityOf(nodePoolOf(nc)) >= priority {
+			continue
+		}
+		if nc.Annotations[karpenterv1.DoNotDisruptAnnotationKey] == "true" || nc.Status.NodeName == "" {
+			continue
+		}
+		if sku, ok := skus[nc.Labels[corev1.LabelInstanceTypeStable]]; !ok || !families[sku.Family] {
+			continue
+		}
+		if allowedPreemptions(ctx, nodePoolOf(nc), nodeClaims) <= 0 {
+			klog.V(4).InfoS("nodeclaim is not preempted, disruption budget of its nodepool is exhausted", "nodeClaim", klog.KObj(nc), "nodePool", nc.Labels[karpenterv1.NodePoolLabelKey])
+			continue
+		}
+		idle, err := c.isIdle(ctx, nc)
+		if err != nil {
+			return nil, err
+		}
+		if idle {
+			candidates = append(candidates, nc)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	// the lowest priority is preempted first, then the oldest.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if pi, pj := priorityOf(nodePoolOf(candidates[i])), priorityOf(nodePoolOf(candidates[j])); pi != pj {
+			return pi < pj
+		}
+		return candidates[i].CreationTimestamp.Before(&candidates[j].CreationTimestamp)
+	})
+	victim := candidates[0]
+
+	stored := victim.DeepCopy()
+	victim.Annotations = lo.Assign(victim.Annotations, map[string]string{DisruptionReasonAnnotationKey: string(DisruptionReasonPreempted)})
+	if err := c.kubeClient.Patch(ctx, victim, client.MergeFrom(stored)); err != nil {
+		return nil, fmt.Errorf("annotating nodeclaim %s, %w", victim.Name, err)
+	}
+	if err := c.kubeClient.Delete(ctx, victim); client.IgnoreNotFound(err) != nil {
+		return nil, fmt.Errorf("deleting nodeclaim %s, %w", victim.Name, err)
+	}
+	klog.InfoS("preempted idle nodeclaim to free quota", "nodeClaim", klog.KObj(nodeClaim), "preempted", klog.KObj(victim))
+	c.recorder.Publish(PreemptedEvent(victim, nodeClaim))
+	return victim, nil
+}
+
+// isIdle returns true if no workload pods run on the node of the nodeclaim.
+func (c *CloudProvider) isIdle(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (bool, error) {
+	podList := &corev1.PodList{}
+	if err := c.nodeClient.List(ctx, podList, client.MatchingFields{"spec.nodeName": nodeClaim.Status.NodeName}); err != nil {
+		return false, fmt.Errorf("listing pods of node %s, %w", nodeClaim.Status.NodeName, err)
+	}
+	return !lo.ContainsBy(podList.Items, func(pod corev1.Pod) bool { return isWorkloadPod(&pod) }), nil
+}
@@ -0,0 +1,237 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/events"
+)
+
+// skuInstanceProvider is an instance provider which only knows the skus.
+type skuInstanceProvider struct {
+	instance.InstanceProvider
+	skus map[string]instance.SKU
+}
+
+func (p *skuInstanceProvider) SKUs(_ context.Context) (map[string]instance.SKU, error) {
+	return p.skus, nil
+}
+
+func TestPreemptForQuota(t *testing.T) {
+	skus := map[string]instance.SKU{
+		"Standard_NC6s_v3":  {Name: "Standard_NC6s_v3", Family: "standardNCSv3Family"},
+		"Standard_NC12s_v3": {Name: "Standard_NC12s_v3", Family: "standardNCSv3Family"},
+		"Standard_NC24ads_A100_v4": {
+			Name: "Standard_NC24ads_A100_v4", Family: "StandardNCADSA100v4Family",
+		},
+	}
+	nodePools := []*karpenterv1.NodePool{
+		preemptionNodePool("high", "10"),
+		preemptionNodePool("low", "-1"),
+		preemptionNodePool("default", ""),
+	}
+	testcases := map[string]struct {
+		nodePools      []*karpenterv1.NodePool
+		nodeClaims     []*karpenterv1.NodeClaim
+		pods           []*v1.Pod
+		expectedVictim string
+	}{
+		"preempt the lowest priority idle nodeclaim of the same family": {
+			nodeClaims: []*karpenterv1.NodeClaim{
+				preemptionNodeClaim("low", "low", "Standard_NC12s_v3", nil),
+				preemptionNodeClaim("default", "default", "Standard_NC6s_v3", nil),
+			},
+			expectedVictim: "low",
+		},
+		"nodeclaims of another family are not preempted": {
+			nodeClaims: []*karpenterv1.NodeClaim{
+				preemptionNodeClaim("other", "default", "Standard_NC24ads_A100_v4", nil),
+			},
+		},
+		"nodeclaims with the same priority are not preempted": {
+			nodeClaims: []*karpenterv1.NodeClaim{
+				preemptionNodeClaim("peer", "high", "Standard_NC6s_v3", nil),
+			},
+		},
+		"busy nodeclaims are not preempted": {
+			nodeClaims: []*karpenterv1.NodeClaim{
+				preemptionNodeClaim("busy", "default", "Standard_NC6s_v3", nil),
+			},
+			pods: []*v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "inference", Namespace: "default"},
+					Spec:       v1.PodSpec{NodeName: "busy-node"},
+					Status:     v1.PodStatus{Phase: v1.PodRunning},
+				},
+			},
+		},
+		"idle nodeclaims with daemonset pods are preempted": {
+			nodeClaims: []*karpenterv1.NodeClaim{
+				preemptionNodeClaim("idle", "default", "Standard_NC6s_v3", nil),
+			},
+			pods: []*v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "ds", Namespace: "default", OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "ds"}}},
+					Spec:       v1.PodSpec{NodeName: "idle-node"},
+					Status:     v1.PodStatus{Phase: v1.PodRunning},
+				},
+			},
+			expectedVictim: "idle",
+		},
+		"nodeclaims with do-not-disrupt are not preempted": {
+			nodeClaims: []*karpenterv1.NodeClaim{
+				preemptionNodeClaim("protected", "default", "Standard_NC6s_v3", map[string]string{karpenterv1.DoNotDisruptAnnotationKey: "true"}),
+			},
+		},
+		"nodeclaims without a nodepool have the default priority": {
+			nodeClaims: []*karpenterv1.NodeClaim{
+				preemptionNodeClaim("orphan", "", "Standard_NC6s_v3", nil),
+			},
+			expectedVictim: "orphan",
+		},
+		"nodeclaims of nodepools whose budget allows no disruption are not preempted": {
+			nodePools: []*karpenterv1.NodePool{
+				preemptionNodePool("budgeted", "", karpenterv1.Budget{Nodes: "0"}),
+			},
+			nodeClaims: []*karpenterv1.NodeClaim{
+				preemptionNodeClaim("idle", "budgeted", "Standard_NC6s_v3", nil),
+			},
+		},
+		"nodeclaims being deleted count against the budget": {
+			nodePools: []*karpenterv1.NodePool{
+				preemptionNodePool("budgeted", "", karpenterv1.Budget{Nodes: "1"}),
+			},
+			nodeClaims: []*karpenterv1.NodeClaim{
+				preemptionNodeClaim("deleting", "budgeted", "Standard_NC6s_v3", nil),
+				preemptionNodeClaim("idle", "budgeted", "Standard_NC6s_v3", nil),
+			},
+		},
+		"nodeclaims are preempted within the budget": {
+			nodePools: []*karpenterv1.NodePool{
+				preemptionNodePool("budgeted", "", karpenterv1.Budget{Nodes: "1"}),
+			},
+			nodeClaims: []*karpenterv1.NodeClaim{
+				preemptionNodeClaim("busy", "budgeted", "Standard_NC6s_v3", nil),
+				preemptionNodeClaim("idle", "budgeted", "Standard_NC6s_v3", nil),
+			},
+			pods: []*v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "inference", Namespace: "default"},
+					Spec:       v1.PodSpec{NodeName: "busy-node"},
+					Status:     v1.PodStatus{Phase: v1.PodRunning},
+				},
+			},
+			expectedVictim: "idle",
+		},
+		"only one preemption is in flight": {
+			nodeClaims: []*karpenterv1.NodeClaim{
+				preemptionNodeClaim("deleting", "default", "Standard_NC6s_v3", map[string]string{DisruptionReasonAnnotationKey: string(DisruptionReasonPreempted)}),
+				preemptionNodeClaim("idle", "default", "Standard_NC6s_v3", nil),
+			},
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			builder := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithIndex(&v1.Pod{}, "spec.nodeName", func(o client.Object) []string {
+					return []string{o.(*v1.Pod).Spec.NodeName}
+				})
+			for _, np := range append(nodePools, tc.nodePools...) {
+				builder = builder.WithObjects(np)
+			}
+			for _, nc := range tc.nodeClaims {
+				builder = builder.WithObjects(nc)
+			}
+			for _, pod := range tc.pods {
+				builder = builder.WithObjects(pod)
+			}
+			fakeClient := builder.Build()
+			for _, nc := range tc.nodeClaims {
+				if nc.Name == "deleting" {
+					assert.NoError(t, fakeClient.Delete(context.Background(), nc))
+				}
+			}
+			fakeRecorder := record.NewFakeRecorder(10)
+			cloudProvider := New(&skuInstanceProvider{skus: skus}, fakeClient, events.NewRecorder(fakeRecorder))
+
+			nodeClaim := preemptionNodeClaim("high", "high", "", nil)
+			nodeClaim.Spec.Requirements = []karpenterv1.NodeSelectorRequirementWithMinValues{
+				{NodeSelectorRequirement: v1.NodeSelectorRequirement{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"Standard_NC6s_v3"}}},
+			}
+			victim, err := cloudProvider.preemptForQuota(context.Background(), nodeClaim)
+			assert.NoError(t, err)
+			if tc.expectedVictim == "" {
+				assert.Nil(t, victim)
+				assert.Len(t, fakeRecorder.Events, 0)
+				return
+			}
+			assert.Equal(t, tc.expectedVictim, victim.Name)
+			assert.Contains(t, <-fakeRecorder.Events, "Preempted")
+
+			nc := &karpenterv1.NodeClaim{}
+			err = fakeClient.Get(context.Background(), client.ObjectKey{Name: tc.expectedVictim}, nc)
+			if err == nil {
+				// the nodeclaim is kept by its finalizer until the agent pool is deleted.
+				assert.False(t, nc.DeletionTimestamp.IsZero())
+				assert.Equal(t, DisruptionReasonPreempted, DisruptionReasonOf(nc))
+			}
+		})
+	}
+}
+
+func preemptionNodePool(name, priority string, budgets ...karpenterv1.Budget) *karpenterv1.NodePool {
+	np := &karpenterv1.NodePool{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       karpenterv1.NodePoolSpec{Disruption: karpenterv1.Disruption{Budgets: budgets}},
+	}
+	if priority != "" {
+		np.Annotations = map[string]string{PriorityAnnotationKey: priority}
+	}
+	return np
+}
+
+func preemptionNodeClaim(name, nodePool, instanceType string, annotations map[string]string) *karpenterv1.NodeClaim {
+	nc := &karpenterv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Labels:            map[string]string{"kaito.sh/workspace": name},
+			Annotations:       annotations,
+			Finalizers:        []string{karpenterv1.TerminationFinalizer},
+			CreationTimestamp: metav1.NewTime(time.Now()),
+		},
+		Status: karpenterv1.NodeClaimStatus{NodeName: name + "-node"},
+	}
+	if nodePool != "" {
+		nc.Labels[karpenterv1.NodePoolLabelKey] = nodePool
+	}
+	if instanceType != "" {
+		nc.Labels[v1.LabelInstanceTypeStable] = instanceType
+	}
+	return nc
+}
@@ -0,0 +1,94 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	nodeclaimutil "sigs.k8s.io/karpenter/pkg/utils/nodeclaim"
+)
+
+// CheckWorkloads publishes a warning event if deleting the agent pool of the nodeclaim disrupts pods not owned by the
+// workspace, it's a no-op unless WORKLOAD_SAFETY_CHECK is enabled. it must be called when the deletion is decided,
+// before the nodes are drained, as the pods are gone by the time the agent pool is deleted.
+func (c *CloudProvider) CheckWorkloads(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) {
+	if !c.workloadSafetyCheck {
+		return
+	}
+	pods, err := c.foreignPods(ctx, nodeClaim)
+	if err != nil {
+		klog.ErrorS(err, "failed to check workloads before deleting agentpool", "nodeClaim", klog.KObj(nodeClaim))
+		return
+	}
+	if len(pods) != 0 {
+		klog.InfoS("deleting agentpool disrupts pods not owned by the workspace", "nodeClaim", klog.KObj(nodeClaim), "pods", len(pods))
+		c.recorder.Publish(WorkloadDisruptionEvent(nodeClaim, pods))
+	}
+}
+
+// foreignPods returns the running pods on the nodes of the agent pool which would be disrupted by deleting
+// the agent pool. daemonset pods and pods from the workspace(or ragengine) owning the nodeclaim are excluded.
+func (c *CloudProvider) foreignPods(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) ([]*corev1.Pod, error) {
+	nodeList, err := c.agentPoolNodes(ctx, nodeClaim)
+	if err != nil {
+		return nil, err
+	}
+
+	var pods []*corev1.Pod
+	for i := range nodeList.Items {
+		podList := &corev1.PodList{}
+		if err := c.nodeClient.List(ctx, podList, client.MatchingFields{"spec.nodeName": nodeList.Items[i].Name}); err != nil {
+			return nil, err
+		}
+		for j := range podList.Items {
+			if isForeignPod(nodeClaim, &podList.Items[j]) {
+				pods = append(pods, &podList.Items[j])
+			}
+		}
+	}
+	return pods, nil
+}
+
+func isForeignPod(nodeClaim *karpenterv1.NodeClaim, pod *corev1.Pod) bool {
+	if !isWorkloadPod(pod) {
+		return false
+	}
+
+	for _, key := range nodeclaimutil.KaitoNodeClaimLabels {
+		if owner, ok := nodeClaim.Labels[key]; ok && pod.Labels[key] == owner {
+			return false
+		}
+	}
+	return true
+}
+
+// isWorkloadPod returns true if the pod is running a workload, i.e. it's neither terminated nor a daemonset or
+// static pod.
+func isWorkloadPod(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	// daemonset pods and static pods are expected on every node
+	return !lo.ContainsBy(pod.OwnerReferences, func(ref metav1.OwnerReference) bool {
+		return ref.Kind == "DaemonSet" || ref.Kind == "Node"
+	})
+}
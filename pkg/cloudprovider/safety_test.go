@@ -0,0 +1,92 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func TestForeignPods(t *testing.T) {
+	testcases := map[string]struct {
+		pod                 *v1.Pod
+		expectedForeignPods int
+	}{
+		"pod from the owning workspace": {
+			pod:                 testPod(map[string]string{"kaito.sh/workspace": "none"}, nil, v1.PodRunning),
+			expectedForeignPods: 0,
+		},
+		"daemonset pod": {
+			pod:                 testPod(nil, []metav1.OwnerReference{{Kind: "DaemonSet", Name: "ds"}}, v1.PodRunning),
+			expectedForeignPods: 0,
+		},
+		"completed pod": {
+			pod:                 testPod(nil, nil, v1.PodSucceeded),
+			expectedForeignPods: 0,
+		},
+		"pod from another workspace": {
+			pod:                 testPod(map[string]string{"kaito.sh/workspace": "other"}, nil, v1.PodRunning),
+			expectedForeignPods: 1,
+		},
+		"pod not from workspace": {
+			pod:                 testPod(nil, []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "rs"}}, v1.PodRunning),
+			expectedForeignPods: 1,
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			nodeClaim := fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).Build()
+			nodeList := fake.CreateNodeListWithNodeClaim([]*karpenterv1.NodeClaim{nodeClaim})
+			tc.pod.Spec.NodeName = nodeList.Items[0].Name
+
+			fakeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithRuntimeObjects([]k8sruntime.Object{&nodeList.Items[0], tc.pod}...).
+				WithIndex(&v1.Pod{}, "spec.nodeName", func(o client.Object) []string {
+					return []string{o.(*v1.Pod).Spec.NodeName}
+				}).
+				Build()
+
+			cloudProvider := New(nil, fakeClient, nil)
+			pods, err := cloudProvider.foreignPods(context.Background(), nodeClaim)
+			assert.NoError(t, err, "expect no error but got one")
+			assert.Equal(t, tc.expectedForeignPods, len(pods), "unexpected number of foreign pods")
+		})
+	}
+}
+
+func testPod(labels map[string]string, ownerReferences []metav1.OwnerReference, phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod",
+			Namespace:       "default",
+			Labels:          labels,
+			OwnerReferences: ownerReferences,
+		},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
@@ -18,16 +18,35 @@ package controllers
 
 import (
 	"github.com/awslabs/operatorpkg/controller"
+	instanceconsistency "github.com/azure/gpu-provisioner/pkg/controllers/instance/consistency"
 	instancegarbagecollection "github.com/azure/gpu-provisioner/pkg/controllers/instance/garbagecollection"
+	instancemigration "github.com/azure/gpu-provisioner/pkg/controllers/instance/migration"
+	instancesweeper "github.com/azure/gpu-provisioner/pkg/controllers/instance/sweeper"
+	nodegpuhealth "github.com/azure/gpu-provisioner/pkg/controllers/node/gpuhealth"
+	nodemodelcache "github.com/azure/gpu-provisioner/pkg/controllers/node/modelcache"
 	nodeclaimstatus "github.com/azure/gpu-provisioner/pkg/controllers/nodeclaim"
+	nodeclaimtermination "github.com/azure/gpu-provisioner/pkg/controllers/nodeclaim/termination"
+	nodeclaimupdate "github.com/azure/gpu-provisioner/pkg/controllers/nodeclaim/update"
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/events"
 )
 
-func NewControllers(kubeClient client.Client, cloudProvider cloudprovider.CloudProvider) []controller.Controller {
+// NewControllers returns the controllers of gpu-provisioner, workloadChecker warns about workloads disrupted by
+// nodeclaim deletions.
+func NewControllers(kubeClient client.Client, cloudProvider cloudprovider.CloudProvider, instanceProvider *instance.Provider, recorder events.Recorder,
+	workloadChecker nodeclaimtermination.WorkloadChecker) []controller.Controller {
 	controllers := []controller.Controller{
-		instancegarbagecollection.NewController(kubeClient, cloudProvider),
+		instancegarbagecollection.NewController(kubeClient, cloudProvider, recorder),
+		instanceconsistency.NewController(kubeClient, cloudProvider),
+		instancemigration.NewController(kubeClient, instanceProvider),
+		instancesweeper.NewController(kubeClient, instanceProvider, recorder),
 		nodeclaimstatus.NewController(kubeClient),
+		nodeclaimtermination.NewController(kubeClient, cloudProvider, recorder).WithWorkloadChecker(workloadChecker),
+		nodeclaimupdate.NewController(kubeClient, instanceProvider),
+		nodegpuhealth.NewController(kubeClient),
+		nodemodelcache.NewController(kubeClient),
 	}
 	return controllers
 }
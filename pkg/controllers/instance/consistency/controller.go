@@ -0,0 +1,190 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/awslabs/operatorpkg/singleton"
+	"github.com/azure/gpu-provisioner/pkg/controllers/middleware"
+	"github.com/azure/gpu-provisioner/pkg/utils"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/operator/injection"
+	nodeclaimutil "sigs.k8s.io/karpenter/pkg/utils/nodeclaim"
+)
+
+const (
+	// ReportConfigMapName is the name of the ConfigMap in the namespace of gpu-provisioner holding the report.
+	ReportConfigMapName = "gpu-provisioner-consistency-report"
+	// ReportKey is the key of the report in the data of the ConfigMap.
+	ReportKey = "report.json"
+)
+
+// MismatchReason is why a NodeClaim, agentpool or node is inconsistent with the others.
+type MismatchReason string
+
+const (
+	// AgentPoolMissing means a launched NodeClaim has no agentpool.
+	AgentPoolMissing MismatchReason = "AgentPoolMissing"
+	// NodeClaimMissing means an agentpool has no NodeClaim, it's garbage collected eventually.
+	NodeClaimMissing MismatchReason = "NodeClaimMissing"
+	// NodeMissing means a registered NodeClaim has no node.
+	NodeMissing MismatchReason = "NodeMissing"
+	// ProviderIDMismatch means the provider id of a NodeClaim differs from the provider id of its agentpool.
+	ProviderIDMismatch MismatchReason = "ProviderIDMismatch"
+)
+
+// Report summarizes NodeClaims, agentpools and nodes, and lists their mismatches.
+type Report struct {
+	GeneratedAt metav1.Time `json:"generatedAt"`
+	NodeClaims  int         `json:"nodeClaims"`
+	AgentPools  int         `json:"agentPools"`
+	Nodes       int         `json:"nodes"`
+	Mismatches  []Mismatch  `json:"mismatches"`
+}
+
+type Mismatch struct {
+	// Name is the name of the NodeClaim, which is the name of the agentpool as well.
+	Name    string         `json:"name"`
+	Reason  MismatchReason `json:"reason"`
+	Message string         `json:"message,omitempty"`
+}
+
+// Controller maintains a ConfigMap summarizing NodeClaims vs agentpools vs nodes, so operators have a single place
+// to check the consistency between them.
+type Controller struct {
+	kubeClient    client.Client
+	cloudProvider cloudprovider.CloudProvider
+
+	// namespace is the namespace of the report ConfigMap.
+	namespace string
+	// interval is the time between two reports.
+	interval time.Duration
+}
+
+func NewController(kubeClient client.Client, cloudProvider cloudprovider.CloudProvider) *Controller {
+	return &Controller{
+		kubeClient:    kubeClient,
+		cloudProvider: cloudProvider,
+		namespace:     utils.WithDefaultString("SYSTEM_NAMESPACE", "gpu-provisioner"),
+		interval:      utils.WithDefaultDuration("CONSISTENCY_REPORT_INTERVAL", 5*time.Minute),
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context) (reconcile.Result, error) {
+	ctx = injection.WithControllerName(ctx, "instance.consistency")
+
+	cloudNodeClaims, err := c.cloudProvider.List(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	kaitoNodeClaims, err := nodeclaimutil.AllKaitoNodeClaims(ctx, c.kubeClient)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	nodeList := &corev1.NodeList{}
+	if err := c.kubeClient.List(ctx, nodeList, client.HasLabels{v1.NodePoolLabelKey}); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	report := newReport(cloudNodeClaims, kaitoNodeClaims, nodeList.Items)
+	log.FromContext(ctx).Info("consistency report", "nodeClaims", report.NodeClaims, "agentPools", report.AgentPools, "nodes", report.Nodes, "mismatches", len(report.Mismatches))
+	for _, reason := range []MismatchReason{AgentPoolMissing, NodeClaimMissing, NodeMissing, ProviderIDMismatch} {
+		Mismatches.With(map[string]string{reasonLabel: string(reason)}).Set(float64(lo.CountBy(report.Mismatches, func(m Mismatch) bool { return m.Reason == reason })))
+	}
+	if err := c.writeReport(ctx, report); err != nil {
+		return reconcile.Result{}, fmt.Errorf("writing consistency report, %w", err)
+	}
+	return reconcile.Result{RequeueAfter: c.interval}, nil
+}
+
+// newReport compares the agentpools(as NodeClaims converted by the cloud provider), the NodeClaims and the nodes.
+// NodeClaims being deleted or not launched yet are expected to be inconsistent and skipped.
+func newReport(cloudNodeClaims []*v1.NodeClaim, nodeClaims []v1.NodeClaim, nodes []corev1.Node) *Report {
+	report := &Report{
+		GeneratedAt: metav1.Now(),
+		NodeClaims:  len(nodeClaims),
+		AgentPools:  len(cloudNodeClaims),
+		Nodes:       len(nodes),
+		Mismatches:  []Mismatch{},
+	}
+	agentPools := lo.SliceToMap(cloudNodeClaims, func(nc *v1.NodeClaim) (string, *v1.NodeClaim) { return nc.Name, nc })
+	nodesByProviderID := lo.SliceToMap(nodes, func(node corev1.Node) (string, *corev1.Node) { return node.Spec.ProviderID, &node })
+
+	for i := range nodeClaims {
+		nodeClaim := &nodeClaims[i]
+		if !nodeClaim.DeletionTimestamp.IsZero() || nodeClaim.Status.ProviderID == "" {
+			continue
+		}
+		agentPool, ok := agentPools[nodeClaim.Name]
+		if !ok {
+			report.Mismatches = append(report.Mismatches, Mismatch{Name: nodeClaim.Name, Reason: AgentPoolMissing})
+			continue
+		}
+		if agentPool.Status.ProviderID != "" && agentPool.Status.ProviderID != nodeClaim.Status.ProviderID {
+			report.Mismatches = append(report.Mismatches, Mismatch{Name: nodeClaim.Name, Reason: ProviderIDMismatch,
+				Message: fmt.Sprintf("nodeclaim has provider id %s, agentpool has provider id %s", nodeClaim.Status.ProviderID, agentPool.Status.ProviderID)})
+		}
+		if _, ok := nodesByProviderID[nodeClaim.Status.ProviderID]; !ok && nodeClaim.StatusConditions().Get(v1.ConditionTypeRegistered).IsTrue() {
+			report.Mismatches = append(report.Mismatches, Mismatch{Name: nodeClaim.Name, Reason: NodeMissing,
+				Message: fmt.Sprintf("no node has provider id %s", nodeClaim.Status.ProviderID)})
+		}
+	}
+
+	nodeClaimNames := lo.SliceToMap(nodeClaims, func(nc v1.NodeClaim) (string, bool) { return nc.Name, true })
+	for _, agentPool := range cloudNodeClaims {
+		if !agentPool.DeletionTimestamp.IsZero() || nodeClaimNames[agentPool.Name] {
+			continue
+		}
+		report.Mismatches = append(report.Mismatches, Mismatch{Name: agentPool.Name, Reason: NodeClaimMissing})
+	}
+	sort.SliceStable(report.Mismatches, func(i, j int) bool { return report.Mismatches[i].Name < report.Mismatches[j].Name })
+	return report
+}
+
+func (c *Controller) writeReport(ctx context.Context, report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: ReportConfigMapName, Namespace: c.namespace}}
+	_, err = controllerutil.CreateOrUpdate(ctx, c.kubeClient, cm, func() error {
+		cm.Data = map[string]string{ReportKey: string(data)}
+		return nil
+	})
+	return err
+}
+
+func (c *Controller) Register(_ context.Context, m manager.Manager) error {
+	return controllerruntime.NewControllerManagedBy(m).
+		Named("instance.consistency").
+		WatchesRawSource(singleton.Source()).
+		Complete(middleware.WrapLowPriority("instance.consistency", singleton.AsReconciler(c)))
+}
@@ -0,0 +1,121 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistency
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func TestNewReport(t *testing.T) {
+	testcases := map[string]struct {
+		cloudNodeClaims    []*v1.NodeClaim
+		nodeClaims         []v1.NodeClaim
+		nodes              []corev1.Node
+		expectedMismatches []Mismatch
+	}{
+		"consistent": {
+			cloudNodeClaims: []*v1.NodeClaim{testNodeClaim("ws1", "id1", false)},
+			nodeClaims:      []v1.NodeClaim{*testNodeClaim("ws1", "id1", true)},
+			nodes:           []corev1.Node{testNode("id1")},
+		},
+		"agentpool missing": {
+			nodeClaims:         []v1.NodeClaim{*testNodeClaim("ws1", "id1", true)},
+			nodes:              []corev1.Node{testNode("id1")},
+			expectedMismatches: []Mismatch{{Name: "ws1", Reason: AgentPoolMissing}},
+		},
+		"nodeclaim missing": {
+			cloudNodeClaims:    []*v1.NodeClaim{testNodeClaim("ws1", "id1", false)},
+			nodes:              []corev1.Node{testNode("id1")},
+			expectedMismatches: []Mismatch{{Name: "ws1", Reason: NodeClaimMissing}},
+		},
+		"node missing": {
+			cloudNodeClaims:    []*v1.NodeClaim{testNodeClaim("ws1", "id1", false)},
+			nodeClaims:         []v1.NodeClaim{*testNodeClaim("ws1", "id1", true)},
+			expectedMismatches: []Mismatch{{Name: "ws1", Reason: NodeMissing, Message: "no node has provider id id1"}},
+		},
+		"unregistered nodeclaim without node is expected": {
+			cloudNodeClaims: []*v1.NodeClaim{testNodeClaim("ws1", "id1", false)},
+			nodeClaims:      []v1.NodeClaim{*testNodeClaim("ws1", "id1", false)},
+		},
+		"provider id mismatch": {
+			cloudNodeClaims: []*v1.NodeClaim{testNodeClaim("ws1", "id2", false)},
+			nodeClaims:      []v1.NodeClaim{*testNodeClaim("ws1", "id1", true)},
+			nodes:           []corev1.Node{testNode("id1")},
+			expectedMismatches: []Mismatch{
+				{Name: "ws1", Reason: ProviderIDMismatch, Message: "nodeclaim has provider id id1, agentpool has provider id id2"},
+			},
+		},
+		"nodeclaim not launched yet is skipped": {
+			nodeClaims: []v1.NodeClaim{*testNodeClaim("ws1", "", false)},
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			report := newReport(tc.cloudNodeClaims, tc.nodeClaims, tc.nodes)
+			assert.Equal(t, len(tc.nodeClaims), report.NodeClaims)
+			assert.Equal(t, len(tc.cloudNodeClaims), report.AgentPools)
+			assert.Equal(t, len(tc.nodes), report.Nodes)
+			if tc.expectedMismatches == nil {
+				tc.expectedMismatches = []Mismatch{}
+			}
+			assert.Equal(t, tc.expectedMismatches, report.Mismatches)
+		})
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	fakeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	c := &Controller{kubeClient: fakeClient, namespace: "gpu-provisioner"}
+
+	for _, mismatches := range [][]Mismatch{{{Name: "ws1", Reason: NodeClaimMissing}}, {}} {
+		assert.NoError(t, c.writeReport(context.Background(), &Report{Mismatches: mismatches}))
+
+		cm := &corev1.ConfigMap{}
+		assert.NoError(t, fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "gpu-provisioner", Name: ReportConfigMapName}, cm))
+		report := &Report{}
+		assert.NoError(t, json.Unmarshal([]byte(cm.Data[ReportKey]), report))
+		assert.Equal(t, mismatches, report.Mismatches)
+	}
+}
+
+func testNodeClaim(name, providerID string, registered bool) *v1.NodeClaim {
+	nc := &v1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     v1.NodeClaimStatus{ProviderID: providerID},
+	}
+	if registered {
+		nc.StatusConditions().SetTrue(v1.ConditionTypeRegistered)
+	}
+	return nc
+}
+
+func testNode(providerID string) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-" + providerID},
+		Spec:       corev1.NodeSpec{ProviderID: providerID},
+	}
+}
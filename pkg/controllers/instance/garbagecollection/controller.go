@@ -18,13 +18,19 @@ package garbagecollection
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"github.com/awslabs/operatorpkg/singleton"
+	azurecloudprovider "github.com/azure/gpu-provisioner/pkg/cloudprovider"
+	"github.com/azure/gpu-provisioner/pkg/controllers/middleware"
+	"github.com/azure/gpu-provisioner/pkg/utils"
 	"github.com/samber/lo"
 	"go.uber.org/multierr"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -32,6 +38,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/events"
 	"sigs.k8s.io/karpenter/pkg/operator/injection"
 	nodeclaimutil "sigs.k8s.io/karpenter/pkg/utils/nodeclaim"
 )
@@ -39,13 +46,49 @@ import (
 type Controller struct {
 	kubeClient    client.Client
 	cloudProvider cloudprovider.CloudProvider
+	recorder      events.Recorder
+
+	// dryRun means leaked instances are only reported(events, metrics and logs) instead of being deleted.
+	dryRun bool
+	// dryRunUntil is the end of the dry-run observation period, zero value means dry-run never expires. it's a
+	// configured timestamp, so restarts and leader failovers don't extend the period.
+	dryRunUntil time.Time
+	// unregisteredWarningPeriod is the time after which an agentpool whose node hasn't registered is reported, well
+	// before the registration ttl of karpenter(15m) removes the nodeclaim and makes the agentpool garbage.
+	unregisteredWarningPeriod time.Duration
+	// interval is the time between two garbage collection runs.
+	interval time.Duration
 }
 
-func NewController(kubeClient client.Client, cloudProvider cloudprovider.CloudProvider) *Controller {
-	return &Controller{
+func NewController(kubeClient client.Client, cloudProvider cloudprovider.CloudProvider, recorder events.Recorder) *Controller {
+	c := &Controller{
 		kubeClient:    kubeClient,
 		cloudProvider: cloudProvider,
+		recorder:      recorder,
+		dryRun:        utils.WithDefaultBool("GC_DRY_RUN", false),
+
+		unregisteredWarningPeriod: utils.WithDefaultDuration("GC_UNREGISTERED_WARNING_PERIOD", 7*time.Minute),
+		interval:                  utils.WithDefaultDuration("GC_INTERVAL", 2*time.Minute),
+	}
+	if value := utils.WithDefaultString("GC_DRY_RUN_UNTIL", ""); value != "" {
+		until, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			// dry-run stays on and never expires instead of enabling destructive cleanup by mistake.
+			klog.ErrorS(err, "invalid GC_DRY_RUN_UNTIL, expected an RFC3339 timestamp, leaked instances are only reported until it's fixed", "value", value)
+			c.dryRun = true
+		} else if c.dryRun {
+			c.dryRunUntil = until
+		}
+	}
+	return c
+}
+
+// isDryRun returns true when leaked instances should only be reported instead of being deleted.
+func (c *Controller) isDryRun() bool {
+	if !c.dryRun {
+		return false
 	}
+	return c.dryRunUntil.IsZero() || time.Now().Before(c.dryRunUntil)
 }
 
 func (c *Controller) Reconcile(ctx context.Context) (reconcile.Result, error) {
@@ -69,6 +112,8 @@ func (c *Controller) Reconcile(ctx context.Context) (reconcile.Result, error) {
 		return nc.Name, true
 	})...)
 
+	c.reportUnregisteredInstances(ctx, cloudNodeClaims, kaitoNodeClaims)
+
 	// instance's related NodeClaim has been removed, and instance has been created for more than 30s
 	// so we need to garbage these leaked cloudprovider instances and nodes.
 	deletedCloudProviderInstances := lo.Filter(cloudNodeClaims, func(nc *v1.NodeClaim, _ int) bool {
@@ -85,7 +130,28 @@ func (c *Controller) Reconcile(ctx context.Context) (reconcile.Result, error) {
 
 		return true
 	})
-	log.FromContext(ctx).Info("instance garbagecollection status", "garbaged instance count", len(deletedCloudProviderInstances))
+	deletedCloudProviderInstances = lo.Filter(deletedCloudProviderInstances, func(nc *v1.NodeClaim, _ int) bool {
+		if c.isProtected(ctx, nc) {
+			log.FromContext(ctx).Info("skip deleting protected leaked cloudprovider instance", "name", nc.Name)
+			c.recorder.Publish(ProtectedGarbageCollectionEvent(nc))
+			return false
+		}
+		return true
+	})
+	dryRun := c.isDryRun()
+	log.FromContext(ctx).Info("instance garbagecollection status", "garbaged instance count", len(deletedCloudProviderInstances), "dryRun", dryRun)
+	// the gauge only keeps the result of the latest run, so it's not labeled by the mode of previous runs.
+	LeakedInstances.Reset()
+	LeakedInstances.With(map[string]string{dryRunLabel: strconv.FormatBool(dryRun)}).Set(float64(len(deletedCloudProviderInstances)))
+
+	if dryRun {
+		for i := range deletedCloudProviderInstances {
+			log.FromContext(ctx).Info("skip deleting leaked cloudprovider instance in dry-run mode", "name", deletedCloudProviderInstances[i].Name,
+				"providerID", deletedCloudProviderInstances[i].Status.ProviderID, "creationTimestamp", deletedCloudProviderInstances[i].CreationTimestamp)
+			c.recorder.Publish(DryRunGarbageCollectionEvent(deletedCloudProviderInstances[i]))
+		}
+		return reconcile.Result{RequeueAfter: c.interval}, nil
+	}
 
 	errs := make([]error, len(deletedCloudProviderInstances))
 	workqueue.ParallelizeUntil(ctx, 20, len(deletedCloudProviderInstances), func(i int) {
@@ -95,37 +161,54 @@ func (c *Controller) Reconcile(ctx context.Context) (reconcile.Result, error) {
 			return
 		}
 		log.FromContext(ctx).Info("delete leaked cloudprovider instance successfully", "name", deletedCloudProviderInstances[i].Name)
+		// the nodes of the instance are deleted by the cloud provider once the agent pool is gone.
+		azurecloudprovider.RecordDisruption(c.recorder, deletedCloudProviderInstances[i], azurecloudprovider.DisruptionReasonGCOrphan)
+	})
 
-		if len(deletedCloudProviderInstances[i].Status.ProviderID) != 0 {
-			nodes, err := nodeclaimutil.AllNodesForNodeClaim(ctx, c.kubeClient, deletedCloudProviderInstances[i])
-			if err != nil {
-				errs[i] = err
-				return
-			}
+	return reconcile.Result{RequeueAfter: c.interval}, multierr.Combine(errs...)
+}
 
-			subErrs := make([]error, len(nodes))
-			for k := range nodes {
-				// If we still get the Node, but it's already marked as terminating, we don't need to call Delete again
-				if nodes[k].DeletionTimestamp.IsZero() {
-					// We delete nodes to trigger the node finalization and deletion flow
-					if err := c.kubeClient.Delete(ctx, nodes[k]); client.IgnoreNotFound(err) != nil {
-						log.FromContext(ctx).Error(err, "failed to delete leaked node", "node", nodes[k].Name)
-						subErrs[k] = err
-					} else {
-						log.FromContext(ctx).Info("delete leaked node successfully", "name", nodes[k].Name)
-					}
-				}
-			}
-			errs[i] = multierr.Combine(subErrs...)
-		}
+// isProtected returns true if the leaked instance is protected from garbage collection, either by the azure tag
+// instance.GCTagKey of the agentpool(converted to the do-not-disrupt annotation by the cloud provider) or by the
+// do-not-disrupt annotation of its nodes. the instance is considered protected if its nodes can't be checked.
+func (c *Controller) isProtected(ctx context.Context, nodeClaim *v1.NodeClaim) bool {
+	if nodeClaim.Annotations[v1.DoNotDisruptAnnotationKey] == "true" {
+		return true
+	}
+	nodes, err := nodeclaimutil.AllNodesForNodeClaim(ctx, c.kubeClient, nodeClaim)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to check protection of leaked cloudprovider instance", "name", nodeClaim.Name)
+		return true
+	}
+	return lo.ContainsBy(nodes, func(node *corev1.Node) bool {
+		return node.Annotations[v1.DoNotDisruptAnnotationKey] == "true"
 	})
+}
 
-	return reconcile.Result{RequeueAfter: time.Minute * 2}, multierr.Combine(errs...)
+// reportUnregisteredInstances publishes a warning event for every agentpool whose node hasn't registered within the
+// warning period, so registration problems are noticed before the agentpool is garbage collected.
+func (c *Controller) reportUnregisteredInstances(ctx context.Context, cloudNodeClaims []*v1.NodeClaim, kaitoNodeClaims []v1.NodeClaim) {
+	cloudNodeClaimNames := sets.New[string](lo.Map(cloudNodeClaims, func(nc *v1.NodeClaim, _ int) string {
+		return nc.Name
+	})...)
+	for i := range kaitoNodeClaims {
+		nodeClaim := &kaitoNodeClaims[i]
+		if !cloudNodeClaimNames.Has(nodeClaim.Name) || !nodeClaim.DeletionTimestamp.IsZero() || nodeClaim.CreationTimestamp.IsZero() {
+			continue
+		}
+		if nodeClaim.StatusConditions().Get(v1.ConditionTypeRegistered).IsTrue() {
+			continue
+		}
+		if age := time.Since(nodeClaim.CreationTimestamp.Time); age >= c.unregisteredWarningPeriod {
+			log.FromContext(ctx).Info("agentpool has no registered node", "name", nodeClaim.Name, "age", age.Round(time.Second))
+			c.recorder.Publish(UnregisteredInstanceEvent(nodeClaim, age))
+		}
+	}
 }
 
 func (c *Controller) Register(_ context.Context, m manager.Manager) error {
 	return controllerruntime.NewControllerManagedBy(m).
 		Named("instance.garbagecollection").
 		WatchesRawSource(singleton.Source()).
-		Complete(singleton.AsReconciler(c))
+		Complete(middleware.WrapLowPriority("instance.garbagecollection", singleton.AsReconciler(c)))
 }
@@ -21,21 +21,27 @@ import (
 	"errors"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
 	"github.com/azure/gpu-provisioner/pkg/cloudprovider"
 	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
 	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/events"
 )
 
 func TestReconcile(t *testing.T) {
@@ -44,38 +50,22 @@ func TestReconcile(t *testing.T) {
 		leakedNodeClaims        []*karpenterv1.NodeClaim
 		mockListAgentPoolResp   func(nodeClaims []*karpenterv1.NodeClaim) *runtime.Pager[armcontainerservice.AgentPoolsClientListResponse]
 		mockDeleteAgentPoolResp func(mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientDeleteResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientDeleteResponse], error)
+		dryRun                  bool
+		expectedEvents          int
 		expectedError           error
 	}{
 		"garbage collection leaked instance without providerID successfully": {
 			nodeClaims: []*karpenterv1.NodeClaim{
-				fake.GetNodeClaimObj("agentpool1", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
-				fake.GetNodeClaimObj("agentpool2", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
+				fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
+				fixture.NodeClaim().WithName("agentpool2").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
 			},
 			leakedNodeClaims: []*karpenterv1.NodeClaim{
-				fake.GetNodeClaimObjWithoutProviderID("agentpool3", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
+				fixture.NodeClaim().WithName("agentpool3").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").WithoutProviderID().Build(),
 			},
 			mockListAgentPoolResp: func(nodeClaims []*karpenterv1.NodeClaim) *runtime.Pager[armcontainerservice.AgentPoolsClientListResponse] {
 				var agentPools []*armcontainerservice.AgentPool
 				for i := range nodeClaims {
-					ap := fake.CreateAgentPoolObjWithNodeClaim(nodeClaims[i])
+					ap := fixture.AgentPoolFor(nodeClaims[i]).Build()
 					agentPools = append(agentPools, &ap)
 				}
 				return runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
@@ -106,38 +96,21 @@ func TestReconcile(t *testing.T) {
 				p, err := runtime.NewPoller(&resp, runtime.NewPipeline("", "", runtime.PipelineOptions{}, nil), pollingOptions)
 				return p, err
 			},
-			expectedError: nil,
+			expectedError:  nil,
+			expectedEvents: 1,
 		},
 		"garbage collection leaked instance with providerID successfully": {
 			nodeClaims: []*karpenterv1.NodeClaim{
-				fake.GetNodeClaimObj("agentpool1", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
-				fake.GetNodeClaimObj("agentpool2", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
+				fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
+				fixture.NodeClaim().WithName("agentpool2").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
 			},
 			leakedNodeClaims: []*karpenterv1.NodeClaim{
-				fake.GetNodeClaimObj("agentpool3", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
+				fixture.NodeClaim().WithName("agentpool3").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
 			},
 			mockListAgentPoolResp: func(nodeClaims []*karpenterv1.NodeClaim) *runtime.Pager[armcontainerservice.AgentPoolsClientListResponse] {
 				var agentPools []*armcontainerservice.AgentPool
 				for i := range nodeClaims {
-					ap := fake.CreateAgentPoolObjWithNodeClaim(nodeClaims[i])
+					ap := fixture.AgentPoolFor(nodeClaims[i]).Build()
 					agentPools = append(agentPools, &ap)
 				}
 				return runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
@@ -168,38 +141,92 @@ func TestReconcile(t *testing.T) {
 				p, err := runtime.NewPoller(&resp, runtime.NewPipeline("", "", runtime.PipelineOptions{}, nil), pollingOptions)
 				return p, err
 			},
-			expectedError: nil,
+			expectedError:  nil,
+			expectedEvents: 1,
 		},
-		"failed to garbage collection leaked instance": {
+		"only report leaked instance in dry-run mode": {
 			nodeClaims: []*karpenterv1.NodeClaim{
-				fake.GetNodeClaimObj("agentpool1", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
+				fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
+			},
+			leakedNodeClaims: []*karpenterv1.NodeClaim{
+				fixture.NodeClaim().WithName("agentpool3").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
+			},
+			mockListAgentPoolResp: func(nodeClaims []*karpenterv1.NodeClaim) *runtime.Pager[armcontainerservice.AgentPoolsClientListResponse] {
+				var agentPools []*armcontainerservice.AgentPool
+				for i := range nodeClaims {
+					ap := fixture.AgentPoolFor(nodeClaims[i]).Build()
+					agentPools = append(agentPools, &ap)
+				}
+				return runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
+					More: func(page armcontainerservice.AgentPoolsClientListResponse) bool {
+						return false
 					},
-				}),
-				fake.GetNodeClaimObj("agentpool2", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
+					Fetcher: func(ctx context.Context, page *armcontainerservice.AgentPoolsClientListResponse) (armcontainerservice.AgentPoolsClientListResponse, error) {
+						return armcontainerservice.AgentPoolsClientListResponse{
+							AgentPoolListResult: armcontainerservice.AgentPoolListResult{
+								Value: agentPools,
+							},
+						}, nil
 					},
-				}),
+				})
 			},
-			leakedNodeClaims: []*karpenterv1.NodeClaim{
-				fake.GetNodeClaimObjWithoutProviderID("agentpool3", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
+			dryRun:         true,
+			expectedEvents: 1,
+			expectedError:  nil,
+		},
+		"report instance without registered node": {
+			nodeClaims: []*karpenterv1.NodeClaim{
+				func() *karpenterv1.NodeClaim {
+					nc := fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build()
+					nc.CreationTimestamp = metav1.NewTime(time.Now().Add(-8 * time.Minute))
+					return nc
+				}(),
+				func() *karpenterv1.NodeClaim {
+					nc := fixture.NodeClaim().WithName("agentpool2").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build()
+					nc.CreationTimestamp = metav1.NewTime(time.Now().Add(-8 * time.Minute))
+					nc.StatusConditions().SetTrue(karpenterv1.ConditionTypeRegistered)
+					return nc
+				}(),
+				func() *karpenterv1.NodeClaim {
+					nc := fixture.NodeClaim().WithName("agentpool3").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build()
+					nc.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Minute))
+					return nc
+				}(),
+			},
+			mockListAgentPoolResp: func(nodeClaims []*karpenterv1.NodeClaim) *runtime.Pager[armcontainerservice.AgentPoolsClientListResponse] {
+				var agentPools []*armcontainerservice.AgentPool
+				for i := range nodeClaims {
+					ap := fixture.AgentPoolFor(nodeClaims[i]).Build()
+					agentPools = append(agentPools, &ap)
+				}
+				return runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
+					More: func(page armcontainerservice.AgentPoolsClientListResponse) bool {
+						return false
+					},
+					Fetcher: func(ctx context.Context, page *armcontainerservice.AgentPoolsClientListResponse) (armcontainerservice.AgentPoolsClientListResponse, error) {
+						return armcontainerservice.AgentPoolsClientListResponse{
+							AgentPoolListResult: armcontainerservice.AgentPoolListResult{
+								Value: agentPools,
+							},
+						}, nil
 					},
-				}),
+				})
+			},
+			expectedEvents: 1,
+			expectedError:  nil,
+		},
+		"failed to garbage collection leaked instance": {
+			nodeClaims: []*karpenterv1.NodeClaim{
+				fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
+				fixture.NodeClaim().WithName("agentpool2").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
+			},
+			leakedNodeClaims: []*karpenterv1.NodeClaim{
+				fixture.NodeClaim().WithName("agentpool3").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").WithoutProviderID().Build(),
 			},
 			mockListAgentPoolResp: func(nodeClaims []*karpenterv1.NodeClaim) *runtime.Pager[armcontainerservice.AgentPoolsClientListResponse] {
 				var agentPools []*armcontainerservice.AgentPool
 				for i := range nodeClaims {
-					ap := fake.CreateAgentPoolObjWithNodeClaim(nodeClaims[i])
+					ap := fixture.AgentPoolFor(nodeClaims[i]).Build()
 					agentPools = append(agentPools, &ap)
 				}
 				return runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
@@ -265,11 +292,21 @@ func TestReconcile(t *testing.T) {
 			instanceProvider := instance.NewProvider(mockAzClient, fakeClient, "testRG", "testCluster")
 
 			// create cloud provider
-			cloudProvider := cloudprovider.New(instanceProvider, nil)
+			cloudProvider := cloudprovider.New(instanceProvider, fakeClient, nil)
 
 			// create garbage collection controller
-			c := NewController(fakeClient, cloudProvider)
+			fakeRecorder := record.NewFakeRecorder(10)
+			c := NewController(fakeClient, cloudProvider, events.NewRecorder(fakeRecorder))
+			c.dryRun = tc.dryRun
+			// leaked instances found by previous runs are not counted again.
+			LeakedInstances.With(map[string]string{dryRunLabel: "true"}).Set(5)
 			_, err := c.Reconcile(context.Background())
+			assert.Equal(t, tc.expectedEvents, len(fakeRecorder.Events), "unexpected number of events")
+			if tc.dryRun {
+				leaked := &dto.Metric{}
+				assert.NoError(t, LeakedInstances.With(map[string]string{dryRunLabel: "true"}).Write(leaked))
+				assert.Equal(t, float64(len(tc.leakedNodeClaims)), leaked.GetGauge().GetValue())
+			}
 
 			if tc.expectedError != nil {
 				assert.Contains(t, err.Error(), tc.expectedError.Error())
@@ -279,3 +316,114 @@ func TestReconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestIsDryRun(t *testing.T) {
+	testcases := map[string]struct {
+		dryRun         string
+		until          string
+		expectedDryRun bool
+	}{
+		"dry-run is disabled": {
+			expectedDryRun: false,
+		},
+		"dry-run never expires": {
+			dryRun:         "true",
+			expectedDryRun: true,
+		},
+		"dry-run before the configured end": {
+			dryRun:         "true",
+			until:          time.Now().Add(time.Hour).Format(time.RFC3339),
+			expectedDryRun: true,
+		},
+		"dry-run after the configured end": {
+			dryRun:         "true",
+			until:          time.Now().Add(-time.Hour).Format(time.RFC3339),
+			expectedDryRun: false,
+		},
+		"dry-run with invalid end never expires": {
+			dryRun:         "true",
+			until:          "72h",
+			expectedDryRun: true,
+		},
+		"invalid end turns dry-run on": {
+			until:          "72h",
+			expectedDryRun: true,
+		},
+		"valid end without dry-run is ignored": {
+			until:          time.Now().Add(time.Hour).Format(time.RFC3339),
+			expectedDryRun: false,
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			t.Setenv("GC_DRY_RUN", tc.dryRun)
+			t.Setenv("GC_DRY_RUN_UNTIL", tc.until)
+			assert.Equal(t, tc.expectedDryRun, NewController(nil, nil, nil).isDryRun())
+		})
+	}
+}
+
+func TestReconcileProtected(t *testing.T) {
+	testcases := map[string]struct {
+		tagged    bool
+		annotated bool
+	}{
+		"leaked instance protected by azure tag": {
+			tagged: true,
+		},
+		"leaked instance protected by node annotation": {
+			annotated: true,
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			leaked := fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build()
+			ap := fixture.AgentPoolFor(leaked).Build()
+			if tc.tagged {
+				ap.Properties.Tags = map[string]*string{instance.GCTagKey: lo.ToPtr(instance.GCTagDisabled)}
+			}
+
+			// BeginDelete is never called for protected instances.
+			agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+			agentPoolMocks.EXPECT().NewListPager(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+				runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
+					More: func(page armcontainerservice.AgentPoolsClientListResponse) bool {
+						return false
+					},
+					Fetcher: func(ctx context.Context, page *armcontainerservice.AgentPoolsClientListResponse) (armcontainerservice.AgentPoolsClientListResponse, error) {
+						return armcontainerservice.AgentPoolsClientListResponse{
+							AgentPoolListResult: armcontainerservice.AgentPoolListResult{
+								Value: []*armcontainerservice.AgentPool{&ap},
+							},
+						}, nil
+					},
+				}))
+
+			nodeList := fake.CreateNodeListWithNodeClaim([]*karpenterv1.NodeClaim{leaked})
+			if tc.annotated {
+				nodeList.Items[0].Annotations = map[string]string{karpenterv1.DoNotDisruptAnnotationKey: "true"}
+			}
+			fakeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithRuntimeObjects(&nodeList.Items[0]).
+				WithIndex(&v1.Node{}, "spec.providerID", func(o client.Object) []string {
+					return []string{o.(*v1.Node).Spec.ProviderID}
+				}).
+				Build()
+
+			instanceProvider := instance.NewProvider(instance.NewAZClientFromAPI(agentPoolMocks), fakeClient, "testRG", "testCluster")
+			cloudProvider := cloudprovider.New(instanceProvider, fakeClient, nil)
+
+			fakeRecorder := record.NewFakeRecorder(10)
+			c := NewController(fakeClient, cloudProvider, events.NewRecorder(fakeRecorder))
+			_, err := c.Reconcile(context.Background())
+			assert.NoError(t, err, "expect no error but got one")
+			assert.Len(t, fakeRecorder.Events, 1)
+			assert.Contains(t, <-fakeRecorder.Events, "GarbageCollectionProtected")
+		})
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package garbagecollection
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	corev1 "k8s.io/api/core/v1"
+	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/events"
+)
+
+func DryRunGarbageCollectionEvent(nodeClaim *v1.NodeClaim) events.Event {
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           corev1.EventTypeWarning,
+		Reason:         "GarbageCollectionDryRun",
+		Message:        fmt.Sprintf("Leaked instance %s would be deleted, skipped because garbage collection is in dry-run mode", nodeClaim.Name),
+		DedupeValues:   []string{nodeClaim.Name},
+	}
+}
+
+func UnregisteredInstanceEvent(nodeClaim *v1.NodeClaim, age time.Duration) events.Event {
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           corev1.EventTypeWarning,
+		Reason:         "UnregisteredInstance",
+		Message:        fmt.Sprintf("agent pool %s has no registered node for %s", nodeClaim.Name, age.Truncate(time.Minute)),
+		DedupeValues:   []string{nodeClaim.Name},
+	}
+}
+
+func ProtectedGarbageCollectionEvent(nodeClaim *v1.NodeClaim) events.Event {
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           corev1.EventTypeNormal,
+		Reason:         "GarbageCollectionProtected",
+		Message:        fmt.Sprintf("Leaked instance %s is not deleted, it's protected by the %s=%s tag or the %s annotation", nodeClaim.Name, instance.GCTagKey, instance.GCTagDisabled, v1.DoNotDisruptAnnotationKey),
+		DedupeValues:   []string{nodeClaim.Name},
+	}
+}
@@ -0,0 +1,42 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package garbagecollection
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+const (
+	garbageCollectionSubsystem = "instance_garbagecollection"
+	dryRunLabel                = "dry_run"
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(LeakedInstances)
+}
+
+var LeakedInstances = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: garbageCollectionSubsystem,
+		Name:      "leaked_instances",
+		Help:      "Number of leaked instances found by the latest run of the instance garbage collection controller. Labeled by whether the controller ran in dry-run mode.",
+	},
+	[]string{dryRunLabel},
+)
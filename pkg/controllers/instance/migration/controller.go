@@ -0,0 +1,123 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"context"
+	"time"
+
+	"github.com/awslabs/operatorpkg/singleton"
+	"github.com/azure/gpu-provisioner/pkg/controllers/middleware"
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/azure/gpu-provisioner/pkg/utils"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	"k8s.io/apimachinery/pkg/types"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/operator/injection"
+)
+
+// Controller backfills the metadata(creation timestamp label and ownership tags) for agentpools
+// created by older releases, so garbage collection and drift logic behave correctly after upgrades.
+type Controller struct {
+	kubeClient       client.Client
+	instanceProvider *instance.Provider
+	// interval is the time between two migration runs.
+	interval time.Duration
+	// pageSize is the max number of agentpools listed at once.
+	pageSize int
+}
+
+func NewController(kubeClient client.Client, instanceProvider *instance.Provider) *Controller {
+	return &Controller{
+		kubeClient:       kubeClient,
+		instanceProvider: instanceProvider,
+		interval:         utils.WithDefaultDuration("MIGRATION_INTERVAL", 10*time.Minute),
+		pageSize:         utils.WithDefaultInt("MIGRATION_PAGE_SIZE", 100),
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context) (reconcile.Result, error) {
+	ctx = injection.WithControllerName(ctx, "instance.migration")
+
+	// agentpools created by older releases have no ownership tags, so they have to be listed from ARM.
+	opts := instance.ListOptions{PageSize: c.pageSize, IncludeUntagged: true}
+	var errs error
+	for {
+		instances, next, err := c.instanceProvider.ListPage(ctx, opts)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		errs = multierr.Append(errs, c.migrate(ctx, instances))
+		if next == "" {
+			break
+		}
+		opts.Continue = next
+	}
+
+	return reconcile.Result{RequeueAfter: c.interval}, errs
+}
+
+// migrate backfills the metadata of the instances which need migration.
+func (c *Controller) migrate(ctx context.Context, instances []*instance.Instance) error {
+	instances = lo.Filter(instances, func(ins *instance.Instance, _ int) bool {
+		return instance.InstanceNeedsMigration(ins)
+	})
+	log.FromContext(ctx).Info("instance migration status", "migrated instance count", len(instances))
+
+	var errs error
+	for i := range instances {
+		apName := lo.FromPtr(instances[i].Name)
+		creationTime, err := c.creationTime(ctx, instance.NodeClaimName(instances[i]))
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+
+		if err := c.instanceProvider.BackfillOwnershipMetadata(ctx, apName, creationTime); err != nil {
+			log.FromContext(ctx).Error(err, "failed to backfill ownership metadata", "agentpool", apName)
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		log.FromContext(ctx).Info("backfill ownership metadata successfully", "agentpool", apName)
+	}
+	return errs
+}
+
+// creationTime returns the creation timestamp of the NodeClaim related to the agentpool. if the NodeClaim
+// doesn't exist, current time is used in order to give the agentpool a full grace period before garbage collection.
+func (c *Controller) creationTime(ctx context.Context, nodeClaimName string) (time.Time, error) {
+	nodeClaim := &v1.NodeClaim{}
+	if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: nodeClaimName}, nodeClaim); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return time.Time{}, err
+		}
+		return time.Now(), nil
+	}
+	return nodeClaim.CreationTimestamp.Time, nil
+}
+
+func (c *Controller) Register(_ context.Context, m manager.Manager) error {
+	return controllerruntime.NewControllerManagedBy(m).
+		Named("instance.migration").
+		WatchesRawSource(singleton.Source()).
+		Complete(middleware.Wrap("instance.migration", singleton.AsReconciler(c)))
+}
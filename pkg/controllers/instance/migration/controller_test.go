@@ -0,0 +1,165 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func TestReconcile(t *testing.T) {
+	testcases := map[string]struct {
+		nodeClaim         *karpenterv1.NodeClaim
+		migrated          bool
+		expectedMigration bool
+	}{
+		"backfill metadata for agentpool created by older release": {
+			nodeClaim:         fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
+			migrated:          false,
+			expectedMigration: true,
+		},
+		"skip agentpool which has been migrated": {
+			nodeClaim:         fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build(),
+			migrated:          true,
+			expectedMigration: false,
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			// NodeClaim is cluster scoped
+			tc.nodeClaim.Namespace = ""
+			tc.nodeClaim.CreationTimestamp = metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+			ap := fixture.AgentPoolFor(tc.nodeClaim).Build()
+			if tc.migrated {
+				ap.Properties.NodeLabels[instance.NodeClaimCreationLabel] = to.Ptr("2024-01-01T00-00-00Z")
+				ap.Properties.Tags = map[string]*string{instance.ManagedByTagKey: to.Ptr(instance.ManagedByTagValue)}
+			}
+
+			agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+			agentPoolMocks.EXPECT().NewListPager(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+				runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
+					More: func(page armcontainerservice.AgentPoolsClientListResponse) bool {
+						return false
+					},
+					Fetcher: func(ctx context.Context, page *armcontainerservice.AgentPoolsClientListResponse) (armcontainerservice.AgentPoolsClientListResponse, error) {
+						return armcontainerservice.AgentPoolsClientListResponse{
+							AgentPoolListResult: armcontainerservice.AgentPoolListResult{
+								Value: []*armcontainerservice.AgentPool{&ap},
+							},
+						}, nil
+					},
+				}))
+
+			if tc.expectedMigration {
+				agentPoolMocks.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), tc.nodeClaim.Name, gomock.Any()).
+					Return(armcontainerservice.AgentPoolsClientGetResponse{AgentPool: ap}, nil)
+
+				mockHandler := fake.NewMockPollingHandler[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse](mockCtrl)
+				mockHandler.EXPECT().Done().Return(true).Times(3)
+				mockHandler.EXPECT().Result(gomock.Any(), gomock.Any()).Return(nil)
+				agentPoolMocks.EXPECT().BeginCreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), tc.nodeClaim.Name, gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _, _, _ string, parameters armcontainerservice.AgentPool, _ *armcontainerservice.AgentPoolsClientBeginCreateOrUpdateOptions) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error) {
+						assert.Equal(t, tc.nodeClaim.CreationTimestamp.UTC().Format(instance.CreationTimestampLayout), lo.FromPtr(parameters.Properties.NodeLabels[instance.NodeClaimCreationLabel]))
+						assert.Equal(t, instance.ManagedByTagValue, lo.FromPtr(parameters.Properties.Tags[instance.ManagedByTagKey]))
+
+						resp := http.Response{StatusCode: http.StatusOK, Body: http.NoBody}
+						return runtime.NewPoller(&resp, runtime.NewPipeline("", "", runtime.PipelineOptions{}, nil), &runtime.NewPollerOptions[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse]{
+							Handler:  mockHandler,
+							Response: &armcontainerservice.AgentPoolsClientCreateOrUpdateResponse{AgentPool: parameters},
+						})
+					})
+			}
+
+			nodeList := fake.CreateNodeListWithNodeClaim([]*karpenterv1.NodeClaim{tc.nodeClaim})
+			nodes := lo.FilterMap(nodeList.Items, func(node v1.Node, _ int) (k8sruntime.Object, bool) {
+				return &node, true
+			})
+			fakeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithRuntimeObjects(nodes...).
+				WithRuntimeObjects(tc.nodeClaim).
+				Build()
+
+			instanceProvider := instance.NewProvider(instance.NewAZClientFromAPI(agentPoolMocks), fakeClient, "testRG", "testCluster")
+
+			c := NewController(fakeClient, instanceProvider)
+			_, err := c.Reconcile(context.Background())
+			assert.NoError(t, err, "expect no error but got one")
+		})
+	}
+}
+
+func TestReconcilePages(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var agentPools []*armcontainerservice.AgentPool
+	for _, name := range []string{"agentpool1", "agentpool2"} {
+		nodeClaim := fixture.NodeClaim().WithName(name).WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build()
+		ap := fixture.AgentPoolFor(nodeClaim).Build()
+		ap.Properties.NodeLabels[instance.NodeClaimCreationLabel] = to.Ptr("2024-01-01T00-00-00Z")
+		ap.Properties.Tags = map[string]*string{instance.ManagedByTagKey: to.Ptr(instance.ManagedByTagValue)}
+		agentPools = append(agentPools, &ap)
+	}
+
+	// every page lists the agentpools from ARM again.
+	agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+	agentPoolMocks.EXPECT().NewListPager(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_, _ string, _ *armcontainerservice.AgentPoolsClientListOptions) *runtime.Pager[armcontainerservice.AgentPoolsClientListResponse] {
+			return runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
+				More: func(page armcontainerservice.AgentPoolsClientListResponse) bool {
+					return false
+				},
+				Fetcher: func(ctx context.Context, page *armcontainerservice.AgentPoolsClientListResponse) (armcontainerservice.AgentPoolsClientListResponse, error) {
+					return armcontainerservice.AgentPoolsClientListResponse{
+						AgentPoolListResult: armcontainerservice.AgentPoolListResult{
+							Value: agentPools,
+						},
+					}, nil
+				},
+			})
+		}).Times(2)
+
+	fakeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	instanceProvider := instance.NewProvider(instance.NewAZClientFromAPI(agentPoolMocks), fakeClient, "testRG", "testCluster")
+
+	c := NewController(fakeClient, instanceProvider)
+	c.pageSize = 1
+	_, err := c.Reconcile(context.Background())
+	assert.NoError(t, err, "expect no error but got one")
+}
@@ -0,0 +1,206 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sweeper
+
+import (
+	"context"
+	"time"
+
+	"github.com/awslabs/operatorpkg/singleton"
+	"github.com/azure/gpu-provisioner/pkg/controllers/middleware"
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/azure/gpu-provisioner/pkg/utils"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/types"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/events"
+	"sigs.k8s.io/karpenter/pkg/operator/injection"
+)
+
+const (
+	stateCreating = "Creating"
+	stateDeleting = "Deleting"
+
+	// DeletingSinceAnnotationKey records on the nodeclaim when the sweeper first observed its agentpool in Deleting
+	// state, so the age of a stuck deletion survives restarts and leader failovers.
+	DeletingSinceAnnotationKey = "kaito.sh/deleting-since"
+)
+
+// Controller sweeps agentpools whose operation is stuck in Creating or Deleting provisioning state for longer than
+// maxOperationDuration. a stuck creation is aborted and a stuck deletion is retried once, the operation is escalated
+// with a warning event for manual intervention if it can't be remediated or is still stuck after the remediation.
+type Controller struct {
+	kubeClient       client.Client
+	instanceProvider *instance.Provider
+	recorder         events.Recorder
+
+	// maxOperationDuration is the time after which an agentpool operation is considered stuck.
+	maxOperationDuration time.Duration
+	// interval is the time between two sweeper runs.
+	interval time.Duration
+
+	// operations are the in-progress agentpool operations observed by the sweeper by agentpool name.
+	operations map[string]*operation
+}
+
+// operation is an in-progress agentpool operation.
+type operation struct {
+	state string
+	// since is when the operation is known to be in progress.
+	since      time.Time
+	remediated bool
+	escalated  bool
+}
+
+func NewController(kubeClient client.Client, instanceProvider *instance.Provider, recorder events.Recorder) *Controller {
+	return &Controller{
+		kubeClient:           kubeClient,
+		instanceProvider:     instanceProvider,
+		recorder:             recorder,
+		maxOperationDuration: utils.WithDefaultDuration("SWEEPER_MAX_OPERATION_DURATION", time.Hour),
+		interval:             utils.WithDefaultDuration("SWEEPER_INTERVAL", 5*time.Minute),
+		operations:           map[string]*operation{},
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context) (reconcile.Result, error) {
+	ctx = injection.WithControllerName(ctx, "instance.sweeper")
+
+	instances, err := c.instanceProvider.List(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	nodeClaimList := &v1.NodeClaimList{}
+	if err := c.kubeClient.List(ctx, nodeClaimList); err != nil {
+		return reconcile.Result{}, err
+	}
+	nodeClaims := lo.SliceToMap(nodeClaimList.Items, func(nc v1.NodeClaim) (string, *v1.NodeClaim) { return nc.Name, &nc })
+
+	inProgress := map[string]string{}
+	for i := range instances {
+		apName, state := lo.FromPtr(instances[i].Name), lo.FromPtr(instances[i].State)
+		if state != stateCreating && state != stateDeleting {
+			continue
+		}
+		inProgress[apName] = state
+		op := c.observe(ctx, instances[i], apName, state, nodeClaims[instance.NodeClaimName(instances[i])])
+		if age := time.Since(op.since); age >= c.maxOperationDuration {
+			c.sweep(ctx, instances[i], apName, op, age)
+		}
+	}
+	// operations which completed are forgotten.
+	for apName := range c.operations {
+		if _, ok := inProgress[apName]; !ok {
+			delete(c.operations, apName)
+		}
+	}
+	for _, nodeClaim := range nodeClaims {
+		if _, ok := nodeClaim.Annotations[DeletingSinceAnnotationKey]; ok && inProgress[instance.AgentPoolName(nodeClaim)] != stateDeleting {
+			c.patchDeletingSince(ctx, nodeClaim, nil)
+		}
+	}
+	return reconcile.Result{RequeueAfter: c.interval}, nil
+}
+
+// observe returns the in-progress operation of the agentpool, a new one is recorded if the state changed.
+func (c *Controller) observe(ctx context.Context, ins *instance.Instance, apName, state string, nodeClaim *v1.NodeClaim) *operation {
+	if op, ok := c.operations[apName]; ok && op.state == state {
+		return op
+	}
+	op := &operation{state: state, since: time.Now()}
+	switch state {
+	case stateCreating:
+		// the creation started when the agentpool was created, which may be long before the sweeper saw it.
+		if created, err := time.Parse(instance.CreationTimestampLayout, ins.Labels[instance.NodeClaimCreationLabel]); err == nil && created.Before(op.since) {
+			op.since = created
+		}
+	case stateDeleting:
+		// the deletion is observed since the time persisted on the nodeclaim, agentpools without a nodeclaim are
+		// only observed in memory.
+		if nodeClaim == nil {
+			break
+		}
+		if since, err := time.Parse(time.RFC3339, nodeClaim.Annotations[DeletingSinceAnnotationKey]); err == nil && since.Before(op.since) {
+			op.since = since
+		} else {
+			c.patchDeletingSince(ctx, nodeClaim, &op.since)
+		}
+	}
+	c.operations[apName] = op
+	return op
+}
+
+// patchDeletingSince persists the time the deletion of the agentpool of the nodeclaim was first observed, nil
+// clears it.
+func (c *Controller) patchDeletingSince(ctx context.Context, nodeClaim *v1.NodeClaim, since *time.Time) {
+	stored := nodeClaim.DeepCopy()
+	if since == nil {
+		delete(nodeClaim.Annotations, DeletingSinceAnnotationKey)
+	} else {
+		nodeClaim.Annotations = lo.Assign(nodeClaim.Annotations, map[string]string{DeletingSinceAnnotationKey: since.UTC().Format(time.RFC3339)})
+	}
+	if err := c.kubeClient.Patch(ctx, nodeClaim, client.MergeFrom(stored)); client.IgnoreNotFound(err) != nil {
+		log.FromContext(ctx).Error(err, "failed to patch the deletion start of agentpool on nodeclaim", "nodeClaim", nodeClaim.Name)
+	}
+}
+
+// sweep remediates the stuck operation once, and escalates it if the remediation failed or didn't help.
+func (c *Controller) sweep(ctx context.Context, ins *instance.Instance, apName string, op *operation, age time.Duration) {
+	if !op.remediated {
+		op.remediated = true
+		var err error
+		if op.state == stateCreating {
+			err = c.instanceProvider.AbortOperation(ctx, apName)
+		} else {
+			err = c.instanceProvider.RetryDeletion(ctx, apName)
+		}
+		if err == nil {
+			log.FromContext(ctx).Info("remediated stuck agentpool operation", "agentpool", apName, "state", op.state, "age", age.Round(time.Second))
+			StuckOperationsTotal.With(map[string]string{stateLabel: op.state, actionLabel: actionRemediated}).Inc()
+			return
+		}
+		log.FromContext(ctx).Error(err, "failed to remediate stuck agentpool operation", "agentpool", apName, "state", op.state)
+	} else if age < 2*c.maxOperationDuration {
+		// give the remediation the same time to take effect before escalating.
+		return
+	}
+	if op.escalated {
+		return
+	}
+	op.escalated = true
+	log.FromContext(ctx).Info("stuck agentpool operation needs manual intervention", "agentpool", apName, "state", op.state, "age", age.Round(time.Second))
+	StuckOperationsTotal.With(map[string]string{stateLabel: op.state, actionLabel: actionEscalated}).Inc()
+
+	nodeClaim := &v1.NodeClaim{}
+	if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: instance.NodeClaimName(ins)}, nodeClaim); err != nil {
+		// the agentpool has no nodeclaim to publish the event on, it's only logged.
+		return
+	}
+	c.recorder.Publish(ManualInterventionRequiredEvent(nodeClaim, op.state, age))
+}
+
+func (c *Controller) Register(_ context.Context, m manager.Manager) error {
+	return controllerruntime.NewControllerManagedBy(m).
+		Named("instance.sweeper").
+		WatchesRawSource(singleton.Source()).
+		Complete(middleware.Wrap("instance.sweeper", singleton.AsReconciler(c)))
+}
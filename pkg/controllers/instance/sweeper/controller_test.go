@@ -0,0 +1,186 @@
+/*
+	Copyright (c) Microsoft Corporation.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sweeper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/events"
+)
+
+func TestReconcile(t *testing.T) {
+	longAgo := time.Now().Add(-3 * time.Hour).UTC().Format(instance.CreationTimestampLayout)
+	testcases := map[string]struct {
+		state          string
+		creationLabel  string
+		apName         string
+		deletingSince  string
+		abortErr       error
+		expectedAbort  bool
+		expectedRetry  bool
+		expectedEvents int
+	}{
+		"abort stuck creation": {
+			state:         stateCreating,
+			creationLabel: longAgo,
+			expectedAbort: true,
+		},
+		"escalate stuck creation which can't be aborted": {
+			state:          stateCreating,
+			creationLabel:  longAgo,
+			abortErr:       fmt.Errorf("abort failed"),
+			expectedAbort:  true,
+			expectedEvents: 1,
+		},
+		"escalate stuck creation of agentpool not named after its nodeclaim": {
+			state:          stateCreating,
+			creationLabel:  longAgo,
+			apName:         "gpu0",
+			abortErr:       fmt.Errorf("abort failed"),
+			expectedAbort:  true,
+			expectedEvents: 1,
+		},
+		"skip recent creation": {
+			state:         stateCreating,
+			creationLabel: time.Now().UTC().Format(instance.CreationTimestampLayout),
+		},
+		"skip deletion observed for the first time": {
+			state:         stateDeleting,
+			creationLabel: longAgo,
+		},
+		"retry stuck deletion observed before a restart": {
+			state:         stateDeleting,
+			creationLabel: longAgo,
+			deletingSince: time.Now().Add(-3 * time.Hour).UTC().Format(time.RFC3339),
+			expectedRetry: true,
+		},
+		"skip deletion observed recently before a restart": {
+			state:         stateDeleting,
+			creationLabel: longAgo,
+			deletingSince: time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+		},
+		"skip succeeded agentpool": {
+			state:         "Succeeded",
+			creationLabel: longAgo,
+		},
+		"clear deletion start of agentpool which is not deleting": {
+			state:         "Failed",
+			creationLabel: longAgo,
+			deletingSince: time.Now().Add(-3 * time.Hour).UTC().Format(time.RFC3339),
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			nodeClaim := fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build()
+			// NodeClaim is cluster scoped
+			nodeClaim.Namespace = ""
+			if tc.deletingSince != "" {
+				nodeClaim.Annotations = map[string]string{DeletingSinceAnnotationKey: tc.deletingSince}
+			}
+			ap := fixture.AgentPoolFor(nodeClaim).Build()
+			ap.Properties.ProvisioningState = to.Ptr(tc.state)
+			ap.Properties.NodeLabels[instance.NodeClaimCreationLabel] = to.Ptr(tc.creationLabel)
+			apName := nodeClaim.Name
+			if tc.apName != "" {
+				apName = tc.apName
+				ap.Name = to.Ptr(apName)
+				ap.Properties.Tags = map[string]*string{instance.NodeClaimTagKey: to.Ptr(nodeClaim.Name)}
+			}
+
+			agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+			agentPoolMocks.EXPECT().NewListPager(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+				runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
+					More: func(page armcontainerservice.AgentPoolsClientListResponse) bool {
+						return false
+					},
+					Fetcher: func(ctx context.Context, page *armcontainerservice.AgentPoolsClientListResponse) (armcontainerservice.AgentPoolsClientListResponse, error) {
+						return armcontainerservice.AgentPoolsClientListResponse{
+							AgentPoolListResult: armcontainerservice.AgentPoolListResult{
+								Value: []*armcontainerservice.AgentPool{&ap},
+							},
+						}, nil
+					},
+				}))
+			if tc.expectedAbort {
+				agentPoolMocks.EXPECT().BeginAbortLatestOperation(gomock.Any(), gomock.Any(), gomock.Any(), apName, gomock.Any()).
+					DoAndReturn(func(_ context.Context, _, _, _ string, _ *armcontainerservice.AgentPoolsClientBeginAbortLatestOperationOptions) (*runtime.Poller[armcontainerservice.AgentPoolsClientAbortLatestOperationResponse], error) {
+						if tc.abortErr != nil {
+							return nil, tc.abortErr
+						}
+						mockHandler := fake.NewMockPollingHandler[armcontainerservice.AgentPoolsClientAbortLatestOperationResponse](mockCtrl)
+						mockHandler.EXPECT().Done().Return(true).AnyTimes()
+						mockHandler.EXPECT().Result(gomock.Any(), gomock.Any()).Return(nil)
+						resp := http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}
+						return runtime.NewPoller(&resp, runtime.NewPipeline("", "", runtime.PipelineOptions{}, nil), &runtime.NewPollerOptions[armcontainerservice.AgentPoolsClientAbortLatestOperationResponse]{
+							Handler:  mockHandler,
+							Response: &armcontainerservice.AgentPoolsClientAbortLatestOperationResponse{},
+						})
+					})
+			}
+
+			if tc.expectedRetry {
+				agentPoolMocks.EXPECT().BeginDelete(gomock.Any(), gomock.Any(), gomock.Any(), apName, gomock.Any()).Return(nil, nil)
+			}
+
+			fakeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(nodeClaim).Build()
+			fakeRecorder := record.NewFakeRecorder(10)
+			instanceProvider := instance.NewProvider(instance.NewAZClientFromAPI(agentPoolMocks), fakeClient, "testRG", "testCluster")
+
+			c := NewController(fakeClient, instanceProvider, events.NewRecorder(fakeRecorder))
+			_, err := c.Reconcile(context.Background())
+			assert.NoError(t, err, "expect no error but got one")
+			assert.Len(t, fakeRecorder.Events, tc.expectedEvents)
+			if tc.expectedEvents != 0 {
+				assert.Contains(t, <-fakeRecorder.Events, "ManualInterventionRequired")
+			}
+			if tc.state == stateCreating || tc.state == stateDeleting {
+				assert.Contains(t, c.operations, apName)
+			} else {
+				assert.Empty(t, c.operations)
+			}
+
+			stored := &v1.NodeClaim{}
+			assert.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(nodeClaim), stored))
+			deletingSince, persisted := stored.Annotations[DeletingSinceAnnotationKey]
+			assert.Equal(t, tc.state == stateDeleting, persisted, "the deletion start is persisted while the agentpool is deleting")
+			if tc.deletingSince != "" && persisted {
+				assert.Equal(t, tc.deletingSince, deletingSince, "the persisted deletion start is kept")
+			}
+		})
+	}
+}
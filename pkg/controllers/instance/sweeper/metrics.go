@@ -0,0 +1,45 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sweeper
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+const (
+	sweeperSubsystem = "instance_sweeper"
+	stateLabel       = "state"
+	actionLabel      = "action"
+
+	actionRemediated = "remediated"
+	actionEscalated  = "escalated"
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(StuckOperationsTotal)
+}
+
+var StuckOperationsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: sweeperSubsystem,
+		Name:      "stuck_operations_total",
+		Help:      "Number of stuck agentpool operations handled by the sweeper. Labeled by provisioning state and action(remediated or escalated).",
+	},
+	[]string{stateLabel, actionLabel},
+)
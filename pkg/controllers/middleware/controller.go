@@ -0,0 +1,70 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/awslabs/operatorpkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// WrapControllers wraps the reconcilers of controllers which register themselves with controller-runtime, e.g. the
+// karpenter controllers, as Wrap does. the controllers of gpu-provisioner wrap their reconcilers when they register.
+func WrapControllers(controllers ...controller.Controller) []controller.Controller {
+	wrapped := make([]controller.Controller, 0, len(controllers))
+	for _, c := range controllers {
+		wrapped = append(wrapped, wrappedController{Controller: c})
+	}
+	return wrapped
+}
+
+type wrappedController struct {
+	controller.Controller
+}
+
+func (c wrappedController) Register(ctx context.Context, m manager.Manager) error {
+	return c.Controller.Register(ctx, &wrappingManager{Manager: m})
+}
+
+// wrappingManager wraps the reconcilers of the controllers added to the manager.
+type wrappingManager struct {
+	manager.Manager
+}
+
+func (m *wrappingManager) Add(r manager.Runnable) error {
+	wrapReconciler(r)
+	return m.Manager.Add(r)
+}
+
+var reconcilerType = reflect.TypeOf((*reconcile.Reconciler)(nil)).Elem()
+
+// wrapReconciler wraps the reconciler of a controller built by controller-runtime, which is added to the manager
+// before it's started. controller-runtime exposes no hook to wrap the reconciler passed to a builder, so the
+// exported Name and Do fields of its controller are used, other runnables are left as they are.
+func wrapReconciler(r manager.Runnable) {
+	v := reflect.ValueOf(r)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+	name, do := v.Elem().FieldByName("Name"), v.Elem().FieldByName("Do")
+	if name.Kind() != reflect.String || !do.IsValid() || do.Type() != reconcilerType || !do.CanSet() || do.IsNil() {
+		return
+	}
+	do.Set(reflect.ValueOf(Wrap(name.String(), do.Interface().(reconcile.Reconciler))))
+}
@@ -0,0 +1,77 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/config"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+type fakeManager struct {
+	manager.Manager
+	runnables []manager.Runnable
+}
+
+func (m *fakeManager) Add(r manager.Runnable) error {
+	m.runnables = append(m.runnables, r)
+	return nil
+}
+
+func (m *fakeManager) GetLogger() logr.Logger {
+	return logr.Discard()
+}
+
+func (m *fakeManager) GetControllerOptions() config.Controller {
+	return config.Controller{}
+}
+
+// panickingController registers a controller-runtime controller whose reconciler panics, and a plain runnable.
+type panickingController struct{}
+
+func (panickingController) Register(_ context.Context, m manager.Manager) error {
+	if _, err := crcontroller.New("test.panics", m, crcontroller.Options{
+		Reconciler: reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+			panic("boom")
+		}),
+	}); err != nil {
+		return err
+	}
+	return m.Add(manager.RunnableFunc(func(context.Context) error { return nil }))
+}
+
+func TestWrapControllers(t *testing.T) {
+	mgr := &fakeManager{}
+	for _, c := range WrapControllers(panickingController{}) {
+		assert.NoError(t, c.Register(context.Background(), mgr))
+	}
+	assert.Len(t, mgr.runnables, 2)
+
+	// the reconciler of the controller is wrapped, the plain runnable is added as it is.
+	_, err := mgr.runnables[0].(crcontroller.Controller).Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "nc"}})
+	assert.ErrorContains(t, err, "reconciler test.panics panicked")
+	panics := &dto.Metric{}
+	assert.NoError(t, ReconcilePanicsTotal.With(map[string]string{controllerLabel: "test.panics"}).Write(panics))
+	assert.Equal(t, float64(1), panics.GetCounter().GetValue())
+}
@@ -0,0 +1,67 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+const (
+	controllerSubsystem = "controller"
+	controllerLabel     = "controller"
+	resultLabel         = "result"
+
+	resultSuccess = "success"
+	resultRequeue = "requeue"
+	resultError   = "error"
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(ReconcileDuration, ReconcilePanicsTotal, ReconcilesShedTotal)
+}
+
+var ReconcileDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: controllerSubsystem,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of reconciles of the gpu-provisioner and karpenter controllers. Labeled by controller and result(success, requeue or error).",
+		Buckets:   metrics.DurationBuckets(),
+	},
+	[]string{controllerLabel, resultLabel},
+)
+
+var ReconcilePanicsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: controllerSubsystem,
+		Name:      "reconcile_panics_total",
+		Help:      "Number of recovered panics of the gpu-provisioner and karpenter controllers. Labeled by controller.",
+	},
+	[]string{controllerLabel},
+)
+
+var ReconcilesShedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: controllerSubsystem,
+		Name:      "reconciles_shed_total",
+		Help:      "Number of reconciles of low priority controllers deferred under apiserver pressure. Labeled by controller.",
+	},
+	[]string{controllerLabel},
+)
@@ -0,0 +1,103 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/azure/gpu-provisioner/pkg/utils/pressure"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+)
+
+// Wrap returns a reconciler which runs r with panic recovery and timing metrics, and maps the error of r to a
+// requeue with the policy shared by all controllers:
+//   - not found errors of request scoped reconcilers are ignored, the object or agentpool is gone and a new event
+//     triggers the next reconcile. singleton reconcilers are triggered by one event only, so their not found errors
+//     are returned and requeued with backoff like other errors, otherwise the loop would stop for good.
+//   - conflicts are requeued without logging an error, the next reconcile reads the latest object.
+//   - cluster upgrading errors are requeued after the cluster state cache ttl instead of backing off.
+//   - panics and other errors are returned, so the request is requeued with backoff.
+func Wrap(name string, r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (result reconcile.Result, err error) {
+		start := time.Now()
+		defer func() {
+			if p := recover(); p != nil {
+				log.FromContext(ctx).Error(fmt.Errorf("%v", p), "reconciler panicked", "controller", name, "stack", string(debug.Stack()))
+				ReconcilePanicsTotal.With(map[string]string{controllerLabel: name}).Inc()
+				result, err = reconcile.Result{}, fmt.Errorf("reconciler %s panicked, %v", name, p)
+			}
+			ReconcileDuration.With(map[string]string{controllerLabel: name, resultLabel: resultOf(result, err)}).Observe(time.Since(start).Seconds())
+		}()
+		result, err = r.Reconcile(ctx, req)
+		return requeuePolicy(ctx, name, req, result, err)
+	})
+}
+
+// WrapLowPriority is Wrap for maintenance loops which can be delayed, e.g. garbage collection. while the apiserver
+// throttles gpu-provisioner their reconciles are skipped and requeued after the pressure cooldown, so the requests
+// of provisioning keep flowing.
+func WrapLowPriority(name string, r reconcile.Reconciler) reconcile.Reconciler {
+	return wrapLowPriority(name, r, pressure.Default)
+}
+
+func wrapLowPriority(name string, r reconcile.Reconciler, detector *pressure.Detector) reconcile.Reconciler {
+	return Wrap(name, reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		if detector.UnderPressure() {
+			log.FromContext(ctx).V(1).Info("reconcile is deferred under apiserver pressure", "controller", name)
+			ReconcilesShedTotal.With(map[string]string{controllerLabel: name}).Inc()
+			return reconcile.Result{RequeueAfter: detector.Cooldown()}, nil
+		}
+		return r.Reconcile(ctx, req)
+	}))
+}
+
+func requeuePolicy(ctx context.Context, name string, req reconcile.Request, result reconcile.Result, err error) (reconcile.Result, error) {
+	switch {
+	case err == nil:
+		return result, nil
+	case !isSingleton(req) && (apierrors.IsNotFound(err) || cloudprovider.IsNodeClaimNotFoundError(err)):
+		return result, nil
+	case apierrors.IsConflict(err):
+		return reconcile.Result{Requeue: true}, nil
+	case instance.IsClusterUpgradingError(err):
+		// agent pool operations conflict with the cluster upgrade, retry after the upgrade instead of backing off.
+		log.FromContext(ctx).Info("reconcile is deferred during cluster upgrade", "controller", name, "reason", err.Error())
+		return reconcile.Result{RequeueAfter: instance.ClusterStateCacheTTL}, nil
+	}
+	return reconcile.Result{}, err
+}
+
+// isSingleton returns true if the request is sent by singleton.Source, which has no object.
+func isSingleton(req reconcile.Request) bool {
+	return req == reconcile.Request{}
+}
+
+func resultOf(result reconcile.Result, err error) string {
+	switch {
+	case err != nil:
+		return resultError
+	case result.Requeue || result.RequeueAfter > 0:
+		return resultRequeue
+	}
+	return resultSuccess
+}
@@ -0,0 +1,135 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/azure/gpu-provisioner/pkg/utils/pressure"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+)
+
+func TestWrap(t *testing.T) {
+	nodeClaimRequest := reconcile.Request{NamespacedName: types.NamespacedName{Name: "nc"}}
+	testcases := map[string]struct {
+		request        *reconcile.Request
+		reconcile      func() (reconcile.Result, error)
+		expectedResult reconcile.Result
+		expectedError  string
+	}{
+		"success": {
+			reconcile: func() (reconcile.Result, error) {
+				return reconcile.Result{RequeueAfter: 10}, nil
+			},
+			expectedResult: reconcile.Result{RequeueAfter: 10},
+		},
+		"object not found is ignored": {
+			request: &nodeClaimRequest,
+			reconcile: func() (reconcile.Result, error) {
+				return reconcile.Result{}, fmt.Errorf("patching, %w", apierrors.NewNotFound(schema.GroupResource{Resource: "nodeclaims"}, "nc"))
+			},
+		},
+		"nodeclaim not found is ignored": {
+			request: &nodeClaimRequest,
+			reconcile: func() (reconcile.Result, error) {
+				return reconcile.Result{}, cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("agentpool not found"))
+			},
+		},
+		"not found of singleton is returned": {
+			reconcile: func() (reconcile.Result, error) {
+				return reconcile.Result{}, fmt.Errorf("writing consistency report, %w", apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "report"))
+			},
+			expectedError: `writing consistency report, configmaps "report" not found`,
+		},
+		"conflict is requeued": {
+			reconcile: func() (reconcile.Result, error) {
+				return reconcile.Result{}, apierrors.NewConflict(schema.GroupResource{Resource: "nodeclaims"}, "nc", fmt.Errorf("modified"))
+			},
+			expectedResult: reconcile.Result{Requeue: true},
+		},
+		"cluster upgrading is requeued after the cluster state cache ttl": {
+			reconcile: func() (reconcile.Result, error) {
+				return reconcile.Result{}, fmt.Errorf("deleting agentpool, %w", &instance.ClusterUpgradingError{ProvisioningState: "Upgrading"})
+			},
+			expectedResult: reconcile.Result{RequeueAfter: instance.ClusterStateCacheTTL},
+		},
+		"other errors are returned": {
+			reconcile: func() (reconcile.Result, error) {
+				return reconcile.Result{}, fmt.Errorf("internal error")
+			},
+			expectedError: "internal error",
+		},
+		"panic is recovered": {
+			reconcile: func() (reconcile.Result, error) {
+				panic("nil map")
+			},
+			expectedError: "reconciler test panicked, nil map",
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			r := Wrap("test", reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+				return tc.reconcile()
+			}))
+			// requests without an object are sent to singleton reconcilers.
+			result, err := r.Reconcile(context.Background(), lo.FromPtr(tc.request))
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedResult, result)
+		})
+	}
+}
+
+func TestWrapLowPriority(t *testing.T) {
+	clk := clocktesting.NewFakePassiveClock(time.Now())
+	detector := pressure.NewDetector(time.Second, time.Minute, clk)
+	reconciles := 0
+	r := wrapLowPriority("test", reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+		reconciles++
+		return reconcile.Result{}, nil
+	}), detector)
+
+	result, err := r.Reconcile(context.Background(), reconcile.Request{})
+	assert.NoError(t, err)
+	assert.Equal(t, reconcile.Result{}, result)
+	assert.Equal(t, 1, reconciles)
+
+	detector.ObserveResult("429")
+	result, err = r.Reconcile(context.Background(), reconcile.Request{})
+	assert.NoError(t, err)
+	assert.Equal(t, reconcile.Result{RequeueAfter: time.Minute}, result, "reconcile is shed under pressure")
+	assert.Equal(t, 1, reconciles)
+
+	clk.SetTime(clk.Now().Add(time.Minute))
+	_, err = r.Reconcile(context.Background(), reconcile.Request{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reconciles, "reconcile resumes after the cooldown")
+}
@@ -0,0 +1,196 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuhealth
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/awslabs/operatorpkg/singleton"
+	azurecloudprovider "github.com/azure/gpu-provisioner/pkg/cloudprovider"
+	"github.com/azure/gpu-provisioner/pkg/controllers/middleware"
+	nodeclaimstatus "github.com/azure/gpu-provisioner/pkg/controllers/nodeclaim"
+	"github.com/azure/gpu-provisioner/pkg/utils"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/operator/injection"
+	nodeutil "sigs.k8s.io/karpenter/pkg/utils/node"
+)
+
+const (
+	// GPUUnhealthyConditionType is true when critical gpu errors(XID, thermal) are reported on the node,
+	// the nodeclaim of the node is deleted by the controller so that the bad node is replaced.
+	GPUUnhealthyConditionType corev1.NodeConditionType = "GPUUnhealthy"
+
+	defaultDCGMExporterSelector = "app.kubernetes.io/name=dcgm-exporter"
+)
+
+// Controller translates gpu health signals exported by dcgm-exporter into node conditions of gpu nodes
+// created by gpu-provisioner.
+type Controller struct {
+	kubeClient client.Client
+	scraper    MetricsScraper
+
+	// enabled is false by default because dcgm-exporter may not be installed in the cluster.
+	enabled          bool
+	exporterSelector string
+	// interval is the time between two scrapes of dcgm-exporter.
+	interval time.Duration
+	// repair enables deleting the nodeclaims of gpu unhealthy nodes, only the condition is reported when it's disabled.
+	repair bool
+}
+
+func NewController(kubeClient client.Client) *Controller {
+	return &Controller{
+		kubeClient:       kubeClient,
+		scraper:          &httpScraper{client: &http.Client{Timeout: 10 * time.Second}},
+		enabled:          utils.WithDefaultBool("GPU_HEALTH_CHECK", false),
+		exporterSelector: utils.WithDefaultString("DCGM_EXPORTER_LABEL_SELECTOR", defaultDCGMExporterSelector),
+		interval:         utils.WithDefaultDuration("GPU_HEALTH_CHECK_INTERVAL", time.Minute),
+		repair:           utils.WithDefaultBool("GPU_HEALTH_REPAIR", true),
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context) (reconcile.Result, error) {
+	ctx = injection.WithControllerName(ctx, "node.gpuhealth")
+	if !c.enabled {
+		return reconcile.Result{}, nil
+	}
+
+	selector, err := labels.Parse(c.exporterSelector)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "invalid dcgm-exporter label selector, gpu health check is disabled", "selector", c.exporterSelector)
+		return reconcile.Result{}, nil
+	}
+
+	nodeList := &corev1.NodeList{}
+	if err := c.kubeClient.List(ctx, nodeList, client.HasLabels{v1.NodePoolLabelKey}, client.MatchingLabels{nodeclaimstatus.AcceleratorLabelKey: "nvidia"}); err != nil {
+		return reconcile.Result{}, err
+	}
+	podList := &corev1.PodList{}
+	if err := c.kubeClient.List(ctx, podList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return reconcile.Result{}, err
+	}
+	exporters := map[string]*corev1.Pod{}
+	for i := range podList.Items {
+		if podList.Items[i].Status.Phase == corev1.PodRunning && podList.Items[i].Status.PodIP != "" {
+			exporters[podList.Items[i].Spec.NodeName] = &podList.Items[i]
+		}
+	}
+
+	var errs error
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		exporter, ok := exporters[node.Name]
+		if !ok || !node.DeletionTimestamp.IsZero() {
+			continue
+		}
+		metrics, err := c.scraper.Scrape(ctx, exporter)
+		if err != nil {
+			// scraping failure doesn't mean the gpu is unhealthy, keep the current condition
+			log.FromContext(ctx).Error(err, "failed to scrape dcgm-exporter", "node", node.Name, "pod", client.ObjectKeyFromObject(exporter))
+			continue
+		}
+		if err := c.updateCondition(ctx, node, detectGPUProblem(metrics)); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	if c.repair {
+		errs = multierr.Append(errs, c.repairNodes(ctx, nodeList.Items))
+	}
+	return reconcile.Result{RequeueAfter: c.interval}, errs
+}
+
+// repairNodes deletes the nodeclaim of one gpu unhealthy node at a time, kaito creates a new nodeclaim for the
+// workspace and the workloads are moved to the new node. nodes protected by the do-not-disrupt annotation, on the
+// node or its nodeclaim, are not repaired.
+func (c *Controller) repairNodes(ctx context.Context, nodes []corev1.Node) error {
+	var candidate *v1.NodeClaim
+	for i := range nodes {
+		node := &nodes[i]
+		if !isGPUUnhealthy(node) {
+			continue
+		}
+		nodeClaims, err := nodeutil.GetNodeClaims(ctx, node, c.kubeClient)
+		if err != nil {
+			return err
+		}
+		for _, nodeClaim := range nodeClaims {
+			if !nodeClaim.DeletionTimestamp.IsZero() {
+				log.FromContext(ctx).V(1).Info("gpu unhealthy node is being replaced, defer other repairs", "node", node.Name, "nodeclaim", nodeClaim.Name)
+				return nil
+			}
+			if candidate == nil && node.Annotations[v1.DoNotDisruptAnnotationKey] != "true" && nodeClaim.Annotations[v1.DoNotDisruptAnnotationKey] != "true" {
+				candidate = nodeClaim
+			}
+		}
+	}
+	if candidate == nil {
+		return nil
+	}
+
+	stored := candidate.DeepCopy()
+	candidate.Annotations = lo.Assign(candidate.Annotations, map[string]string{
+		azurecloudprovider.DisruptionReasonAnnotationKey: string(azurecloudprovider.DisruptionReasonRepaired),
+	})
+	if err := c.kubeClient.Patch(ctx, candidate, client.MergeFrom(stored)); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	log.FromContext(ctx).Info("delete nodeclaim of gpu unhealthy node", "nodeclaim", candidate.Name, "node", candidate.Status.NodeName)
+	return client.IgnoreNotFound(c.kubeClient.Delete(ctx, candidate))
+}
+
+func isGPUUnhealthy(node *corev1.Node) bool {
+	return nodeutil.GetCondition(node, GPUUnhealthyConditionType).Status == corev1.ConditionTrue
+}
+
+func (c *Controller) updateCondition(ctx context.Context, node *corev1.Node, problem *gpuProblem) error {
+	condition := corev1.NodeCondition{
+		Type:    GPUUnhealthyConditionType,
+		Status:  corev1.ConditionFalse,
+		Reason:  "GPUHealthy",
+		Message: "No critical GPU error is reported by dcgm-exporter",
+	}
+	if problem != nil {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = problem.reason
+		condition.Message = problem.message
+	}
+
+	stored := node.DeepCopy()
+	if !utils.SetNodeCondition(node, condition) {
+		return nil
+	}
+
+	log.FromContext(ctx).Info("update gpu health condition", "node", node.Name, "status", condition.Status, "reason", condition.Reason, "message", condition.Message)
+	return client.IgnoreNotFound(c.kubeClient.Status().Patch(ctx, node, client.MergeFrom(stored)))
+}
+
+func (c *Controller) Register(_ context.Context, m manager.Manager) error {
+	return controllerruntime.NewControllerManagedBy(m).
+		Named("node.gpuhealth").
+		WatchesRawSource(singleton.Source()).
+		Complete(middleware.Wrap("node.gpuhealth", singleton.AsReconciler(c)))
+}
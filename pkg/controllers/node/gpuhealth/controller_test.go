@@ -0,0 +1,241 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuhealth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	azurecloudprovider "github.com/azure/gpu-provisioner/pkg/cloudprovider"
+	nodeclaimstatus "github.com/azure/gpu-provisioner/pkg/controllers/nodeclaim"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+type fakeScraper struct {
+	metrics string
+}
+
+func (f *fakeScraper) Scrape(_ context.Context, _ *corev1.Pod) (map[string]*dto.MetricFamily, error) {
+	parser := expfmt.TextParser{}
+	return parser.TextToMetricFamilies(strings.NewReader(f.metrics))
+}
+
+func TestReconcile(t *testing.T) {
+	testcases := map[string]struct {
+		metrics           string
+		withExporter      bool
+		expectedCondition bool
+		expectedStatus    corev1.ConditionStatus
+		expectedReason    string
+	}{
+		"critical xid error": {
+			metrics:           "DCGM_FI_DEV_XID_ERRORS{gpu=\"0\"} 79\nDCGM_FI_DEV_GPU_TEMP{gpu=\"0\"} 40\n",
+			withExporter:      true,
+			expectedCondition: true,
+			expectedStatus:    corev1.ConditionTrue,
+			expectedReason:    "XIDError",
+		},
+		"gpu overheated": {
+			metrics:           "DCGM_FI_DEV_XID_ERRORS{gpu=\"0\"} 0\nDCGM_FI_DEV_GPU_TEMP{gpu=\"1\"} 95\n",
+			withExporter:      true,
+			expectedCondition: true,
+			expectedStatus:    corev1.ConditionTrue,
+			expectedReason:    "GPUOverheated",
+		},
+		"non-critical xid error": {
+			metrics:           "DCGM_FI_DEV_XID_ERRORS{gpu=\"0\"} 13\nDCGM_FI_DEV_GPU_TEMP{gpu=\"0\"} 40\n",
+			withExporter:      true,
+			expectedCondition: true,
+			expectedStatus:    corev1.ConditionFalse,
+			expectedReason:    "GPUHealthy",
+		},
+		"no dcgm-exporter on node": {
+			metrics:           "DCGM_FI_DEV_XID_ERRORS{gpu=\"0\"} 79\n",
+			withExporter:      false,
+			expectedCondition: false,
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "aks-agentpool1-20562481-vmss_0",
+					Labels: map[string]string{
+						karpenterv1.NodePoolLabelKey:        "kaito",
+						nodeclaimstatus.AcceleratorLabelKey: "nvidia",
+					},
+				},
+			}
+			builder := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithStatusSubresource(&corev1.Node{}).
+				WithIndex(&karpenterv1.NodeClaim{}, "status.providerID", providerIDIndex).
+				WithRuntimeObjects(node)
+			if tc.withExporter {
+				builder = builder.WithRuntimeObjects(&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "dcgm-exporter-0",
+						Namespace: "gpu-operator",
+						Labels:    map[string]string{"app.kubernetes.io/name": "dcgm-exporter"},
+					},
+					Spec:   corev1.PodSpec{NodeName: node.Name},
+					Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"},
+				})
+			}
+			fakeClient := builder.Build()
+
+			c := NewController(fakeClient)
+			c.enabled = true
+			c.scraper = &fakeScraper{metrics: tc.metrics}
+			_, err := c.Reconcile(context.Background())
+			assert.NoError(t, err, "expect no error but got one")
+
+			current := &corev1.Node{}
+			assert.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(node), current))
+			var condition *corev1.NodeCondition
+			for i := range current.Status.Conditions {
+				if current.Status.Conditions[i].Type == GPUUnhealthyConditionType {
+					condition = &current.Status.Conditions[i]
+				}
+			}
+			if !tc.expectedCondition {
+				assert.Nil(t, condition, "expect no gpu health condition")
+				return
+			}
+			assert.NotNil(t, condition, "expect gpu health condition")
+			assert.Equal(t, tc.expectedStatus, condition.Status)
+			assert.Equal(t, tc.expectedReason, condition.Reason)
+		})
+	}
+}
+
+func providerIDIndex(o client.Object) []string {
+	return []string{o.(*karpenterv1.NodeClaim).Status.ProviderID}
+}
+
+func gpuNode(name string, unhealthy bool, annotations map[string]string) *corev1.Node {
+	status := corev1.ConditionFalse
+	if unhealthy {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "aks-" + name + "-20562481-vmss_0",
+			Annotations: annotations,
+			Labels: map[string]string{
+				karpenterv1.NodePoolLabelKey:        "kaito",
+				nodeclaimstatus.AcceleratorLabelKey: "nvidia",
+			},
+		},
+		Spec: corev1.NodeSpec{ProviderID: fixture.ProviderID(name)},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: GPUUnhealthyConditionType, Status: status}},
+		},
+	}
+}
+
+func TestRepair(t *testing.T) {
+	testcases := map[string]struct {
+		nodes           []*corev1.Node
+		nodeClaims      []*karpenterv1.NodeClaim
+		expectedDeleted []string
+	}{
+		"delete nodeclaim of gpu unhealthy node": {
+			nodes: []*corev1.Node{gpuNode("agentpool1", true, nil), gpuNode("agentpool2", false, nil)},
+			nodeClaims: []*karpenterv1.NodeClaim{
+				fixture.NodeClaim().WithName("agentpool1").Build(),
+				fixture.NodeClaim().WithName("agentpool2").Build(),
+			},
+			expectedDeleted: []string{"agentpool1"},
+		},
+		"repair one node at a time": {
+			nodes: []*corev1.Node{gpuNode("agentpool1", true, nil), gpuNode("agentpool2", true, nil)},
+			nodeClaims: []*karpenterv1.NodeClaim{
+				fixture.NodeClaim().WithName("agentpool1").Build(),
+				fixture.NodeClaim().WithName("agentpool2").Build(),
+			},
+			expectedDeleted: []string{"agentpool1"},
+		},
+		"defer repair while another unhealthy node is being replaced": {
+			nodes: []*corev1.Node{gpuNode("agentpool1", true, nil), gpuNode("agentpool2", true, nil)},
+			nodeClaims: []*karpenterv1.NodeClaim{
+				fixture.NodeClaim().WithName("agentpool1").Build(),
+				fixture.NodeClaim().WithName("agentpool2").Deleting().Build(),
+			},
+		},
+		"skip node with do-not-disrupt annotation": {
+			nodes: []*corev1.Node{
+				gpuNode("agentpool1", true, map[string]string{karpenterv1.DoNotDisruptAnnotationKey: "true"}),
+				gpuNode("agentpool2", true, nil),
+			},
+			nodeClaims: []*karpenterv1.NodeClaim{
+				fixture.NodeClaim().WithName("agentpool1").Build(),
+				fixture.NodeClaim().WithName("agentpool2").Build(),
+			},
+			expectedDeleted: []string{"agentpool2"},
+		},
+		"skip nodeclaim with do-not-disrupt annotation": {
+			nodes: []*corev1.Node{gpuNode("agentpool1", true, nil)},
+			nodeClaims: []*karpenterv1.NodeClaim{
+				fixture.NodeClaim().WithName("agentpool1").WithAnnotations(map[string]string{karpenterv1.DoNotDisruptAnnotationKey: "true"}).Build(),
+			},
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			builder := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithIndex(&karpenterv1.NodeClaim{}, "status.providerID", providerIDIndex)
+			for _, node := range tc.nodes {
+				builder = builder.WithObjects(node)
+			}
+			for _, nodeClaim := range tc.nodeClaims {
+				nodeClaim.Namespace = ""
+				nodeClaim.Finalizers = []string{karpenterv1.TerminationFinalizer}
+				builder = builder.WithObjects(nodeClaim)
+			}
+			fakeClient := builder.Build()
+
+			c := NewController(fakeClient)
+			nodes := lo.Map(tc.nodes, func(node *corev1.Node, _ int) corev1.Node { return *node })
+			assert.NoError(t, c.repairNodes(context.Background(), nodes))
+
+			for _, nodeClaim := range tc.nodeClaims {
+				if !nodeClaim.DeletionTimestamp.IsZero() {
+					continue
+				}
+				current := &karpenterv1.NodeClaim{}
+				assert.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(nodeClaim), current))
+				deleted := lo.Contains(tc.expectedDeleted, nodeClaim.Name)
+				assert.Equal(t, deleted, !current.DeletionTimestamp.IsZero(), "unexpected deletion of nodeclaim %s", nodeClaim.Name)
+				if deleted {
+					assert.Equal(t, string(azurecloudprovider.DisruptionReasonRepaired), current.Annotations[azurecloudprovider.DisruptionReasonAnnotationKey])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuhealth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// metrics exported by dcgm-exporter, see https://github.com/NVIDIA/dcgm-exporter
+	xidErrorsMetric      = "DCGM_FI_DEV_XID_ERRORS"
+	gpuTemperatureMetric = "DCGM_FI_DEV_GPU_TEMP"
+
+	dcgmExporterPort = 9400
+)
+
+var (
+	// criticalXIDs are the xid errors which can only be recovered by replacing the node,
+	// see https://docs.nvidia.com/deploy/xid-errors/index.html
+	criticalXIDs = sets.New[int](48, 63, 64, 74, 79, 92, 94, 95)
+	// gpuTemperatureThreshold is the gpu temperature(in celsius) above which the gpu is considered overheated.
+	gpuTemperatureThreshold = 90.0
+)
+
+// MetricsScraper scrapes metrics from a dcgm-exporter pod.
+type MetricsScraper interface {
+	Scrape(ctx context.Context, pod *corev1.Pod) (map[string]*dto.MetricFamily, error)
+}
+
+type httpScraper struct {
+	client *http.Client
+}
+
+func (s *httpScraper) Scrape(ctx context.Context, pod *corev1.Pod) (map[string]*dto.MetricFamily, error) {
+	url := fmt.Sprintf("http://%s/metrics", net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(dcgmExporterPort)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scraping %s returns status %s", url, resp.Status)
+	}
+
+	parser := expfmt.TextParser{}
+	return parser.TextToMetricFamilies(resp.Body)
+}
+
+// gpuProblem is a critical gpu error found in dcgm metrics.
+type gpuProblem struct {
+	reason  string
+	message string
+}
+
+// detectGPUProblem returns the first critical gpu error in the metrics, or nil if all gpus are healthy.
+func detectGPUProblem(metrics map[string]*dto.MetricFamily) *gpuProblem {
+	if mf, ok := metrics[xidErrorsMetric]; ok {
+		for _, m := range mf.GetMetric() {
+			if xid := int(metricValue(m)); criticalXIDs.Has(xid) {
+				return &gpuProblem{
+					reason:  "XIDError",
+					message: fmt.Sprintf("GPU %s reported critical XID error %d", gpuID(m), xid),
+				}
+			}
+		}
+	}
+	if mf, ok := metrics[gpuTemperatureMetric]; ok {
+		for _, m := range mf.GetMetric() {
+			if temperature := metricValue(m); temperature >= gpuTemperatureThreshold {
+				return &gpuProblem{
+					reason:  "GPUOverheated",
+					message: fmt.Sprintf("GPU %s temperature %.0fC exceeds %.0fC", gpuID(m), temperature, gpuTemperatureThreshold),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func metricValue(m *dto.Metric) float64 {
+	if m.GetGauge() != nil {
+		return m.GetGauge().GetValue()
+	}
+	if m.GetCounter() != nil {
+		return m.GetCounter().GetValue()
+	}
+	return m.GetUntyped().GetValue()
+}
+
+func gpuID(m *dto.Metric) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == "gpu" {
+			return l.GetValue()
+		}
+	}
+	return "unknown"
+}
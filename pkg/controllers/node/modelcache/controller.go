@@ -0,0 +1,232 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelcache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/awslabs/operatorpkg/singleton"
+	"github.com/azure/gpu-provisioner/pkg/controllers/middleware"
+	"github.com/azure/gpu-provisioner/pkg/utils"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/operator/injection"
+	nodeutil "sigs.k8s.io/karpenter/pkg/utils/node"
+	nodeclaimutil "sigs.k8s.io/karpenter/pkg/utils/nodeclaim"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ModelCacheReadyConditionType is true once the model weights of the workspace are downloaded to the local disk of
+	// the node, workloads(or their scheduling gates) can wait for it instead of downloading the weights on start.
+	ModelCacheReadyConditionType corev1.NodeConditionType = "ModelCacheReady"
+	// WorkspaceNamespaceLabelKey is set by kaito on the nodeclaims of a workspace along with the workspace name.
+	WorkspaceNamespaceLabelKey = "kaito.sh/workspacenamespace"
+	// NodeLabelKey is set on the pre-warm jobs with the name of the node they run on.
+	NodeLabelKey = "kaito.sh/model-cache-node"
+
+	defaultTemplateConfigMap = "gpu-provisioner-model-cache-prewarm"
+	// templateKey is the key of the job manifest in the template configmap.
+	templateKey = "job.yaml"
+)
+
+// Controller runs a pre-warm job on every ready node of a workspace, which downloads the model weights of the
+// workspace to the local disk, and reflects the job completion as the ModelCacheReady node condition.
+type Controller struct {
+	kubeClient client.Client
+
+	// enabled is false by default because the pre-warm job depends on how the model weights are stored.
+	enabled bool
+	// namespace is the namespace of the template configmap and the pre-warm jobs.
+	namespace         string
+	templateConfigMap string
+	interval          time.Duration
+}
+
+func NewController(kubeClient client.Client) *Controller {
+	return &Controller{
+		kubeClient:        kubeClient,
+		enabled:           utils.WithDefaultBool("MODEL_CACHE_PREWARM", false),
+		namespace:         utils.WithDefaultString("SYSTEM_NAMESPACE", "gpu-provisioner"),
+		templateConfigMap: utils.WithDefaultString("MODEL_CACHE_PREWARM_TEMPLATE", defaultTemplateConfigMap),
+		interval:          utils.WithDefaultDuration("MODEL_CACHE_PREWARM_INTERVAL", 30*time.Second),
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context) (reconcile.Result, error) {
+	ctx = injection.WithControllerName(ctx, "node.modelcache")
+	if !c.enabled {
+		return reconcile.Result{}, nil
+	}
+
+	template, err := c.jobTemplate(ctx)
+	if err != nil {
+		// the template is provided by the user, retry after it's fixed instead of backing off.
+		log.FromContext(ctx).Error(err, "invalid model cache pre-warm job template", "configmap", client.ObjectKey{Namespace: c.namespace, Name: c.templateConfigMap})
+		return reconcile.Result{RequeueAfter: c.interval}, nil
+	}
+
+	nodeList := &corev1.NodeList{}
+	if err := c.kubeClient.List(ctx, nodeList, client.HasLabels{v1.NodePoolLabelKey, nodeclaimutil.WorkspaceLabelKey}); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	var errs error
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		if !node.DeletionTimestamp.IsZero() || nodeutil.GetCondition(node, corev1.NodeReady).Status != corev1.ConditionTrue {
+			continue
+		}
+		// the weights stay on the local disk, the job is not run again once the cache is ready.
+		if nodeutil.GetCondition(node, ModelCacheReadyConditionType).Status == corev1.ConditionTrue {
+			continue
+		}
+		if err := c.prewarm(ctx, node, template); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("pre-warming model cache of node %s, %w", node.Name, err))
+		}
+	}
+	return reconcile.Result{RequeueAfter: c.interval}, errs
+}
+
+// jobTemplate returns the pre-warm job in the template configmap.
+func (c *Controller) jobTemplate(ctx context.Context) (*batchv1.Job, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.kubeClient.Get(ctx, client.ObjectKey{Namespace: c.namespace, Name: c.templateConfigMap}, cm); err != nil {
+		return nil, err
+	}
+	manifest, ok := cm.Data[templateKey]
+	if !ok {
+		return nil, fmt.Errorf("key %s is not found", templateKey)
+	}
+	job := &batchv1.Job{}
+	if err := yaml.UnmarshalStrict([]byte(manifest), job); err != nil {
+		return nil, fmt.Errorf("parsing %s, %w", templateKey, err)
+	}
+	if len(job.Spec.Template.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("job of %s has no container", templateKey)
+	}
+	return job, nil
+}
+
+// prewarm creates the pre-warm job of the node if it doesn't exist, and sets the ModelCacheReady condition by the
+// status of the job.
+func (c *Controller) prewarm(ctx context.Context, node *corev1.Node, template *batchv1.Job) error {
+	job := &batchv1.Job{}
+	err := c.kubeClient.Get(ctx, client.ObjectKey{Namespace: c.namespace, Name: JobName(node)}, job)
+	if apierrors.IsNotFound(err) {
+		job = newJob(node, template, c.namespace)
+		if err := c.kubeClient.Create(ctx, job); client.IgnoreAlreadyExists(err) != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("created model cache pre-warm job", "node", node.Name, "job", client.ObjectKeyFromObject(job))
+	} else if err != nil {
+		return err
+	}
+	return c.updateCondition(ctx, node, jobCondition(job))
+}
+
+// JobName returns the name of the pre-warm job of the node.
+func JobName(node *corev1.Node) string {
+	return fmt.Sprintf("model-cache-%s", node.Name)
+}
+
+// newJob returns the pre-warm job of the node from the template. the job is pinned to the node and tolerates all its
+// taints, the workspace is passed to the containers by env WORKSPACE_NAME and WORKSPACE_NAMESPACE.
+func newJob(node *corev1.Node, template *batchv1.Job, namespace string) *batchv1.Job {
+	job := template.DeepCopy()
+	job.ObjectMeta = metav1.ObjectMeta{
+		Name:        JobName(node),
+		Namespace:   namespace,
+		Labels:      lo.Assign(template.Labels, map[string]string{NodeLabelKey: node.Name}),
+		Annotations: template.Annotations,
+		// the job is garbage collected along with the node.
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Node",
+			Name:       node.Name,
+			UID:        node.UID,
+		}},
+	}
+	job.Spec.Template.Spec.NodeName = node.Name
+	job.Spec.Template.Spec.Tolerations = append(job.Spec.Template.Spec.Tolerations, corev1.Toleration{Operator: corev1.TolerationOpExists})
+	env := []corev1.EnvVar{
+		{Name: "WORKSPACE_NAME", Value: node.Labels[nodeclaimutil.WorkspaceLabelKey]},
+		{Name: "WORKSPACE_NAMESPACE", Value: node.Labels[WorkspaceNamespaceLabelKey]},
+	}
+	for i := range job.Spec.Template.Spec.Containers {
+		job.Spec.Template.Spec.Containers[i].Env = append(job.Spec.Template.Spec.Containers[i].Env, env...)
+	}
+	return job
+}
+
+func jobCondition(job *batchv1.Job) corev1.NodeCondition {
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			return corev1.NodeCondition{
+				Type:    ModelCacheReadyConditionType,
+				Status:  corev1.ConditionTrue,
+				Reason:  "Downloaded",
+				Message: fmt.Sprintf("Model weights are downloaded by job %s", job.Name),
+			}
+		case batchv1.JobFailed:
+			return corev1.NodeCondition{
+				Type:    ModelCacheReadyConditionType,
+				Status:  corev1.ConditionFalse,
+				Reason:  "DownloadFailed",
+				Message: fmt.Sprintf("Job %s failed, %s", job.Name, cond.Message),
+			}
+		}
+	}
+	return corev1.NodeCondition{
+		Type:    ModelCacheReadyConditionType,
+		Status:  corev1.ConditionFalse,
+		Reason:  "Downloading",
+		Message: fmt.Sprintf("Model weights are being downloaded by job %s", job.Name),
+	}
+}
+
+func (c *Controller) updateCondition(ctx context.Context, node *corev1.Node, condition corev1.NodeCondition) error {
+	stored := node.DeepCopy()
+	if !utils.SetNodeCondition(node, condition) {
+		return nil
+	}
+
+	log.FromContext(ctx).Info("update model cache condition", "node", node.Name, "status", condition.Status, "reason", condition.Reason)
+	return client.IgnoreNotFound(c.kubeClient.Status().Patch(ctx, node, client.MergeFrom(stored)))
+}
+
+func (c *Controller) Register(_ context.Context, m manager.Manager) error {
+	return controllerruntime.NewControllerManagedBy(m).
+		Named("node.modelcache").
+		WatchesRawSource(singleton.Source()).
+		Complete(middleware.Wrap("node.modelcache", singleton.AsReconciler(c)))
+}
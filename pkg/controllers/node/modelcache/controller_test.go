@@ -0,0 +1,165 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	nodeutil "sigs.k8s.io/karpenter/pkg/utils/node"
+	nodeclaimutil "sigs.k8s.io/karpenter/pkg/utils/nodeclaim"
+)
+
+const testTemplate = `
+spec:
+  template:
+    spec:
+      restartPolicy: OnFailure
+      containers:
+      - name: download
+        image: mcr.microsoft.com/aks/kaito/model-downloader:latest
+`
+
+func TestReconcile(t *testing.T) {
+	testcases := map[string]struct {
+		nodeReady         bool
+		template          *string
+		jobCondition      *batchv1.JobCondition
+		expectedJob       bool
+		expectedCondition bool
+		expectedStatus    corev1.ConditionStatus
+		expectedReason    string
+	}{
+		"job is created on ready node": {
+			nodeReady:         true,
+			template:          lo.ToPtr(testTemplate),
+			expectedJob:       true,
+			expectedCondition: true,
+			expectedStatus:    corev1.ConditionFalse,
+			expectedReason:    "Downloading",
+		},
+		"job is completed": {
+			nodeReady:         true,
+			template:          lo.ToPtr(testTemplate),
+			jobCondition:      &batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+			expectedJob:       true,
+			expectedCondition: true,
+			expectedStatus:    corev1.ConditionTrue,
+			expectedReason:    "Downloaded",
+		},
+		"job is failed": {
+			nodeReady:         true,
+			template:          lo.ToPtr(testTemplate),
+			jobCondition:      &batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"},
+			expectedJob:       true,
+			expectedCondition: true,
+			expectedStatus:    corev1.ConditionFalse,
+			expectedReason:    "DownloadFailed",
+		},
+		"node is not ready": {
+			nodeReady:         false,
+			template:          lo.ToPtr(testTemplate),
+			expectedJob:       false,
+			expectedCondition: false,
+		},
+		"template is not found": {
+			nodeReady:         true,
+			expectedJob:       false,
+			expectedCondition: false,
+		},
+		"template has no container": {
+			nodeReady:         true,
+			template:          lo.ToPtr("spec: {}"),
+			expectedJob:       false,
+			expectedCondition: false,
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "aks-agentpool1-20562481-vmss000000",
+					Labels: map[string]string{
+						karpenterv1.NodePoolLabelKey:    "kaito",
+						nodeclaimutil.WorkspaceLabelKey: "workspace-falcon-7b",
+						WorkspaceNamespaceLabelKey:      "default",
+					},
+				},
+				Spec: corev1.NodeSpec{
+					Taints: []corev1.Taint{{Key: "sku", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}},
+				},
+				Status: corev1.NodeStatus{
+					Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}},
+				},
+			}
+			if tc.nodeReady {
+				node.Status.Conditions[0].Status = corev1.ConditionTrue
+			}
+			builder := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithStatusSubresource(&corev1.Node{}).
+				WithRuntimeObjects(node)
+			if tc.template != nil {
+				builder = builder.WithRuntimeObjects(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: defaultTemplateConfigMap, Namespace: "gpu-provisioner"},
+					Data:       map[string]string{templateKey: *tc.template},
+				})
+			}
+			if tc.jobCondition != nil {
+				builder = builder.WithRuntimeObjects(&batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Name: JobName(node), Namespace: "gpu-provisioner"},
+					Status:     batchv1.JobStatus{Conditions: []batchv1.JobCondition{*tc.jobCondition}},
+				})
+			}
+			fakeClient := builder.Build()
+
+			c := NewController(fakeClient)
+			c.enabled = true
+			_, err := c.Reconcile(context.Background())
+			assert.NoError(t, err, "expect no error but got one")
+
+			job := &batchv1.Job{}
+			err = fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "gpu-provisioner", Name: JobName(node)}, job)
+			assert.Equal(t, tc.expectedJob, err == nil, "unexpected job existence, %v", err)
+			if tc.expectedJob && tc.jobCondition == nil {
+				assert.Equal(t, node.Name, job.Spec.Template.Spec.NodeName)
+				assert.Equal(t, node.Name, job.Labels[NodeLabelKey])
+				assert.Contains(t, job.Spec.Template.Spec.Tolerations, corev1.Toleration{Operator: corev1.TolerationOpExists})
+				assert.Contains(t, job.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{Name: "WORKSPACE_NAME", Value: "workspace-falcon-7b"})
+				assert.Contains(t, job.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{Name: "WORKSPACE_NAMESPACE", Value: "default"})
+			}
+
+			current := &corev1.Node{}
+			assert.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(node), current))
+			condition := nodeutil.GetCondition(current, ModelCacheReadyConditionType)
+			if !tc.expectedCondition {
+				assert.Empty(t, condition.Type, "expect no model cache condition")
+				return
+			}
+			assert.Equal(t, tc.expectedStatus, condition.Status)
+			assert.Equal(t, tc.expectedReason, condition.Reason)
+		})
+	}
+}
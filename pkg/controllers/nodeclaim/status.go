@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/azure/gpu-provisioner/pkg/controllers/middleware"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -32,10 +33,18 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/controllers/nodeclaim/lifecycle"
 	"sigs.k8s.io/karpenter/pkg/operator/injection"
 	nodeclaimutil "sigs.k8s.io/karpenter/pkg/utils/nodeclaim"
 )
 
+const (
+	// AcceleratorLabelKey is set by AKS on nodes with GPU
+	AcceleratorLabelKey = "kubernetes.azure.com/accelerator"
+	// ResourceNvidiaGPU is the extended resource advertised by nvidia device plugin
+	ResourceNvidiaGPU corev1.ResourceName = "nvidia.com/gpu"
+)
+
 var (
 	nodeSelectorPredicate, _ = predicate.LabelSelectorPredicate(metav1.LabelSelector{
 		MatchExpressions: []metav1.LabelSelectorRequirement{
@@ -83,10 +92,17 @@ func (c *Controller) Reconcile(ctx context.Context, node *corev1.Node) (reconcil
 	if !nodeClaim.StatusConditions().Get(v1.ConditionTypeInitialized).IsTrue() {
 		nodeClaim.StatusConditions().SetUnknownWithReason(v1.ConditionTypeNodeReady, "NodeClaimNotInitialized", "node claim is not initialized")
 	} else {
-		if isNodeReady(node) {
-			nodeClaim.StatusConditions().SetTrue(v1.ConditionTypeNodeReady)
-		} else {
+		// karpenter initializes the nodeclaim once, the startup taints and gpus are checked on every node update so
+		// that the nodeclaim is only reported ready when it's usable by the workloads.
+		if !isNodeReady(node) {
 			nodeClaim.StatusConditions().SetFalse(v1.ConditionTypeNodeReady, "NodeNotReady", "Node status is NotReady")
+		} else if taint, ok := lifecycle.StartupTaintsRemoved(node, nodeClaim); !ok {
+			nodeClaim.StatusConditions().SetFalse(v1.ConditionTypeNodeReady, "StartupTaintsExist", fmt.Sprintf("StartupTaint %q still exists", taint.ToString()))
+		} else if !isGPUReady(node) {
+			// pods should not land on the node before the gpu drivers and device plugin are initialized
+			nodeClaim.StatusConditions().SetFalse(v1.ConditionTypeNodeReady, "GPUNotReady", fmt.Sprintf("Node allocatable %s is 0", ResourceNvidiaGPU))
+		} else {
+			nodeClaim.StatusConditions().SetTrue(v1.ConditionTypeNodeReady)
 		}
 	}
 
@@ -110,6 +126,16 @@ func isNodeReady(node *corev1.Node) bool {
 	return false
 }
 
+// isGPUReady returns true when the node is not a nvidia gpu node or the device plugin has advertised gpus.
+func isGPUReady(node *corev1.Node) bool {
+	if node.Labels[AcceleratorLabelKey] != "nvidia" {
+		return true
+	}
+
+	gpu, ok := node.Status.Allocatable[ResourceNvidiaGPU]
+	return ok && !gpu.IsZero()
+}
+
 func (c *Controller) Register(_ context.Context, m manager.Manager) error {
 	return controllerruntime.NewControllerManagedBy(m).
 		Named("nodeclaim.status").
@@ -124,5 +150,5 @@ func (c *Controller) Register(_ context.Context, m manager.Manager) error {
 		).
 		WithEventFilter(nodeclaimutil.KaitoResourcePredicate).
 		WithEventFilter(nodeSelectorPredicate).
-		Complete(reconcile.AsReconciler(m.GetClient(), c))
+		Complete(middleware.Wrap("nodeclaim.status", reconcile.AsReconciler(m.GetClient(), c)))
 }
@@ -21,9 +21,10 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -40,13 +41,7 @@ func TestReconcile(t *testing.T) {
 		expectedError       error
 	}{
 		"nodeclaim status change from true to false": {
-			nodeClaim: fake.GetNodeClaimObj("agentpool1", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-				{
-					Key:      "node.kubernetes.io/instance-type",
-					Operator: "In",
-					Values:   []string{"Standard_NC6s_v3"},
-				},
-			}),
+			nodeClaim:           fixture.NodeClaim().WithInstanceType("Standard_NC6s_v3").Build(),
 			initNodeReadyStatus: true,
 			node: &v1.Node{
 				ObjectMeta: metav1.ObjectMeta{
@@ -73,13 +68,34 @@ func TestReconcile(t *testing.T) {
 			expectedError:       nil,
 		},
 		"nodeclaim status change from false to true": {
-			nodeClaim: fake.GetNodeClaimObj("agentpool1", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-				{
-					Key:      "node.kubernetes.io/instance-type",
-					Operator: "In",
-					Values:   []string{"Standard_NC6s_v3"},
+			nodeClaim:           fixture.NodeClaim().WithInstanceType("Standard_NC6s_v3").Build(),
+			initNodeReadyStatus: false,
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: fmt.Sprintf("aks-%s-20562481-vmss_0", "agentpool1"),
+					Labels: map[string]string{
+						"agentpool":                      "agentpool1",
+						"kubernetes.azure.com/agentpool": "agentpool1",
+						karpenterv1.NodePoolLabelKey:     "kaito",
+					},
 				},
-			}),
+				Spec: v1.NodeSpec{
+					ProviderID: fmt.Sprintf("azure:///subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-%s-20562481-vmss/virtualMachines/0", "agentpool1"),
+				},
+				Status: v1.NodeStatus{
+					Conditions: []v1.NodeCondition{
+						{
+							Type:   v1.NodeReady,
+							Status: v1.ConditionTrue,
+						},
+					},
+				},
+			},
+			expectedReadyStatus: metav1.ConditionStatus(v1.ConditionTrue),
+			expectedError:       nil,
+		},
+		"gpu node is not ready before device plugin advertises gpus": {
+			nodeClaim:           fixture.NodeClaim().WithInstanceType("Standard_NC6s_v3").Build(),
 			initNodeReadyStatus: false,
 			node: &v1.Node{
 				ObjectMeta: metav1.ObjectMeta{
@@ -88,12 +104,47 @@ func TestReconcile(t *testing.T) {
 						"agentpool":                      "agentpool1",
 						"kubernetes.azure.com/agentpool": "agentpool1",
 						karpenterv1.NodePoolLabelKey:     "kaito",
+						AcceleratorLabelKey:              "nvidia",
 					},
 				},
 				Spec: v1.NodeSpec{
 					ProviderID: fmt.Sprintf("azure:///subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-%s-20562481-vmss/virtualMachines/0", "agentpool1"),
 				},
 				Status: v1.NodeStatus{
+					Allocatable: v1.ResourceList{
+						ResourceNvidiaGPU: resource.MustParse("0"),
+					},
+					Conditions: []v1.NodeCondition{
+						{
+							Type:   v1.NodeReady,
+							Status: v1.ConditionTrue,
+						},
+					},
+				},
+			},
+			expectedReadyStatus: metav1.ConditionStatus(v1.ConditionFalse),
+			expectedError:       nil,
+		},
+		"gpu node is ready after device plugin advertises gpus": {
+			nodeClaim:           fixture.NodeClaim().WithInstanceType("Standard_NC6s_v3").Build(),
+			initNodeReadyStatus: false,
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: fmt.Sprintf("aks-%s-20562481-vmss_0", "agentpool1"),
+					Labels: map[string]string{
+						"agentpool":                      "agentpool1",
+						"kubernetes.azure.com/agentpool": "agentpool1",
+						karpenterv1.NodePoolLabelKey:     "kaito",
+						AcceleratorLabelKey:              "nvidia",
+					},
+				},
+				Spec: v1.NodeSpec{
+					ProviderID: fmt.Sprintf("azure:///subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-%s-20562481-vmss/virtualMachines/0", "agentpool1"),
+				},
+				Status: v1.NodeStatus{
+					Allocatable: v1.ResourceList{
+						ResourceNvidiaGPU: resource.MustParse("1"),
+					},
 					Conditions: []v1.NodeCondition{
 						{
 							Type:   v1.NodeReady,
@@ -105,6 +156,36 @@ func TestReconcile(t *testing.T) {
 			expectedReadyStatus: metav1.ConditionStatus(v1.ConditionTrue),
 			expectedError:       nil,
 		},
+		"node is not ready before startup taints are removed": {
+			nodeClaim: fixture.NodeClaim().WithInstanceType("Standard_NC6s_v3").
+				WithStartupTaints(v1.Taint{Key: "nvidia.com/gpu", Value: "present", Effect: v1.TaintEffectNoSchedule}).
+				Build(),
+			initNodeReadyStatus: true,
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: fmt.Sprintf("aks-%s-20562481-vmss_0", "agentpool1"),
+					Labels: map[string]string{
+						"agentpool":                      "agentpool1",
+						"kubernetes.azure.com/agentpool": "agentpool1",
+						karpenterv1.NodePoolLabelKey:     "kaito",
+					},
+				},
+				Spec: v1.NodeSpec{
+					ProviderID: fmt.Sprintf("azure:///subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-%s-20562481-vmss/virtualMachines/0", "agentpool1"),
+					Taints:     []v1.Taint{{Key: "nvidia.com/gpu", Value: "present", Effect: v1.TaintEffectNoSchedule}},
+				},
+				Status: v1.NodeStatus{
+					Conditions: []v1.NodeCondition{
+						{
+							Type:   v1.NodeReady,
+							Status: v1.ConditionTrue,
+						},
+					},
+				},
+			},
+			expectedReadyStatus: metav1.ConditionStatus(v1.ConditionFalse),
+			expectedError:       nil,
+		},
 	}
 
 	for k, tc := range testcases {
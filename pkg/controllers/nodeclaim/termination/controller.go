@@ -0,0 +1,157 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package termination
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	azurecloudprovider "github.com/azure/gpu-provisioner/pkg/cloudprovider"
+	"github.com/azure/gpu-provisioner/pkg/controllers/middleware"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/events"
+	"sigs.k8s.io/karpenter/pkg/operator/injection"
+	nodeclaimutil "sigs.k8s.io/karpenter/pkg/utils/nodeclaim"
+)
+
+// TerminationFinalizer is added by gpu-provisioner on kaito nodeclaims, it is only removed after
+// the agent pool of the nodeclaim has been deleted.
+const TerminationFinalizer = "kaito.sh/agentpool-termination"
+
+// Controller makes sure the agent pool is deleted before the nodeclaim goes away. karpenter termination
+// skips the cloudprovider deletion for nodeclaims without providerID(e.g. agent pool creation is still
+// in progress), and the agent pool would be leaked without this finalizer.
+type Controller struct {
+	kubeClient    client.Client
+	cloudProvider cloudprovider.CloudProvider
+	recorder      events.Recorder
+
+	// workloadChecker checks the workloads disrupted by the deletion of a nodeclaim, it's nil if not configured.
+	workloadChecker WorkloadChecker
+	mu              sync.Mutex
+	// checked are the uids of deleted nodeclaims whose workloads have been checked.
+	checked sets.Set[types.UID]
+}
+
+// WorkloadChecker warns about workloads which are disrupted by deleting the agent pool of the nodeclaim.
+type WorkloadChecker interface {
+	CheckWorkloads(ctx context.Context, nodeClaim *v1.NodeClaim)
+}
+
+func NewController(kubeClient client.Client, cloudProvider cloudprovider.CloudProvider, recorder events.Recorder) *Controller {
+	return &Controller{
+		kubeClient:    kubeClient,
+		cloudProvider: cloudProvider,
+		recorder:      recorder,
+		checked:       sets.New[types.UID](),
+	}
+}
+
+// WithWorkloadChecker makes the controller check the workloads of a nodeclaim once its deletion is observed, before
+// karpenter termination drains the nodes.
+func (c *Controller) WithWorkloadChecker(checker WorkloadChecker) *Controller {
+	c.workloadChecker = checker
+	return c
+}
+
+func (c *Controller) Reconcile(ctx context.Context, nodeClaim *v1.NodeClaim) (reconcile.Result, error) {
+	ctx = injection.WithControllerName(ctx, "nodeclaim.termination")
+
+	if nodeClaim.GetDeletionTimestamp().IsZero() {
+		if controllerutil.ContainsFinalizer(nodeClaim, TerminationFinalizer) {
+			return reconcile.Result{}, nil
+		}
+		stored := nodeClaim.DeepCopy()
+		controllerutil.AddFinalizer(nodeClaim, TerminationFinalizer)
+		if err := c.kubeClient.Patch(ctx, nodeClaim, client.MergeFromWithOptions(stored, client.MergeFromWithOptimisticLock{})); err != nil {
+			return reconcile.Result{}, client.IgnoreNotFound(err)
+		}
+		return reconcile.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(nodeClaim, TerminationFinalizer) {
+		return reconcile.Result{}, nil
+	}
+	c.checkWorkloads(ctx, nodeClaim)
+	// karpenter drains the node and deletes the instance first, wait until karpenter termination is finished
+	// so that the workloads are not interrupted. the nodeclaim update will trigger a new reconcile. an emergency
+	// deletion doesn't wait for the drain once the overridden grace period elapses.
+	if controllerutil.ContainsFinalizer(nodeClaim, v1.TerminationFinalizer) {
+		remaining, ok := azurecloudprovider.GracePeriodOverrideRemaining(nodeClaim)
+		if !ok {
+			return reconcile.Result{}, nil
+		}
+		if remaining > 0 {
+			return reconcile.Result{RequeueAfter: remaining}, nil
+		}
+	}
+
+	// duplicate delete attempts(e.g. the instance has been deleted by karpenter termination) are coalesced by
+	// the instance provider, and the delete returns after the agent pool deletion LRO is completed.
+	// errors of a cluster upgrade are requeued after the upgrade by the middleware instead of backing off.
+	err := c.cloudProvider.Delete(ctx, nodeClaim)
+	if err != nil && !cloudprovider.IsNodeClaimNotFoundError(err) {
+		return reconcile.Result{}, fmt.Errorf("deleting agentpool for nodeclaim(%s), %w", nodeClaim.Name, err)
+	}
+	log.FromContext(ctx).Info("agentpool is deleted, remove finalizer", "nodeclaim", nodeClaim.Name)
+	// the finalizer is removed once per nodeclaim, so the deletion is recorded here instead of in every
+	// coalesced delete attempt.
+	azurecloudprovider.RecordDisruption(c.recorder, nodeClaim, azurecloudprovider.DisruptionReasonOf(nodeClaim))
+
+	stored := nodeClaim.DeepCopy()
+	controllerutil.RemoveFinalizer(nodeClaim, TerminationFinalizer)
+	if err := c.kubeClient.Patch(ctx, nodeClaim, client.MergeFromWithOptions(stored, client.MergeFromWithOptimisticLock{})); err != nil {
+		return reconcile.Result{}, err
+	}
+	c.mu.Lock()
+	c.checked.Delete(nodeClaim.UID)
+	c.mu.Unlock()
+	return reconcile.Result{}, nil
+}
+
+// checkWorkloads checks the workloads of the deleted nodeclaim once, while karpenter termination hasn't drained the
+// nodes yet. the workloads are checked again after a restart.
+func (c *Controller) checkWorkloads(ctx context.Context, nodeClaim *v1.NodeClaim) {
+	if c.workloadChecker == nil || !controllerutil.ContainsFinalizer(nodeClaim, v1.TerminationFinalizer) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checked.Has(nodeClaim.UID) {
+		return
+	}
+	c.checked.Insert(nodeClaim.UID)
+	c.workloadChecker.CheckWorkloads(ctx, nodeClaim)
+}
+
+func (c *Controller) Register(_ context.Context, m manager.Manager) error {
+	return controllerruntime.NewControllerManagedBy(m).
+		Named("nodeclaim.termination").
+		For(&v1.NodeClaim{}).
+		WithEventFilter(nodeclaimutil.KaitoResourcePredicate).
+		Complete(middleware.Wrap("nodeclaim.termination", reconcile.AsReconciler(m.GetClient(), c)))
+}
@@ -0,0 +1,203 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package termination
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/cloudprovider"
+	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/events"
+)
+
+func TestReconcile(t *testing.T) {
+	testcases := map[string]struct {
+		finalizers              []string
+		annotations             map[string]string
+		deleting                bool
+		mockDeleteAgentPoolResp func(mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientDeleteResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientDeleteResponse], error)
+		expectedFinalizer       bool
+		expectedRemoved         bool
+		expectedError           error
+	}{
+		"add finalizer to nodeclaim": {
+			expectedFinalizer: true,
+		},
+		"wait for karpenter termination before deleting agentpool": {
+			finalizers:        []string{karpenterv1.TerminationFinalizer, TerminationFinalizer},
+			deleting:          true,
+			expectedFinalizer: true,
+		},
+		"wait for karpenter termination until overridden grace period elapses": {
+			finalizers:        []string{karpenterv1.TerminationFinalizer, TerminationFinalizer},
+			annotations:       map[string]string{cloudprovider.TerminationGracePeriodOverrideAnnotationKey: "1h"},
+			deleting:          true,
+			expectedFinalizer: true,
+		},
+		"delete agentpool without waiting for karpenter termination after overridden grace period": {
+			finalizers:  []string{karpenterv1.TerminationFinalizer, TerminationFinalizer},
+			annotations: map[string]string{cloudprovider.TerminationGracePeriodOverrideAnnotationKey: "0s"},
+			deleting:    true,
+			mockDeleteAgentPoolResp: func(mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientDeleteResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientDeleteResponse], error) {
+				delResp := armcontainerservice.AgentPoolsClientDeleteResponse{}
+				resp := http.Response{Status: "200 OK", StatusCode: http.StatusOK, Body: http.NoBody}
+
+				mockHandler.EXPECT().Done().Return(true).Times(3)
+				mockHandler.EXPECT().Result(gomock.Any(), gomock.Any()).Return(nil)
+
+				pollingOptions := &runtime.NewPollerOptions[armcontainerservice.AgentPoolsClientDeleteResponse]{
+					Handler:  mockHandler,
+					Response: &delResp,
+				}
+
+				return runtime.NewPoller(&resp, runtime.NewPipeline("", "", runtime.PipelineOptions{}, nil), pollingOptions)
+			},
+			expectedFinalizer: false,
+		},
+		"remove finalizer after agentpool is deleted": {
+			finalizers: []string{TerminationFinalizer},
+			deleting:   true,
+			mockDeleteAgentPoolResp: func(mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientDeleteResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientDeleteResponse], error) {
+				delResp := armcontainerservice.AgentPoolsClientDeleteResponse{}
+				resp := http.Response{Status: "200 OK", StatusCode: http.StatusOK, Body: http.NoBody}
+
+				mockHandler.EXPECT().Done().Return(true).Times(3)
+				mockHandler.EXPECT().Result(gomock.Any(), gomock.Any()).Return(nil)
+
+				pollingOptions := &runtime.NewPollerOptions[armcontainerservice.AgentPoolsClientDeleteResponse]{
+					Handler:  mockHandler,
+					Response: &delResp,
+				}
+
+				return runtime.NewPoller(&resp, runtime.NewPipeline("", "", runtime.PipelineOptions{}, nil), pollingOptions)
+			},
+			expectedRemoved: true,
+		},
+		"keep finalizer when agentpool deletion failed": {
+			finalizers: []string{TerminationFinalizer},
+			deleting:   true,
+			mockDeleteAgentPoolResp: func(mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientDeleteResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientDeleteResponse], error) {
+				return nil, errors.New("failed to delete agentpool")
+			},
+			expectedFinalizer: true,
+			expectedError:     errors.New("failed to delete agentpool"),
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+			if tc.mockDeleteAgentPoolResp != nil {
+				mockHandler := fake.NewMockPollingHandler[armcontainerservice.AgentPoolsClientDeleteResponse](mockCtrl)
+				resp, err := tc.mockDeleteAgentPoolResp(mockHandler)
+				agentPoolMocks.EXPECT().BeginDelete(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool1", gomock.Any()).Return(resp, err)
+			}
+
+			nodeClaim := fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_NC6s_v3").Build()
+			nodeClaim.Namespace = ""
+			nodeClaim.Finalizers = tc.finalizers
+			nodeClaim.Annotations = tc.annotations
+			if tc.deleting {
+				nodeClaim.DeletionTimestamp = &metav1.Time{Time: metav1.Now().Time}
+			}
+
+			fakeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithRuntimeObjects(nodeClaim).
+				Build()
+
+			// prepare instance provider
+			mockAzClient := instance.NewAZClientFromAPI(agentPoolMocks)
+			instanceProvider := instance.NewProvider(mockAzClient, fakeClient, "testRG", "testCluster")
+
+			recorder := record.NewFakeRecorder(10)
+			c := NewController(fakeClient, cloudprovider.New(instanceProvider, fakeClient, nil), events.NewRecorder(recorder))
+
+			stored := &karpenterv1.NodeClaim{}
+			assert.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(nodeClaim), stored))
+			_, err := c.Reconcile(context.Background(), stored)
+
+			if tc.expectedError != nil {
+				assert.Contains(t, err.Error(), tc.expectedError.Error())
+			} else {
+				assert.NoError(t, err, "expect no error but got one")
+			}
+
+			var nc karpenterv1.NodeClaim
+			err = fakeClient.Get(context.Background(), client.ObjectKeyFromObject(nodeClaim), &nc)
+			if tc.expectedRemoved {
+				assert.True(t, apierrors.IsNotFound(err), "expect nodeclaim is removed")
+				assert.Contains(t, <-recorder.Events, "disruption reason user-requested")
+				return
+			}
+			assert.NoError(t, err, "expect get current nodeclaim")
+			assert.Equal(t, tc.expectedFinalizer, controllerutil.ContainsFinalizer(&nc, TerminationFinalizer), "unexpected finalizer")
+		})
+	}
+}
+
+// countingWorkloadChecker is a workload checker which counts the checked nodeclaims.
+type countingWorkloadChecker struct {
+	checked []string
+}
+
+func (c *countingWorkloadChecker) CheckWorkloads(_ context.Context, nodeClaim *karpenterv1.NodeClaim) {
+	c.checked = append(c.checked, nodeClaim.Name)
+}
+
+func TestReconcileChecksWorkloadsBeforeDrain(t *testing.T) {
+	nodeClaim := fixture.NodeClaim().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).Build()
+	nodeClaim.Namespace = ""
+	nodeClaim.UID = "uid"
+	nodeClaim.Finalizers = []string{karpenterv1.TerminationFinalizer, TerminationFinalizer}
+	nodeClaim.DeletionTimestamp = &metav1.Time{Time: metav1.Now().Time}
+	fakeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(nodeClaim).Build()
+
+	checker := &countingWorkloadChecker{}
+	c := NewController(fakeClient, nil, nil).WithWorkloadChecker(checker)
+	for i := 0; i < 2; i++ {
+		_, err := c.Reconcile(context.Background(), nodeClaim.DeepCopy())
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"agentpool1"}, checker.checked, "workloads are checked once while karpenter drains the nodes")
+
+	// workloads are gone once karpenter termination has drained the nodes, they are not checked anymore.
+	drained := nodeClaim.DeepCopy()
+	drained.UID = "drained"
+	drained.Finalizers = []string{TerminationFinalizer}
+	c.checkWorkloads(context.Background(), drained)
+	assert.Len(t, checker.checked, 1)
+}
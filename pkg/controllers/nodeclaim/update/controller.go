@@ -0,0 +1,101 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package update
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/azure/gpu-provisioner/pkg/controllers/middleware"
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/azure/gpu-provisioner/pkg/utils"
+	"github.com/patrickmn/go-cache"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/operator/injection"
+	nodeclaimutil "sigs.k8s.io/karpenter/pkg/utils/nodeclaim"
+)
+
+// Controller syncs label and taint changes of nodeclaims to their agent pools in place, so relabeled nodeclaims
+// don't drift from their agent pools. agent pool updates of a nodeclaim are rate limited by the update interval.
+type Controller struct {
+	kubeClient       client.Client
+	instanceProvider *instance.Provider
+
+	// updateInterval is the minimum time between two agent pool updates of the same nodeclaim.
+	updateInterval time.Duration
+	// lastUpdates records the time of the last agent pool update by nodeclaim name.
+	lastUpdates *cache.Cache
+}
+
+func NewController(kubeClient client.Client, instanceProvider *instance.Provider) *Controller {
+	updateInterval := utils.WithDefaultDuration("AGENTPOOL_UPDATE_INTERVAL", 5*time.Minute)
+	return &Controller{
+		kubeClient:       kubeClient,
+		instanceProvider: instanceProvider,
+		updateInterval:   updateInterval,
+		lastUpdates:      cache.New(updateInterval, time.Minute),
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context, nodeClaim *v1.NodeClaim) (reconcile.Result, error) {
+	ctx = injection.WithControllerName(ctx, "nodeclaim.update")
+	// agent pools of nodeclaims which are not launched yet are created with the current labels and taints.
+	if !nodeClaim.GetDeletionTimestamp().IsZero() || len(nodeClaim.Status.ProviderID) == 0 {
+		return reconcile.Result{}, nil
+	}
+
+	if _, expiration, ok := c.lastUpdates.GetWithExpiration(nodeClaim.Name); ok {
+		return reconcile.Result{RequeueAfter: time.Until(expiration)}, nil
+	}
+
+	// errors of a cluster upgrade are requeued after the upgrade by the middleware instead of backing off.
+	specHash, updated, err := c.instanceProvider.UpdateLabelsAndTaints(ctx, nodeClaim)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("updating agentpool for nodeclaim(%s), %w", nodeClaim.Name, err)
+	}
+	if !updated {
+		return reconcile.Result{}, nil
+	}
+	c.lastUpdates.SetDefault(nodeClaim.Name, struct{}{})
+	log.FromContext(ctx).Info("agentpool labels and taints are updated", "nodeclaim", nodeClaim.Name)
+
+	// the spec hash is refreshed, so the updated agent pool is not considered as drifted.
+	if _, ok := nodeClaim.Annotations[instance.SpecHashAnnotationKey]; ok {
+		stored := nodeClaim.DeepCopy()
+		nodeClaim.Annotations[instance.SpecHashAnnotationKey] = specHash
+		if err := c.kubeClient.Patch(ctx, nodeClaim, client.MergeFrom(stored)); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+func (c *Controller) Register(_ context.Context, m manager.Manager) error {
+	return controllerruntime.NewControllerManagedBy(m).
+		Named("nodeclaim.update").
+		For(&v1.NodeClaim{}, builder.WithPredicates(predicate.Or(predicate.LabelChangedPredicate{}, predicate.GenerationChangedPredicate{}))).
+		WithEventFilter(nodeclaimutil.KaitoResourcePredicate).
+		Complete(middleware.Wrap("nodeclaim.update", reconcile.AsReconciler(m.GetClient(), c)))
+}
@@ -0,0 +1,108 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package update
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func TestReconcile(t *testing.T) {
+	testcases := map[string]struct {
+		labels          map[string]string
+		recentlyUpdated bool
+		expectedUpdate  bool
+		expectedRequeue bool
+	}{
+		"labels are not changed": {
+			labels: map[string]string{"test": "test"},
+		},
+		"update agentpool with new label": {
+			labels:         map[string]string{"test": "test", "team": "a"},
+			expectedUpdate: true,
+		},
+		"rate limit agentpool updates": {
+			labels:          map[string]string{"test": "test", "team": "a"},
+			recentlyUpdated: true,
+			expectedRequeue: true,
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			nodeClaim := fixture.NodeClaim().WithName("agentpool1").WithLabels(tc.labels).WithInstanceType("Standard_NC6s_v3").Build()
+			nodeClaim.Namespace = ""
+			nodeClaim.Annotations = map[string]string{instance.SpecHashAnnotationKey: "0"}
+
+			agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+			if !tc.recentlyUpdated {
+				// the agent pool carries the labels the nodeclaim had on creation.
+				ap := fixture.AgentPool().WithName("agentpool1").WithLabels(map[string]string{"test": "test"}).Build()
+				agentPoolMocks.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool1", gomock.Any()).Return(armcontainerservice.AgentPoolsClientGetResponse{AgentPool: ap}, nil)
+			}
+			if tc.expectedUpdate {
+				mockHandler := fake.NewMockPollingHandler[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse](mockCtrl)
+				mockHandler.EXPECT().Done().Return(true).AnyTimes()
+				mockHandler.EXPECT().Result(gomock.Any(), gomock.Any()).Return(nil)
+				resp := http.Response{Status: "200 OK", StatusCode: http.StatusOK, Body: http.NoBody}
+				poller, err := runtime.NewPoller(&resp, runtime.NewPipeline("", "", runtime.PipelineOptions{}, nil), &runtime.NewPollerOptions[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse]{
+					Handler:  mockHandler,
+					Response: &armcontainerservice.AgentPoolsClientCreateOrUpdateResponse{},
+				})
+				assert.NoError(t, err)
+				agentPoolMocks.EXPECT().BeginCreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool1", gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _, _, _ string, ap armcontainerservice.AgentPool, _ *armcontainerservice.AgentPoolsClientBeginCreateOrUpdateOptions) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error) {
+						assert.Equal(t, "a", *ap.Properties.NodeLabels["team"])
+						return poller, nil
+					})
+			}
+
+			fakeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(nodeClaim).Build()
+			instanceProvider := instance.NewProvider(instance.NewAZClientFromAPI(agentPoolMocks), fakeClient, "testRG", "testCluster")
+			c := NewController(fakeClient, instanceProvider)
+			if tc.recentlyUpdated {
+				c.lastUpdates.SetDefault(nodeClaim.Name, struct{}{})
+			}
+
+			stored := &karpenterv1.NodeClaim{}
+			assert.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(nodeClaim), stored))
+			result, err := c.Reconcile(context.Background(), stored)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedRequeue, result.RequeueAfter > 0)
+
+			nc := &karpenterv1.NodeClaim{}
+			assert.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(nodeClaim), nc))
+			assert.Equal(t, tc.expectedUpdate, nc.Annotations[instance.SpecHashAnnotationKey] != "0", "unexpected spec hash annotation")
+		})
+	}
+}
@@ -24,13 +24,10 @@ package fake
 import (
 	"context"
 	"reflect"
-	"fmt"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
 	"go.uber.org/mock/gomock"
-	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
-	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 )
 
 // MockAgentPoolsAPI is a mock of AgentPoolsAPI interface.
@@ -56,6 +53,21 @@ func (m *MockAgentPoolsAPI) EXPECT() *MockAgentPoolsAPIMockRecorder {
 	return m.recorder
 }
 
+// BeginAbortLatestOperation mocks base method.
+func (m *MockAgentPoolsAPI) BeginAbortLatestOperation(ctx context.Context, resourceGroupName, resourceName, agentPoolName string, options *armcontainerservice.AgentPoolsClientBeginAbortLatestOperationOptions) (*runtime.Poller[armcontainerservice.AgentPoolsClientAbortLatestOperationResponse], error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginAbortLatestOperation", ctx, resourceGroupName, resourceName, agentPoolName, options)
+	ret0, _ := ret[0].(*runtime.Poller[armcontainerservice.AgentPoolsClientAbortLatestOperationResponse])
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginAbortLatestOperation indicates an expected call of BeginAbortLatestOperation.
+func (mr *MockAgentPoolsAPIMockRecorder) BeginAbortLatestOperation(ctx, resourceGroupName, resourceName, agentPoolName, options any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginAbortLatestOperation", reflect.TypeOf((*MockAgentPoolsAPI)(nil).BeginAbortLatestOperation), ctx, resourceGroupName, resourceName, agentPoolName, options)
+}
+
 // BeginCreateOrUpdate mocks base method.
 func (m *MockAgentPoolsAPI) BeginCreateOrUpdate(ctx context.Context, resourceGroupName, resourceName, agentPoolName string, parameters armcontainerservice.AgentPool, options *armcontainerservice.AgentPoolsClientBeginCreateOrUpdateOptions) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error) {
 	m.ctrl.T.Helper()
@@ -114,18 +126,3 @@ func (mr *MockAgentPoolsAPIMockRecorder) NewListPager(resourceGroupName, resourc
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewListPager", reflect.TypeOf((*MockAgentPoolsAPI)(nil).NewListPager), resourceGroupName, resourceName, options)
 }
-
-func CreateAgentPoolObjWithNodeClaim(nc *karpenterv1.NodeClaim) armcontainerservice.AgentPool {
-	return armcontainerservice.AgentPool{
-		Name: &nc.Name,
-		ID:   to.Ptr(fmt.Sprintf("azure:///subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-%s-20562481-vmss/virtualMachines/0", nc.Name)),
-		Properties: &armcontainerservice.ManagedClusterAgentPoolProfileProperties{
-			VMSize: to.Ptr(nc.Spec.Requirements[0].Values[0]),
-			NodeLabels: map[string]*string{
-				"test":               to.Ptr("test"),
-				"kaito.sh/workspace": to.Ptr("none"),
-				karpenterv1.NodePoolLabelKey: to.Ptr("kaito"),
-			},
-		},
-	}
-}
\ No newline at end of file
@@ -0,0 +1,134 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixture
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	v1 "k8s.io/api/core/v1"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// AgentPoolBuilder builds an agent pool as returned by the AKS API. The zero configuration is a succeeded, on-demand
+// linux user pool with a single node, labeled as created for a kaito nodeclaim.
+type AgentPoolBuilder struct {
+	agentPool armcontainerservice.AgentPool
+}
+
+// AgentPool returns a builder for the default agent pool.
+func AgentPool() *AgentPoolBuilder {
+	b := &AgentPoolBuilder{
+		agentPool: armcontainerservice.AgentPool{
+			Properties: &armcontainerservice.ManagedClusterAgentPoolProfileProperties{
+				NodeLabels: map[string]*string{
+					"kaito.sh/workspace":         to.Ptr("none"),
+					karpenterv1.NodePoolLabelKey: to.Ptr("kaito"),
+				},
+				Type:              to.Ptr(armcontainerservice.AgentPoolTypeVirtualMachineScaleSets),
+				Mode:              to.Ptr(armcontainerservice.AgentPoolModeUser),
+				VMSize:            to.Ptr(DefaultInstanceType),
+				OSType:            to.Ptr(armcontainerservice.OSTypeLinux),
+				Count:             to.Ptr(int32(1)),
+				ScaleSetPriority:  to.Ptr(armcontainerservice.ScaleSetPriorityRegular),
+				ProvisioningState: to.Ptr("Succeeded"),
+			},
+		},
+	}
+	return b.WithName(DefaultNodeClaimName)
+}
+
+// WithName names the pool and sets its resource id in the default cluster.
+// AgentPoolFor returns a builder for the agent pool created for the nodeclaim: named after it, labeled with its labels
+// and sized as its first instance type.
+func AgentPoolFor(nodeClaim *karpenterv1.NodeClaim) *AgentPoolBuilder {
+	b := AgentPool().WithName(nodeClaim.Name).WithLabels(nodeClaim.Labels)
+	for _, r := range nodeClaim.Spec.Requirements {
+		if r.Key == v1.LabelInstanceTypeStable && len(r.Values) > 0 {
+			return b.WithVMSize(r.Values[0])
+		}
+	}
+	return b
+}
+
+func (b *AgentPoolBuilder) WithName(name string) *AgentPoolBuilder {
+	b.agentPool.Name = to.Ptr(name)
+	b.agentPool.ID = to.Ptr(fmt.Sprintf("/subscriptions/%s/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster/agentPools/%s", SubscriptionID, name))
+	return b
+}
+
+func (b *AgentPoolBuilder) WithVMSize(vmSize string) *AgentPoolBuilder {
+	b.agentPool.Properties.VMSize = to.Ptr(vmSize)
+	return b
+}
+
+func (b *AgentPoolBuilder) WithLabels(labels map[string]string) *AgentPoolBuilder {
+	for k, v := range labels {
+		b.agentPool.Properties.NodeLabels[k] = to.Ptr(v)
+	}
+	return b
+}
+
+// WithTaints adds taints in the key=value:effect form used by the AKS API.
+func (b *AgentPoolBuilder) WithTaints(taints ...string) *AgentPoolBuilder {
+	for _, taint := range taints {
+		b.agentPool.Properties.NodeTaints = append(b.agentPool.Properties.NodeTaints, to.Ptr(taint))
+	}
+	return b
+}
+
+func (b *AgentPoolBuilder) WithTags(tags map[string]string) *AgentPoolBuilder {
+	if b.agentPool.Properties.Tags == nil {
+		b.agentPool.Properties.Tags = map[string]*string{}
+	}
+	for k, v := range tags {
+		b.agentPool.Properties.Tags[k] = to.Ptr(v)
+	}
+	return b
+}
+
+func (b *AgentPoolBuilder) WithOSDiskSizeGB(sizeGB int32) *AgentPoolBuilder {
+	b.agentPool.Properties.OSDiskSizeGB = to.Ptr(sizeGB)
+	return b
+}
+
+// WithSpot makes the pool a spot scale set.
+func (b *AgentPoolBuilder) WithSpot() *AgentPoolBuilder {
+	b.agentPool.Properties.ScaleSetPriority = to.Ptr(armcontainerservice.ScaleSetPrioritySpot)
+	return b
+}
+
+// WithProvisioningState sets the provisioning state reported by the AKS API.
+func (b *AgentPoolBuilder) WithProvisioningState(state string) *AgentPoolBuilder {
+	b.agentPool.Properties.ProvisioningState = to.Ptr(state)
+	return b
+}
+
+// Failed marks the pool as failed to provision.
+func (b *AgentPoolBuilder) Failed() *AgentPoolBuilder {
+	return b.WithProvisioningState("Failed")
+}
+
+// Creating marks the pool as still being provisioned.
+func (b *AgentPoolBuilder) Creating() *AgentPoolBuilder {
+	return b.WithProvisioningState("Creating")
+}
+
+// Build returns the agent pool. The builder must not be reused afterwards.
+func (b *AgentPoolBuilder) Build() armcontainerservice.AgentPool {
+	return b.agentPool
+}
@@ -0,0 +1,66 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixture
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func TestNodeClaim(t *testing.T) {
+	nodeClaim := NodeClaim().Build()
+	assert.Equal(t, DefaultNodeClaimName, nodeClaim.Name)
+	assert.Equal(t, DefaultNamespace, nodeClaim.Namespace)
+	assert.Equal(t, map[string]string{"kaito.sh/workspace": "none", karpenterv1.NodePoolLabelKey: "kaito"}, nodeClaim.Labels)
+	assert.Equal(t, "azure:///subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool1-20562481-vmss/virtualMachines/0", nodeClaim.Status.ProviderID)
+
+	nodeClaim = NodeClaim().WithName("gpunc").WithInstanceType("Standard_NC12s_v3").WithGPU(2).WithSpot().WithoutProviderID().Build()
+	assert.Equal(t, "gpunc", nodeClaim.Name)
+	assert.Empty(t, nodeClaim.Status.ProviderID)
+	assert.True(t, resource.NewQuantity(2, resource.DecimalSI).Equal(nodeClaim.Spec.Resources.Requests[ResourceNvidiaGPU]))
+	assert.Equal(t, []karpenterv1.NodeSelectorRequirementWithMinValues{
+		{NodeSelectorRequirement: v1.NodeSelectorRequirement{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"Standard_NC12s_v3"}}, MinValues: lo.ToPtr(1)},
+		{NodeSelectorRequirement: v1.NodeSelectorRequirement{Key: karpenterv1.CapacityTypeLabelKey, Operator: v1.NodeSelectorOpIn, Values: []string{karpenterv1.CapacityTypeSpot}}, MinValues: lo.ToPtr(1)},
+	}, nodeClaim.Spec.Requirements)
+
+	nodeClaim = NodeClaim().WithLabels(map[string]string{"kaito.sh/ragengine": "none"}).WithoutLabels("kaito.sh/workspace").WithNodeClassRef("AKSNodeClass", "default").Build()
+	assert.Equal(t, map[string]string{"kaito.sh/ragengine": "none", karpenterv1.NodePoolLabelKey: "kaito"}, nodeClaim.Labels)
+	assert.Equal(t, &karpenterv1.NodeClassReference{Kind: "AKSNodeClass", Name: "default"}, nodeClaim.Spec.NodeClassRef)
+}
+
+func TestAgentPool(t *testing.T) {
+	agentPool := AgentPool().Build()
+	assert.Equal(t, DefaultNodeClaimName, lo.FromPtr(agentPool.Name))
+	assert.Equal(t, "Succeeded", lo.FromPtr(agentPool.Properties.ProvisioningState))
+	assert.Equal(t, "kaito", lo.FromPtr(agentPool.Properties.NodeLabels[karpenterv1.NodePoolLabelKey]))
+
+	agentPool = AgentPool().WithName("gpunc").WithSpot().WithTags(map[string]string{"team": "ml"}).Failed().Build()
+	assert.Equal(t, "gpunc", lo.FromPtr(agentPool.Name))
+	assert.Contains(t, lo.FromPtr(agentPool.ID), "/agentPools/gpunc")
+	assert.Equal(t, "Spot", string(lo.FromPtr(agentPool.Properties.ScaleSetPriority)))
+	assert.Equal(t, "ml", lo.FromPtr(agentPool.Properties.Tags["team"]))
+	assert.Equal(t, "Failed", lo.FromPtr(agentPool.Properties.ProvisioningState))
+
+	agentPool = AgentPoolFor(NodeClaim().WithName("gpunc").WithSpot().WithInstanceType("Standard_NC12s_v3").Build()).Build()
+	assert.Equal(t, "gpunc", lo.FromPtr(agentPool.Name))
+	assert.Equal(t, "Standard_NC12s_v3", lo.FromPtr(agentPool.Properties.VMSize))
+	assert.Equal(t, "none", lo.FromPtr(agentPool.Properties.NodeLabels["kaito.sh/workspace"]))
+}
@@ -0,0 +1,187 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fixture provides builders for the NodeClaims and agent pools used across unit and e2e tests, so that each
+// test only spells out the fields it cares about.
+package fixture
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+const (
+	// ResourceNvidiaGPU is the extended resource advertised by the nvidia device plugin.
+	ResourceNvidiaGPU v1.ResourceName = "nvidia.com/gpu"
+
+	DefaultNodeClaimName = "agentpool1"
+	DefaultInstanceType  = "Standard_NC6s_v3"
+	DefaultNamespace     = "nodeclaim-ns"
+	SubscriptionID       = "00000000-0000-0000-0000-000000000000"
+	NodeResourceGroup    = "nodeRG"
+)
+
+// NodeClaimBuilder builds a kaito NodeClaim. The zero configuration is a nodeclaim labeled
+// as owned by a kaito workspace, with a provider id pointing at instance 0 of its agent pool's scale set.
+type NodeClaimBuilder struct {
+	nodeClaim     *karpenterv1.NodeClaim
+	setProviderID bool
+}
+
+// NodeClaim returns a builder for the default kaito NodeClaim.
+func NodeClaim() *NodeClaimBuilder {
+	return &NodeClaimBuilder{
+		nodeClaim: &karpenterv1.NodeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      DefaultNodeClaimName,
+				Namespace: DefaultNamespace,
+				Labels: map[string]string{
+					"kaito.sh/workspace":         "none",
+					karpenterv1.NodePoolLabelKey: "kaito",
+				},
+			},
+			Spec: karpenterv1.NodeClaimSpec{
+				NodeClassRef: &karpenterv1.NodeClassReference{},
+			},
+		},
+		setProviderID: true,
+	}
+}
+
+func (b *NodeClaimBuilder) WithName(name string) *NodeClaimBuilder {
+	b.nodeClaim.Name = name
+	return b
+}
+
+func (b *NodeClaimBuilder) WithLabels(labels map[string]string) *NodeClaimBuilder {
+	for k, v := range labels {
+		b.nodeClaim.Labels[k] = v
+	}
+	return b
+}
+
+// WithoutLabels removes the labels, including the default ones.
+func (b *NodeClaimBuilder) WithoutLabels(keys ...string) *NodeClaimBuilder {
+	for _, k := range keys {
+		delete(b.nodeClaim.Labels, k)
+	}
+	return b
+}
+
+func (b *NodeClaimBuilder) WithAnnotations(annotations map[string]string) *NodeClaimBuilder {
+	if b.nodeClaim.Annotations == nil {
+		b.nodeClaim.Annotations = map[string]string{}
+	}
+	for k, v := range annotations {
+		b.nodeClaim.Annotations[k] = v
+	}
+	return b
+}
+
+func (b *NodeClaimBuilder) WithNodeClassRef(kind, name string) *NodeClaimBuilder {
+	b.nodeClaim.Spec.NodeClassRef = &karpenterv1.NodeClassReference{Kind: kind, Name: name}
+	return b
+}
+
+func (b *NodeClaimBuilder) WithTaints(taints ...v1.Taint) *NodeClaimBuilder {
+	b.nodeClaim.Spec.Taints = append(b.nodeClaim.Spec.Taints, taints...)
+	return b
+}
+
+func (b *NodeClaimBuilder) WithStartupTaints(taints ...v1.Taint) *NodeClaimBuilder {
+	b.nodeClaim.Spec.StartupTaints = append(b.nodeClaim.Spec.StartupTaints, taints...)
+	return b
+}
+
+// WithRequirement adds a requirement with a MinValues of 1, as kaito sets on every requirement it generates.
+func (b *NodeClaimBuilder) WithRequirement(key string, operator v1.NodeSelectorOperator, values ...string) *NodeClaimBuilder {
+	b.nodeClaim.Spec.Requirements = append(b.nodeClaim.Spec.Requirements, karpenterv1.NodeSelectorRequirementWithMinValues{
+		NodeSelectorRequirement: v1.NodeSelectorRequirement{
+			Key:      key,
+			Operator: operator,
+			Values:   values,
+		},
+		MinValues: to.Ptr(int(1)),
+	})
+	return b
+}
+
+// WithNodeSelectorRequirements adds the requirements, as WithRequirement does.
+func (b *NodeClaimBuilder) WithNodeSelectorRequirements(requirements ...v1.NodeSelectorRequirement) *NodeClaimBuilder {
+	for _, r := range requirements {
+		b.WithRequirement(r.Key, r.Operator, r.Values...)
+	}
+	return b
+}
+
+// WithInstanceType requires the given VM sizes.
+func (b *NodeClaimBuilder) WithInstanceType(instanceTypes ...string) *NodeClaimBuilder {
+	return b.WithRequirement(v1.LabelInstanceTypeStable, v1.NodeSelectorOpIn, instanceTypes...)
+}
+
+// WithSpot requires the spot capacity type.
+func (b *NodeClaimBuilder) WithSpot() *NodeClaimBuilder {
+	return b.WithRequirement(karpenterv1.CapacityTypeLabelKey, v1.NodeSelectorOpIn, karpenterv1.CapacityTypeSpot)
+}
+
+// WithResource requests a quantity of the given resource.
+func (b *NodeClaimBuilder) WithResource(name v1.ResourceName, quantity resource.Quantity) *NodeClaimBuilder {
+	if b.nodeClaim.Spec.Resources.Requests == nil {
+		b.nodeClaim.Spec.Resources.Requests = v1.ResourceList{}
+	}
+	b.nodeClaim.Spec.Resources.Requests[name] = quantity
+	return b
+}
+
+// WithGPU requests count nvidia GPUs.
+func (b *NodeClaimBuilder) WithGPU(count int64) *NodeClaimBuilder {
+	return b.WithResource(ResourceNvidiaGPU, *resource.NewQuantity(count, resource.DecimalSI))
+}
+
+// WithStorage requests an OS disk of sizeGi gibibytes.
+func (b *NodeClaimBuilder) WithStorage(sizeGi int64) *NodeClaimBuilder {
+	return b.WithResource(v1.ResourceStorage, *resource.NewQuantity(sizeGi*1024*1024*1024, resource.BinarySI))
+}
+
+// WithoutProviderID leaves the status empty, as for a nodeclaim whose agent pool has not been created yet.
+func (b *NodeClaimBuilder) WithoutProviderID() *NodeClaimBuilder {
+	b.setProviderID = false
+	return b
+}
+
+// Deleting marks the nodeclaim as being deleted.
+func (b *NodeClaimBuilder) Deleting() *NodeClaimBuilder {
+	b.nodeClaim.DeletionTimestamp = &metav1.Time{Time: metav1.Now().Time}
+	return b
+}
+
+// Build returns the NodeClaim. The builder must not be reused afterwards.
+func (b *NodeClaimBuilder) Build() *karpenterv1.NodeClaim {
+	if b.setProviderID {
+		b.nodeClaim.Status.ProviderID = ProviderID(b.nodeClaim.Name)
+	}
+	return b.nodeClaim
+}
+
+// ProviderID returns the provider id of instance 0 of the scale set backing the named agent pool.
+func ProviderID(agentPoolName string) string {
+	return fmt.Sprintf("azure:///subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/virtualMachineScaleSets/aks-%s-20562481-vmss/virtualMachines/0",
+		SubscriptionID, NodeResourceGroup, agentPoolName)
+}
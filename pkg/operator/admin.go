@@ -0,0 +1,42 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/samber/lo"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const adminShutdownTimeout = 10 * time.Second
+
+// newAdminServer returns the server of the admin endpoints, which change the state of gpu-provisioner or expose ARM
+// details. unlike the metrics server it's not scraped by anyone, it listens on ADMIN_BIND_ADDRESS which is empty by
+// default, e.g. 127.0.0.1:8082 keeps it reachable only from the pod with kubectl port-forward, which is authorized
+// by the RBAC of the cluster.
+func newAdminServer(addr string, handler http.Handler) *manager.Server {
+	return &manager.Server{
+		Name: "admin",
+		Server: &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		ShutdownTimeout: lo.ToPtr(adminShutdownTimeout),
+	}
+}
@@ -0,0 +1,53 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminServer(t *testing.T) {
+	invalidator := &fakeCacheInvalidator{}
+	mux := http.NewServeMux()
+	mux.Handle(CacheInvalidationPath, cacheInvalidationHandler(invalidator))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := newAdminServer(listener.Addr().String(), mux)
+	server.Listener = listener
+	// the admin endpoints are served by every replica, e.g. caches are flushed on the replica receiving the request.
+	assert.False(t, server.NeedLeaderElection())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- server.Start(ctx)
+	}()
+
+	resp, err := http.Post("http://"+listener.Addr().String()+CacheInvalidationPath, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp.Body.Close()
+	assert.Equal(t, 1, invalidator.invalidated)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,43 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"net/http"
+)
+
+// CacheInvalidationPath is served by the admin server, a POST request to it flushes the caches of gpu-provisioner.
+const CacheInvalidationPath = "/cache/invalidate"
+
+type cacheInvalidator interface {
+	InvalidateCache()
+}
+
+// cacheInvalidationHandler flushes the caches on demand, e.g. for operators who just received a quota increase or
+// a new SKU enablement and don't want to wait for cache expiration.
+func cacheInvalidationHandler(invalidators ...cacheInvalidator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		for _, invalidator := range invalidators {
+			invalidator.InvalidateCache()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
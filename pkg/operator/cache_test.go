@@ -0,0 +1,65 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCacheInvalidator struct {
+	invalidated int
+}
+
+func (f *fakeCacheInvalidator) InvalidateCache() {
+	f.invalidated++
+}
+
+func TestCacheInvalidationHandler(t *testing.T) {
+	testCases := []struct {
+		name                string
+		method              string
+		expectedStatus      int
+		expectedInvalidated int
+	}{
+		{
+			name:                "Successfully invalidate cache",
+			method:              http.MethodPost,
+			expectedStatus:      http.StatusNoContent,
+			expectedInvalidated: 1,
+		},
+		{
+			name:           "Fail to invalidate cache because of unsupported method",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			invalidator := &fakeCacheInvalidator{}
+			recorder := httptest.NewRecorder()
+
+			cacheInvalidationHandler(invalidator).ServeHTTP(recorder, httptest.NewRequest(tc.method, CacheInvalidationPath, nil))
+
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+			assert.Equal(t, tc.expectedInvalidated, invalidator.invalidated)
+		})
+	}
+}
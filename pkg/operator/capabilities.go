@@ -0,0 +1,108 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/azure/gpu-provisioner/pkg/auth"
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/azure/gpu-provisioner/pkg/utils"
+	"github.com/azure/gpu-provisioner/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	"knative.dev/pkg/logging"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+const (
+	operatorSubsystem = "gpu_provisioner"
+	featureLabel      = "feature"
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(BuildInfo, FeatureEnabled)
+}
+
+var (
+	BuildInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: operatorSubsystem,
+			Name:      "build_info",
+			Help:      "Always 1. Labeled by the version, git commit and build date of gpu-provisioner and the go version it was built with.",
+		},
+		[]string{"version", "git_commit", "build_date", "go_version"},
+	)
+	FeatureEnabled = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: operatorSubsystem,
+			Name:      "feature_enabled",
+			Help:      "Whether an optional feature of gpu-provisioner is enabled(1) or not(0). Labeled by feature.",
+		},
+		[]string{featureLabel},
+	)
+)
+
+// envFeatures are the optional features enabled by env, with the defaults used by the components reading them.
+var envFeatures = []struct {
+	name string
+	env  string
+	def  bool
+}{
+	{name: "spot-agentpools", env: "ENABLE_SPOT_AGENTPOOLS"},
+	{name: "resource-graph-listing", env: "LIST_AGENTPOOLS_WITH_RESOURCE_GRAPH"},
+	{name: "gpu-health-check", env: "GPU_HEALTH_CHECK"},
+	{name: "workload-safety-check", env: "WORKLOAD_SAFETY_CHECK"},
+	{name: "gc-dry-run", env: "GC_DRY_RUN"},
+	{name: "e2e-test-mode", env: "E2E_TEST_MODE"},
+}
+
+// capabilities returns whether each optional feature of gpu-provisioner is enabled.
+func capabilities(cfg *auth.Config) map[string]bool {
+	features := map[string]bool{
+		"default-tags":            utils.WithDefaultString("AGENTPOOL_TAGS", "") != "",
+		"auxiliary-tenants":       len(cfg.AuxiliaryTenantIDs) > 0,
+		"custom-resource-manager": cfg.ResourceManagerEndpoint != "" || cfg.ResourceManagerAudience != "",
+	}
+	for _, f := range envFeatures {
+		features[f.name] = utils.WithDefaultBool(f.env, f.def)
+	}
+	return features
+}
+
+// reportCapabilities logs and exports the build information and the enabled features of gpu-provisioner, so the
+// capabilities of a deployment can be seen at a glance.
+func reportCapabilities(ctx context.Context, cfg *auth.Config) {
+	BuildInfo.WithLabelValues(version.BuildVersion, version.GitCommit, version.BuildDate, runtime.Version()).Set(1)
+	features := capabilities(cfg)
+	for name, enabled := range features {
+		FeatureEnabled.WithLabelValues(name).Set(lo.Ternary(enabled, 1.0, 0.0))
+	}
+	logging.FromContext(ctx).With(
+		"version", version.BuildVersion,
+		"gitCommit", version.GitCommit,
+		"buildDate", version.BuildDate,
+		"goVersion", runtime.Version(),
+		"provider", ProviderAKS,
+		"deploymentMode", cfg.DeploymentMode,
+		"apiVersions", instance.APIVersions(),
+		"features", features,
+	).Infof("starting gpu-provisioner")
+}
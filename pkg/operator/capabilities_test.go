@@ -0,0 +1,51 @@
+/*
+	Copyright (c) Microsoft Corporation.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/azure/gpu-provisioner/pkg/auth"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportCapabilities(t *testing.T) {
+	t.Setenv("ENABLE_SPOT_AGENTPOOLS", "true")
+	t.Setenv("GC_DRY_RUN", "false")
+	t.Setenv("AGENTPOOL_TAGS", "team=ml")
+	cfg := &auth.Config{AuxiliaryTenantIDs: []string{"tenant-aux"}}
+
+	assert.Equal(t, map[string]bool{
+		"spot-agentpools":         true,
+		"resource-graph-listing":  false,
+		"gpu-health-check":        false,
+		"workload-safety-check":   false,
+		"gc-dry-run":              false,
+		"e2e-test-mode":           false,
+		"default-tags":            true,
+		"auxiliary-tenants":       true,
+		"custom-resource-manager": false,
+	}, capabilities(cfg))
+
+	reportCapabilities(context.Background(), cfg)
+	for feature, expected := range map[string]float64{"spot-agentpools": 1, "gc-dry-run": 0} {
+		metric := &dto.Metric{}
+		assert.NoError(t, FeatureEnabled.WithLabelValues(feature).Write(metric))
+		assert.Equal(t, expected, metric.GetGauge().GetValue(), feature)
+	}
+}
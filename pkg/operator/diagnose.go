@@ -0,0 +1,93 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/azure/gpu-provisioner/pkg/utils"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// DiagnoseFlag makes the controller run the diagnostic checks, print the report and exit instead of starting.
+const DiagnoseFlag = "--diagnose"
+
+type diagnoser interface {
+	Diagnose(ctx context.Context) []instance.DiagnosticCheck
+}
+
+// Diagnose runs the diagnostic checks with the configuration of the controller and writes the report to w. it
+// returns the exit code of the process, which is non-zero if any check failed.
+func Diagnose(ctx context.Context, w io.Writer) int {
+	azConfig, err := GetAzConfig()
+	if err != nil {
+		fmt.Fprintf(w, "[FAIL] configuration: getting azure config, %s\n", err)
+		return 1
+	}
+	azClient, err := instance.CreateAzClient(azConfig)
+	if err != nil {
+		fmt.Fprintf(w, "[FAIL] credentials: creating azure client, %s\n", err)
+		return 1
+	}
+	kubeClient, err := client.New(config.GetConfigOrDie(), client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		fmt.Fprintf(w, "[FAIL] kubernetes: creating kube client, %s\n", err)
+		return 1
+	}
+	kubeClient = newTimeoutClient(kubeClient, utils.WithDefaultDuration("KUBE_REQUEST_TIMEOUT", 30*time.Second))
+	fmt.Fprintf(w, "cluster %s/%s in %s, subscription %s\n", azConfig.ResourceGroup, azConfig.ClusterName, azConfig.Location, azConfig.SubscriptionID)
+	provider := instance.NewProvider(azClient, kubeClient, azConfig.ResourceGroup, azConfig.ClusterName)
+	code := writeDiagnosis(ctx, w, provider)
+	// the operation index is only available to the diagnosis if the controller persists it.
+	if err := provider.LoadOperations(ctx); err != nil {
+		fmt.Fprintf(w, "loading operation index, %s\n", err)
+	}
+	writeOperations(w, provider)
+	return code
+}
+
+func writeOperations(w io.Writer, index operationIndex) {
+	records := index.Operations()
+	if len(records) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "operations:")
+	for _, record := range records {
+		fmt.Fprintf(w, "%s %s %s %s", record.Timestamp.Format(time.RFC3339), record.NodeClaim, record.Operation, record.OperationID)
+		if record.Error != "" {
+			fmt.Fprintf(w, " failed: %s", record.Error)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func writeDiagnosis(ctx context.Context, w io.Writer, d diagnoser) int {
+	code := 0
+	for _, check := range d.Diagnose(ctx) {
+		result := "PASS"
+		if !check.Passed {
+			result, code = "FAIL", 1
+		}
+		fmt.Fprintf(w, "[%s] %s: %s\n", result, check.Name, check.Message)
+	}
+	return code
+}
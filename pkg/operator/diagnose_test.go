@@ -0,0 +1,61 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDiagnoser struct {
+	checks []instance.DiagnosticCheck
+}
+
+func (f *fakeDiagnoser) Diagnose(_ context.Context) []instance.DiagnosticCheck {
+	return f.checks
+}
+
+func TestWriteDiagnosis(t *testing.T) {
+	testcases := map[string]struct {
+		checks         []instance.DiagnosticCheck
+		expectedCode   int
+		expectedReport string
+	}{
+		"all checks pass": {
+			checks:         []instance.DiagnosticCheck{{Name: "credentials", Passed: true, Message: "acquired ARM token"}},
+			expectedReport: "[PASS] credentials: acquired ARM token\n",
+		},
+		"a check fails": {
+			checks: []instance.DiagnosticCheck{
+				{Name: "credentials", Passed: true, Message: "acquired ARM token"},
+				{Name: "quota", Message: "no instance type within quota for nc1"},
+			},
+			expectedCode:   1,
+			expectedReport: "[PASS] credentials: acquired ARM token\n[FAIL] quota: no instance type within quota for nc1\n",
+		},
+	}
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			assert.Equal(t, tc.expectedCode, writeDiagnosis(context.Background(), w, &fakeDiagnoser{checks: tc.checks}))
+			assert.Equal(t, tc.expectedReport, w.String())
+		})
+	}
+}
@@ -0,0 +1,88 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"slices"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	ProviderAKS = "aks"
+
+	ProviderMetricLabel    = "provider"
+	ClusterNameMetricLabel = "cluster_name"
+
+	ProviderAnnotationKey    = "kaito.sh/provider"
+	ClusterNameAnnotationKey = "kaito.sh/cluster-name"
+)
+
+// labeledRegistry adds the provider dimension labels to all metrics gathered from the wrapped registry, including
+// metrics registered by karpenter and controller-runtime before the operator is created.
+type labeledRegistry struct {
+	crmetrics.RegistererGatherer
+	labels []*dto.LabelPair
+}
+
+func newLabeledRegistry(registry crmetrics.RegistererGatherer, labels map[string]string) *labeledRegistry {
+	r := &labeledRegistry{RegistererGatherer: registry}
+	for name, value := range labels {
+		r.labels = append(r.labels, &dto.LabelPair{Name: lo.ToPtr(name), Value: lo.ToPtr(value)})
+	}
+	return r
+}
+
+func (r *labeledRegistry) Gather() ([]*dto.MetricFamily, error) {
+	families, err := r.RegistererGatherer.Gather()
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			for _, label := range r.labels {
+				// labels set by the metric itself take precedence over the dimension labels.
+				if !lo.ContainsBy(metric.Label, func(l *dto.LabelPair) bool { return l.GetName() == label.GetName() }) {
+					metric.Label = append(metric.Label, label)
+				}
+			}
+			slices.SortFunc(metric.Label, func(a, b *dto.LabelPair) int {
+				return strings.Compare(a.GetName(), b.GetName())
+			})
+		}
+	}
+	return families, err
+}
+
+// annotatedEventRecorder adds the provider dimension annotations to all events.
+type annotatedEventRecorder struct {
+	record.EventRecorder
+	annotations map[string]string
+}
+
+func (r *annotatedEventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.EventRecorder.AnnotatedEventf(object, r.annotations, eventtype, reason, "%s", message)
+}
+
+func (r *annotatedEventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.AnnotatedEventf(object, r.annotations, eventtype, reason, messageFmt, args...)
+}
+
+func (r *annotatedEventRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.AnnotatedEventf(object, lo.Assign(r.annotations, annotations), eventtype, reason, messageFmt, args...)
+}
@@ -0,0 +1,75 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabeledRegistryGather(t *testing.T) {
+	testCases := []struct {
+		name           string
+		metricLabels   map[string]string
+		expectedLabels map[string]string
+	}{
+		{
+			name:         "Add provider and cluster name labels to metrics",
+			metricLabels: map[string]string{"controller": "gc"},
+			expectedLabels: map[string]string{
+				"controller":           "gc",
+				ProviderMetricLabel:    ProviderAKS,
+				ClusterNameMetricLabel: "test-cluster",
+			},
+		},
+		{
+			name:         "Keep provider label set by the metric",
+			metricLabels: map[string]string{ProviderMetricLabel: "arc"},
+			expectedLabels: map[string]string{
+				ProviderMetricLabel:    "arc",
+				ClusterNameMetricLabel: "test-cluster",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			registry := newLabeledRegistry(prometheus.NewRegistry(), map[string]string{
+				ProviderMetricLabel:    ProviderAKS,
+				ClusterNameMetricLabel: "test-cluster",
+			})
+			counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_total", Help: "test", ConstLabels: tc.metricLabels})
+			registry.MustRegister(counter)
+			counter.Inc()
+
+			families, err := registry.Gather()
+			assert.NoError(t, err)
+			assert.Len(t, families, 1)
+			assert.Len(t, families[0].Metric, 1)
+
+			labels := map[string]string{}
+			var names []string
+			for _, l := range families[0].Metric[0].Label {
+				labels[l.GetName()] = l.GetValue()
+				names = append(names, l.GetName())
+			}
+			assert.Equal(t, tc.expectedLabels, labels)
+			assert.IsNonDecreasing(t, names)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/azure/gpu-provisioner/pkg/cloudprovider"
+	"github.com/samber/lo"
+)
+
+// RecentErrorsPath is served by the admin server, a GET request to it returns the recent errors of cloudprovider
+// operations as JSON, the oldest first, optionally filtered by the operation query parameter, e.g.
+//
+//	curl localhost:8082/errors?operation=create
+const RecentErrorsPath = "/errors"
+
+type errorLog interface {
+	RecentErrors() []cloudprovider.ErrorRecord
+}
+
+// recentErrorsHandler serves the recent errors of the cloudprovider for quick triage, together with the
+// seconds_since_last_success gauges they tell whether an operation is failing or not attempted at all.
+func recentErrorsHandler(log errorLog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		records := log.RecentErrors()
+		if operation := r.URL.Query().Get("operation"); operation != "" {
+			records = lo.Filter(records, func(record cloudprovider.ErrorRecord, _ int) bool { return record.Operation == operation })
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(records)
+	})
+}
@@ -0,0 +1,87 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/azure/gpu-provisioner/pkg/cloudprovider"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeErrorLog struct {
+	records []cloudprovider.ErrorRecord
+}
+
+func (f *fakeErrorLog) RecentErrors() []cloudprovider.ErrorRecord {
+	return f.records
+}
+
+func TestRecentErrorsHandler(t *testing.T) {
+	log := &fakeErrorLog{records: []cloudprovider.ErrorRecord{
+		{Operation: "create", NodeClaim: "ws1", Error: "QuotaExceeded"},
+		{Operation: "list", Error: "internal server error"},
+		{Operation: "create", NodeClaim: "ws2", Error: "SkuNotAvailable"},
+	}}
+	testCases := []struct {
+		name           string
+		method         string
+		target         string
+		expectedStatus int
+		expectedErrors []string
+	}{
+		{
+			name:           "List all errors",
+			method:         http.MethodGet,
+			target:         RecentErrorsPath,
+			expectedStatus: http.StatusOK,
+			expectedErrors: []string{"QuotaExceeded", "internal server error", "SkuNotAvailable"},
+		},
+		{
+			name:           "List errors of an operation",
+			method:         http.MethodGet,
+			target:         RecentErrorsPath + "?operation=create",
+			expectedStatus: http.StatusOK,
+			expectedErrors: []string{"QuotaExceeded", "SkuNotAvailable"},
+		},
+		{
+			name:           "Fail to list errors because of unsupported method",
+			method:         http.MethodPost,
+			target:         RecentErrorsPath,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+
+			recentErrorsHandler(log).ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.target, nil))
+
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			var records []cloudprovider.ErrorRecord
+			assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&records))
+			assert.Equal(t, tc.expectedErrors, lo.Map(records, func(record cloudprovider.ErrorRecord, _ int) string { return record.Error }))
+		})
+	}
+}
@@ -0,0 +1,131 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/azure/gpu-provisioner/pkg/utils/opts"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newNodeClient returns the kube client used to look up nodes of agent pools. nodes are looked up in the cluster of
+// the kubeconfig if it's set, e.g. when gpu-provisioner runs in a management cluster, otherwise kubeClient is used.
+func newNodeClient(kubeClient client.Client, kubeconfig string, timeout time.Duration) (client.Client, error) {
+	if kubeconfig == "" {
+		return kubeClient, nil
+	}
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig %s, %w", kubeconfig, err)
+	}
+	nodeClient, err := client.New(config, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		return nil, fmt.Errorf("creating kube client for kubeconfig %s, %w", kubeconfig, err)
+	}
+	return newTimeoutClient(nodeClient, timeout), nil
+}
+
+// timeoutClient applies the request timeout to every call of the wrapped kube client, so a hung connection can't
+// stall a reconcile indefinitely.
+type timeoutClient struct {
+	client.Client
+	timeout time.Duration
+}
+
+func newTimeoutClient(c client.Client, timeout time.Duration) client.Client {
+	return &timeoutClient{Client: c, timeout: timeout}
+}
+
+func (c *timeoutClient) do(ctx context.Context, method string, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	err := fn(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		opts.RequestTimeoutsTotal.WithLabelValues(opts.KubeClient, method).Inc()
+	}
+	return err
+}
+
+func (c *timeoutClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return c.do(ctx, "Get", func(ctx context.Context) error { return c.Client.Get(ctx, key, obj, opts...) })
+}
+
+func (c *timeoutClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.do(ctx, "List", func(ctx context.Context) error { return c.Client.List(ctx, list, opts...) })
+}
+
+func (c *timeoutClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return c.do(ctx, "Create", func(ctx context.Context) error { return c.Client.Create(ctx, obj, opts...) })
+}
+
+func (c *timeoutClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return c.do(ctx, "Delete", func(ctx context.Context) error { return c.Client.Delete(ctx, obj, opts...) })
+}
+
+func (c *timeoutClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return c.do(ctx, "Update", func(ctx context.Context) error { return c.Client.Update(ctx, obj, opts...) })
+}
+
+func (c *timeoutClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return c.do(ctx, "Patch", func(ctx context.Context) error { return c.Client.Patch(ctx, obj, patch, opts...) })
+}
+
+func (c *timeoutClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	return c.do(ctx, "DeleteAllOf", func(ctx context.Context) error { return c.Client.DeleteAllOf(ctx, obj, opts...) })
+}
+
+func (c *timeoutClient) Status() client.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+func (c *timeoutClient) SubResource(subResource string) client.SubResourceClient {
+	return &timeoutSubResourceClient{SubResourceClient: c.Client.SubResource(subResource), client: c}
+}
+
+type timeoutSubResourceClient struct {
+	client.SubResourceClient
+	client *timeoutClient
+}
+
+func (c *timeoutSubResourceClient) Get(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	return c.client.do(ctx, "SubResourceGet", func(ctx context.Context) error {
+		return c.SubResourceClient.Get(ctx, obj, subResource, opts...)
+	})
+}
+
+func (c *timeoutSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	return c.client.do(ctx, "SubResourceCreate", func(ctx context.Context) error {
+		return c.SubResourceClient.Create(ctx, obj, subResource, opts...)
+	})
+}
+
+func (c *timeoutSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	return c.client.do(ctx, "SubResourceUpdate", func(ctx context.Context) error {
+		return c.SubResourceClient.Update(ctx, obj, opts...)
+	})
+}
+
+func (c *timeoutSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	return c.client.do(ctx, "SubResourcePatch", func(ctx context.Context) error {
+		return c.SubResourceClient.Patch(ctx, obj, patch, opts...)
+	})
+}
@@ -0,0 +1,118 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestTimeoutClient(t *testing.T) {
+	testCases := []struct {
+		name          string
+		delay         time.Duration
+		expectedError error
+	}{
+		{
+			name: "Successfully get node before the request timeout",
+		},
+		{
+			name:          "Fail to get node because the request timed out",
+			delay:         time.Minute,
+			expectedError: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			node := &corev1.Node{}
+			node.Name = "test-node"
+			kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(node).WithInterceptorFuncs(interceptor.Funcs{
+				Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+					select {
+					case <-time.After(tc.delay):
+						return c.Get(ctx, key, obj, opts...)
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				},
+			}).Build()
+
+			err := newTimeoutClient(kubeClient, 100*time.Millisecond).Get(context.Background(), client.ObjectKeyFromObject(node), &corev1.Node{})
+			assert.ErrorIs(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestNewNodeClient(t *testing.T) {
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	assert.NoError(t, os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: provisioned
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: provisioned
+  context:
+    cluster: provisioned
+current-context: provisioned
+`), 0600))
+
+	testCases := []struct {
+		name             string
+		kubeconfig       string
+		expectKubeClient bool
+		expectedError    bool
+	}{
+		{
+			name:             "Use the kube client without kubeconfig",
+			expectKubeClient: true,
+		},
+		{
+			name:       "Create a client of the provisioned cluster",
+			kubeconfig: kubeconfig,
+		},
+		{
+			name:          "Fail because the kubeconfig doesn't exist",
+			kubeconfig:    filepath.Join(t.TempDir(), "missing"),
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClient, err := newNodeClient(kubeClient, tc.kubeconfig, time.Second)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectKubeClient, nodeClient == kubeClient)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/samber/lo"
+)
+
+// OperationIndexPath is served by the admin server, a GET request to it returns the ARM operations started on
+// agent pools as JSON, optionally filtered by the nodeclaim query parameter, e.g.
+//
+//	curl localhost:8082/operations?nodeclaim=ws1a2b3c
+const OperationIndexPath = "/operations"
+
+type operationIndex interface {
+	Operations() []instance.OperationRecord
+}
+
+// operationIndexHandler serves the operation ids of nodeclaims, so they can be attached to support requests without
+// searching the logs.
+func operationIndexHandler(index operationIndex) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		records := index.Operations()
+		if nodeClaim := r.URL.Query().Get("nodeclaim"); nodeClaim != "" {
+			records = lo.Filter(records, func(record instance.OperationRecord, _ int) bool { return record.NodeClaim == nodeClaim })
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(records)
+	})
+}
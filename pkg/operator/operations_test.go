@@ -0,0 +1,90 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeOperationIndex struct {
+	records []instance.OperationRecord
+}
+
+func (f *fakeOperationIndex) Operations() []instance.OperationRecord {
+	return f.records
+}
+
+func TestOperationIndexHandler(t *testing.T) {
+	index := &fakeOperationIndex{records: []instance.OperationRecord{
+		{NodeClaim: "ws1", Operation: "create", OperationID: "op1"},
+		{NodeClaim: "ws2", Operation: "create", OperationID: "op2", Error: "QuotaExceeded"},
+		{NodeClaim: "ws1", Operation: "resume", OperationID: "op3"},
+	}}
+	testCases := []struct {
+		name                 string
+		method               string
+		target               string
+		expectedStatus       int
+		expectedOperationIDs []string
+	}{
+		{
+			name:                 "List all operations",
+			method:               http.MethodGet,
+			target:               OperationIndexPath,
+			expectedStatus:       http.StatusOK,
+			expectedOperationIDs: []string{"op1", "op2", "op3"},
+		},
+		{
+			name:                 "List operations of a nodeclaim",
+			method:               http.MethodGet,
+			target:               OperationIndexPath + "?nodeclaim=ws1",
+			expectedStatus:       http.StatusOK,
+			expectedOperationIDs: []string{"op1", "op3"},
+		},
+		{
+			name:           "Fail to list operations because of unsupported method",
+			method:         http.MethodPost,
+			target:         OperationIndexPath,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+
+			operationIndexHandler(index).ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.target, nil))
+
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			records := []instance.OperationRecord{}
+			assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&records))
+			operationIDs := []string{}
+			for _, record := range records {
+				operationIDs = append(operationIDs, record.OperationID)
+			}
+			assert.Equal(t, tc.expectedOperationIDs, operationIDs)
+		})
+	}
+}
@@ -18,18 +18,52 @@ package operator
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"time"
 
+	"github.com/awslabs/operatorpkg/controller"
 	"github.com/azure/gpu-provisioner/pkg/auth"
+	"github.com/azure/gpu-provisioner/pkg/cloudprovider"
 	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/azure/gpu-provisioner/pkg/utils"
+	"github.com/azure/gpu-provisioner/pkg/utils/pressure"
+	"github.com/samber/lo"
 	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/karpenter/pkg/events"
 	"sigs.k8s.io/karpenter/pkg/operator"
 )
 
+const (
+	cacheWarmUpTimeout = time.Minute
+	// eventSource is the source component of events, it's the same as the one used by karpenter.
+	eventSource = "karpenter"
+)
+
 // Operator is injected into the AWS CloudProvider's factories
 type Operator struct {
 	*operator.Operator
 	InstanceProvider *instance.Provider
+
+	kubeClient client.Client
+	nodeClient client.Client
+	// warmedUp is closed once the instance cache warm-up is done or timed out.
+	warmedUp chan struct{}
+}
+
+// GetClient returns the kube client of the manager with the request timeout applied to every call.
+func (o *Operator) GetClient() client.Client {
+	return o.kubeClient
+}
+
+// GetNodeClient returns the kube client used to look up nodes of agent pools, it's the client of the provisioned
+// cluster if NODE_KUBECONFIG is set, otherwise the same as GetClient.
+func (o *Operator) GetNodeClient() client.Client {
+	return o.nodeClient
 }
 
 func NewOperator(ctx context.Context, operator *operator.Operator) (context.Context, *Operator) {
@@ -46,17 +80,83 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 		panic(fmt.Sprintf("Configure azure client fails. Please ensure federatedcredential has been created for identity %s.", os.Getenv("AZURE_CLIENT_ID")))
 	}
 
+	// metrics, events and logs are tagged with the provider type and cluster name, so fleets of clusters can be
+	// sliced per backend in dashboards.
+	crmetrics.Registry = newLabeledRegistry(crmetrics.Registry, map[string]string{
+		ProviderMetricLabel:    ProviderAKS,
+		ClusterNameMetricLabel: azConfig.ClusterName,
+	})
+	operator.EventRecorder = events.NewRecorder(&annotatedEventRecorder{
+		EventRecorder: operator.GetEventRecorderFor(eventSource),
+		annotations: map[string]string{
+			ProviderAnnotationKey:    ProviderAKS,
+			ClusterNameAnnotationKey: azConfig.ClusterName,
+		},
+	})
+	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("provider", ProviderAKS, "cluster", azConfig.ClusterName))
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("provider", ProviderAKS, "cluster", azConfig.ClusterName))
+	reportCapabilities(ctx, azConfig)
+	// low priority controllers back off while the apiserver throttles the kube clients.
+	pressure.Install()
+
+	kubeRequestTimeout := utils.WithDefaultDuration("KUBE_REQUEST_TIMEOUT", 30*time.Second)
+	kubeClient := newTimeoutClient(operator.GetClient(), kubeRequestTimeout)
+	nodeClient, err := newNodeClient(kubeClient, utils.WithDefaultString("NODE_KUBECONFIG", ""), kubeRequestTimeout)
+	if err != nil {
+		panic(fmt.Sprintf("creating node client, %v", err))
+	}
 	instanceProvider := instance.NewProvider(
 		azClient,
-		operator.GetClient(),
+		kubeClient,
 		azConfig.ResourceGroup,
 		azConfig.ClusterName,
-	)
+	).WithNodeClient(nodeClient).WithAPIReader(operator.Manager.GetAPIReader())
+
+	// endpoints changing the state of gpu-provisioner, issuing ARM calls or exposing ARM details are served by the admin
+	// server, which is disabled by default, instead of the metrics server which has no authentication.
+	admin := http.NewServeMux()
+	admin.Handle(CacheInvalidationPath, cacheInvalidationHandler(instanceProvider))
+	admin.Handle(InstanceTypeReportPath, instanceTypeReportHandler(instanceProvider))
+	admin.Handle(OperationIndexPath, operationIndexHandler(instanceProvider))
+	admin.Handle(RecentErrorsPath, recentErrorsHandler(cloudprovider.Health))
+	if addr := utils.WithDefaultString("ADMIN_BIND_ADDRESS", ""); addr != "" {
+		lo.Must0(operator.Manager.Add(newAdminServer(addr, admin)))
+	}
+
+	// runnables without leader election preference are started after the leader lease is acquired, the controllers
+	// registered by WithControllers wait for warmedUp before they start.
+	warmedUp := make(chan struct{})
+	lo.Must0(operator.Manager.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		warmUpCtx, cancel := context.WithTimeout(ctx, cacheWarmUpTimeout)
+		defer cancel()
+		if err := instanceProvider.WarmUp(warmUpCtx); err != nil {
+			// cache warming is best effort, controllers fall back to ARM calls on cache miss.
+			logging.FromContext(ctx).Errorf("warming up instance cache, %s", err)
+		}
+		close(warmedUp)
+		if err := instanceProvider.LoadOperations(ctx); err != nil {
+			logging.FromContext(ctx).Errorf("loading operation index, %s", err)
+		}
+		return nil
+	})))
 
 	return ctx, &Operator{
 		Operator:         operator,
 		InstanceProvider: instanceProvider,
+		kubeClient:       kubeClient,
+		nodeClient:       nodeClient,
+		warmedUp:         warmedUp,
+	}
+}
+
+// WithControllers registers the controllers with the manager, the leader elected ones are started once the
+// instance cache is warmed up, which takes at most cacheWarmUpTimeout.
+func (o *Operator) WithControllers(ctx context.Context, controllers ...controller.Controller) *Operator {
+	mgr := &warmUpGate{Manager: o.Manager, warmedUp: o.warmedUp}
+	for _, c := range controllers {
+		lo.Must0(c.Register(ctx, mgr))
 	}
+	return o
 }
 
 func GetAzConfig() (*auth.Config, error) {
@@ -0,0 +1,66 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// InstanceTypeReportPath is served by the admin server, a POST request to it with a JSON list of nodeclaim
+// requirements returns the gpu instance types they allow with availability, quota headroom and hourly price, e.g.
+//
+//	curl -X POST localhost:8082/instancetypes/report -d '[{"key":"kaito.sh/gpu-generation","operator":"In","values":["ampere"]}]'
+const InstanceTypeReportPath = "/instancetypes/report"
+
+type instanceTypeReporter interface {
+	InstanceTypeReports(ctx context.Context, requirements []karpenterv1.NodeSelectorRequirementWithMinValues) ([]instance.InstanceTypeReport, error)
+}
+
+// instanceTypeReportHandler reports what instance types nodeclaims with the requirements could be created with,
+// which helps authoring requirements before any nodeclaim is created.
+func instanceTypeReportHandler(reporter instanceTypeReporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		requirements := []karpenterv1.NodeSelectorRequirementWithMinValues{}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&requirements); err != nil {
+				http.Error(w, fmt.Sprintf("decoding requirements, %s", err), http.StatusBadRequest)
+				return
+			}
+		}
+		reports, err := reporter.InstanceTypeReports(r.Context(), requirements)
+		if err != nil {
+			if instance.IsCapacityTypeNotSupportedError(err) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(reports)
+	})
+}
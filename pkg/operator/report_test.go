@@ -0,0 +1,104 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/azure/gpu-provisioner/pkg/providers/instance"
+	"github.com/stretchr/testify/assert"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+type fakeInstanceTypeReporter struct {
+	requirements []karpenterv1.NodeSelectorRequirementWithMinValues
+	err          error
+}
+
+func (f *fakeInstanceTypeReporter) InstanceTypeReports(_ context.Context, requirements []karpenterv1.NodeSelectorRequirementWithMinValues) ([]instance.InstanceTypeReport, error) {
+	f.requirements = requirements
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []instance.InstanceTypeReport{{Name: "Standard_NC6s_v3", Available: true}}, nil
+}
+
+func TestInstanceTypeReportHandler(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		method               string
+		body                 string
+		reportErr            error
+		expectedStatus       int
+		expectedRequirements int
+		expectedReports      []instance.InstanceTypeReport
+	}{
+		{
+			name:                 "Successfully report instance types allowed by requirements",
+			method:               http.MethodPost,
+			body:                 `[{"key":"kaito.sh/gpu-generation","operator":"In","values":["volta"]}]`,
+			expectedStatus:       http.StatusOK,
+			expectedRequirements: 1,
+			expectedReports:      []instance.InstanceTypeReport{{Name: "Standard_NC6s_v3", Available: true}},
+		},
+		{
+			name:            "Successfully report instance types without requirements",
+			method:          http.MethodPost,
+			expectedStatus:  http.StatusOK,
+			expectedReports: []instance.InstanceTypeReport{{Name: "Standard_NC6s_v3", Available: true}},
+		},
+		{
+			name:           "Fail to report because of invalid requirements",
+			method:         http.MethodPost,
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Fail to report because capacity type is not supported",
+			method:         http.MethodPost,
+			body:           `[]`,
+			reportErr:      &instance.CapacityTypeNotSupportedError{CapacityTypes: []string{karpenterv1.CapacityTypeSpot}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Fail to report because of unsupported method",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter := &fakeInstanceTypeReporter{err: tc.reportErr}
+			recorder := httptest.NewRecorder()
+
+			instanceTypeReportHandler(reporter).ServeHTTP(recorder, httptest.NewRequest(tc.method, InstanceTypeReportPath, strings.NewReader(tc.body)))
+
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+			assert.Len(t, reporter.requirements, tc.expectedRequirements)
+			if tc.expectedReports != nil {
+				var reports []instance.InstanceTypeReport
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &reports))
+				assert.Equal(t, tc.expectedReports, reports)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// warmUpGate is the manager the controllers are registered with, it holds back the start of leader elected
+// runnables until the instance cache warm-up is done, so controllers reconciling right after leader election
+// are served from the warm cache.
+type warmUpGate struct {
+	manager.Manager
+	warmedUp <-chan struct{}
+}
+
+func (g *warmUpGate) Add(r manager.Runnable) error {
+	if !needsLeaderElection(r) {
+		return g.Manager.Add(r)
+	}
+	return g.Manager.Add(&gatedRunnable{Runnable: r, warmedUp: g.warmedUp})
+}
+
+// needsLeaderElection mirrors how the manager groups runnables, caches, servers and webhooks are started
+// regardless of the warm-up.
+func needsLeaderElection(r manager.Runnable) bool {
+	switch runnable := r.(type) {
+	case *manager.Server, webhook.Server, interface{ GetCache() cache.Cache }:
+		return false
+	case manager.LeaderElectionRunnable:
+		return runnable.NeedLeaderElection()
+	default:
+		return true
+	}
+}
+
+type gatedRunnable struct {
+	manager.Runnable
+	warmedUp <-chan struct{}
+}
+
+func (r *gatedRunnable) Start(ctx context.Context) error {
+	select {
+	case <-r.warmedUp:
+	case <-ctx.Done():
+		return nil
+	}
+	return r.Runnable.Start(ctx)
+}
+
+func (r *gatedRunnable) NeedLeaderElection() bool {
+	return true
+}
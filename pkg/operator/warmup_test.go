@@ -0,0 +1,90 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+type fakeManager struct {
+	manager.Manager
+	runnables []manager.Runnable
+}
+
+func (m *fakeManager) Add(r manager.Runnable) error {
+	m.runnables = append(m.runnables, r)
+	return nil
+}
+
+type leaderElectionRunnable struct {
+	manager.RunnableFunc
+	needLeaderElection bool
+}
+
+func (r leaderElectionRunnable) NeedLeaderElection() bool {
+	return r.needLeaderElection
+}
+
+func TestWarmUpGate(t *testing.T) {
+	started := make(chan struct{}, 2)
+	start := manager.RunnableFunc(func(context.Context) error {
+		started <- struct{}{}
+		return nil
+	})
+	warmedUp := make(chan struct{})
+	mgr := &fakeManager{}
+	gate := &warmUpGate{Manager: mgr, warmedUp: warmedUp}
+	assert.NoError(t, gate.Add(leaderElectionRunnable{RunnableFunc: start, needLeaderElection: true}))
+	assert.NoError(t, gate.Add(leaderElectionRunnable{RunnableFunc: start}))
+	assert.Len(t, mgr.runnables, 2)
+
+	// runnables without leader election are not gated.
+	_, gated := mgr.runnables[1].(*gatedRunnable)
+	assert.False(t, gated)
+
+	done := make(chan error)
+	go func() {
+		done <- mgr.runnables[0].Start(context.Background())
+	}()
+	select {
+	case <-started:
+		t.Fatal("leader elected runnable started before the warm-up is done")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.True(t, mgr.runnables[0].(manager.LeaderElectionRunnable).NeedLeaderElection())
+
+	close(warmedUp)
+	assert.NoError(t, <-done)
+	assert.Len(t, started, 1)
+}
+
+func TestGatedRunnableStopsBeforeWarmUp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := &gatedRunnable{
+		Runnable: manager.RunnableFunc(func(context.Context) error {
+			t.Fatal("runnable started before the warm-up is done")
+			return nil
+		}),
+		warmedUp: make(chan struct{}),
+	}
+	assert.NoError(t, r.Start(ctx))
+}
@@ -17,29 +17,105 @@ package instance
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
 
 	sdkerrors "github.com/Azure/azure-sdk-for-go-extensions/pkg/errors"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
 )
 
-func createAgentPool(ctx context.Context, client AgentPoolsAPI, rg, apName, clusterName string, ap armcontainerservice.AgentPool) (*armcontainerservice.AgentPool, error) {
+// createAgentPool creates or updates the agent pool and waits until the LRO completes, the id of the ARM operation
+// is returned as well.
+func createAgentPool(ctx context.Context, client AgentPoolsAPI, rg, apName, clusterName string, ap armcontainerservice.AgentPool) (*armcontainerservice.AgentPool, string, error) {
 	klog.InfoS("createAgentPool", "agentpool", apName)
 
-	poller, err := client.BeginCreateOrUpdate(ctx, rg, clusterName, apName, ap, nil)
+	// only the response of the initial request carries the operation, polling responses are not captured.
+	var rawResp *http.Response
+	poller, err := client.BeginCreateOrUpdate(runtime.WithCaptureResponse(ctx, &rawResp), rg, clusterName, apName, ap, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	operationID := operationIDOf(rawResp)
 	res, err := poller.PollUntilDone(ctx, nil)
 	if err != nil {
-		return nil, err
+		return nil, operationID, err
+	}
+	return &res.AgentPool, operationID, nil
+}
+
+// operationIDOf returns the id of the async ARM operation started by the request of resp, or the ARM request id if
+// the response has no Azure-AsyncOperation header.
+func operationIDOf(resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	if operation, err := url.Parse(resp.Header.Get("Azure-AsyncOperation")); err == nil && operation.Path != "" {
+		return path.Base(operation.Path)
 	}
-	return &res.AgentPool, nil
+	return resp.Header.Get("x-ms-request-id")
+}
+
+// agentPoolOperationPollInterval is the interval of checking an agent pool operation started by someone else.
+var agentPoolOperationPollInterval = 10 * time.Second
+
+// agentPoolOperationWaitTimeout bounds the wait for an agent pool operation started by someone else, so a stuck
+// operation doesn't block the caller forever.
+var agentPoolOperationWaitTimeout = 15 * time.Minute
+
+// AgentPoolOperationInProgressError is returned when an agent pool operation started by someone else doesn't
+// complete within agentPoolOperationWaitTimeout, the caller should retry later.
+type AgentPoolOperationInProgressError struct {
+	AgentPool string
+}
+
+func (e *AgentPoolOperationInProgressError) Error() string {
+	return fmt.Sprintf("operation on agentpool %q is still in progress after %s", e.AgentPool, agentPoolOperationWaitTimeout)
 }
 
-func deleteAgentPool(ctx context.Context, client AgentPoolsAPI, rg, clusterName, apName string) error {
+func IsAgentPoolOperationInProgressError(err error) bool {
+	var opErr *AgentPoolOperationInProgressError
+	return errors.As(err, &opErr)
+}
+
+// deleteAgentPool deletes the agent pool and waits until the deletion LRO completes. the deletion LRO is resumed
+// from resumeToken if it's not empty, and saveResumeToken is called with the resume token of a new deletion LRO.
+func deleteAgentPool(ctx context.Context, client AgentPoolsAPI, rg, clusterName, apName, resumeToken string, saveResumeToken func(string)) error {
 	klog.InfoS("deleteAgentPool", "agentpool", apName)
+	if resumeToken != "" {
+		poller, err := client.BeginDelete(ctx, rg, clusterName, apName, &armcontainerservice.AgentPoolsClientBeginDeleteOptions{ResumeToken: resumeToken})
+		if err == nil {
+			return pollAgentPoolDeletion(ctx, poller)
+		}
+		if azErr := sdkerrors.IsResponseError(err); azErr != nil && azErr.ErrorCode == "NotFound" {
+			return nil
+		}
+		klog.InfoS("failed to resume agentpool deletion, start a new one", "agentpool", apName, "error", err)
+	}
+
 	poller, err := client.BeginDelete(ctx, rg, clusterName, apName, nil)
+	for err != nil && isOperationInProgressError(err) {
+		// another operation(e.g. a deletion triggered by someone else) is in progress on the agent pool, adopt it
+		// instead of failing, and delete the agent pool again if it still exists when the operation completes.
+		klog.InfoS("deleteAgentPool waits for the in-progress operation", "agentpool", apName)
+		waited, waitErr := waitForAgentPoolOperation(ctx, client, rg, clusterName, apName)
+		if waitErr != nil {
+			err = waitErr
+			break
+		}
+		if !waited {
+			// the conflict is not caused by an in-progress operation
+			break
+		}
+		poller, err = client.BeginDelete(ctx, rg, clusterName, apName, nil)
+	}
 	if err != nil {
 		azErr := sdkerrors.IsResponseError(err)
 		if azErr != nil && azErr.ErrorCode == "NotFound" {
@@ -47,7 +123,16 @@ func deleteAgentPool(ctx context.Context, client AgentPoolsAPI, rg, clusterName,
 		}
 		return err
 	}
-	_, err = poller.PollUntilDone(ctx, nil)
+	if saveResumeToken != nil {
+		if token, err := poller.ResumeToken(); err == nil {
+			saveResumeToken(token)
+		}
+	}
+	return pollAgentPoolDeletion(ctx, poller)
+}
+
+func pollAgentPoolDeletion(ctx context.Context, poller *runtime.Poller[armcontainerservice.AgentPoolsClientDeleteResponse]) error {
+	_, err := poller.PollUntilDone(ctx, nil)
 	if err != nil {
 		azErr := sdkerrors.IsResponseError(err)
 		if azErr != nil && azErr.ErrorCode == "NotFound" {
@@ -57,6 +142,56 @@ func deleteAgentPool(ctx context.Context, client AgentPoolsAPI, rg, clusterName,
 	return err
 }
 
+// waitForAgentPoolOperation waits until the provisioning state of the agent pool is terminal and reports whether
+// an operation was in progress. NotFound error is returned if the agent pool is deleted by the operation, and an
+// AgentPoolOperationInProgressError if the operation doesn't complete within agentPoolOperationWaitTimeout.
+func waitForAgentPoolOperation(ctx context.Context, client AgentPoolsAPI, rg, clusterName, apName string) (bool, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, agentPoolOperationWaitTimeout)
+	defer cancel()
+	polls := 0
+	err := wait.PollUntilContextCancel(waitCtx, agentPoolOperationPollInterval, true, func(ctx context.Context) (bool, error) {
+		polls++
+		ap, err := getAgentPool(ctx, client, rg, clusterName, apName)
+		if err != nil {
+			return false, err
+		}
+		if ap.Properties == nil {
+			return true, nil
+		}
+		switch lo.FromPtr(ap.Properties.ProvisioningState) {
+		case "Succeeded", "Failed", "Canceled":
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil && ctx.Err() == nil && errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+		return polls > 1, &AgentPoolOperationInProgressError{AgentPool: apName}
+	}
+	return polls > 1, err
+}
+
+// abortAgentPoolOperation aborts the in-progress operation of the agent pool and waits until the abort completes,
+// the agent pool ends up in Canceled provisioning state.
+func abortAgentPoolOperation(ctx context.Context, client AgentPoolsAPI, rg, clusterName, apName string) error {
+	klog.InfoS("abortAgentPoolOperation", "agentpool", apName)
+	poller, err := client.BeginAbortLatestOperation(ctx, rg, clusterName, apName, nil)
+	if err != nil {
+		return err
+	}
+	_, err = poller.PollUntilDone(ctx, nil)
+	return err
+}
+
+func isOperationInProgressError(err error) bool {
+	azErr := sdkerrors.IsResponseError(err)
+	if azErr == nil {
+		return false
+	}
+	return azErr.StatusCode == http.StatusConflict ||
+		azErr.ErrorCode == "OperationNotAllowed" ||
+		azErr.ErrorCode == "AnotherOperationInProgress"
+}
+
 func getAgentPool(ctx context.Context, client AgentPoolsAPI, rg, clusterName, apName string) (*armcontainerservice.AgentPool, error) {
 	resp, err := client.Get(ctx, rg, clusterName, apName, nil)
 	if err != nil {
@@ -66,15 +201,27 @@ func getAgentPool(ctx context.Context, client AgentPoolsAPI, rg, clusterName, ap
 	return &resp.AgentPool, nil
 }
 
-func listAgentPools(ctx context.Context, client AgentPoolsAPI, rg, clusterName string) ([]*armcontainerservice.AgentPool, error) {
-	var apList []*armcontainerservice.AgentPool
+// forEachAgentPool fetches agent pools page by page and calls fn for each of them, so the whole agent pool list
+// is never held in memory. iteration stops when fn returns false or an error.
+func forEachAgentPool(ctx context.Context, client AgentPoolsAPI, rg, clusterName string, fn func(ap *armcontainerservice.AgentPool) (bool, error)) error {
 	pager := client.NewListPager(rg, clusterName, nil)
 	for pager.More() {
 		page, err := pager.NextPage(ctx)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		for i := range page.Value {
+			next, err := fn(page.Value[i])
+			if err != nil || !next {
+				return err
+			}
 		}
-		apList = append(apList, page.Value...)
 	}
-	return apList, nil
+	return nil
+}
+
+// isNotFoundAzError returns true if ARM responded the resource is not found.
+func isNotFoundAzError(err error) bool {
+	azErr := sdkerrors.IsResponseError(err)
+	return azErr != nil && azErr.ErrorCode == "NotFound"
 }
@@ -0,0 +1,85 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// maxAlternativeSKUs is the number of alternatives suggested when no instance type of a nodeclaim is available.
+const maxAlternativeSKUs = 3
+
+// SKUUnavailableError is returned when every instance type allowed by the nodeclaim requirements is restricted for
+// the subscription in the region.
+type SKUUnavailableError struct {
+	InstanceTypes []string
+	Location      string
+}
+
+func (e *SKUUnavailableError) Error() string {
+	return fmt.Sprintf("SKUs %v are not available in region %q", e.InstanceTypes, e.Location)
+}
+
+func IsSKUUnavailableError(err error) bool {
+	var suErr *SKUUnavailableError
+	return errors.As(err, &suErr)
+}
+
+// availableInstanceTypes drops the instance types which are restricted in the region, the order of instance types is
+// kept. instance types missing in skus are kept, ARM decides whether they can be created.
+func (p *Provider) availableInstanceTypes(skus map[string]SKU, instanceTypes []string) ([]string, error) {
+	available := lo.Filter(instanceTypes, func(instanceType string, _ int) bool {
+		sku, ok := skus[instanceType]
+		return !ok || !sku.Restricted
+	})
+	if len(available) != 0 {
+		return available, nil
+	}
+	err := &SKUUnavailableError{InstanceTypes: instanceTypes, Location: p.azClient.location}
+	alternatives := alternativeSKUs(skus, skus[instanceTypes[0]])
+	if len(alternatives) == 0 {
+		return nil, withRemediation(err, "allow instance types which are available in the region")
+	}
+	return nil, withRemediation(err, fmt.Sprintf("allow available instance types with the same gpu count, e.g. %s", strings.Join(alternatives, ", ")))
+}
+
+// alternativeSKUs returns the nearest available skus of the unavailable sku, which have the same gpu count. skus of
+// the same gpu generation come first, then skus with the closest gpu memory.
+func alternativeSKUs(skus map[string]SKU, unavailable SKU) []string {
+	candidates := lo.Filter(lo.Values(skus), func(sku SKU, _ int) bool {
+		return !sku.Restricted && sku.GPUCount > 0 && sku.GPUCount == unavailable.GPUCount
+	})
+	distance := func(sku SKU) int64 {
+		d := sku.GPUMemoryGiB - unavailable.GPUMemoryGiB
+		return max(d, -d)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		ci, cj := candidates[i], candidates[j]
+		if sameI, sameJ := ci.GPUGeneration == unavailable.GPUGeneration, cj.GPUGeneration == unavailable.GPUGeneration; sameI != sameJ {
+			return sameI
+		}
+		if distance(ci) != distance(cj) {
+			return distance(ci) < distance(cj)
+		}
+		return ci.Name < cj.Name
+	})
+	return lo.Map(lo.Slice(candidates, 0, maxAlternativeSKUs), func(sku SKU, _ int) string { return sku.Name })
+}
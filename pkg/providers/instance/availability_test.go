@@ -0,0 +1,69 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvailableInstanceTypes(t *testing.T) {
+	skus := map[string]SKU{
+		"Standard_NC24ads_A100_v4": {Name: "Standard_NC24ads_A100_v4", GPUCount: 1, GPUGeneration: "ampere", GPUMemoryGiB: 80, Restricted: true},
+		"Standard_NC6s_v3":         {Name: "Standard_NC6s_v3", GPUCount: 1, GPUGeneration: "volta", GPUMemoryGiB: 16},
+		"Standard_NC4as_T4_v3":     {Name: "Standard_NC4as_T4_v3", GPUCount: 1, GPUGeneration: "turing", GPUMemoryGiB: 16},
+		"Standard_NV36ads_A10_v5":  {Name: "Standard_NV36ads_A10_v5", GPUCount: 1, GPUGeneration: "ampere", GPUMemoryGiB: 24},
+		"Standard_NC48ads_A100_v4": {Name: "Standard_NC48ads_A100_v4", GPUCount: 2, GPUGeneration: "ampere", GPUMemoryGiB: 160},
+		"Standard_ND96asr_v4":      {Name: "Standard_ND96asr_v4", GPUCount: 8, GPUGeneration: "ampere", GPUMemoryGiB: 320, Restricted: true},
+		"Standard_D4s_v3":          {Name: "Standard_D4s_v3"},
+	}
+	testcases := map[string]struct {
+		instanceTypes []string
+		expected      []string
+		expectedHint  string
+	}{
+		"restricted instance types are dropped": {
+			instanceTypes: []string{"Standard_NC24ads_A100_v4", "Standard_NC6s_v3"},
+			expected:      []string{"Standard_NC6s_v3"},
+		},
+		"unknown instance types are kept": {
+			instanceTypes: []string{"Standard_NC24ads_A100_v4", "Standard_NC_unknown"},
+			expected:      []string{"Standard_NC_unknown"},
+		},
+		"alternatives have the same gpu count and prefer the same generation": {
+			instanceTypes: []string{"Standard_NC24ads_A100_v4"},
+			expectedHint:  "allow available instance types with the same gpu count, e.g. Standard_NV36ads_A10_v5, Standard_NC4as_T4_v3, Standard_NC6s_v3",
+		},
+		"no alternative": {
+			instanceTypes: []string{"Standard_ND96asr_v4"},
+			expectedHint:  "allow instance types which are available in the region",
+		},
+	}
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			p := &Provider{azClient: &AZClient{location: "eastus"}}
+			instanceTypes, err := p.availableInstanceTypes(skus, tc.instanceTypes)
+			if tc.expectedHint != "" {
+				assert.True(t, IsSKUUnavailableError(err))
+				assert.Equal(t, tc.expectedHint, RemediationOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, instanceTypes)
+		})
+	}
+}
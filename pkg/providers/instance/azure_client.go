@@ -17,6 +17,7 @@ package instance
 
 import (
 	"context"
+	"fmt"
 	"maps"
 	"net/http"
 
@@ -37,9 +38,23 @@ import (
 
 const (
 	RPReferer = "rp.e2e.ig.e2e-aks.azure.com"
+
+	// agentPoolsAPIVersion is the AKS API version of the vendored armcontainerservice client.
+	agentPoolsAPIVersion = "2024-01-01"
 )
 
+// APIVersions returns the versions of the Azure APIs called by gpu-provisioner by API.
+func APIVersions() map[string]string {
+	return map[string]string{
+		"agentPools":    agentPoolsAPIVersion,
+		"resourceSKUs":  resourceSKUsAPIVersion,
+		"resourceGraph": resourceGraphAPIVersion,
+		"computeUsages": computeUsagesAPIVersion,
+	}
+}
+
 type AgentPoolsAPI interface {
+	BeginAbortLatestOperation(ctx context.Context, resourceGroupName string, resourceName string, agentPoolName string, options *armcontainerservice.AgentPoolsClientBeginAbortLatestOperationOptions) (*runtime.Poller[armcontainerservice.AgentPoolsClientAbortLatestOperationResponse], error)
 	BeginCreateOrUpdate(ctx context.Context, resourceGroupName string, resourceName string, agentPoolName string, parameters armcontainerservice.AgentPool, options *armcontainerservice.AgentPoolsClientBeginCreateOrUpdateOptions) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error)
 	Get(ctx context.Context, resourceGroupName string, resourceName string, agentPoolName string, options *armcontainerservice.AgentPoolsClientGetOptions) (armcontainerservice.AgentPoolsClientGetResponse, error)
 	BeginDelete(ctx context.Context, resourceGroupName string, resourceName string, agentPoolName string, options *armcontainerservice.AgentPoolsClientBeginDeleteOptions) (*runtime.Poller[armcontainerservice.AgentPoolsClientDeleteResponse], error)
@@ -48,6 +63,20 @@ type AgentPoolsAPI interface {
 
 type AZClient struct {
 	agentPoolsClient AgentPoolsAPI
+	// resourceGraphClient is used to list agent pools owned by gpu-provisioner when it's not nil.
+	resourceGraphClient ResourceGraphAPI
+	subscriptionID      string
+	// retailPricesClient is used to get prices of instance types for price capped nodeclaims.
+	retailPricesClient RetailPricesAPI
+	location           string
+	// managedClustersClient is used to defer agent pool operations while the cluster is being upgraded.
+	managedClustersClient ManagedClustersAPI
+	// resourceSKUsClient is used to get the SKUs of instance types.
+	resourceSKUsClient ResourceSKUsAPI
+	// computeUsagesClient is used to get the vCPU quota headroom of instance types.
+	computeUsagesClient ComputeUsagesAPI
+	// resourceProvidersClient is used to diagnose the registration of required resource providers.
+	resourceProvidersClient ResourceProvidersAPI
 }
 
 func NewAZClientFromAPI(
@@ -76,7 +105,9 @@ func NewAZClient(cfg *auth.Config, env *azure.Environment) (*AZClient, error) {
 	var err error
 
 	if cfg.DeploymentMode == "managed" {
-		cred, err = azidentity.NewDefaultAzureCredential(nil)
+		cred, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+			AdditionallyAllowedTenants: cfg.AuxiliaryTenantIDs,
+		})
 	} else {
 		// deploymentMode value is "self-hosted" or "", then use the federated identity.
 		authorizer, uerr := auth.NewAuthorizer(cfg, env)
@@ -98,6 +129,8 @@ func NewAZClient(cfg *auth.Config, env *azure.Environment) (*AZClient, error) {
 	if isE2E {
 		opts = setArmClientOptions()
 	}
+	setResourceManager(opts, cfg.ResourceManagerEndpoint, cfg.ResourceManagerAudience)
+	opts.AuxiliaryTenants = cfg.AuxiliaryTenantIDs
 
 	agentPoolClient, err := armcontainerservice.NewAgentPoolsClient(cfg.SubscriptionID, cred, opts)
 	if err != nil {
@@ -105,9 +138,56 @@ func NewAZClient(cfg *auth.Config, env *azure.Environment) (*AZClient, error) {
 	}
 	klog.V(5).Infof("Created agent pool client %v using token credential", agentPoolClient)
 
-	return &AZClient{
-		agentPoolsClient: agentPoolClient,
-	}, nil
+	managedClustersClient, err := armcontainerservice.NewManagedClustersClient(cfg.SubscriptionID, cred, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceSKUsClient, err := NewResourceSKUsClient(cfg.SubscriptionID, cred, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	computeUsagesClient, err := NewComputeUsagesClient(cfg.SubscriptionID, cred, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceProvidersClient, err := NewResourceProvidersClient(cfg.SubscriptionID, cred, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	azClient := &AZClient{
+		agentPoolsClient:   agentPoolClient,
+		subscriptionID:     cfg.SubscriptionID,
+		retailPricesClient: NewRetailPricesClient(opts),
+		location:           cfg.Location,
+
+		managedClustersClient:   managedClustersClient,
+		resourceSKUsClient:      resourceSKUsClient,
+		computeUsagesClient:     computeUsagesClient,
+		resourceProvidersClient: resourceProvidersClient,
+	}
+	// air-gapped deployments read SKUs and prices from the bundle generated by cmd/skugen.
+	if path := utils.WithDefaultString("SKU_BUNDLE_PATH", ""); path != "" {
+		bundle, err := LoadSKUBundle(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading sku bundle, %w", err)
+		}
+		azClient.resourceSKUsClient = bundle
+		azClient.retailPricesClient = bundle
+		klog.V(5).Infof("Loaded sku bundle of location %s generated at %s", bundle.Location, bundle.GeneratedAt)
+	}
+	// agent pools are filtered by ownership tags in azure resource graph instead of listing all agent pools of
+	// the cluster. resource graph is eventually consistent, so newly created agent pools may be listed with delay.
+	if utils.WithDefaultBool("LIST_AGENTPOOLS_WITH_RESOURCE_GRAPH", false) {
+		if azClient.resourceGraphClient, err = NewResourceGraphClient(cred, opts); err != nil {
+			return nil, err
+		}
+		klog.V(5).Infof("Created resource graph client for listing agent pools")
+	}
+	return azClient, nil
 }
 
 func setArmClientOptions() *arm.ClientOptions {
@@ -121,14 +201,30 @@ func setArmClientOptions() *arm.ClientOptions {
 			"x-ms-correlation-request-id": []string{uuid.New().String()},
 		},
 	)
-	opt.Cloud.Services = maps.Clone(opt.Cloud.Services) // we need this because map is a reference type
-	opt.Cloud.Services[cloud.ResourceManager] = cloud.ServiceConfiguration{
-		Audience: cloud.AzurePublic.Services[cloud.ResourceManager].Audience,
-		Endpoint: "https://" + RPReferer,
-	}
+	setResourceManager(opt, "https://"+RPReferer, "")
 	return opt
 }
 
+// setResourceManager overrides the ARM endpoint and the audience of ARM tokens of the client options, the
+// configured ones(public cloud by default) are kept for empty values.
+func setResourceManager(opts *arm.ClientOptions, endpoint, audience string) {
+	if endpoint == "" && audience == "" {
+		return
+	}
+	if opts.Cloud.Services == nil {
+		opts.Cloud = cloud.AzurePublic
+	}
+	opts.Cloud.Services = maps.Clone(opts.Cloud.Services)
+	config := opts.Cloud.Services[cloud.ResourceManager]
+	if endpoint != "" {
+		config.Endpoint = endpoint
+	}
+	if audience != "" {
+		config.Audience = audience
+	}
+	opts.Cloud.Services[cloud.ResourceManager] = config
+}
+
 // PolicySetHeaders sets http header
 type PolicySetHeaders http.Header
 
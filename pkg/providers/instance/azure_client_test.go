@@ -0,0 +1,72 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetResourceManager(t *testing.T) {
+	public := cloud.AzurePublic.Services[cloud.ResourceManager]
+	testCases := []struct {
+		name             string
+		opts             *arm.ClientOptions
+		endpoint         string
+		audience         string
+		expectedEndpoint string
+		expectedAudience string
+	}{
+		{
+			name:             "Keep public cloud without overrides",
+			opts:             &arm.ClientOptions{},
+			expectedEndpoint: public.Endpoint,
+			expectedAudience: public.Audience,
+		},
+		{
+			name:             "Override endpoint and audience",
+			opts:             &arm.ClientOptions{},
+			endpoint:         "https://api-dogfood.resources.windows-int.net/",
+			audience:         "https://management.core.windows.net/",
+			expectedEndpoint: "https://api-dogfood.resources.windows-int.net/",
+			expectedAudience: "https://management.core.windows.net/",
+		},
+		{
+			name:             "Override audience of e2e endpoint",
+			opts:             setArmClientOptions(),
+			audience:         "https://management.core.windows.net/",
+			expectedEndpoint: "https://" + RPReferer,
+			expectedAudience: "https://management.core.windows.net/",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setResourceManager(tc.opts, tc.endpoint, tc.audience)
+			config, ok := tc.opts.Cloud.Services[cloud.ResourceManager]
+			if !ok {
+				config = public
+			}
+			assert.Equal(t, tc.expectedEndpoint, config.Endpoint)
+			assert.Equal(t, tc.expectedAudience, config.Audience)
+			// the public cloud configuration is not modified.
+			assert.Equal(t, public, cloud.AzurePublic.Services[cloud.ResourceManager])
+		})
+	}
+}
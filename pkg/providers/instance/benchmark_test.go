@@ -0,0 +1,73 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// benchmarks cover the hot paths of creating agent pools, `make bench-gate` compares them against
+// hack/benchgate/baseline.json and fails on regressions.
+
+func benchmarkNodeClaim(requirements ...v1.NodeSelectorRequirement) *karpenterv1.NodeClaim {
+	return fixture.NodeClaim().WithName("bench").WithLabels(map[string]string{"kaito.sh/workspace": "bench"}).
+		WithTaints(v1.Taint{Key: "sku", Value: "gpu", Effect: v1.TaintEffectNoSchedule}).
+		WithStorage(120).
+		WithInstanceType("Standard_NC6s_v3", "Standard_NC24ads_A100_v4", "Standard_ND96asr_v4").
+		WithNodeSelectorRequirements(requirements...).
+		Build()
+}
+
+func BenchmarkNewAgentPoolObject(b *testing.B) {
+	nodeClaim := benchmarkNodeClaim()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := newAgentPoolObject("Standard_NC6s_v3", karpenterv1.CapacityTypeOnDemand, nodeClaim); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAgentPoolSpecHash(b *testing.B) {
+	ap, err := newAgentPoolObject("Standard_NC6s_v3", karpenterv1.CapacityTypeOnDemand, benchmarkNodeClaim())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		agentPoolSpecHash(&ap)
+	}
+}
+
+func BenchmarkInstanceTypesWithSKURequirements(b *testing.B) {
+	p := &Provider{azClient: &AZClient{location: "eastus"}, skuCache: cache.New(SKUCacheTTL, SKUCacheTTL)}
+	p.skuCache.SetDefault(skusCacheKey, lo.SliceToMap(testResourceSKUs[:3], func(r ResourceSKU) (string, SKU) { return r.Name, newSKU(r) }))
+	nodeClaim := benchmarkNodeClaim(v1.NodeSelectorRequirement{Key: LabelSKURDMACapable, Operator: v1.NodeSelectorOpIn, Values: []string{"true"}})
+	instanceTypes := []string{"Standard_NC6s_v3", "Standard_NC24ads_A100_v4", "Standard_ND96asr_v4"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := p.instanceTypesWithSKURequirements(context.Background(), nodeClaim, instanceTypes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+)
+
+// CapacityTypeNotSupportedError is returned when none of the capacity types allowed by the nodeclaim requirements
+// can be satisfied.
+type CapacityTypeNotSupportedError struct {
+	CapacityTypes []string
+}
+
+func (e *CapacityTypeNotSupportedError) Error() string {
+	return fmt.Sprintf("capacity types %v are not supported", e.CapacityTypes)
+}
+
+func IsCapacityTypeNotSupportedError(err error) bool {
+	var ctErr *CapacityTypeNotSupportedError
+	return errors.As(err, &ctErr)
+}
+
+// capacityTypeOf returns the capacity type of the agent pool created for the nodeclaim. on-demand is preferred
+// whenever the karpenter.sh/capacity-type requirement allows it, spot is only used if spot agent pools are enabled.
+func capacityTypeOf(nodeClaim *karpenterv1.NodeClaim, spotEnabled bool) (string, error) {
+	requirements := scheduling.NewNodeSelectorRequirementsWithMinValues(nodeClaim.Spec.Requirements...)
+	if !requirements.Has(karpenterv1.CapacityTypeLabelKey) {
+		return karpenterv1.CapacityTypeOnDemand, nil
+	}
+	requirement := requirements.Get(karpenterv1.CapacityTypeLabelKey)
+	switch {
+	case requirement.Has(karpenterv1.CapacityTypeOnDemand):
+		return karpenterv1.CapacityTypeOnDemand, nil
+	case spotEnabled && requirement.Has(karpenterv1.CapacityTypeSpot):
+		return karpenterv1.CapacityTypeSpot, nil
+	}
+	err := &CapacityTypeNotSupportedError{CapacityTypes: requirement.Values()}
+	if requirement.Has(karpenterv1.CapacityTypeSpot) {
+		return "", withRemediation(err, "enable spot agent pools with ENABLE_SPOT_AGENTPOOLS=true or allow the on-demand capacity type")
+	}
+	return "", withRemediation(err, "allow the on-demand capacity type")
+}
+
+// setCapacityType configures the scale set priority of the agent pool for the capacity type. spot VMs are evicted
+// by deletion and the max price is capped at the on-demand price.
+func setCapacityType(ap *armcontainerservice.AgentPool, capacityType string) {
+	if capacityType != karpenterv1.CapacityTypeSpot {
+		return
+	}
+	ap.Properties.ScaleSetPriority = to.Ptr(armcontainerservice.ScaleSetPrioritySpot)
+	ap.Properties.ScaleSetEvictionPolicy = to.Ptr(armcontainerservice.ScaleSetEvictionPolicyDelete)
+	ap.Properties.SpotMaxPrice = to.Ptr[float32](-1)
+}
+
+// agentPoolCapacityType returns the capacity type of the agent pool.
+func agentPoolCapacityType(ap *armcontainerservice.AgentPool) string {
+	if ap.Properties != nil && ap.Properties.ScaleSetPriority != nil && *ap.Properties.ScaleSetPriority == armcontainerservice.ScaleSetPrioritySpot {
+		return karpenterv1.CapacityTypeSpot
+	}
+	return karpenterv1.CapacityTypeOnDemand
+}
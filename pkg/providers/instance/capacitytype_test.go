@@ -0,0 +1,76 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"testing"
+
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func TestCapacityTypeOf(t *testing.T) {
+	capacityTypeRequirement := func(values ...string) []v1.NodeSelectorRequirement {
+		return []v1.NodeSelectorRequirement{{Key: karpenterv1.CapacityTypeLabelKey, Operator: v1.NodeSelectorOpIn, Values: values}}
+	}
+
+	testCases := []struct {
+		name                 string
+		requirements         []v1.NodeSelectorRequirement
+		spotEnabled          bool
+		expectedCapacityType string
+		expectedError        bool
+	}{
+		{
+			name:                 "Use on-demand without capacity type requirement",
+			requirements:         []v1.NodeSelectorRequirement{},
+			expectedCapacityType: karpenterv1.CapacityTypeOnDemand,
+		},
+		{
+			name:                 "Prefer on-demand when both capacity types are allowed",
+			requirements:         capacityTypeRequirement(karpenterv1.CapacityTypeSpot, karpenterv1.CapacityTypeOnDemand),
+			spotEnabled:          true,
+			expectedCapacityType: karpenterv1.CapacityTypeOnDemand,
+		},
+		{
+			name:                 "Use spot when only spot is allowed and spot agentpools are enabled",
+			requirements:         capacityTypeRequirement(karpenterv1.CapacityTypeSpot),
+			spotEnabled:          true,
+			expectedCapacityType: karpenterv1.CapacityTypeSpot,
+		},
+		{
+			name:          "Fail because only spot is allowed and spot agentpools are disabled",
+			requirements:  capacityTypeRequirement(karpenterv1.CapacityTypeSpot),
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClaim := fixture.NodeClaim().WithName("nodeclaim-test").WithNodeSelectorRequirements(tc.requirements...).Build()
+
+			capacityType, err := capacityTypeOf(nodeClaim, tc.spotEnabled)
+			if tc.expectedError {
+				assert.True(t, IsCapacityTypeNotSupportedError(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCapacityType, capacityType)
+		})
+	}
+}
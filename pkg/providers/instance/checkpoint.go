@@ -0,0 +1,185 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// CheckpointStoreConfigMap persists checkpoints to ConfigMaps, it's the default.
+	CheckpointStoreConfigMap = "configmap"
+	// CheckpointStoreSecret persists checkpoints to Secrets, so they are covered by the encryption at rest and the
+	// RBAC of secrets.
+	CheckpointStoreSecret = "secret"
+
+	// sealedResumeTokenPrefix marks resume tokens encrypted with RESUME_TOKEN_ENCRYPTION_KEY, tokens without it
+	// were stored before encryption was enabled.
+	sealedResumeTokenPrefix = "sealed:"
+)
+
+// CheckpointStore persists the state gpu-provisioner picks up after restarts, e.g. the operation index, to objects
+// in the namespace of gpu-provisioner.
+type CheckpointStore interface {
+	// Load returns the data of the named checkpoint, or nil if it doesn't exist.
+	Load(ctx context.Context, name string) (map[string]string, error)
+	// Save creates or replaces the data of the named checkpoint.
+	Save(ctx context.Context, name string, data map[string]string) error
+}
+
+func newCheckpointStore(kind string, kubeClient client.Client, namespace string) (CheckpointStore, error) {
+	switch kind {
+	case CheckpointStoreConfigMap:
+		return &configMapCheckpointStore{kubeClient: kubeClient, namespace: namespace}, nil
+	case CheckpointStoreSecret:
+		return &secretCheckpointStore{kubeClient: kubeClient, reader: kubeClient, namespace: namespace}, nil
+	}
+	return nil, fmt.Errorf("unknown checkpoint store %q, expected %q or %q", kind, CheckpointStoreConfigMap, CheckpointStoreSecret)
+}
+
+type configMapCheckpointStore struct {
+	kubeClient client.Client
+	namespace  string
+}
+
+func (s *configMapCheckpointStore) Load(ctx context.Context, name string) (map[string]string, error) {
+	cm := &corev1.ConfigMap{}
+	if err := s.kubeClient.Get(ctx, client.ObjectKey{Name: name, Namespace: s.namespace}, cm); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	return cm.Data, nil
+}
+
+func (s *configMapCheckpointStore) Save(ctx context.Context, name string, data map[string]string) error {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: s.namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, s.kubeClient, cm, func() error {
+		cm.Data = data
+		return nil
+	})
+	return err
+}
+
+type secretCheckpointStore struct {
+	kubeClient client.Client
+	// reader reads secrets, it's an uncached reader in the controller so gpu-provisioner doesn't watch all secrets
+	// of the cluster.
+	reader    client.Reader
+	namespace string
+}
+
+func (s *secretCheckpointStore) Load(ctx context.Context, name string) (map[string]string, error) {
+	secret := &corev1.Secret{}
+	if err := s.reader.Get(ctx, client.ObjectKey{Name: name, Namespace: s.namespace}, secret); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	return lo.MapValues(secret.Data, func(v []byte, _ string) string { return string(v) }), nil
+}
+
+func (s *secretCheckpointStore) Save(ctx context.Context, name string, data map[string]string) error {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: s.namespace}}
+	if err := s.reader.Get(ctx, client.ObjectKeyFromObject(secret), secret); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	secret.Data = lo.MapValues(data, func(v string, _ string) []byte { return []byte(v) })
+	if secret.ResourceVersion == "" {
+		secret.Type = corev1.SecretTypeOpaque
+		return s.kubeClient.Create(ctx, secret)
+	}
+	return s.kubeClient.Update(ctx, secret)
+}
+
+// newResumeTokenCipher returns the cipher encrypting resume tokens with the base64 encoded AES key, resume tokens
+// are not stored if the key is empty.
+func newResumeTokenCipher(key string) (cipher.AEAD, error) {
+	if key == "" {
+		return nil, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("decoding key, %w", err)
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealResumeToken encrypts the resume token, as it embeds the polling URLs of the LRO. the token must never be
+// stored in plaintext, so it fails without a key.
+func (p *Provider) sealResumeToken(token string) (string, error) {
+	if p.resumeTokenCipher == nil {
+		return "", fmt.Errorf("RESUME_TOKEN_ENCRYPTION_KEY is not set")
+	}
+	nonce := make([]byte, p.resumeTokenCipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return sealedResumeTokenPrefix + base64.StdEncoding.EncodeToString(p.resumeTokenCipher.Seal(nonce, nonce, []byte(token), nil)), nil
+}
+
+// openResumeToken decrypts a resume token stored by sealResumeToken, tokens stored before encryption was enabled
+// are returned as is.
+func (p *Provider) openResumeToken(stored string) (string, error) {
+	if !strings.HasPrefix(stored, sealedResumeTokenPrefix) {
+		return stored, nil
+	}
+	if p.resumeTokenCipher == nil {
+		return "", fmt.Errorf("resume token is encrypted but RESUME_TOKEN_ENCRYPTION_KEY is not set")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, sealedResumeTokenPrefix))
+	if err != nil {
+		return "", err
+	}
+	nonceSize := p.resumeTokenCipher.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", fmt.Errorf("resume token is too short")
+	}
+	token, err := p.resumeTokenCipher.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s"']+`)
+
+// redactURLs drops the query of URLs in the message, query parameters of ARM URLs(e.g. of LRO polling URLs) may
+// carry signatures which must not be persisted.
+func redactURLs(message string) string {
+	return urlPattern.ReplaceAllStringFunc(message, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil || (u.RawQuery == "" && u.Fragment == "") {
+			return raw
+		}
+		u.RawQuery, u.Fragment, u.RawFragment = "", "", ""
+		return u.String() + "?REDACTED"
+	})
+}
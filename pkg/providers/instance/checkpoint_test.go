@@ -0,0 +1,124 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheckpointStore(t *testing.T) {
+	testcases := map[string]struct {
+		kind   string
+		object client.Object
+	}{
+		"configmap": {
+			kind:   CheckpointStoreConfigMap,
+			object: &corev1.ConfigMap{},
+		},
+		"secret": {
+			kind:   CheckpointStoreSecret,
+			object: &corev1.Secret{},
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+			store, err := newCheckpointStore(tc.kind, kubeClient, "gpu-provisioner")
+			assert.NoError(t, err)
+
+			data, err := store.Load(context.Background(), "checkpoint")
+			assert.NoError(t, err)
+			assert.Nil(t, data, "missing checkpoints load as nil")
+
+			assert.NoError(t, store.Save(context.Background(), "checkpoint", map[string]string{"key": "v1"}))
+			assert.NoError(t, store.Save(context.Background(), "checkpoint", map[string]string{"key": "v2"}))
+			data, err = store.Load(context.Background(), "checkpoint")
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{"key": "v2"}, data)
+			assert.NoError(t, kubeClient.Get(context.Background(), client.ObjectKey{Name: "checkpoint", Namespace: "gpu-provisioner"}, tc.object))
+		})
+	}
+
+	_, err := newCheckpointStore("crd", nil, "gpu-provisioner")
+	assert.Error(t, err)
+}
+
+func TestResumeTokenEncryption(t *testing.T) {
+	_, err := newResumeTokenCipher("not base64")
+	assert.Error(t, err)
+	_, err = newResumeTokenCipher(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+
+	token := `{"type":"Location","pollURL":"https://management.azure.com/operations/1?api-version=2024-02-01&sig=secret"}`
+	plain := &Provider{}
+	_, err = plain.sealResumeToken(token)
+	assert.Error(t, err, "tokens are never stored in plaintext")
+
+	aead, err := newResumeTokenCipher(base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")))
+	assert.NoError(t, err)
+	p := &Provider{resumeTokenCipher: aead}
+	sealed, err := p.sealResumeToken(token)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, sealedResumeTokenPrefix))
+	assert.NotContains(t, sealed, "sig=secret")
+
+	opened, err := p.openResumeToken(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, token, opened)
+	opened, err = p.openResumeToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, token, opened, "tokens stored before encryption was enabled are read as is")
+	_, err = plain.openResumeToken(sealed)
+	assert.Error(t, err, "sealed tokens can't be read without the key")
+	_, err = p.openResumeToken(sealedResumeTokenPrefix + "AAAA")
+	assert.Error(t, err)
+}
+
+func TestRedactURLs(t *testing.T) {
+	testcases := map[string]struct {
+		message  string
+		expected string
+	}{
+		"no url": {
+			message:  "QuotaExceeded",
+			expected: "QuotaExceeded",
+		},
+		"url without query": {
+			message:  "GET https://management.azure.com/subscriptions/sub",
+			expected: "GET https://management.azure.com/subscriptions/sub",
+		},
+		"url with query": {
+			message:  "GET https://management.azure.com/operations/1?api-version=2024-02-01&sig=secret\nRESPONSE 500",
+			expected: "GET https://management.azure.com/operations/1?REDACTED\nRESPONSE 500",
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, tc.expected, redactURLs(tc.message))
+		})
+	}
+}
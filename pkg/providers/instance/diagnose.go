@@ -0,0 +1,195 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	sdkerrors "github.com/Azure/azure-sdk-for-go-extensions/pkg/errors"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+	nodeclaimutil "sigs.k8s.io/karpenter/pkg/utils/nodeclaim"
+)
+
+// gpuInstanceTypesGroup is the instance type group diagnosed when no kaito nodeclaim exists.
+const gpuInstanceTypesGroup = "gpu instance types"
+
+// DiagnosticCheck is the outcome of checking a prerequisite of creating agent pools.
+type DiagnosticCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+}
+
+// Diagnose checks the credentials, the access to the managed cluster, the resource provider registration, and the
+// availability and quota of the instance types of the kaito nodeclaims, or of all gpu instance types if there is
+// no nodeclaim. checks only read, so they are safe to run against a live cluster.
+func (p *Provider) Diagnose(ctx context.Context) []DiagnosticCheck {
+	checks := p.diagnoseClusterAccess(ctx)
+	checks = append(checks, p.diagnoseAgentPoolAccess(ctx), p.diagnoseResourceProviders(ctx))
+	return append(checks, p.diagnoseInstanceTypes(ctx)...)
+}
+
+// diagnoseClusterAccess gets the managed cluster, a response from ARM means a token was acquired, so the
+// credentials work even if the identity is not authorized on the cluster.
+func (p *Provider) diagnoseClusterAccess(ctx context.Context) []DiagnosticCheck {
+	credentials := DiagnosticCheck{Name: "credentials"}
+	cluster := DiagnosticCheck{Name: "cluster-access"}
+	if p.azClient.managedClustersClient == nil {
+		credentials.Message = "managed clusters client is not configured"
+		cluster.Message = credentials.Message
+		return []DiagnosticCheck{credentials, cluster}
+	}
+	resp, err := p.azClient.managedClustersClient.Get(ctx, p.resourceGroup, p.clusterName, nil)
+	azErr := sdkerrors.IsResponseError(err)
+	switch {
+	case err == nil:
+		credentials.Passed, cluster.Passed = true, true
+		credentials.Message = "acquired ARM token"
+		state := ""
+		if resp.Properties != nil {
+			state = lo.FromPtr(resp.Properties.ProvisioningState)
+		}
+		cluster.Message = fmt.Sprintf("read managed cluster %s/%s in provisioning state %q", p.resourceGroup, p.clusterName, state)
+	case azErr == nil || azErr.StatusCode == http.StatusUnauthorized:
+		credentials.Message = fmt.Sprintf("authenticating to ARM, %s", err)
+		cluster.Message = "skipped, credentials don't work"
+	case azErr.StatusCode == http.StatusForbidden:
+		credentials.Passed = true
+		credentials.Message = "acquired ARM token"
+		cluster.Message = fmt.Sprintf("identity is not authorized to read managed cluster %s/%s, assign it a role with Microsoft.ContainerService/managedClusters/agentPools/* actions, %s",
+			p.resourceGroup, p.clusterName, azErr.ErrorCode)
+	default:
+		credentials.Passed = true
+		credentials.Message = "acquired ARM token"
+		cluster.Message = fmt.Sprintf("getting managed cluster %s/%s, %s", p.resourceGroup, p.clusterName, err)
+	}
+	return []DiagnosticCheck{credentials, cluster}
+}
+
+// diagnoseAgentPoolAccess lists the agent pools of the cluster, permissions to write agent pools can't be checked
+// without side effects.
+func (p *Provider) diagnoseAgentPoolAccess(ctx context.Context) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "agentpool-access"}
+	pager := p.azClient.agentPoolsClient.NewListPager(p.resourceGroup, p.clusterName, nil)
+	page, err := pager.NextPage(ctx)
+	if err != nil {
+		check.Message = fmt.Sprintf("listing agent pools, %s", err)
+		return check
+	}
+	check.Passed = true
+	check.Message = fmt.Sprintf("listed %d agent pool(s)", len(page.Value))
+	return check
+}
+
+func (p *Provider) diagnoseResourceProviders(ctx context.Context) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "resource-providers"}
+	if p.azClient.resourceProvidersClient == nil {
+		check.Message = "resource providers client is not configured"
+		return check
+	}
+	var problems []string
+	for _, namespace := range RequiredResourceProviders {
+		state, err := p.azClient.resourceProvidersClient.RegistrationState(ctx, namespace)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("getting %s, %s", namespace, err))
+		case !strings.EqualFold(state, "Registered"):
+			problems = append(problems, fmt.Sprintf("%s is %s, register it with `az provider register -n %s`", namespace, state, namespace))
+		}
+	}
+	if len(problems) != 0 {
+		check.Message = strings.Join(problems, "; ")
+		return check
+	}
+	check.Passed = true
+	check.Message = fmt.Sprintf("%s registered", strings.Join(RequiredResourceProviders, ", "))
+	return check
+}
+
+// diagnoseInstanceTypes checks each kaito nodeclaim has an instance type which is available in the region, and which
+// fits in the quota headroom of its family.
+func (p *Provider) diagnoseInstanceTypes(ctx context.Context) []DiagnosticCheck {
+	availability := DiagnosticCheck{Name: "sku-availability"}
+	quota := DiagnosticCheck{Name: "quota"}
+	skus, err := p.SKUs(ctx)
+	if err != nil {
+		availability.Message = fmt.Sprintf("getting skus, %s", err)
+		quota.Message = "skipped, skus are unknown"
+		return []DiagnosticCheck{availability, quota}
+	}
+	groups, err := p.instanceTypeGroups(ctx, skus)
+	if err != nil {
+		availability.Message = fmt.Sprintf("listing nodeclaims, %s", err)
+		quota.Message = "skipped, nodeclaims are unknown"
+		return []DiagnosticCheck{availability, quota}
+	}
+	available := lo.MapValues(groups, func(instanceTypes []string, _ string) []string {
+		return lo.Filter(instanceTypes, func(name string, _ int) bool {
+			sku, ok := skus[name]
+			return ok && !sku.Restricted
+		})
+	})
+	availability.Message, availability.Passed = summarizeGroups(available, "available in "+p.azClient.location)
+
+	headroom, err := p.quotaHeadroom(ctx)
+	if err != nil {
+		quota.Message = fmt.Sprintf("getting quota, %s", err)
+		return []DiagnosticCheck{availability, quota}
+	}
+	withinQuota := lo.MapValues(available, func(instanceTypes []string, _ string) []string {
+		return lo.Filter(instanceTypes, func(name string, _ int) bool {
+			return headroom[skus[name].Family] >= skus[name].VCPUs
+		})
+	})
+	quota.Message, quota.Passed = summarizeGroups(withinQuota, "within quota")
+	return []DiagnosticCheck{availability, quota}
+}
+
+// instanceTypeGroups returns the instance types allowed by each kaito nodeclaim by nodeclaim name, or all gpu
+// instance types if there is no kaito nodeclaim.
+func (p *Provider) instanceTypeGroups(ctx context.Context, skus map[string]SKU) (map[string][]string, error) {
+	nodeClaims, err := nodeclaimutil.AllKaitoNodeClaims(ctx, p.kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	groups := map[string][]string{}
+	for i := range nodeClaims {
+		requirements := scheduling.NewNodeSelectorRequirementsWithMinValues(nodeClaims[i].Spec.Requirements...)
+		groups[nodeClaims[i].Name] = requirements.Get(corev1.LabelInstanceTypeStable).Values()
+	}
+	if len(groups) == 0 {
+		groups[gpuInstanceTypesGroup] = lo.Keys(lo.PickBy(skus, func(_ string, sku SKU) bool { return sku.GPUCount > 0 }))
+	}
+	return groups, nil
+}
+
+// summarizeGroups passes if every group has an instance type left.
+func summarizeGroups(groups map[string][]string, condition string) (string, bool) {
+	failed := lo.Keys(lo.PickBy(groups, func(_ string, instanceTypes []string) bool { return len(instanceTypes) == 0 }))
+	if len(failed) != 0 {
+		sort.Strings(failed)
+		return fmt.Sprintf("no instance type %s for %s", condition, strings.Join(failed, ", ")), false
+	}
+	names := lo.Keys(groups)
+	sort.Strings(names)
+	return fmt.Sprintf("instance types %s for %s", condition, strings.Join(names, ", ")), true
+}
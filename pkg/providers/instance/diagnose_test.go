@@ -0,0 +1,136 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeResourceProviders struct {
+	states map[string]string
+}
+
+func (f *fakeResourceProviders) RegistrationState(_ context.Context, namespace string) (string, error) {
+	return f.states[namespace], nil
+}
+
+func TestDiagnose(t *testing.T) {
+	registered := map[string]string{"Microsoft.ContainerService": "Registered", "Microsoft.Compute": "Registered"}
+	nodeClaim := fixture.NodeClaim().WithName("nc1").WithInstanceType("Standard_NC6s_v3").Build()
+	testcases := map[string]struct {
+		clusterErr     error
+		states         map[string]string
+		restricted     bool
+		headroom       int64
+		nodeClaims     []client.Object
+		expectedFailed []string
+	}{
+		"all checks pass": {
+			states:     registered,
+			headroom:   12,
+			nodeClaims: []client.Object{nodeClaim},
+		},
+		"gpu instance types are diagnosed without nodeclaims": {
+			states:   registered,
+			headroom: 6,
+		},
+		"credentials don't work": {
+			clusterErr:     errors.New("failed to acquire token"),
+			states:         registered,
+			headroom:       12,
+			expectedFailed: []string{"credentials", "cluster-access"},
+		},
+		"identity is not authorized on the cluster": {
+			clusterErr:     &azcore.ResponseError{StatusCode: http.StatusForbidden, ErrorCode: "AuthorizationFailed"},
+			states:         registered,
+			headroom:       12,
+			expectedFailed: []string{"cluster-access"},
+		},
+		"resource provider is not registered": {
+			states:         map[string]string{"Microsoft.ContainerService": "Registered", "Microsoft.Compute": "NotRegistered"},
+			headroom:       12,
+			expectedFailed: []string{"resource-providers"},
+		},
+		"instance type is restricted": {
+			states:         registered,
+			restricted:     true,
+			headroom:       12,
+			nodeClaims:     []client.Object{nodeClaim},
+			expectedFailed: []string{"sku-availability", "quota"},
+		},
+		"quota is exhausted": {
+			states:         registered,
+			headroom:       4,
+			nodeClaims:     []client.Object{nodeClaim},
+			expectedFailed: []string{"quota"},
+		},
+	}
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+			agentPoolMocks.EXPECT().NewListPager(gomock.Any(), gomock.Any(), gomock.Any()).Return(runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
+				More: func(page armcontainerservice.AgentPoolsClientListResponse) bool {
+					return false
+				},
+				Fetcher: func(ctx context.Context, page *armcontainerservice.AgentPoolsClientListResponse) (armcontainerservice.AgentPoolsClientListResponse, error) {
+					return armcontainerservice.AgentPoolsClientListResponse{}, nil
+				},
+			}))
+			resourceSKU := ResourceSKU{
+				Name:         "Standard_NC6s_v3",
+				ResourceType: "virtualMachines",
+				Family:       "standardNCSv3Family",
+				Capabilities: []ResourceSKUCapability{{Name: "vCPUs", Value: "6"}, {Name: "GPUs", Value: "1"}},
+			}
+			if tc.restricted {
+				resourceSKU.Restrictions = []ResourceSKURestriction{{Type: "Location", ReasonCode: "NotAvailableForSubscription"}}
+			}
+			azClient := NewAZClientFromAPI(agentPoolMocks)
+			azClient.location = "eastus"
+			azClient.managedClustersClient = &fakeManagedClusters{provisioningState: "Succeeded", err: tc.clusterErr}
+			azClient.resourceProvidersClient = &fakeResourceProviders{states: tc.states}
+			azClient.resourceSKUsClient = &fakeResourceSKUs{skus: []ResourceSKU{resourceSKU}}
+			azClient.computeUsagesClient = &fakeComputeUsages{usages: []ComputeUsage{
+				{Name: ComputeUsageName{Value: "standardNCSv3Family"}, CurrentValue: 100 - tc.headroom, Limit: 100},
+			}}
+			kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tc.nodeClaims...).Build()
+			p := NewProvider(azClient, kubeClient, "testRG", "testCluster")
+
+			checks := p.Diagnose(context.Background())
+			assert.Equal(t, []string{"credentials", "cluster-access", "agentpool-access", "resource-providers", "sku-availability", "quota"},
+				lo.Map(checks, func(c DiagnosticCheck, _ int) string { return c.Name }))
+			failed := lo.FilterMap(checks, func(c DiagnosticCheck, _ int) (string, bool) { return c.Name, !c.Passed })
+			assert.ElementsMatch(t, tc.expectedFailed, failed)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"regexp"
+)
+
+// LabelGPUGeneration is the nvidia gpu architecture of the instance type, e.g. ampere or hopper.
+const LabelGPUGeneration = "kaito.sh/gpu-generation"
+
+const (
+	GPUGenerationPascal = "pascal"
+	GPUGenerationVolta  = "volta"
+	GPUGenerationTuring = "turing"
+	GPUGenerationAmpere = "ampere"
+	GPUGenerationHopper = "hopper"
+)
+
+// gpuGenerations maps the vm families to their gpu generation by the vm size name, the first match wins.
+var gpuGenerations = []struct {
+	pattern    *regexp.Regexp
+	generation string
+}{
+	// ND H100 v5, NCads H100 v5, ND H200 v5
+	{regexp.MustCompile(`_H[12]00_`), GPUGenerationHopper},
+	// NC A100 v4, ND A100 v4, NDm A100 v4, NVads A10 v5
+	{regexp.MustCompile(`A10[0]?_v[45]`), GPUGenerationAmpere},
+	{regexp.MustCompile(`^Standard_ND96asr_v4$`), GPUGenerationAmpere},
+	// NCasT4_v3
+	{regexp.MustCompile(`_T4_v3$`), GPUGenerationTuring},
+	// NCv3, NDv2
+	{regexp.MustCompile(`^Standard_NC\d+r?s_v3$`), GPUGenerationVolta},
+	{regexp.MustCompile(`^Standard_ND\d+r?s_v2$`), GPUGenerationVolta},
+	// NCv2, ND
+	{regexp.MustCompile(`^Standard_NC\d+r?s_v2$`), GPUGenerationPascal},
+	{regexp.MustCompile(`^Standard_ND\d+r?s$`), GPUGenerationPascal},
+}
+
+// gpuGeneration returns the gpu generation of the vm size, or empty if the vm size has no nvidia gpu or is unknown.
+func gpuGeneration(vmSize string) string {
+	for _, g := range gpuGenerations {
+		if g.pattern.MatchString(vmSize) {
+			return g.generation
+		}
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGPUGeneration(t *testing.T) {
+	testCases := map[string]string{
+		"Standard_NC6s_v2":           GPUGenerationPascal,
+		"Standard_ND24rs":            GPUGenerationPascal,
+		"Standard_NC24rs_v3":         GPUGenerationVolta,
+		"Standard_ND40rs_v2":         GPUGenerationVolta,
+		"Standard_NC16as_T4_v3":      GPUGenerationTuring,
+		"Standard_NC24ads_A100_v4":   GPUGenerationAmpere,
+		"Standard_ND96amsr_A100_v4":  GPUGenerationAmpere,
+		"Standard_ND96asr_v4":        GPUGenerationAmpere,
+		"Standard_NV36ads_A10_v5":    GPUGenerationAmpere,
+		"Standard_ND96isr_H100_v5":   GPUGenerationHopper,
+		"Standard_NC40ads_H100_v5":   GPUGenerationHopper,
+		"Standard_ND96isr_H200_v5":   GPUGenerationHopper,
+		"Standard_ND96isr_MI300X_v5": "",
+		"Standard_D4s_v3":            "",
+	}
+
+	for vmSize, expected := range testCases {
+		t.Run(vmSize, func(t *testing.T) {
+			assert.Equal(t, expected, gpuGeneration(vmSize))
+		})
+	}
+}
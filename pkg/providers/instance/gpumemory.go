@@ -0,0 +1,79 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"fmt"
+	"regexp"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// ModelGPUMemoryAnnotationKey declares the gpu memory the model of the nodeclaim needs to load, e.g. "80Gi". instance
+// types with less gpu memory in total are skipped, so the next allowed instance type which fits is created instead.
+const ModelGPUMemoryAnnotationKey = "kaito.sh/model-gpu-memory"
+
+// gpuMemories maps the vm families to the memory in GiB of each of their gpus by the vm size name, the first match
+// wins. vm sizes with fractional gpus(e.g. NVads A10 v5) are not listed.
+var gpuMemories = []struct {
+	pattern   *regexp.Regexp
+	memoryGiB int64
+}{
+	{regexp.MustCompile(`_H200_`), 141},
+	// NCads H100 v5 has H100 NVL gpus
+	{regexp.MustCompile(`^Standard_NC\d+ads_H100_v5$`), 94},
+	{regexp.MustCompile(`_H100_`), 80},
+	{regexp.MustCompile(`_A100_v4$`), 80},
+	{regexp.MustCompile(`^Standard_ND96asr_v4$`), 40},
+	{regexp.MustCompile(`_T4_v3$`), 16},
+	{regexp.MustCompile(`^Standard_ND\d+r?s_v2$`), 32},
+	{regexp.MustCompile(`^Standard_NC\d+r?s_v[23]$`), 16},
+	{regexp.MustCompile(`^Standard_ND\d+r?s$`), 24},
+}
+
+// gpuMemoryGiB returns the total gpu memory in GiB of the vm size with gpuCount gpus, or 0 if it's unknown.
+func gpuMemoryGiB(vmSize string, gpuCount int64) int64 {
+	for _, m := range gpuMemories {
+		if m.pattern.MatchString(vmSize) {
+			return m.memoryGiB * gpuCount
+		}
+	}
+	return 0
+}
+
+// modelGPUMemory returns the gpu memory declared by the ModelGPUMemoryAnnotationKey annotation of nodeclaim, or nil
+// if the nodeclaim has no such annotation.
+func modelGPUMemory(nodeClaim *karpenterv1.NodeClaim) (*resource.Quantity, error) {
+	value, ok := nodeClaim.Annotations[ModelGPUMemoryAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	memory, err := resource.ParseQuantity(value)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s annotation %q, %w", ModelGPUMemoryAnnotationKey, value, err)
+	}
+	return &memory, nil
+}
+
+// fitsGPUMemory returns true if the gpu memory of the sku is enough for the model. skus with unknown gpu memory are
+// considered as fitting because they can't be validated.
+func fitsGPUMemory(sku SKU, memory *resource.Quantity) bool {
+	if memory == nil || sku.GPUMemoryGiB == 0 {
+		return true
+	}
+	return resource.NewQuantity(sku.GPUMemoryGiB<<30, resource.BinarySI).Cmp(*memory) >= 0
+}
@@ -0,0 +1,50 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGPUMemoryGiB(t *testing.T) {
+	testCases := []struct {
+		vmSize   string
+		gpuCount int64
+		expected int64
+	}{
+		{vmSize: "Standard_NC6s_v2", gpuCount: 1, expected: 16},
+		{vmSize: "Standard_ND24rs", gpuCount: 4, expected: 96},
+		{vmSize: "Standard_NC24rs_v3", gpuCount: 4, expected: 64},
+		{vmSize: "Standard_ND40rs_v2", gpuCount: 8, expected: 256},
+		{vmSize: "Standard_NC16as_T4_v3", gpuCount: 1, expected: 16},
+		{vmSize: "Standard_NC48ads_A100_v4", gpuCount: 2, expected: 160},
+		{vmSize: "Standard_ND96amsr_A100_v4", gpuCount: 8, expected: 640},
+		{vmSize: "Standard_ND96asr_v4", gpuCount: 8, expected: 320},
+		{vmSize: "Standard_NC40ads_H100_v5", gpuCount: 1, expected: 94},
+		{vmSize: "Standard_ND96isr_H100_v5", gpuCount: 8, expected: 640},
+		{vmSize: "Standard_ND96isr_H200_v5", gpuCount: 8, expected: 1128},
+		{vmSize: "Standard_NV36ads_A10_v5", gpuCount: 1, expected: 0},
+		{vmSize: "Standard_D4s_v3", gpuCount: 0, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.vmSize, func(t *testing.T) {
+			assert.Equal(t, tc.expected, gpuMemoryGiB(tc.vmSize, tc.gpuCount))
+		})
+	}
+}
@@ -0,0 +1,189 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/utils"
+	"github.com/azure/gpu-provisioner/pkg/utils/validation"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/karpenter/pkg/apis"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// ImportCandidate is a gpu agent pool created out of kaito, e.g. manually, which can be imported to be managed
+// through a nodeclaim.
+type ImportCandidate struct {
+	AgentPoolName string
+	// NodeClaim is the proposed nodeclaim of the agent pool, its status carries the provider id of the node.
+	NodeClaim *karpenterv1.NodeClaim
+}
+
+// ImportCandidates scans the cluster for gpu agent pools which are not managed by kaito and proposes nodeclaims of
+// the workspace for them. only user agent pools with exactly one node are proposed, since one nodeclaim maps to one
+// node, and their names must be valid nodeclaim names of gpu-provisioner.
+func (p *Provider) ImportCandidates(ctx context.Context, workspace string) ([]ImportCandidate, error) {
+	var candidates []ImportCandidate
+	err := p.forEachAgentPool(ctx, false, func(ap *armcontainerservice.AgentPool) (bool, error) {
+		apName := lo.FromPtr(ap.Name)
+		if reason := importSkipReason(ap); reason != "" {
+			klog.V(1).InfoS("skip importing agentpool", "agentpool", apName, "reason", reason)
+			return true, nil
+		}
+		nodes, err := p.getNodesByName(ctx, apName)
+		if err != nil {
+			return false, fmt.Errorf("listing nodes of agentpool %s, %w", apName, err)
+		}
+		if len(nodes) != 1 {
+			klog.V(1).InfoS("skip importing agentpool", "agentpool", apName, "reason", fmt.Sprintf("%d nodes are found", len(nodes)))
+			return true, nil
+		}
+		if err := utils.ValidateProviderID(nodes[0].Spec.ProviderID, apName); err != nil {
+			klog.V(1).InfoS("skip importing agentpool", "agentpool", apName, "reason", err.Error())
+			return true, nil
+		}
+		candidates = append(candidates, ImportCandidate{
+			AgentPoolName: apName,
+			NodeClaim:     importedNodeClaim(ap, nodes[0], workspace),
+		})
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// importSkipReason returns why the agent pool can't be imported, or an empty string if it can.
+func importSkipReason(ap *armcontainerservice.AgentPool) string {
+	switch {
+	case ap.Properties == nil:
+		return "agent pool has no properties"
+	case agentPoolIsOwnedByKaito(ap):
+		return "agent pool is managed by kaito already"
+	case lo.FromPtr(ap.Properties.Mode) == armcontainerservice.AgentPoolModeSystem:
+		return "system agent pools are not imported"
+	case !strings.Contains(lo.FromPtr(ap.Properties.VMSize), "Standard_N"):
+		return "agent pool has no gpu"
+	case lo.FromPtr(ap.Properties.Count) != 1 || lo.FromPtr(ap.Properties.EnableAutoScaling):
+		return "agent pool has more than one node or autoscaling enabled"
+	case !AgentPoolNameRegex.MatchString(lo.FromPtr(ap.Name)):
+		return "agent pool name is not a valid nodeclaim name"
+	}
+	return ""
+}
+
+// importedNodeClaim proposes the nodeclaim of the agent pool, it requires the vm size and the capacity type of the
+// agent pool, and carries the labels and taints of the agent pool so the update controller doesn't change them.
+func importedNodeClaim(ap *armcontainerservice.AgentPool, node *v1.Node, workspace string) *karpenterv1.NodeClaim {
+	labels := lo.PickBy(lo.MapValues(ap.Properties.NodeLabels, func(v *string, _ string) string { return lo.FromPtr(v) }), func(key string, _ string) bool {
+		return !karpenterv1.IsRestrictedNodeLabel(key) && !validation.IsRestrictedKey(key) && key != NodeClaimCreationLabel
+	})
+	labels[karpenterv1.NodePoolLabelKey] = "kaito"
+	labels[KaitoNodeLabels[0]] = workspace
+
+	taints := lo.FilterMap(ap.Properties.NodeTaints, func(t *string, _ int) (v1.Taint, bool) {
+		if validation.IsRestrictedKey(taintKey(lo.FromPtr(t))) {
+			return v1.Taint{}, false
+		}
+		return parseTaint(lo.FromPtr(t))
+	})
+
+	requirement := func(key, value string) karpenterv1.NodeSelectorRequirementWithMinValues {
+		return karpenterv1.NodeSelectorRequirementWithMinValues{
+			NodeSelectorRequirement: v1.NodeSelectorRequirement{Key: key, Operator: v1.NodeSelectorOpIn, Values: []string{value}},
+		}
+	}
+	return &karpenterv1.NodeClaim{
+		TypeMeta: metav1.TypeMeta{APIVersion: apis.Group + "/v1", Kind: "NodeClaim"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        lo.FromPtr(ap.Name),
+			Labels:      labels,
+			Annotations: map[string]string{karpenterv1.DoNotDisruptAnnotationKey: "true"},
+		},
+		Spec: karpenterv1.NodeClaimSpec{
+			Taints: taints,
+			Requirements: []karpenterv1.NodeSelectorRequirementWithMinValues{
+				requirement(v1.LabelInstanceTypeStable, lo.FromPtr(ap.Properties.VMSize)),
+				requirement(karpenterv1.NodePoolLabelKey, "kaito"),
+				requirement(karpenterv1.CapacityTypeLabelKey, agentPoolCapacityType(ap)),
+			},
+			Resources: karpenterv1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: *resource.NewQuantity(int64(lo.FromPtr(ap.Properties.OSDiskSizeGB))<<30, resource.BinarySI),
+				},
+			},
+			NodeClassRef: &karpenterv1.NodeClassReference{Group: "karpenter.azure.com", Kind: "AKSNodeClass", Name: "default"},
+		},
+		Status: karpenterv1.NodeClaimStatus{
+			ProviderID: node.Spec.ProviderID,
+		},
+	}
+}
+
+// parseTaint parses a taint in the agent pool format key=value:effect.
+func parseTaint(s string) (v1.Taint, bool) {
+	keyValue, effect, ok := strings.Cut(s, ":")
+	if !ok {
+		return v1.Taint{}, false
+	}
+	key, value, _ := strings.Cut(keyValue, "=")
+	return v1.Taint{Key: key, Value: value, Effect: v1.TaintEffect(effect)}, true
+}
+
+// Import creates the proposed nodeclaim of the candidate as launched, so the nodeclaim lifecycle registers the
+// existing node instead of creating an agent pool, and then applies the ownership metadata of kaito to the agent
+// pool. the nodeclaim is created first, otherwise the garbage collection controller could delete the agent pool
+// owned by kaito without a nodeclaim.
+func (p *Provider) Import(ctx context.Context, candidate ImportCandidate) error {
+	klog.InfoS("Instance.Import", "agentpool name", candidate.AgentPoolName)
+	nodeClaim := candidate.NodeClaim.DeepCopy()
+	providerID := nodeClaim.Status.ProviderID
+	if err := p.kubeClient.Create(ctx, nodeClaim); err != nil {
+		return fmt.Errorf("creating nodeclaim %s, %w", nodeClaim.Name, err)
+	}
+	stored := nodeClaim.DeepCopy()
+	nodeClaim.Status.ProviderID = providerID
+	nodeClaim.StatusConditions().SetTrue(karpenterv1.ConditionTypeLaunched)
+	if err := p.kubeClient.Status().Patch(ctx, nodeClaim, client.MergeFrom(stored)); err != nil {
+		return fmt.Errorf("marking nodeclaim %s launched, %w", nodeClaim.Name, err)
+	}
+
+	apObj, err := getAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, p.clusterName, candidate.AgentPoolName)
+	if err != nil {
+		return fmt.Errorf("agentPool.Get for %q failed: %w", candidate.AgentPoolName, err)
+	}
+	backfillAgentPoolMetadata(apObj, nodeClaim.CreationTimestamp.Time)
+	apObj.Properties.NodeLabels[karpenterv1.NodePoolLabelKey] = to.Ptr(nodeClaim.Labels[karpenterv1.NodePoolLabelKey])
+	apObj.Properties.NodeLabels[KaitoNodeLabels[0]] = to.Ptr(nodeClaim.Labels[KaitoNodeLabels[0]])
+	apObj.Properties.Tags[SpecHashTagKey] = to.Ptr(agentPoolSpecHash(apObj))
+
+	defer p.agentPoolCache.Delete(candidate.AgentPoolName)
+	if _, _, err := createAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, candidate.AgentPoolName, p.clusterName, *apObj); err != nil {
+		return fmt.Errorf("agentPool.BeginCreateOrUpdate for %q failed: %w", candidate.AgentPoolName, err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func manualAgentPool(name, vmSize string, count int32, mode armcontainerservice.AgentPoolMode) *armcontainerservice.AgentPool {
+	return &armcontainerservice.AgentPool{
+		Name: to.Ptr(name),
+		Properties: &armcontainerservice.ManagedClusterAgentPoolProfileProperties{
+			VMSize:       to.Ptr(vmSize),
+			Count:        to.Ptr(count),
+			Mode:         to.Ptr(mode),
+			OSDiskSizeGB: to.Ptr(int32(128)),
+			NodeLabels:   map[string]*string{"team": to.Ptr("ml")},
+			NodeTaints:   []*string{to.Ptr("sku=gpu:NoSchedule"), to.Ptr("kubernetes.azure.com/scalesetpriority=spot:NoSchedule")},
+		},
+	}
+}
+
+func agentPoolNode(apName string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "aks-" + apName + "-20562481-vmss_0",
+			Labels: map[string]string{"agentpool": apName, "kubernetes.azure.com/agentpool": apName},
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: "azure:///subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-" + apName + "-20562481-vmss/virtualMachines/0",
+		},
+	}
+}
+
+func TestImportCandidates(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	owned := manualAgentPool("kaitopool", "Standard_NC6s_v3", 1, armcontainerservice.AgentPoolModeUser)
+	owned.Properties.NodeLabels["kaito.sh/workspace"] = to.Ptr("ws")
+	agentPools := []*armcontainerservice.AgentPool{
+		manualAgentPool("gpupool", "Standard_NC6s_v3", 1, armcontainerservice.AgentPoolModeUser),
+		manualAgentPool("nodepool1", "Standard_NC6s_v3", 1, armcontainerservice.AgentPoolModeSystem),
+		manualAgentPool("cpupool", "Standard_D4s_v3", 1, armcontainerservice.AgentPoolModeUser),
+		manualAgentPool("bigpool", "Standard_NC6s_v3", 3, armcontainerservice.AgentPoolModeUser),
+		manualAgentPool("nonodepool", "Standard_NC6s_v3", 1, armcontainerservice.AgentPoolModeUser),
+		owned,
+	}
+	agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+	agentPoolMocks.EXPECT().NewListPager(gomock.Any(), gomock.Any(), gomock.Any()).Return(runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
+		More: func(page armcontainerservice.AgentPoolsClientListResponse) bool {
+			return false
+		},
+		Fetcher: func(ctx context.Context, page *armcontainerservice.AgentPoolsClientListResponse) (armcontainerservice.AgentPoolsClientListResponse, error) {
+			return armcontainerservice.AgentPoolsClientListResponse{
+				AgentPoolListResult: armcontainerservice.AgentPoolListResult{Value: agentPools},
+			}, nil
+		},
+	}))
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).
+		WithObjects(agentPoolNode("gpupool"), agentPoolNode("kaitopool")).Build()
+	p := NewProvider(NewAZClientFromAPI(agentPoolMocks), kubeClient, "testRG", "testCluster")
+
+	candidates, err := p.ImportCandidates(context.Background(), "ws")
+	assert.NoError(t, err)
+	assert.Len(t, candidates, 1)
+	assert.Equal(t, "gpupool", candidates[0].AgentPoolName)
+
+	nodeClaim := candidates[0].NodeClaim
+	assert.Equal(t, "gpupool", nodeClaim.Name)
+	assert.Equal(t, map[string]string{"team": "ml", karpenterv1.NodePoolLabelKey: "kaito", "kaito.sh/workspace": "ws"}, nodeClaim.Labels)
+	assert.Equal(t, []v1.Taint{{Key: "sku", Value: "gpu", Effect: v1.TaintEffectNoSchedule}}, nodeClaim.Spec.Taints)
+	assert.Equal(t, []string{"Standard_NC6s_v3"}, lo.Must(lo.Find(nodeClaim.Spec.Requirements, func(r karpenterv1.NodeSelectorRequirementWithMinValues) bool {
+		return r.Key == v1.LabelInstanceTypeStable
+	})).Values)
+	assert.Equal(t, resource.MustParse("128Gi"), nodeClaim.Spec.Resources.Requests[v1.ResourceStorage])
+	assert.Equal(t, agentPoolNode("gpupool").Spec.ProviderID, nodeClaim.Status.ProviderID)
+}
+
+func TestImport(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ap := manualAgentPool("gpupool", "Standard_NC6s_v3", 1, armcontainerservice.AgentPoolModeUser)
+	node := agentPoolNode("gpupool")
+	nodeClaim := importedNodeClaim(ap, node, "ws")
+
+	var updated armcontainerservice.AgentPool
+	agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+	agentPoolMocks.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), "gpupool", gomock.Any()).Return(armcontainerservice.AgentPoolsClientGetResponse{AgentPool: *ap}, nil)
+	agentPoolMocks.EXPECT().BeginCreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), "gpupool", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _, _ string, parameters armcontainerservice.AgentPool, _ *armcontainerservice.AgentPoolsClientBeginCreateOrUpdateOptions) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error) {
+			updated = parameters
+			mockHandler := fake.NewMockPollingHandler[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse](mockCtrl)
+			mockHandler.EXPECT().Done().Return(true).AnyTimes()
+			mockHandler.EXPECT().Result(gomock.Any(), gomock.Any()).Return(nil)
+			return runtime.NewPoller(&http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}, runtime.NewPipeline("", "", runtime.PipelineOptions{}, nil),
+				&runtime.NewPollerOptions[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse]{
+					Handler:  mockHandler,
+					Response: &armcontainerservice.AgentPoolsClientCreateOrUpdateResponse{AgentPool: parameters},
+				})
+		})
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).
+		WithStatusSubresource(&karpenterv1.NodeClaim{}).WithObjects(node).Build()
+	p := NewProvider(NewAZClientFromAPI(agentPoolMocks), kubeClient, "testRG", "testCluster")
+
+	assert.NoError(t, p.Import(context.Background(), ImportCandidate{AgentPoolName: "gpupool", NodeClaim: nodeClaim}))
+
+	created := &karpenterv1.NodeClaim{}
+	assert.NoError(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: "gpupool"}, created))
+	assert.Equal(t, node.Spec.ProviderID, created.Status.ProviderID)
+	assert.True(t, created.StatusConditions().Get(karpenterv1.ConditionTypeLaunched).IsTrue())
+
+	assert.True(t, agentPoolIsOwnedByKaito(&updated))
+	assert.True(t, agentPoolIsCreatedFromNodeClaim(&updated))
+	assert.Equal(t, "kaito", lo.FromPtr(updated.Properties.NodeLabels[karpenterv1.NodePoolLabelKey]))
+	assert.Equal(t, ManagedByTagValue, lo.FromPtr(updated.Properties.Tags[ManagedByTagKey]))
+	assert.Equal(t, agentPoolSpecHash(&updated), lo.FromPtr(updated.Properties.Tags[SpecHashTagKey]))
+}
@@ -17,16 +17,25 @@ package instance
 
 import (
 	"context"
+	"crypto/cipher"
+	"encoding/base64"
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
 	"github.com/azure/gpu-provisioner/pkg/utils"
+	"github.com/azure/gpu-provisioner/pkg/utils/validation"
+	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
+	"go.uber.org/multierr"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/retry"
@@ -43,6 +52,28 @@ const (
 	NodeClaimCreationLabel = "kaito.sh/creation-timestamp"
 	// use self-defined layout in order to satisfy node label syntax
 	CreationTimestampLayout = "2006-01-02T15-04-05Z"
+
+	// AgentPoolCacheTTL is the time agent pools fetched from ARM are served from cache.
+	AgentPoolCacheTTL = 30 * time.Second
+	// DeletedAgentPoolTTL is the time a completed agent pool deletion is remembered, duplicate delete
+	// attempts within this period return directly without calling ARM.
+	DeletedAgentPoolTTL = 5 * time.Minute
+
+	// DeleteResumeTokenAnnotation records the resume token of the agent pool deletion LRO on the nodeclaim, so the
+	// deletion is resumed instead of restarted after gpu-provisioner restarts. the token embeds the polling URLs of the
+	// LRO, so it's only recorded encrypted when RESUME_TOKEN_ENCRYPTION_KEY is set.
+	DeleteResumeTokenAnnotation = "kaito.sh/delete-resume-token"
+
+	// azure tag key can not contain "/", so "_" is used instead.
+	ManagedByTagKey   = "kaito.sh_managed-by"
+	ManagedByTagValue = "gpu-provisioner"
+
+	// GCTagKey with value GCTagDisabled protects the agent pool from garbage collection, so it can be protected from
+	// the Azure side by teams without cluster access.
+	GCTagKey      = "kaito-gc"
+	GCTagDisabled = "disabled"
+
+	azureProviderIDPrefix = "azure://"
 )
 
 var (
@@ -50,11 +81,72 @@ var (
 	AgentPoolNameRegex = regexp.MustCompile(`^[a-z][a-z0-9]{0,11}$`)
 )
 
+var _ InstanceProvider = &Provider{}
+
 type Provider struct {
-	azClient      *AZClient
-	kubeClient    client.Client
+	azClient   *AZClient
+	kubeClient client.Client
+	// nodeClient looks up the nodes of agent pools, it's the kube client of the provisioned cluster which differs
+	// from kubeClient when gpu-provisioner runs in a management cluster.
+	nodeClient    client.Reader
 	resourceGroup string
 	clusterName   string
+
+	// agentPoolCache caches agent pools by name in order to reduce ARM GET calls.
+	agentPoolCache *cache.Cache
+	// warmUpMu is held by WarmUp, Get waits for cache warming to finish so it is served from the warm cache.
+	warmUpMu sync.RWMutex
+	// fetches tracks in-flight agent pool GETs by name, so bursts of reconciles on cache miss share one ARM call.
+	fetchesMu sync.Mutex
+	fetches   map[string]*fetch
+
+	// deletions tracks in-flight agent pool deletions by name, so concurrent delete attempts share one LRO.
+	deletionsMu sync.Mutex
+	deletions   map[string]*deletion
+	// deletedAgentPools records agent pools whose deletion LRO has completed.
+	deletedAgentPools *cache.Cache
+
+	// retailPriceCache caches retail prices of instance types for price capped nodeclaims.
+	retailPriceCache *cache.Cache
+	// clusterStateCache caches the provisioning state of the managed cluster.
+	clusterStateCache *cache.Cache
+	// skuCache caches the SKUs of the region of the cluster.
+	skuCache *cache.Cache
+
+	// spotAgentPools allows creating spot agent pools for nodeclaims which only allow spot capacity type.
+	spotAgentPools bool
+	// tagTemplates are the default tags of created agent pools, values are rendered with the nodeclaim.
+	tagTemplates map[string]*template.Template
+	// upgradeSettings are the upgrade settings of created agent pools, AKS defaults are used if it's nil.
+	upgradeSettings *armcontainerservice.AgentPoolUpgradeSettings
+	// resumeAgentPools makes Create start the deallocated agent pool of the nodeclaim instead of creating one.
+	resumeAgentPools bool
+	// logSpecDiffs logs the agent pool spec diff of update decisions.
+	logSpecDiffs bool
+	// naming generates the agent pool names of nodeclaims.
+	naming NamingStrategy
+
+	// operations indexes the ARM operations started on agent pools by nodeclaim name.
+	operationsMu       sync.Mutex
+	operations         *cache.Cache
+	operationRetention time.Duration
+	// checkpoints persists the operation index, it's not persisted if nil.
+	checkpoints CheckpointStore
+	// resumeTokenCipher encrypts the deletion resume tokens recorded on nodeclaims, they are not recorded if nil.
+	resumeTokenCipher cipher.AEAD
+}
+
+// fetch is an in-flight agent pool GET, done is closed when the GET returns.
+type fetch struct {
+	done  chan struct{}
+	apObj *armcontainerservice.AgentPool
+	err   error
+}
+
+// deletion is an in-flight agent pool deletion, done is closed when the deletion LRO completes.
+type deletion struct {
+	done chan struct{}
+	err  error
 }
 
 func NewProvider(
@@ -63,28 +155,111 @@ func NewProvider(
 	resourceGroup string,
 	clusterName string,
 ) *Provider {
-	return &Provider{
-		azClient:      azClient,
-		kubeClient:    kubeClient,
-		resourceGroup: resourceGroup,
-		clusterName:   clusterName,
+	tagTemplates, err := parseTagTemplates(utils.WithDefaultString("AGENTPOOL_TAGS", ""))
+	if err != nil {
+		panic(fmt.Sprintf("invalid AGENTPOOL_TAGS, %v", err))
+	}
+	upgradeSettings, err := newUpgradeSettings(
+		utils.WithDefaultString("AGENTPOOL_UPGRADE_MAX_SURGE", ""),
+		utils.WithDefaultDuration("AGENTPOOL_UPGRADE_DRAIN_TIMEOUT", 0),
+		utils.WithDefaultDuration("AGENTPOOL_UPGRADE_NODE_SOAK_DURATION", 0),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("invalid agent pool upgrade settings, %v", err))
+	}
+	operationRetention := utils.WithDefaultDuration("OPERATION_INDEX_RETENTION", 24*time.Hour)
+	var checkpoints CheckpointStore
+	if utils.WithDefaultBool("PERSIST_OPERATION_INDEX", false) {
+		checkpoints, err = newCheckpointStore(utils.WithDefaultString("CHECKPOINT_STORE", CheckpointStoreConfigMap), kubeClient, utils.WithDefaultString("SYSTEM_NAMESPACE", "gpu-provisioner"))
+		if err != nil {
+			panic(fmt.Sprintf("invalid CHECKPOINT_STORE, %v", err))
+		}
+	}
+	resumeTokenCipher, err := newResumeTokenCipher(utils.WithDefaultString("RESUME_TOKEN_ENCRYPTION_KEY", ""))
+	if err != nil {
+		panic(fmt.Sprintf("invalid RESUME_TOKEN_ENCRYPTION_KEY, %v", err))
+	}
+	if resumeTokenCipher == nil {
+		klog.Warning("RESUME_TOKEN_ENCRYPTION_KEY is not set, resume tokens of agent pool deletions are not recorded and deletions interrupted by a restart are started again")
 	}
+	p := &Provider{
+		azClient:          azClient,
+		kubeClient:        kubeClient,
+		nodeClient:        kubeClient,
+		resourceGroup:     resourceGroup,
+		clusterName:       clusterName,
+		agentPoolCache:    cache.New(AgentPoolCacheTTL, time.Minute),
+		fetches:           map[string]*fetch{},
+		deletions:         map[string]*deletion{},
+		deletedAgentPools: cache.New(DeletedAgentPoolTTL, time.Minute),
+		retailPriceCache:  cache.New(RetailPriceCacheTTL, time.Hour),
+		clusterStateCache: cache.New(ClusterStateCacheTTL, time.Minute),
+		skuCache:          cache.New(SKUCacheTTL, time.Hour),
+		// pods on spot agent pools must tolerate the spot taint added by AKS, so spot is opt-in.
+		spotAgentPools:  utils.WithDefaultBool("ENABLE_SPOT_AGENTPOOLS", false),
+		tagTemplates:    tagTemplates,
+		upgradeSettings: upgradeSettings,
+		// looking up deallocated agent pools costs an agent pool GET per creation, so resuming is opt-in.
+		resumeAgentPools: utils.WithDefaultBool("ENABLE_AGENTPOOL_RESUME", false),
+		logSpecDiffs:     utils.WithDefaultBool("LOG_SPEC_DIFF", false),
+
+		operations:         cache.New(operationRetention, time.Hour),
+		operationRetention: operationRetention,
+		checkpoints:        checkpoints,
+		resumeTokenCipher:  resumeTokenCipher,
+	}
+	// existing agent pools keep their names whatever the naming is, so the naming can be changed at any time.
+	p.naming, err = newNamingStrategy(utils.WithDefaultString("AGENTPOOL_NAMING", NamingLegacy), utils.WithDefaultString("AGENTPOOL_NAME_PREFIX", "gpu"), p)
+	if err != nil {
+		panic(fmt.Sprintf("invalid agent pool naming, %v", err))
+	}
+	return p
+}
+
+// WithNodeClient makes the provider look up nodes of agent pools with the node client, e.g. a client of the
+// provisioned cluster when gpu-provisioner runs in a management cluster.
+func (p *Provider) WithNodeClient(nodeClient client.Reader) *Provider {
+	p.nodeClient = nodeClient
+	return p
+}
+
+// WithAPIReader makes the provider read checkpoints stored in secrets with the uncached reader, so gpu-provisioner
+// doesn't cache all secrets of the cluster.
+func (p *Provider) WithAPIReader(reader client.Reader) *Provider {
+	if store, ok := p.checkpoints.(*secretCheckpointStore); ok {
+		store.reader = reader
+	}
+	return p
 }
 
 // Create an instance given the constraints.
 // instanceTypes should be sorted by priority for spot capacity type.
-func (p *Provider) Create(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (*Instance, error) {
+func (p *Provider) Create(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (*CreateResult, error) {
 	klog.InfoS("Instance.Create", "nodeClaim", klog.KObj(nodeClaim))
 
-	// We made a strong assumption here. The nodeClaim name should be a valid agent pool name without "-".
-	apName := nodeClaim.Name
+	// with the legacy naming the nodeClaim name should be a valid agent pool name without "-".
+	apName, err := p.agentPoolName(ctx, nodeClaim)
+	if err != nil {
+		return nil, err
+	}
 	if !AgentPoolNameRegex.MatchString(apName) {
 		//https://learn.microsoft.com/en-us/troubleshoot/azure/azure-kubernetes/aks-common-issues-faq#what-naming-restrictions-are-enforced-for-aks-resources-and-parameters-
 		return nil, fmt.Errorf("agentpool name(%s) is invalid, must match regex pattern: ^[a-z][a-z0-9]{0,11}$", apName)
 	}
+	capacityType, err := capacityTypeOf(nodeClaim, p.spotAgentPools)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkClusterUpgrading(ctx); err != nil {
+		return nil, err
+	}
+	// agent pool with the same name may be deleted recently, it should be deleted again when the new one is removed.
+	p.deletedAgentPools.Delete(apName)
 
+	result := &CreateResult{AgentPoolName: apName, CapacityType: capacityType}
+	start := time.Now()
 	var ap *armcontainerservice.AgentPool
-	err := retry.OnError(retry.DefaultBackoff, func(err error) bool {
+	err = retry.OnError(retry.DefaultBackoff, func(err error) bool {
 		return false
 	}, func() error {
 		instanceTypes := scheduling.NewNodeSelectorRequirementsWithMinValues(nodeClaim.Spec.Requirements...).Get("node.kubernetes.io/instance-type").Values()
@@ -92,15 +267,51 @@ func (p *Provider) Create(ctx context.Context, nodeClaim *karpenterv1.NodeClaim)
 			return fmt.Errorf("nodeClaim spec has no requirement for instance type")
 		}
 
+		instanceTypes, skuLabels, err := p.instanceTypesWithSKURequirements(ctx, nodeClaim, instanceTypes)
+		if err != nil {
+			return err
+		}
+
+		instanceTypes, err = p.instanceTypesUnderPriceCap(ctx, nodeClaim, instanceTypes, capacityType)
+		if err != nil {
+			return err
+		}
+
+		if p.resumeAgentPools {
+			resumable, err := p.resumableAgentPool(ctx, apName, instanceTypes, capacityType)
+			if err != nil {
+				return err
+			}
+			if resumable != nil {
+				result.VMSize = lo.FromPtr(resumable.Properties.VMSize)
+				result.Resumed = true
+				logging.FromContext(ctx).Debugf("resuming deallocated Agent pool %s (%s)", apName, result.VMSize)
+				ap, result.OperationID, err = resumeAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, apName, p.clusterName, resumable)
+				p.agentPoolCache.Delete(apName)
+				if err != nil {
+					return fmt.Errorf("resuming agent pool %q failed: %w", apName, err)
+				}
+				return nil
+			}
+		}
+
 		vmSize := instanceTypes[0]
-		apObj, apErr := newAgentPoolObject(vmSize, nodeClaim)
+		result.VMSize = vmSize
+		apObj, apErr := newAgentPoolObject(vmSize, capacityType, nodeClaim)
 		if apErr != nil {
 			return apErr
 		}
+		// nodes are labeled with the sku labels the nodeclaim requires, so they satisfy the nodeclaim requirements.
+		addNodeLabels(&apObj, skuLabels)
+		if err := addTags(&apObj, p.tagTemplates, nodeClaim); err != nil {
+			return err
+		}
+		tagNodeClaim(&apObj, nodeClaim, apName)
+		apObj.Properties.UpgradeSettings = p.upgradeSettings
 
 		logging.FromContext(ctx).Debugf("creating Agent pool %s (%s)", apName, vmSize)
-		var err error
-		ap, err = createAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, apName, p.clusterName, apObj)
+		ap, result.OperationID, err = createAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, apName, p.clusterName, apObj)
+		p.agentPoolCache.Delete(apName)
 		if err != nil {
 			switch {
 			case strings.Contains(err.Error(), "Operation is not allowed because there's an in progress create node pool operation"):
@@ -110,16 +321,24 @@ func (p *Provider) Create(ctx context.Context, nodeClaim *karpenterv1.NodeClaim)
 				return nil
 			default:
 				logging.FromContext(ctx).Errorf("failed to create agent pool for nodeclaim(%s), %v", nodeClaim.Name, err)
-				return fmt.Errorf("agentPool.BeginCreateOrUpdate for %q failed: %w", apName, err)
+				err = fmt.Errorf("agentPool.BeginCreateOrUpdate for %q failed: %w", apName, err)
+				if IsQuotaExceededError(err) {
+					return withRemediation(err, p.quotaRemediation(ctx, vmSize))
+				}
+				return err
 			}
 		}
 		logging.FromContext(ctx).Debugf("created agent pool %s", *ap.ID)
 		return nil
 	})
+	p.recordOperation(ctx, apName, lo.Ternary(result.Resumed, "resume", "create"), result.OperationID, err)
 	if err != nil {
 		return nil, err
 	}
+	result.CreateDuration = time.Since(start)
+	result.Zone = lo.FromPtr(agentPoolZone(ap))
 
+	start = time.Now()
 	instance, err := p.fromRegisteredAgentPoolToInstance(ctx, ap)
 	if instance == nil && err == nil {
 		// means the node object has not been found yet, we wait until the node is created
@@ -147,7 +366,12 @@ func (p *Provider) Create(ctx context.Context, nodeClaim *karpenterv1.NodeClaim)
 			return nil, err
 		}
 	}
-	return instance, err
+	if err != nil {
+		return nil, err
+	}
+	result.RegistrationDuration = time.Since(start)
+	result.Instance = instance
+	return result, nil
 }
 
 func (p *Provider) Get(ctx context.Context, id string) (*Instance, error) {
@@ -155,7 +379,7 @@ func (p *Provider) Get(ctx context.Context, id string) (*Instance, error) {
 	if err != nil {
 		return nil, fmt.Errorf("getting agentpool name, %w", err)
 	}
-	apObj, err := getAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, p.clusterName, apName)
+	apObj, err := p.getAgentPool(ctx, apName)
 	if err != nil {
 		if strings.Contains(err.Error(), "Agent Pool not found") {
 			return nil, cloudprovider.NewNodeClaimNotFoundError(err)
@@ -167,21 +391,191 @@ func (p *Provider) Get(ctx context.Context, id string) (*Instance, error) {
 	return p.convertAgentPoolToInstance(ctx, apObj, id)
 }
 
+// ListOptions controls the pagination of ListPage.
+type ListOptions struct {
+	// PageSize is the max number of instances returned by one ListPage call, zero means no limit.
+	PageSize int
+	// Continue is the continuation token returned by the previous ListPage call, empty means listing from the beginning.
+	Continue string
+	// IncludeUntagged lists agent pools from ARM even if resource graph is enabled, so agent pools without
+	// ownership tags(e.g. created by older releases) are included.
+	IncludeUntagged bool
+}
+
 func (p *Provider) List(ctx context.Context) ([]*Instance, error) {
-	apList, err := listAgentPools(ctx, p.azClient.agentPoolsClient, p.resourceGroup, p.clusterName)
+	instances, _, err := p.ListPage(ctx, ListOptions{})
+	return instances, err
+}
+
+// ListPage returns at most opts.PageSize instances owned by kaito sorted by name, and a continuation token for
+// fetching the next page. an empty continuation token means there are no more instances.
+// the continuation token is the name of the last listed agent pool, so agent pools created or deleted between pages
+// never shift the following pages. agent pools are streamed from azure and only the page with the smallest names
+// after the token is kept, so callers can walk large clusters without holding all agent pool objects in memory.
+func (p *Provider) ListPage(ctx context.Context, opts ListOptions) ([]*Instance, string, error) {
+	after, err := decodeContinueToken(opts.Continue)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// page holds the owned agent pools with the smallest names after the token seen so far, in order of names.
+	var page []*armcontainerservice.AgentPool
+	more := false
+	fromResourceGraph := p.azClient.resourceGraphClient != nil && !opts.IncludeUntagged
+	err = p.forEachAgentPool(ctx, fromResourceGraph, func(ap *armcontainerservice.AgentPool) (bool, error) {
+		// skip agentPool that is not owned by kaito or not created from nodeclaim
+		if !agentPoolIsOwnedByKaito(ap) || !agentPoolIsCreatedFromNodeClaim(ap) {
+			return true, nil
+		}
+		name := lo.FromPtr(ap.Name)
+		if name <= after {
+			return true, nil
+		}
+		i, _ := slices.BinarySearchFunc(page, name, func(ap *armcontainerservice.AgentPool, name string) int {
+			return strings.Compare(lo.FromPtr(ap.Name), name)
+		})
+		page = slices.Insert(page, i, ap)
+		if opts.PageSize > 0 && len(page) > opts.PageSize {
+			page = page[:opts.PageSize]
+			more = true
+		}
+		return true, nil
+	})
 	if err != nil {
 		logging.FromContext(ctx).Errorf("Listing agentpools failed: %v", err)
-		return nil, fmt.Errorf("agentPool.NewListPager failed: %w", err)
+		return nil, "", fmt.Errorf("agentPool.NewListPager failed: %w", err)
+	}
+
+	instances := make([]*Instance, 0, len(page))
+	for _, ap := range page {
+		// only agent pools of the page are cached, agent pools from resource graph may be stale, so they are not
+		// cached for Get.
+		if !fromResourceGraph {
+			p.agentPoolCache.SetDefault(lo.FromPtr(ap.Name), ap)
+		}
+		instance, err := p.fromKaitoAgentPoolToInstance(ctx, ap)
+		if err != nil {
+			return nil, "", err
+		}
+		instances = append(instances, instance)
 	}
 
-	instances, err := p.fromAPListToInstances(ctx, apList)
-	return instances, cloudprovider.IgnoreNodeClaimNotFoundError(err)
+	if more {
+		return instances, encodeContinueToken(lo.FromPtr(page[len(page)-1].Name)), nil
+	}
+	return instances, "", nil
+}
+
+// encodeContinueToken returns the continuation token listing agent pools with names after the name.
+func encodeContinueToken(name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(name))
 }
 
-func (p *Provider) Delete(ctx context.Context, apName string) error {
-	klog.InfoS("Instance.Delete", "agentpool name", apName)
+// decodeContinueToken returns the name of the last agent pool listed before the continuation token, an empty
+// token lists from the beginning.
+func decodeContinueToken(token string) (string, error) {
+	name, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("invalid continuation token %q", token)
+	}
+	return string(name), nil
+}
+
+// forEachAgentPool iterates agent pools of the cluster, only agent pools owned by gpu-provisioner are returned
+// when resource graph is used.
+func (p *Provider) forEachAgentPool(ctx context.Context, fromResourceGraph bool, fn func(ap *armcontainerservice.AgentPool) (bool, error)) error {
+	if fromResourceGraph {
+		return forEachOwnedAgentPool(ctx, p.azClient.resourceGraphClient, p.azClient.subscriptionID, p.resourceGroup, p.clusterName, fn)
+	}
+	return forEachAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, p.clusterName, fn)
+}
 
-	err := deleteAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, p.clusterName, apName)
+// Delete deletes the agent pool of the instance identified by the provider ID and waits until the deletion LRO
+// completes. concurrent delete attempts for the same agent pool are coalesced into one LRO, and attempts after the
+// deletion has completed return directly.
+//
+// Deprecated behavior: agent pool names are still accepted in place of the provider ID for callers of the previous
+// name based contract and for nodeclaims which failed to launch and have no provider ID.
+func (p *Provider) Delete(ctx context.Context, id string) error {
+	apName, err := agentPoolNameFromID(id)
+	if err != nil {
+		return fmt.Errorf("getting agentpool name, %w", err)
+	}
+	klog.InfoS("Instance.Delete", "agentpool name", apName, "id", id)
+
+	if _, ok := p.deletedAgentPools.Get(apName); ok {
+		klog.InfoS("Instance.Delete skipped, agentpool has been deleted", "agentpool name", apName)
+		return nil
+	}
+	if err := p.checkClusterUpgrading(ctx); err != nil {
+		return err
+	}
+
+	p.deletionsMu.Lock()
+	d, inflight := p.deletions[apName]
+	if !inflight {
+		d = &deletion{done: make(chan struct{})}
+		p.deletions[apName] = d
+	}
+	p.deletionsMu.Unlock()
+
+	if inflight {
+		klog.InfoS("Instance.Delete coalesced with in-flight deletion", "agentpool name", apName)
+		select {
+		case <-d.done:
+			return d.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	d.err = p.deleteAgentPool(ctx, apName)
+	if d.err == nil {
+		p.deletedAgentPools.SetDefault(apName, struct{}{})
+	}
+	p.deletionsMu.Lock()
+	delete(p.deletions, apName)
+	p.deletionsMu.Unlock()
+	close(d.done)
+	return d.err
+}
+
+// agentPoolNameFromID translates the provider ID of an instance to its agent pool name, ids without the azure
+// provider ID prefix are agent pool names already.
+func agentPoolNameFromID(id string) (string, error) {
+	if !strings.HasPrefix(id, azureProviderIDPrefix) {
+		return id, nil
+	}
+	return utils.ParseAgentPoolNameFromID(id)
+}
+
+func (p *Provider) deleteAgentPool(ctx context.Context, apName string) error {
+	defer p.agentPoolCache.Delete(apName)
+
+	ap, err := p.checkAgentPoolOwnership(ctx, apName)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Refusing to delete agentpool %q: %v", apName, err)
+		return err
+	}
+
+	var resumeToken string
+	var saveResumeToken func(string)
+	if nodeClaim, err := p.nodeClaimOfAgentPool(ctx, apName, ap); err == nil {
+		resumeToken, err = p.openResumeToken(nodeClaim.Annotations[DeleteResumeTokenAnnotation])
+		if err != nil {
+			// the deletion is restarted, and the token of the new deletion LRO replaces the unreadable one.
+			logging.FromContext(ctx).Errorf("Reading deletion resume token of nodeclaim %q failed: %v", apName, err)
+		}
+		if resumeToken == "" && p.resumeTokenCipher != nil {
+			saveResumeToken = func(token string) {
+				p.saveDeleteResumeToken(ctx, nodeClaim, token)
+			}
+		}
+	} else if !apierrors.IsNotFound(err) {
+		logging.FromContext(ctx).Errorf("Getting nodeclaim %q for deletion resume token failed: %v", apName, err)
+	}
+
+	err = deleteAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, p.clusterName, apName, resumeToken, saveResumeToken)
 	if err != nil {
 		logging.FromContext(ctx).Errorf("Deleting agentpool %q failed: %v", apName, err)
 		return fmt.Errorf("agentPool.Delete for %q failed: %w", apName, err)
@@ -189,25 +583,118 @@ func (p *Provider) Delete(ctx context.Context, apName string) error {
 	return nil
 }
 
+// saveDeleteResumeToken records the resume token of the deletion LRO on the nodeclaim, failure is only logged
+// because the deletion will be restarted if the token is lost.
+func (p *Provider) saveDeleteResumeToken(ctx context.Context, nodeClaim *karpenterv1.NodeClaim, token string) {
+	sealed, err := p.sealResumeToken(token)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Encrypting deletion resume token of nodeclaim %q failed: %v", nodeClaim.Name, err)
+		return
+	}
+	stored := nodeClaim.DeepCopy()
+	nodeClaim.Annotations = lo.Assign(nodeClaim.Annotations, map[string]string{DeleteResumeTokenAnnotation: sealed})
+	if err := p.kubeClient.Patch(ctx, nodeClaim, client.MergeFrom(stored)); err != nil {
+		logging.FromContext(ctx).Errorf("Saving deletion resume token on nodeclaim %q failed: %v", nodeClaim.Name, err)
+	}
+}
+
+// InvalidateCache flushes every cache of the provider, so resources changed out of band(e.g. agent pools after a
+// quota increase) are fetched from azure on the next call. new caches of the provider must be flushed here too.
+func (p *Provider) InvalidateCache() {
+	p.agentPoolCache.Flush()
+	p.retailPriceCache.Flush()
+	p.clusterStateCache.Flush()
+	p.skuCache.Flush()
+	klog.InfoS("Instance cache invalidated")
+}
+
+// WarmUp pre-populates the agent pool cache and the node cache, so controllers reconciling right after
+// startup or leader failover are served from cache instead of issuing a flood of cold-path ARM GETs.
+func (p *Provider) WarmUp(ctx context.Context) error {
+	p.warmUpMu.Lock()
+	defer p.warmUpMu.Unlock()
+
+	start := time.Now()
+	// node informer is started and synced by the first List call against the cached client.
+	if err := p.nodeClient.List(ctx, &v1.NodeList{}); err != nil {
+		return fmt.Errorf("warming up node cache, %w", err)
+	}
+	instances, err := p.List(ctx)
+	if err != nil {
+		return fmt.Errorf("warming up agent pool cache, %w", err)
+	}
+	klog.InfoS("Instance.WarmUp", "agentpool count", len(instances), "duration", time.Since(start))
+	return nil
+}
+
+// getAgentPool returns the agent pool from cache if present, otherwise fetches it from ARM.
+func (p *Provider) getAgentPool(ctx context.Context, apName string) (*armcontainerservice.AgentPool, error) {
+	p.warmUpMu.RLock()
+	cached, ok := p.agentPoolCache.Get(apName)
+	p.warmUpMu.RUnlock()
+	if ok {
+		return cached.(*armcontainerservice.AgentPool), nil
+	}
+
+	p.fetchesMu.Lock()
+	f, inflight := p.fetches[apName]
+	if !inflight {
+		f = &fetch{done: make(chan struct{})}
+		p.fetches[apName] = f
+	}
+	p.fetchesMu.Unlock()
+
+	if inflight {
+		select {
+		case <-f.done:
+			return f.apObj, f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	f.apObj, f.err = getAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, p.clusterName, apName)
+	if f.err == nil {
+		p.agentPoolCache.SetDefault(apName, f.apObj)
+	}
+	p.fetchesMu.Lock()
+	delete(p.fetches, apName)
+	p.fetchesMu.Unlock()
+	close(f.done)
+	return f.apObj, f.err
+}
+
 func (p *Provider) convertAgentPoolToInstance(ctx context.Context, apObj *armcontainerservice.AgentPool, id string) (*Instance, error) {
 	if apObj == nil || len(id) == 0 {
 		return nil, fmt.Errorf("agent pool or provider id is nil")
 	}
 
-	instanceLabels := lo.MapValues(apObj.Properties.NodeLabels, func(k *string, _ string) string {
-		return lo.FromPtr(k)
-	})
+	ins := agentPoolToInstance(apObj)
+	ins.ID = to.Ptr(id)
+	return ins, nil
+}
 
+// agentPoolToInstance converts the fields every instance has in common, all conversions of agent pools go through it
+// so that consumers see the same fields(e.g. ownership and billing tags) no matter how the instance was found.
+func agentPoolToInstance(apObj *armcontainerservice.AgentPool) *Instance {
 	return &Instance{
-		Name:     apObj.Name,
-		ID:       to.Ptr(id),
-		Type:     apObj.Properties.VMSize,
-		SubnetID: apObj.Properties.VnetSubnetID,
-		Tags:     apObj.Properties.Tags,
-		State:    apObj.Properties.ProvisioningState,
-		Labels:   instanceLabels,
-		ImageID:  apObj.Properties.NodeImageVersion,
-	}, nil
+		Name:         apObj.Name,
+		Type:         apObj.Properties.VMSize,
+		SubnetID:     apObj.Properties.VnetSubnetID,
+		Tags:         agentPoolTags(apObj),
+		State:        apObj.Properties.ProvisioningState,
+		Labels:       lo.MapValues(apObj.Properties.NodeLabels, func(k *string, _ string) string { return lo.FromPtr(k) }),
+		ImageID:      apObj.Properties.NodeImageVersion,
+		SpecHash:     agentPoolSpecHash(apObj),
+		CapacityType: to.Ptr(agentPoolCapacityType(apObj)),
+		Zone:         agentPoolZone(apObj),
+		AgentPoolID:  apObj.ID,
+	}
+}
+
+// agentPoolTags returns a copy of the agent pool tags without tags of nil value, the map is never nil.
+func agentPoolTags(apObj *armcontainerservice.AgentPool) map[string]*string {
+	return lo.PickBy(apObj.Properties.Tags, func(_ string, value *string) bool { return value != nil })
 }
 
 func (p *Provider) fromRegisteredAgentPoolToInstance(ctx context.Context, apObj *armcontainerservice.AgentPool) (*Instance, error) {
@@ -241,21 +728,13 @@ func (p *Provider) fromRegisteredAgentPoolToInstance(ctx context.Context, apObj
 		// provider id is not found
 		return nil, nil
 	}
+	if err := utils.ValidateProviderID(nodes[0].Spec.ProviderID, lo.FromPtr(apObj.Name)); err != nil {
+		return nil, fmt.Errorf("adopting node %s, %w", nodes[0].Name, err)
+	}
 
-	// tokens := strings.SplitAfter(node.Name, "-vmss") // remove the vm index "0000"
-	instanceLabels := lo.MapValues(apObj.Properties.NodeLabels, func(k *string, _ string) string {
-		return lo.FromPtr(k)
-	})
-	return &Instance{
-		Name: apObj.Name,
-		// ID:       to.Ptr(fmt.Sprint("azure://", p.getVMSSNodeProviderID(lo.FromPtr(subID), tokens[0]))),
-		ID:       to.Ptr(nodes[0].Spec.ProviderID),
-		Type:     apObj.Properties.VMSize,
-		SubnetID: apObj.Properties.VnetSubnetID,
-		Tags:     apObj.Properties.Tags,
-		State:    apObj.Properties.ProvisioningState,
-		Labels:   instanceLabels,
-	}, nil
+	ins := agentPoolToInstance(apObj)
+	ins.ID = to.Ptr(nodes[0].Spec.ProviderID)
+	return ins, nil
 }
 
 // fromKaitoAgentPoolToInstance is used to convert agentpool that owned by kaito to Instance, and agentPools that have no
@@ -265,17 +744,7 @@ func (p *Provider) fromKaitoAgentPoolToInstance(ctx context.Context, apObj *armc
 		return nil, fmt.Errorf("agent pool is nil")
 	}
 
-	instanceLabels := lo.MapValues(apObj.Properties.NodeLabels, func(k *string, _ string) string {
-		return lo.FromPtr(k)
-	})
-	ins := &Instance{
-		Name:     apObj.Name,
-		Type:     apObj.Properties.VMSize,
-		SubnetID: apObj.Properties.VnetSubnetID,
-		Tags:     apObj.Properties.Tags,
-		State:    apObj.Properties.ProvisioningState,
-		Labels:   instanceLabels,
-	}
+	ins := agentPoolToInstance(apObj)
 
 	nodes, err := p.getNodesByName(ctx, lo.FromPtr(apObj.Name))
 	if err != nil {
@@ -283,45 +752,22 @@ func (p *Provider) fromKaitoAgentPoolToInstance(ctx context.Context, apObj *armc
 	}
 
 	if len(nodes) == 1 && len(nodes[0].Spec.ProviderID) != 0 {
-		ins.ID = to.Ptr(nodes[0].Spec.ProviderID)
+		if err := utils.ValidateProviderID(nodes[0].Spec.ProviderID, lo.FromPtr(apObj.Name)); err != nil {
+			// the agent pool is garbage collected by name without the id of a foreign node.
+			klog.ErrorS(err, "skip adopting node", "node", nodes[0].Name)
+		} else {
+			ins.ID = to.Ptr(nodes[0].Spec.ProviderID)
+		}
 	}
 
 	return ins, nil
 }
 
-func (p *Provider) fromAPListToInstances(ctx context.Context, apList []*armcontainerservice.AgentPool) ([]*Instance, error) {
-	instances := []*Instance{}
-	if len(apList) == 0 {
-		return instances, cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("agentpools not found"))
+func newAgentPoolObject(vmSize string, capacityType string, nodeClaim *karpenterv1.NodeClaim) (armcontainerservice.AgentPool, error) {
+	if err := multierr.Combine(validation.ValidateLabels(nodeClaim.Labels), validation.ValidateTaints(nodeClaim.Spec.Taints)); err != nil {
+		return armcontainerservice.AgentPool{}, fmt.Errorf("validating nodeclaim(%s), %w", nodeClaim.Name, err)
 	}
-	for index := range apList {
-		// skip agentPool that is not owned by kaito
-		if !agentPoolIsOwnedByKaito(apList[index]) {
-			continue
-		}
 
-		// skip agentPool which is not created from nodeclaim
-		if !agentPoolIsCreatedFromNodeClaim(apList[index]) {
-			continue
-		}
-
-		instance, err := p.fromKaitoAgentPoolToInstance(ctx, apList[index])
-		if err != nil {
-			return instances, err
-		}
-		if instance != nil {
-			instances = append(instances, instance)
-		}
-	}
-
-	if len(instances) == 0 {
-		return instances, cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("agentpools not found"))
-	}
-
-	return instances, nil
-}
-
-func newAgentPoolObject(vmSize string, nodeClaim *karpenterv1.NodeClaim) (armcontainerservice.AgentPool, error) {
 	taints := nodeClaim.Spec.Taints
 	taintsStr := []*string{}
 	for _, t := range taints {
@@ -355,8 +801,9 @@ func newAgentPoolObject(vmSize string, nodeClaim *karpenterv1.NodeClaim) (armcon
 		diskSizeGB = int32(storage.Value() >> 30)
 	}
 
-	return armcontainerservice.AgentPool{
+	ap := armcontainerservice.AgentPool{
 		Properties: &armcontainerservice.ManagedClusterAgentPoolProfileProperties{
+			Tags:         map[string]*string{ManagedByTagKey: to.Ptr(ManagedByTagValue)},
 			NodeLabels:   labels,
 			NodeTaints:   taintsStr, //[]*string{to.Ptr("sku=gpu:NoSchedule")},
 			Type:         to.Ptr(scaleSetsType),
@@ -365,7 +812,10 @@ func newAgentPoolObject(vmSize string, nodeClaim *karpenterv1.NodeClaim) (armcon
 			Count:        to.Ptr(int32(1)),
 			OSDiskSizeGB: to.Ptr(diskSizeGB),
 		},
-	}, nil
+	}
+	setCapacityType(&ap, capacityType)
+	ap.Properties.Tags[SpecHashTagKey] = to.Ptr(agentPoolSpecHash(&ap))
+	return ap, nil
 }
 
 func (p *Provider) getNodesByName(ctx context.Context, apName string) ([]*v1.Node, error) {
@@ -375,7 +825,7 @@ func (p *Provider) getNodesByName(ctx context.Context, apName string) ([]*v1.Nod
 	err := retry.OnError(retry.DefaultRetry, func(err error) bool {
 		return true
 	}, func() error {
-		return p.kubeClient.List(ctx, nodeList, labelSelector)
+		return p.nodeClient.List(ctx, nodeList, labelSelector)
 	})
 	if err != nil {
 		return nil, err
@@ -21,19 +21,23 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
 	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/mock/gomock"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 )
@@ -49,22 +53,17 @@ func TestNewAgentPoolObject(t *testing.T) {
 		{
 			name:   "NodeClaim with Storage requirement",
 			vmSize: "Standard_NC6s_v3",
-			nodeClaim: fake.GetNodeClaimObj("nodeclaim-test", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{
-				Requests: v1.ResourceList{
-					v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(30*1024*1024*1024, resource.DecimalSI)),
-				},
-			}, []v1.NodeSelectorRequirement{}),
+			nodeClaim: fixture.NodeClaim().WithName("nodeclaim-test").WithLabels(map[string]string{"test": "test"}).
+				WithStorage(30).Build(),
 			expected: GetAgentPoolObj(armcontainerservice.AgentPoolTypeVirtualMachineScaleSets,
 				armcontainerservice.ScaleSetPriorityRegular, map[string]*string{"test": to.Ptr("test")},
 				[]*string{}, 30, "Standard_NC6s_v3"),
 			expectedErr: false,
 		},
 		{
-			name:   "NodeClaim with no Storage requirement",
-			vmSize: "Standard_NC6s_v3",
-			nodeClaim: fake.GetNodeClaimObj("nodeclaim-test", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{
-				Requests: v1.ResourceList{},
-			}, []v1.NodeSelectorRequirement{}),
+			name:        "NodeClaim with no Storage requirement",
+			vmSize:      "Standard_NC6s_v3",
+			nodeClaim:   fixture.NodeClaim().WithName("nodeclaim-test").WithLabels(map[string]string{"test": "test"}).Build(),
 			expected:    armcontainerservice.AgentPool{},
 			expectedErr: true,
 		},
@@ -72,7 +71,7 @@ func TestNewAgentPoolObject(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result, err := newAgentPoolObject(tc.vmSize, tc.nodeClaim)
+			result, err := newAgentPoolObject(tc.vmSize, karpenterv1.CapacityTypeOnDemand, tc.nodeClaim)
 			if tc.expectedErr {
 				assert.EqualError(t, err, fmt.Sprintf("storage request of nodeclaim(%s) should be more than 0", tc.nodeClaim.Name))
 				return
@@ -124,7 +123,7 @@ func TestGet(t *testing.T) {
 		{
 			name:          "Fail to get instance because agent pool ID cannot be parsed properly",
 			id:            "azure:///subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/virtualMachines/0",
-			expectedError: errors.New("getting agentpool name, id does not match the regxp for ParseAgentPoolNameFromID"),
+			expectedError: errors.New("getting agentpool name, provider id"),
 		},
 	}
 
@@ -235,6 +234,37 @@ func TestFromAgentPoolToInstance(t *testing.T) {
 	}
 }
 
+func TestAgentPoolTags(t *testing.T) {
+	testCases := map[string]struct {
+		tags         map[string]*string
+		expectedTags map[string]*string
+	}{
+		"agent pool without tags": {
+			tags:         nil,
+			expectedTags: map[string]*string{},
+		},
+		"tags of nil value are dropped": {
+			tags:         map[string]*string{ManagedByTagKey: to.Ptr(ManagedByTagValue), "owner": nil},
+			expectedTags: map[string]*string{ManagedByTagKey: to.Ptr(ManagedByTagValue)},
+		},
+	}
+
+	for k, tc := range testCases {
+		t.Run(k, func(t *testing.T) {
+			ap := GetAgentPoolObjWithName("agentpool0", "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool0-20562481-vmss", "Standard_NC6s_v3")
+			ap.Properties.Tags = tc.tags
+
+			ins := agentPoolToInstance(&ap)
+			assert.Equal(t, tc.expectedTags, ins.Tags)
+			if tc.tags != nil {
+				// the instance owns a copy of the tags.
+				ins.Tags["billing"] = to.Ptr("team")
+				assert.Nil(t, ap.Properties.Tags["billing"])
+			}
+		})
+	}
+}
+
 func TestDelete(t *testing.T) {
 	testCases := []struct {
 		name              string
@@ -261,6 +291,30 @@ func TestDelete(t *testing.T) {
 				return p, err
 			},
 		},
+		{
+			name:   "Successfully delete instance by provider ID",
+			apName: "azure:///subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool0-20562481-vmss/virtualMachines/0",
+			mockAgentPoolResp: func(mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientDeleteResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientDeleteResponse], error) {
+				delResp := armcontainerservice.AgentPoolsClientDeleteResponse{}
+				resp := http.Response{Status: "200 OK", StatusCode: http.StatusOK, Body: http.NoBody}
+
+				mockHandler.EXPECT().Done().Return(true).Times(3)
+				mockHandler.EXPECT().Result(gomock.Any(), gomock.Any()).Return(nil)
+
+				pollingOptions := &runtime.NewPollerOptions[armcontainerservice.AgentPoolsClientDeleteResponse]{
+					Handler:  mockHandler,
+					Response: &delResp,
+				}
+
+				p, err := runtime.NewPoller(&resp, runtime.NewPipeline("", "", runtime.PipelineOptions{}, nil), pollingOptions)
+				return p, err
+			},
+		},
+		{
+			name:          "Fail to delete instance because of invalid provider ID",
+			apName:        "azure:///subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/nodeRG",
+			expectedError: errors.New("getting agentpool name"),
+		},
 		{
 			name:   "Successfully deletes instance because poller returns a 404 not found error",
 			apName: "agentpool0",
@@ -331,6 +385,7 @@ func TestDelete(t *testing.T) {
 			}
 
 			mockK8sClient := fake.NewClient()
+			mockK8sClient.On("Get", mock.IsType(context.Background()), mock.Anything, mock.IsType(&karpenterv1.NodeClaim{}), mock.Anything).Return(NotFoundNodeClaimError())
 			p := createTestProvider(agentPoolMocks, mockK8sClient)
 
 			err := p.Delete(context.Background(), tc.apName)
@@ -344,6 +399,215 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestDeleteOperationInProgress(t *testing.T) {
+	agentPoolOperationPollInterval = time.Millisecond
+	conflictErr := &azcore.ResponseError{StatusCode: http.StatusConflict, ErrorCode: "OperationNotAllowed"}
+
+	testCases := []struct {
+		name              string
+		provisioningState []string
+		deleteAgain       bool
+		expectedError     error
+	}{
+		{
+			name:              "Successfully adopt in-progress deletion",
+			provisioningState: []string{"Deleting", "Deleting"},
+		},
+		{
+			name:              "Successfully delete instance after in-progress update completes",
+			provisioningState: []string{"Updating", "Succeeded"},
+			deleteAgain:       true,
+		},
+		{
+			name:              "Fail to delete instance because conflict is not caused by an in-progress operation",
+			provisioningState: []string{"Succeeded"},
+			expectedError:     conflictErr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+			beginDelete := agentPoolMocks.EXPECT().BeginDelete(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool0", gomock.Any()).Return(nil, conflictErr)
+			var getCalls []any
+			for _, state := range tc.provisioningState {
+				ap := GetAgentPoolObjWithName("agentpool0", "agentpool0", "Standard_NC6s_v3")
+				ap.Properties.ProvisioningState = to.Ptr(state)
+				getCalls = append(getCalls, agentPoolMocks.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool0", gomock.Any()).
+					Return(armcontainerservice.AgentPoolsClientGetResponse{AgentPool: ap}, nil))
+			}
+			if tc.deleteAgain {
+				agentPoolMocks.EXPECT().BeginDelete(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool0", gomock.Any()).Return(nil, NotFoundAzError()).After(beginDelete)
+			}
+			if !tc.deleteAgain && tc.expectedError == nil {
+				// the agent pool disappears after the in-progress deletion
+				getCalls = append(getCalls, agentPoolMocks.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool0", gomock.Any()).
+					Return(armcontainerservice.AgentPoolsClientGetResponse{}, NotFoundAzError()))
+			}
+			gomock.InOrder(getCalls...)
+
+			mockK8sClient := fake.NewClient()
+			mockK8sClient.On("Get", mock.IsType(context.Background()), mock.Anything, mock.IsType(&karpenterv1.NodeClaim{}), mock.Anything).Return(NotFoundNodeClaimError())
+			p := createTestProvider(agentPoolMocks, mockK8sClient)
+			err := p.Delete(context.Background(), "agentpool0")
+
+			if tc.expectedError == nil {
+				assert.NoError(t, err, "Not expected to return error")
+			} else {
+				assert.Contains(t, err.Error(), tc.expectedError.Error())
+			}
+		})
+	}
+}
+
+func TestDeleteOperationInProgressTimeout(t *testing.T) {
+	agentPoolOperationPollInterval = time.Millisecond
+	agentPoolOperationWaitTimeout = 50 * time.Millisecond
+	defer func() { agentPoolOperationWaitTimeout = 15 * time.Minute }()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+	agentPoolMocks.EXPECT().BeginDelete(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool0", gomock.Any()).
+		Return(nil, &azcore.ResponseError{StatusCode: http.StatusConflict, ErrorCode: "OperationNotAllowed"})
+	ap := GetAgentPoolObjWithName("agentpool0", "agentpool0", "Standard_NC6s_v3")
+	ap.Properties.ProvisioningState = to.Ptr("Updating")
+	agentPoolMocks.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool0", gomock.Any()).
+		Return(armcontainerservice.AgentPoolsClientGetResponse{AgentPool: ap}, nil).AnyTimes()
+
+	mockK8sClient := fake.NewClient()
+	mockK8sClient.On("Get", mock.IsType(context.Background()), mock.Anything, mock.IsType(&karpenterv1.NodeClaim{}), mock.Anything).Return(NotFoundNodeClaimError())
+	p := createTestProvider(agentPoolMocks, mockK8sClient)
+	err := p.Delete(context.Background(), "agentpool0")
+	assert.True(t, IsAgentPoolOperationInProgressError(err), "unexpected error %v", err)
+}
+
+func TestDeleteResumeToken(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+	// deletion LRO is resumed from the token recorded on nodeclaim instead of starting a new one
+	agentPoolMocks.EXPECT().BeginDelete(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool0", &armcontainerservice.AgentPoolsClientBeginDeleteOptions{ResumeToken: "token"}).
+		Return(nil, NotFoundAzError())
+
+	nodeClaim := fixture.NodeClaim().WithName("agentpool0").WithLabels(map[string]string{"test": "test"}).Build()
+	nodeClaim.Namespace = ""
+	nodeClaim.Annotations = map[string]string{DeleteResumeTokenAnnotation: "token"}
+	mockK8sClient := fake.NewClient()
+	mockK8sClient.CreateOrUpdateObjectInMap(nodeClaim)
+	mockK8sClient.On("Get", mock.IsType(context.Background()), mock.Anything, mock.IsType(&karpenterv1.NodeClaim{}), mock.Anything).Return(nil)
+
+	p := createTestProvider(agentPoolMocks, mockK8sClient)
+	assert.NoError(t, p.Delete(context.Background(), "agentpool0"))
+}
+
+func TestDeleteCoalesced(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+	mockHandler := fake.NewMockPollingHandler[armcontainerservice.AgentPoolsClientDeleteResponse](mockCtrl)
+	mockHandler.EXPECT().Done().Return(true).AnyTimes()
+	mockHandler.EXPECT().Result(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	delResp := armcontainerservice.AgentPoolsClientDeleteResponse{}
+	resp := http.Response{Status: "200 OK", StatusCode: http.StatusOK, Body: http.NoBody}
+	poller, err := runtime.NewPoller(&resp, runtime.NewPipeline("", "", runtime.PipelineOptions{}, nil), &runtime.NewPollerOptions[armcontainerservice.AgentPoolsClientDeleteResponse]{
+		Handler:  mockHandler,
+		Response: &delResp,
+	})
+	assert.NoError(t, err)
+
+	// the deletion LRO is blocked until all delete attempts are started, and only one LRO is expected.
+	release := make(chan struct{})
+	agentPoolMocks.EXPECT().BeginDelete(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool0", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _, _ string, _ *armcontainerservice.AgentPoolsClientBeginDeleteOptions) (*runtime.Poller[armcontainerservice.AgentPoolsClientDeleteResponse], error) {
+			<-release
+			return poller, nil
+		}).Times(1)
+
+	mockK8sClient := fake.NewClient()
+	mockK8sClient.On("Get", mock.IsType(context.Background()), mock.Anything, mock.IsType(&karpenterv1.NodeClaim{}), mock.Anything).Return(NotFoundNodeClaimError())
+	p := createTestProvider(agentPoolMocks, mockK8sClient)
+
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			errs <- p.Delete(context.Background(), "agentpool0")
+		}()
+	}
+	close(release)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, <-errs)
+	}
+
+	// delete attempt after the deletion is completed returns without calling ARM.
+	assert.NoError(t, p.Delete(context.Background(), "agentpool0"))
+}
+
+func TestOperationIDOf(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   http.Header
+		expected string
+	}{
+		{
+			name: "Return the id of the async operation",
+			header: http.Header{
+				"Azure-Asyncoperation": []string{"https://management.azure.com/subscriptions/sub/providers/Microsoft.ContainerService/locations/eastus/operations/op-123?api-version=2024-01-01"},
+				"X-Ms-Request-Id":      []string{"req-456"},
+			},
+			expected: "op-123",
+		},
+		{
+			name:     "Return the request id without async operation",
+			header:   http.Header{"X-Ms-Request-Id": []string{"req-456"}},
+			expected: "req-456",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, operationIDOf(&http.Response{Header: tc.header}))
+		})
+	}
+	assert.Empty(t, operationIDOf(nil))
+}
+
+func TestGetAgentPoolCoalesced(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// the GET is blocked until all reconciles are started, and only one GET is expected.
+	release := make(chan struct{})
+	ap := GetAgentPoolObjWithName("agentpool0", "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool0-20562481-vmss", "Standard_NC6s_v3")
+	agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+	agentPoolMocks.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool0", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _, _ string, _ *armcontainerservice.AgentPoolsClientGetOptions) (armcontainerservice.AgentPoolsClientGetResponse, error) {
+			<-release
+			return armcontainerservice.AgentPoolsClientGetResponse{AgentPool: ap}, nil
+		}).Times(1)
+	p := createTestProvider(agentPoolMocks, nil)
+
+	results := make(chan *armcontainerservice.AgentPool, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			apObj, err := p.getAgentPool(context.Background(), "agentpool0")
+			assert.NoError(t, err)
+			results <- apObj
+		}()
+	}
+	close(release)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "agentpool0", lo.FromPtr((<-results).Name))
+	}
+}
+
 func TestList(t *testing.T) {
 	testCases := []struct {
 		name              string
@@ -445,21 +709,81 @@ func TestList(t *testing.T) {
 	}
 }
 
-func TestFromAPListToInstanceFailure(t *testing.T) {
+func TestListPage(t *testing.T) {
+	agentPools := func() []*armcontainerservice.AgentPool {
+		ap0 := GetAgentPoolObjWithName("agentpool0", "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool0-20562481-vmss", "Standard_NC6s_v3")
+		ap1 := GetAgentPoolObjWithName("agentpool1", "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool1-20562481-vmss", "Standard_NC6s_v3")
+		ap2 := GetAgentPoolObjWithName("agentpool2", "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool2-20562481-vmss", "Standard_NC6s_v3")
+		return []*armcontainerservice.AgentPool{&ap0, &ap1, &ap2}
+	}
+
 	testCases := []struct {
 		name              string
-		id                string
-		mockAgentPoolList func(id string) []*armcontainerservice.AgentPool
-		expectedError     func(err string) error
+		mockAgentPoolList []*armcontainerservice.AgentPool
+		opts              ListOptions
+		expectedNames     []string
+		expectedContinue  string
+		expectedError     error
 	}{
 		{
-			name: "Fail to get instance from agent pool list because no agentpools are found",
-			mockAgentPoolList: func(id string) []*armcontainerservice.AgentPool {
-				return []*armcontainerservice.AgentPool{}
-			},
-			expectedError: func(err string) error {
-				return errors.New("nodeclaim not found, agentpools not found")
-			},
+			name:              "Successfully list the first page",
+			mockAgentPoolList: agentPools(),
+			opts:              ListOptions{PageSize: 2},
+			expectedNames:     []string{"agentpool0", "agentpool1"},
+			expectedContinue:  encodeContinueToken("agentpool1"),
+		},
+		{
+			name:              "Successfully list the last page with continuation token",
+			mockAgentPoolList: agentPools(),
+			opts:              ListOptions{PageSize: 2, Continue: encodeContinueToken("agentpool1")},
+			expectedNames:     []string{"agentpool2"},
+			expectedContinue:  "",
+		},
+		{
+			name:              "Successfully list all instances without page size",
+			mockAgentPoolList: agentPools(),
+			opts:              ListOptions{},
+			expectedNames:     []string{"agentpool0", "agentpool1", "agentpool2"},
+			expectedContinue:  "",
+		},
+		{
+			name: "Successfully list instances sorted by name regardless of agentpool order",
+			mockAgentPoolList: func() []*armcontainerservice.AgentPool {
+				aps := agentPools()
+				return []*armcontainerservice.AgentPool{aps[2], aps[0], aps[1]}
+			}(),
+			opts:             ListOptions{PageSize: 2},
+			expectedNames:    []string{"agentpool0", "agentpool1"},
+			expectedContinue: encodeContinueToken("agentpool1"),
+		},
+		{
+			name: "Successfully list the next page after the last listed agentpool is deleted",
+			mockAgentPoolList: func() []*armcontainerservice.AgentPool {
+				aps := agentPools()
+				return []*armcontainerservice.AgentPool{aps[0], aps[2]}
+			}(),
+			opts:             ListOptions{PageSize: 2, Continue: encodeContinueToken("agentpool1")},
+			expectedNames:    []string{"agentpool2"},
+			expectedContinue: "",
+		},
+		{
+			name:              "Successfully list the whole last page without continuation token",
+			mockAgentPoolList: agentPools(),
+			opts:              ListOptions{PageSize: 3},
+			expectedNames:     []string{"agentpool0", "agentpool1", "agentpool2"},
+			expectedContinue:  "",
+		},
+		{
+			name:              "Successfully list empty page because no agentpools are found",
+			mockAgentPoolList: []*armcontainerservice.AgentPool{},
+			opts:              ListOptions{PageSize: 2},
+			expectedNames:     []string{},
+			expectedContinue:  "",
+		},
+		{
+			name:          "Fail to list instances because of invalid continuation token",
+			opts:          ListOptions{PageSize: 2, Continue: "not a token"},
+			expectedError: errors.New("invalid continuation token \"not a token\""),
 		},
 	}
 
@@ -469,18 +793,92 @@ func TestFromAPListToInstanceFailure(t *testing.T) {
 			defer mockCtrl.Finish()
 
 			agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+			if tc.mockAgentPoolList != nil {
+				agentPoolMocks.EXPECT().NewListPager(gomock.Any(), gomock.Any(), gomock.Any()).Return(runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
+					More: func(page armcontainerservice.AgentPoolsClientListResponse) bool {
+						return false
+					},
+					Fetcher: func(ctx context.Context, page *armcontainerservice.AgentPoolsClientListResponse) (armcontainerservice.AgentPoolsClientListResponse, error) {
+						return armcontainerservice.AgentPoolsClientListResponse{
+							AgentPoolListResult: armcontainerservice.AgentPoolListResult{
+								Value: tc.mockAgentPoolList,
+							},
+						}, nil
+					},
+				}))
+			}
+
 			mockK8sClient := fake.NewClient()
+			mockK8sClient.On("List", mock.IsType(context.Background()), mock.IsType(&v1.NodeList{}), mock.Anything).Return(nil)
 
 			p := createTestProvider(agentPoolMocks, mockK8sClient)
 
-			instanceList, err := p.fromAPListToInstances(context.Background(), tc.mockAgentPoolList(tc.id))
+			instanceList, next, err := p.ListPage(context.Background(), tc.opts)
 
-			assert.EqualError(t, err, tc.expectedError(tc.id).Error())
-			assert.Empty(t, instanceList, "Response instance list should be empty")
+			if tc.expectedError != nil {
+				assert.EqualError(t, err, tc.expectedError.Error())
+				return
+			}
+			assert.NoError(t, err, "Not expected to return error")
+			assert.Equal(t, tc.expectedNames, lo.Map(instanceList, func(ins *Instance, _ int) string {
+				return lo.FromPtr(ins.Name)
+			}))
+			assert.Equal(t, tc.expectedContinue, next, "Continuation token is not expected")
+			assert.ElementsMatch(t, tc.expectedNames, lo.Keys(p.agentPoolCache.Items()), "only agentpools of the page are cached")
 		})
 	}
 }
 
+func TestWarmUp(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ap := GetAgentPoolObjWithName("agentpool0", "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool0-20562481-vmss", "Standard_NC6s_v3")
+	agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+	agentPoolMocks.EXPECT().NewListPager(gomock.Any(), gomock.Any(), gomock.Any()).Return(runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
+		More: func(page armcontainerservice.AgentPoolsClientListResponse) bool {
+			return false
+		},
+		Fetcher: func(ctx context.Context, page *armcontainerservice.AgentPoolsClientListResponse) (armcontainerservice.AgentPoolsClientListResponse, error) {
+			return armcontainerservice.AgentPoolsClientListResponse{
+				AgentPoolListResult: armcontainerservice.AgentPoolListResult{
+					Value: []*armcontainerservice.AgentPool{&ap},
+				},
+			}, nil
+		},
+	}))
+	// agentPool.Get should not be called because the agent pool is served from the warm cache
+	agentPoolMocks.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	mockK8sClient := fake.NewClient()
+	mockK8sClient.On("List", mock.IsType(context.Background()), mock.IsType(&v1.NodeList{}), mock.Anything).Return(nil)
+
+	p := createTestProvider(agentPoolMocks, mockK8sClient)
+	assert.NoError(t, p.WarmUp(context.Background()), "Not expected to return error")
+
+	instance, err := p.Get(context.Background(), "azure:///subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool0-20562481-vmss/virtualMachines/0")
+	assert.NoError(t, err, "Not expected to return error")
+	assert.Equal(t, ap.Name, instance.Name, "Instance name should be same as the agent pool")
+}
+
+func TestInvalidateCache(t *testing.T) {
+	p := createTestProvider(nil, fake.NewClient())
+	caches := map[string]*cache.Cache{
+		"agentpool":     p.agentPoolCache,
+		"retail price":  p.retailPriceCache,
+		"cluster state": p.clusterStateCache,
+		"sku":           p.skuCache,
+	}
+	for name, c := range caches {
+		c.SetDefault("key", name)
+	}
+
+	p.InvalidateCache()
+	for name, c := range caches {
+		assert.Zero(t, c.ItemCount(), "%s cache is not flushed", name)
+	}
+}
+
 func TestCreateSuccess(t *testing.T) {
 	testCases := []struct {
 		name              string
@@ -490,17 +888,8 @@ func TestCreateSuccess(t *testing.T) {
 	}{
 		{
 			name: "Successfully create instance",
-			nodeClaim: fake.GetNodeClaimObj("agentpool0", map[string]string{"test": "test"}, []v1.Taint{},
-				karpenterv1.ResourceRequirements{Requests: v1.ResourceList{
-					v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(30*1024*1024*1024, resource.DecimalSI)),
-				}},
-				[]v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
+			nodeClaim: fixture.NodeClaim().WithName("agentpool0").WithLabels(map[string]string{"test": "test"}).
+				WithStorage(30).WithInstanceType("Standard_NC6s_v3").Build(),
 			mockAgentPoolResp: func(nodeClaim *karpenterv1.NodeClaim, mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error) {
 				ap := GetAgentPoolObjWithName(nodeClaim.Name, "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool0-20562481-vmss", nodeClaim.Spec.Requirements[0].Values[0])
 
@@ -536,17 +925,8 @@ func TestCreateSuccess(t *testing.T) {
 		},
 		{
 			name: "Successfully create instance after waiting for node to be ready",
-			nodeClaim: fake.GetNodeClaimObj("agentpool0", map[string]string{"test": "test"}, []v1.Taint{},
-				karpenterv1.ResourceRequirements{Requests: v1.ResourceList{
-					v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(30*1024*1024*1024, resource.DecimalSI)),
-				}},
-				[]v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
+			nodeClaim: fixture.NodeClaim().WithName("agentpool0").WithLabels(map[string]string{"test": "test"}).
+				WithStorage(30).WithInstanceType("Standard_NC6s_v3").Build(),
 			mockAgentPoolResp: func(nodeClaim *karpenterv1.NodeClaim, mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error) {
 				ap := GetAgentPoolObjWithName(nodeClaim.Name, "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool0-20562481-vmss", nodeClaim.Spec.Requirements[0].Values[0])
 
@@ -624,17 +1004,8 @@ func TestCreateFailure(t *testing.T) {
 	}{
 		{
 			name: "Fail to create instance because node is not found and returns error on retry",
-			nodeClaim: fake.GetNodeClaimObj("agentpool0", map[string]string{"test": "test"}, []v1.Taint{},
-				karpenterv1.ResourceRequirements{Requests: v1.ResourceList{
-					v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(30*1024*1024*1024, resource.DecimalSI)),
-				}},
-				[]v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
+			nodeClaim: fixture.NodeClaim().WithName("agentpool0").WithLabels(map[string]string{"test": "test"}).
+				WithStorage(30).WithInstanceType("Standard_NC6s_v3").Build(),
 			mockAgentPoolResp: func(nodeClaim *karpenterv1.NodeClaim, mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error) {
 				ap := GetAgentPoolObjWithName(nodeClaim.Name, "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool0-20562481-vmss", nodeClaim.Spec.Requirements[0].Values[0])
 
@@ -663,17 +1034,8 @@ func TestCreateFailure(t *testing.T) {
 		},
 		{
 			name: "Fail to create instance because node object is not found",
-			nodeClaim: fake.GetNodeClaimObj("agentpool0", map[string]string{"test": "test"}, []v1.Taint{},
-				karpenterv1.ResourceRequirements{Requests: v1.ResourceList{
-					v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(30*1024*1024*1024, resource.DecimalSI)),
-				}},
-				[]v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
+			nodeClaim: fixture.NodeClaim().WithName("agentpool0").WithLabels(map[string]string{"test": "test"}).
+				WithStorage(30).WithInstanceType("Standard_NC6s_v3").Build(),
 			mockAgentPoolResp: func(nodeClaim *karpenterv1.NodeClaim, mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error) {
 				ap := GetAgentPoolObjWithName(nodeClaim.Name, "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool0-20562481-vmss", nodeClaim.Spec.Requirements[0].Values[0])
 
@@ -700,17 +1062,8 @@ func TestCreateFailure(t *testing.T) {
 		},
 		{
 			name: "Fail to delete instance because poller returns error",
-			nodeClaim: fake.GetNodeClaimObj("agentpool0", map[string]string{"test": "test"}, []v1.Taint{},
-				karpenterv1.ResourceRequirements{Requests: v1.ResourceList{
-					v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(30*1024*1024*1024, resource.DecimalSI)),
-				}},
-				[]v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_NC6s_v3"},
-					},
-				}),
+			nodeClaim: fixture.NodeClaim().WithName("agentpool0").WithLabels(map[string]string{"test": "test"}).
+				WithStorage(30).WithInstanceType("Standard_NC6s_v3").Build(),
 			mockAgentPoolResp: func(nodeClaim *karpenterv1.NodeClaim, mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error) {
 				createResp := armcontainerservice.AgentPoolsClientCreateOrUpdateResponse{
 					AgentPool: armcontainerservice.AgentPool{},
@@ -732,17 +1085,8 @@ func TestCreateFailure(t *testing.T) {
 		},
 		{
 			name: "Fail to create instance because agentPool.CreateOrUpdate returns a failure",
-			nodeClaim: fake.GetNodeClaimObj("agentpool0", map[string]string{"test": "test"}, []v1.Taint{},
-				karpenterv1.ResourceRequirements{Requests: v1.ResourceList{
-					v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(30*1024*1024*1024, resource.DecimalSI)),
-				}},
-				[]v1.NodeSelectorRequirement{
-					{
-						Key:      "node.kubernetes.io/instance-type",
-						Operator: "In",
-						Values:   []string{"Standard_D4s_v4"},
-					},
-				}),
+			nodeClaim: fixture.NodeClaim().WithName("agentpool0").WithLabels(map[string]string{"test": "test"}).
+				WithStorage(30).WithInstanceType("Standard_D4s_v4").Build(),
 			mockAgentPoolResp: func(nodeClaim *karpenterv1.NodeClaim, mockHandler *fake.MockPollingHandler[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse]) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error) {
 				return nil, errors.New("Failed to create agent pool")
 			},
@@ -750,31 +1094,19 @@ func TestCreateFailure(t *testing.T) {
 		},
 		{
 			name: "Fail to create instance because nodeClaim spec does not have requirement for instance type",
-			nodeClaim: fake.GetNodeClaimObj("agentpool000", map[string]string{"test": "test"}, []v1.Taint{},
-				karpenterv1.ResourceRequirements{Requests: v1.ResourceList{
-					v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(30*1024*1024*1024, resource.DecimalSI)),
-				}},
-				[]v1.NodeSelectorRequirement{}),
+			nodeClaim: fixture.NodeClaim().WithName("agentpool000").WithLabels(map[string]string{"test": "test"}).
+				WithStorage(30).Build(),
 			expectedError: errors.New("nodeClaim spec has no requirement for instance type"),
 		},
 		{
 			name: "Fail to create instance because of invalid nodeClaim name",
-			nodeClaim: fake.GetNodeClaimObj("invalid-name", map[string]string{"test": "test"}, []v1.Taint{},
-				karpenterv1.ResourceRequirements{Requests: v1.ResourceList{
-					v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(30*1024*1024*1024, resource.DecimalSI)),
-				}},
-				[]v1.NodeSelectorRequirement{}),
+			nodeClaim: fixture.NodeClaim().WithName("invalid-name").WithLabels(map[string]string{"test": "test"}).
+				WithStorage(30).Build(),
 			expectedError: errors.New("is invalid, must match regex pattern: ^[a-z][a-z0-9]{0,11}$"),
 		},
 		{
-			name: "Fail to create instance because of no storage request",
-			nodeClaim: fake.GetNodeClaimObj("agentpool000", map[string]string{"test": "test"}, []v1.Taint{}, karpenterv1.ResourceRequirements{}, []v1.NodeSelectorRequirement{
-				{
-					Key:      "node.kubernetes.io/instance-type",
-					Operator: "In",
-					Values:   []string{"Standard_D4s_v4"},
-				},
-			}),
+			name:          "Fail to create instance because of no storage request",
+			nodeClaim:     fixture.NodeClaim().WithName("agentpool000").WithLabels(map[string]string{"test": "test"}).WithInstanceType("Standard_D4s_v4").Build(),
 			expectedError: errors.New("storage request of nodeclaim(agentpool000) should be more than 0"),
 		},
 	}
@@ -874,3 +1206,7 @@ var (
 func NotFoundAzError() *azcore.ResponseError {
 	return &azcore.ResponseError{ErrorCode: "NotFound"}
 }
+
+func NotFoundNodeClaimError() error {
+	return apierrors.NewNotFound(schema.GroupResource{Group: "karpenter.sh", Resource: "nodeclaims"}, "")
+}
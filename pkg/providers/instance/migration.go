@@ -0,0 +1,83 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"k8s.io/klog/v2"
+)
+
+// InstanceNeedsMigration returns true when the instance is created by older releases
+// and misses the metadata(creation timestamp label or ownership tags) that newer releases rely on.
+func InstanceNeedsMigration(ins *Instance) bool {
+	if ins == nil {
+		return false
+	}
+	if _, ok := ins.Labels[NodeClaimCreationLabel]; !ok {
+		return true
+	}
+	return ins.Tags[ManagedByTagKey] == nil
+}
+
+// BackfillOwnershipMetadata adds the creation timestamp label and ownership tags to the agent pool
+// if they are missing. creationTime is only used when the creation timestamp label is missing.
+func (p *Provider) BackfillOwnershipMetadata(ctx context.Context, apName string, creationTime time.Time) error {
+	klog.InfoS("Instance.BackfillOwnershipMetadata", "agentpool name", apName)
+
+	apObj, err := getAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, p.clusterName, apName)
+	if err != nil {
+		return fmt.Errorf("agentPool.Get for %q failed: %w", apName, err)
+	}
+	if !backfillAgentPoolMetadata(apObj, creationTime) {
+		return nil
+	}
+
+	defer p.agentPoolCache.Delete(apName)
+	if _, _, err := createAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, apName, p.clusterName, *apObj); err != nil {
+		return fmt.Errorf("agentPool.BeginCreateOrUpdate for %q failed: %w", apName, err)
+	}
+	return nil
+}
+
+// backfillAgentPoolMetadata fills the missing metadata into apObj, and returns true if apObj is changed.
+func backfillAgentPoolMetadata(apObj *armcontainerservice.AgentPool, creationTime time.Time) bool {
+	if apObj == nil || apObj.Properties == nil {
+		return false
+	}
+
+	changed := false
+	if apObj.Properties.NodeLabels == nil {
+		apObj.Properties.NodeLabels = map[string]*string{}
+	}
+	if _, ok := apObj.Properties.NodeLabels[NodeClaimCreationLabel]; !ok {
+		apObj.Properties.NodeLabels[NodeClaimCreationLabel] = to.Ptr(creationTime.UTC().Format(CreationTimestampLayout))
+		changed = true
+	}
+
+	if apObj.Properties.Tags == nil {
+		apObj.Properties.Tags = map[string]*string{}
+	}
+	if apObj.Properties.Tags[ManagedByTagKey] == nil {
+		apObj.Properties.Tags[ManagedByTagKey] = to.Ptr(ManagedByTagValue)
+		changed = true
+	}
+	return changed
+}
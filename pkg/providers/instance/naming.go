@@ -0,0 +1,209 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/karpenter/pkg/apis"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+const (
+	// NamingLegacy names agent pools after their nodeclaims, it's the default.
+	NamingLegacy = "legacy"
+	// NamingHash names agent pools with the prefix and a hash of the nodeclaim name.
+	NamingHash = "hash"
+	// NamingCounter names agent pools with the prefix and the lowest number not used by another agent pool.
+	NamingCounter = "counter"
+
+	// AgentPoolNameAnnotationKey binds a nodeclaim to its agent pool when the agent pool is not named after the
+	// nodeclaim. nodeclaims without it are bound to the agent pool of the same name.
+	AgentPoolNameAnnotationKey = "kaito.sh/agentpool-name"
+	// NodeClaimTagKey records the nodeclaim name on agent pools which are not named after their nodeclaims, so
+	// leaked agent pools are still matched with nodeclaims by name for garbage collection.
+	NodeClaimTagKey = "kaito.sh_nodeclaim"
+
+	// reservedNameTTL is how long a counter name is reserved after it's chosen, so concurrent creations never
+	// choose the same name before the agent pool shows up in the agent pool list.
+	reservedNameTTL = 10 * time.Minute
+)
+
+var agentPoolNamePrefixRegex = regexp.MustCompile(`^[a-z][a-z0-9]{0,7}$`)
+
+// NamingStrategy generates the name of the agent pool of a nodeclaim, the name must match AgentPoolNameRegex.
+type NamingStrategy interface {
+	AgentPoolName(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (string, error)
+}
+
+// newNamingStrategy returns the naming strategy of the kind, prefix is only used by the hash and counter strategies
+// and must leave at least 4 characters of the 12 allowed for the hash or counter.
+func newNamingStrategy(kind, prefix string, p *Provider) (NamingStrategy, error) {
+	if kind != NamingLegacy && !agentPoolNamePrefixRegex.MatchString(prefix) {
+		return nil, fmt.Errorf("agent pool name prefix %q must match regex pattern %s", prefix, agentPoolNamePrefixRegex)
+	}
+	switch kind {
+	case NamingLegacy:
+		return legacyNaming{}, nil
+	case NamingHash:
+		return hashNaming{prefix: prefix}, nil
+	case NamingCounter:
+		return &counterNaming{prefix: prefix, provider: p, reserved: cache.New(reservedNameTTL, time.Minute)}, nil
+	}
+	return nil, fmt.Errorf("unknown agent pool naming %q, expected %q, %q or %q", kind, NamingLegacy, NamingHash, NamingCounter)
+}
+
+// legacyNaming names the agent pool after the nodeclaim, the nodeclaim name must be a valid agent pool name.
+type legacyNaming struct{}
+
+func (legacyNaming) AgentPoolName(_ context.Context, nodeClaim *karpenterv1.NodeClaim) (string, error) {
+	return nodeClaim.Name, nil
+}
+
+// hashNaming derives the agent pool name from the nodeclaim name, so nodeclaim names which are not valid agent pool
+// names(e.g. with "-") are supported and retries always choose the same name.
+type hashNaming struct {
+	prefix string
+}
+
+func (n hashNaming) AgentPoolName(_ context.Context, nodeClaim *karpenterv1.NodeClaim) (string, error) {
+	sum := sha256.Sum256([]byte(nodeClaim.Name))
+	return n.prefix + hex.EncodeToString(sum[:])[:12-len(n.prefix)], nil
+}
+
+// counterNaming names agent pools with the prefix and the lowest free number, e.g. gpu0, gpu1.
+type counterNaming struct {
+	prefix   string
+	provider *Provider
+
+	mu sync.Mutex
+	// reserved are the names chosen recently, their agent pools may not be listed yet.
+	reserved *cache.Cache
+}
+
+func (n *counterNaming) AgentPoolName(ctx context.Context, _ *karpenterv1.NodeClaim) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	used := sets.New(lo.Keys(n.reserved.Items())...)
+	if err := n.provider.forEachAgentPool(ctx, false, func(ap *armcontainerservice.AgentPool) (bool, error) {
+		used.Insert(lo.FromPtr(ap.Name))
+		return true, nil
+	}); err != nil {
+		return "", fmt.Errorf("listing agent pool names, %w", err)
+	}
+	// agent pools bound to nodeclaims may not be created yet.
+	nodeClaims := &karpenterv1.NodeClaimList{}
+	if err := n.provider.kubeClient.List(ctx, nodeClaims); err != nil {
+		return "", fmt.Errorf("listing nodeclaims, %w", err)
+	}
+	for i := range nodeClaims.Items {
+		used.Insert(AgentPoolName(&nodeClaims.Items[i]))
+	}
+
+	for i := 0; len(n.prefix)+len(strconv.Itoa(i)) <= 12; i++ {
+		if name := n.prefix + strconv.Itoa(i); !used.Has(name) {
+			n.reserved.SetDefault(name, struct{}{})
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no free agent pool name with prefix %q", n.prefix)
+}
+
+// AgentPoolName returns the name of the agent pool the nodeclaim is bound to.
+func AgentPoolName(nodeClaim *karpenterv1.NodeClaim) string {
+	if name, ok := nodeClaim.Annotations[AgentPoolNameAnnotationKey]; ok && name != "" {
+		return name
+	}
+	return nodeClaim.Name
+}
+
+// agentPoolName returns the agent pool name of the nodeclaim, a name generated by the naming strategy is bound to
+// the nodeclaim by annotation before the agent pool is created, so retries and restarts keep using it.
+func (p *Provider) agentPoolName(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (string, error) {
+	if _, ok := nodeClaim.Annotations[AgentPoolNameAnnotationKey]; ok {
+		return AgentPoolName(nodeClaim), nil
+	}
+	apName, err := p.naming.AgentPoolName(ctx, nodeClaim)
+	if err != nil || apName == nodeClaim.Name {
+		return apName, err
+	}
+	stored := nodeClaim.DeepCopy()
+	nodeClaim.Annotations = lo.Assign(nodeClaim.Annotations, map[string]string{AgentPoolNameAnnotationKey: apName})
+	if err := p.kubeClient.Patch(ctx, nodeClaim, client.MergeFrom(stored)); err != nil {
+		nodeClaim.Annotations = stored.Annotations
+		return "", fmt.Errorf("binding nodeclaim to agent pool %q, %w", apName, err)
+	}
+	return apName, nil
+}
+
+// nodeClaimOfAgentPool returns the nodeclaim bound to the agent pool, or a NotFound error. agent pools which are not
+// named after their nodeclaims are resolved by the NodeClaimTagKey tag of ap, or of the cached agent pool if ap is
+// nil, it's best effort as the agent pool may be neither fetched nor cached.
+func (p *Provider) nodeClaimOfAgentPool(ctx context.Context, apName string, ap *armcontainerservice.AgentPool) (*karpenterv1.NodeClaim, error) {
+	if ap == nil {
+		if cached, ok := p.agentPoolCache.Get(apName); ok {
+			ap = cached.(*armcontainerservice.AgentPool)
+		}
+	}
+	name := apName
+	if ap != nil && ap.Properties != nil {
+		name = nodeClaimNameOf(apName, ap.Properties.Tags)
+	}
+	nodeClaim := &karpenterv1.NodeClaim{}
+	if err := p.kubeClient.Get(ctx, client.ObjectKey{Name: name}, nodeClaim); err != nil {
+		return nil, err
+	}
+	if AgentPoolName(nodeClaim) != apName {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: apis.Group, Resource: "nodeclaims"}, name)
+	}
+	return nodeClaim, nil
+}
+
+// NodeClaimName returns the name of the nodeclaim the instance was created for, which is recorded by the
+// NodeClaimTagKey tag if the agent pool is not named after the nodeclaim.
+func NodeClaimName(ins *Instance) string {
+	return nodeClaimNameOf(lo.FromPtr(ins.Name), ins.Tags)
+}
+
+func nodeClaimNameOf(apName string, tags map[string]*string) string {
+	if name := lo.FromPtr(tags[NodeClaimTagKey]); name != "" {
+		return name
+	}
+	return apName
+}
+
+// tagNodeClaim records the nodeclaim name on an agent pool which is not named after it.
+func tagNodeClaim(ap *armcontainerservice.AgentPool, nodeClaim *karpenterv1.NodeClaim, apName string) {
+	if apName != nodeClaim.Name {
+		ap.Properties.Tags[NodeClaimTagKey] = to.Ptr(nodeClaim.Name)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func TestNewNamingStrategy(t *testing.T) {
+	testcases := map[string]struct {
+		kind          string
+		prefix        string
+		expectedError bool
+	}{
+		"legacy ignores the prefix": {
+			kind:   NamingLegacy,
+			prefix: "Invalid-Prefix",
+		},
+		"hash": {
+			kind:   NamingHash,
+			prefix: "gpu",
+		},
+		"counter": {
+			kind:   NamingCounter,
+			prefix: "gpu",
+		},
+		"longest prefix": {
+			kind:   NamingHash,
+			prefix: "kaitogpu",
+		},
+		"prefix too long": {
+			kind:          NamingHash,
+			prefix:        "gpuprefix",
+			expectedError: true,
+		},
+		"prefix starts with a digit": {
+			kind:          NamingCounter,
+			prefix:        "0gpu",
+			expectedError: true,
+		},
+		"unknown naming": {
+			kind:          "random",
+			prefix:        "gpu",
+			expectedError: true,
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			_, err := newNamingStrategy(tc.kind, tc.prefix, &Provider{})
+			assert.Equal(t, tc.expectedError, err != nil, "unexpected error %v", err)
+		})
+	}
+}
+
+func TestHashNaming(t *testing.T) {
+	testcases := map[string]struct {
+		prefix         string
+		nodeClaimNames []string
+	}{
+		"nodeclaim names which are not valid agent pool names": {
+			prefix:         "gpu",
+			nodeClaimNames: []string{"workspace-falcon-7b", "workspace-falcon-40b", "Workspace-Falcon-7b", "ragengine.falcon-7b"},
+		},
+		"nodeclaim names differing in one character": {
+			prefix:         "gpu",
+			nodeClaimNames: []string{"ws0", "ws1", "ws2", "ws-1", "ws-10", "ws-01", "wsa", "wsb"},
+		},
+		"nodeclaim names differing in their suffix": {
+			prefix:         "g",
+			nodeClaimNames: []string{"workspace-phi-3-mini-128k-instruct", "workspace-phi-3-mini-4k-instruct", "workspace-phi-3-mini-128k-instructx"},
+		},
+		"longest prefix": {
+			prefix:         "kaitogpu",
+			nodeClaimNames: []string{"workspace-falcon-7b", "workspace-falcon-7c", "workspace-falcon-8b"},
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			names := map[string]string{}
+			for _, nodeClaimName := range tc.nodeClaimNames {
+				name, err := hashNaming{prefix: tc.prefix}.AgentPoolName(context.Background(), fixture.NodeClaim().WithName(nodeClaimName).Build())
+				assert.NoError(t, err)
+				assert.Regexp(t, AgentPoolNameRegex, name)
+				assert.Len(t, name, 12)
+				assert.True(t, strings.HasPrefix(name, tc.prefix), "%s has no prefix %s", name, tc.prefix)
+
+				again, err := hashNaming{prefix: tc.prefix}.AgentPoolName(context.Background(), fixture.NodeClaim().WithName(nodeClaimName).Build())
+				assert.NoError(t, err)
+				assert.Equal(t, name, again, "retries choose the same name")
+
+				assert.NotContains(t, names, name, "%s and %s collide", nodeClaimName, names[name])
+				names[name] = nodeClaimName
+			}
+		})
+	}
+}
+
+func TestAgentPoolNameRegex(t *testing.T) {
+	testcases := map[string]struct {
+		name  string
+		valid bool
+	}{
+		"one letter":                    {name: "g", valid: true},
+		"letters and digits":            {name: "gpu0", valid: true},
+		"12 characters":                 {name: "gpu012345678", valid: true},
+		"13 characters":                 {name: "gpu0123456789"},
+		"empty":                         {name: ""},
+		"starts with a digit":           {name: "0gpu"},
+		"upper case":                    {name: "Gpu0"},
+		"hyphen":                        {name: "gpu-0"},
+		"nodeclaim name of a workspace": {name: "workspace-falcon-7b"},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, tc.valid, AgentPoolNameRegex.MatchString(tc.name))
+		})
+	}
+}
+
+func TestCounterNaming(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	agentPools := []*armcontainerservice.AgentPool{{Name: to.Ptr("nodepool1")}, {Name: to.Ptr("gpu0")}, {Name: to.Ptr("gpu2")}}
+	agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+	agentPoolMocks.EXPECT().NewListPager(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_, _ string, _ *armcontainerservice.AgentPoolsClientListOptions) *runtime.Pager[armcontainerservice.AgentPoolsClientListResponse] {
+		return runtime.NewPager(runtime.PagingHandler[armcontainerservice.AgentPoolsClientListResponse]{
+			More: func(page armcontainerservice.AgentPoolsClientListResponse) bool {
+				return false
+			},
+			Fetcher: func(ctx context.Context, page *armcontainerservice.AgentPoolsClientListResponse) (armcontainerservice.AgentPoolsClientListResponse, error) {
+				return armcontainerservice.AgentPoolsClientListResponse{
+					AgentPoolListResult: armcontainerservice.AgentPoolListResult{Value: agentPools},
+				}, nil
+			},
+		})
+	}).Times(2)
+	// the agent pool of a bound nodeclaim may not be created yet
+	bound := fixture.NodeClaim().WithName("ws1").WithAnnotations(map[string]string{AgentPoolNameAnnotationKey: "gpu1"}).Build()
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(bound).Build()
+	p := NewProvider(NewAZClientFromAPI(agentPoolMocks), kubeClient, "testRG", "testCluster")
+	naming, err := newNamingStrategy(NamingCounter, "gpu", p)
+	assert.NoError(t, err)
+
+	name, err := naming.AgentPoolName(context.Background(), fixture.NodeClaim().WithName("ws2").Build())
+	assert.NoError(t, err)
+	assert.Equal(t, "gpu3", name)
+	name, err = naming.AgentPoolName(context.Background(), fixture.NodeClaim().WithName("ws3").Build())
+	assert.NoError(t, err)
+	assert.Equal(t, "gpu4", name, "names chosen recently are reserved")
+}
+
+func TestAgentPoolNameBinding(t *testing.T) {
+	nodeClaim := fixture.NodeClaim().WithName("workspace-falcon-7b").WithoutProviderID().Build()
+	nodeClaim.Namespace = ""
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(nodeClaim).Build()
+	p := &Provider{kubeClient: kubeClient, naming: hashNaming{prefix: "gpu"}, agentPoolCache: cache.New(time.Minute, time.Minute)}
+
+	apName, err := p.agentPoolName(context.Background(), nodeClaim)
+	assert.NoError(t, err)
+	assert.Equal(t, apName, AgentPoolName(nodeClaim))
+	stored := &karpenterv1.NodeClaim{}
+	assert.NoError(t, kubeClient.Get(context.Background(), client.ObjectKeyFromObject(nodeClaim), stored))
+	assert.Equal(t, apName, stored.Annotations[AgentPoolNameAnnotationKey], "the name is bound before the agent pool is created")
+
+	// a bound name is kept even if the naming changes
+	p.naming = legacyNaming{}
+	again, err := p.agentPoolName(context.Background(), stored)
+	assert.NoError(t, err)
+	assert.Equal(t, apName, again)
+
+	_, err = p.nodeClaimOfAgentPool(context.Background(), apName, nil)
+	assert.True(t, apierrors.IsNotFound(err), "the nodeclaim is unknown until the agent pool is fetched or cached")
+	ap := fixture.AgentPool().WithName(apName).WithTags(map[string]string{NodeClaimTagKey: nodeClaim.Name}).Build()
+	found, err := p.nodeClaimOfAgentPool(context.Background(), apName, &ap)
+	assert.NoError(t, err)
+	assert.Equal(t, nodeClaim.Name, found.Name)
+	p.agentPoolCache.SetDefault(apName, &ap)
+	found, err = p.nodeClaimOfAgentPool(context.Background(), apName, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, nodeClaim.Name, found.Name)
+
+	legacy := fixture.NodeClaim().WithName("ws1").Build()
+	apName, err = p.agentPoolName(context.Background(), legacy)
+	assert.NoError(t, err)
+	assert.Equal(t, "ws1", apName)
+	assert.Empty(t, legacy.Annotations, "legacy names are not bound by annotation")
+}
+
+func TestTagNodeClaim(t *testing.T) {
+	ap := fixture.AgentPool().WithName("gpu0").WithTags(map[string]string{}).Build()
+	tagNodeClaim(&ap, fixture.NodeClaim().WithName("ws1").Build(), "gpu0")
+	assert.Equal(t, "ws1", *ap.Properties.Tags[NodeClaimTagKey])
+
+	legacy := fixture.AgentPool().WithName("ws1").WithTags(map[string]string{}).Build()
+	tagNodeClaim(&legacy, fixture.NodeClaim().WithName("ws1").Build(), "ws1")
+	assert.NotContains(t, legacy.Properties.Tags, NodeClaimTagKey)
+}
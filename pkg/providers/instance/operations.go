@@ -0,0 +1,119 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// OperationIndexConfigMapName is the name of the ConfigMap(or Secret, see CHECKPOINT_STORE) in the namespace of
+	// gpu-provisioner the operation index is persisted to, so it survives restarts.
+	OperationIndexConfigMapName = "gpu-provisioner-operation-index"
+	// OperationIndexKey is the key of the operation records in the data of the ConfigMap.
+	OperationIndexKey = "operations.json"
+
+	// maxOperationsPerNodeClaim bounds the records of a nodeclaim, older records are dropped first.
+	maxOperationsPerNodeClaim = 10
+)
+
+// OperationRecord is an ARM operation started on the agent pool of a nodeclaim, support engineers look operations up
+// by its id in ARM logs.
+type OperationRecord struct {
+	NodeClaim string `json:"nodeClaim"`
+	// Operation is the kind of the operation, e.g. create or resume.
+	Operation   string    `json:"operation"`
+	OperationID string    `json:"operationID"`
+	Timestamp   time.Time `json:"timestamp"`
+	// Error is the error the operation failed with, empty if it succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// recordOperation adds the operation to the index of the nodeclaim, and persists the index if it's enabled.
+// operations without an id never reached ARM, so they are not recorded.
+func (p *Provider) recordOperation(ctx context.Context, nodeClaim, operation, operationID string, err error) {
+	if operationID == "" {
+		return
+	}
+	record := OperationRecord{NodeClaim: nodeClaim, Operation: operation, OperationID: operationID, Timestamp: time.Now().UTC()}
+	if err != nil {
+		record.Error = redactURLs(err.Error())
+	}
+	p.operationsMu.Lock()
+	records := []OperationRecord{}
+	if cached, ok := p.operations.Get(nodeClaim); ok {
+		records = cached.([]OperationRecord)
+	}
+	records = append(records, record)
+	p.operations.SetDefault(nodeClaim, records[max(len(records)-maxOperationsPerNodeClaim, 0):])
+	p.operationsMu.Unlock()
+
+	if p.checkpoints != nil {
+		if err := p.persistOperations(ctx); err != nil {
+			klog.ErrorS(err, "failed to persist operation index", "nodeClaim", nodeClaim)
+		}
+	}
+}
+
+// Operations returns the recorded operations of all nodeclaims, oldest first.
+func (p *Provider) Operations() []OperationRecord {
+	p.operationsMu.Lock()
+	defer p.operationsMu.Unlock()
+	records := lo.Flatten(lo.MapToSlice(p.operations.Items(), func(_ string, item cache.Item) []OperationRecord {
+		return item.Object.([]OperationRecord)
+	}))
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Timestamp.Before(records[j].Timestamp) })
+	return records
+}
+
+func (p *Provider) persistOperations(ctx context.Context) error {
+	data, err := json.Marshal(p.Operations())
+	if err != nil {
+		return err
+	}
+	return p.checkpoints.Save(ctx, OperationIndexConfigMapName, map[string]string{OperationIndexKey: string(data)})
+}
+
+// LoadOperations restores the persisted operation index, records older than the retention are dropped. it's a no-op
+// if persisting the index is disabled.
+func (p *Provider) LoadOperations(ctx context.Context) error {
+	if p.checkpoints == nil {
+		return nil
+	}
+	data, err := p.checkpoints.Load(ctx, OperationIndexConfigMapName)
+	if err != nil || data == nil {
+		return err
+	}
+	var records []OperationRecord
+	if err := json.Unmarshal([]byte(data[OperationIndexKey]), &records); err != nil {
+		return err
+	}
+	p.operationsMu.Lock()
+	defer p.operationsMu.Unlock()
+	for nodeClaim, nodeClaimRecords := range lo.GroupBy(records, func(r OperationRecord) string { return r.NodeClaim }) {
+		if expiration := nodeClaimRecords[len(nodeClaimRecords)-1].Timestamp.Add(p.operationRetention); time.Now().Before(expiration) {
+			p.operations.Set(nodeClaim, nodeClaimRecords, time.Until(expiration))
+		}
+	}
+	return nil
+}
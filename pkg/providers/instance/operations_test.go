@@ -0,0 +1,80 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newOperationIndexProvider(namespace string) *Provider {
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	p := &Provider{
+		kubeClient:         kubeClient,
+		operations:         cache.New(time.Hour, time.Hour),
+		operationRetention: time.Hour,
+	}
+	if namespace != "" {
+		p.checkpoints = &configMapCheckpointStore{kubeClient: kubeClient, namespace: namespace}
+	}
+	return p
+}
+
+func TestRecordOperation(t *testing.T) {
+	p := newOperationIndexProvider("")
+	p.recordOperation(context.Background(), "ws1", "create", "", errors.New("invalid agent pool"))
+	assert.Empty(t, p.Operations())
+
+	p.recordOperation(context.Background(), "ws1", "create", "op0", errors.New("QuotaExceeded"))
+	for i := 1; i <= maxOperationsPerNodeClaim; i++ {
+		p.recordOperation(context.Background(), "ws1", "create", fmt.Sprintf("op%d", i), nil)
+	}
+	p.recordOperation(context.Background(), "ws2", "resume", "op-ws2", nil)
+
+	records := p.Operations()
+	assert.Len(t, records, maxOperationsPerNodeClaim+1)
+	assert.Equal(t, "op1", records[0].OperationID, "the oldest record of ws1 is dropped")
+	assert.Equal(t, "op-ws2", records[len(records)-1].OperationID)
+	assert.Empty(t, records[0].Error)
+}
+
+func TestPersistOperations(t *testing.T) {
+	p := newOperationIndexProvider("gpu-provisioner")
+	p.recordOperation(context.Background(), "ws1", "create", "op1", errors.New("QuotaExceeded"))
+	p.recordOperation(context.Background(), "ws2", "create", "op2", nil)
+
+	restarted := newOperationIndexProvider("gpu-provisioner")
+	restarted.checkpoints = p.checkpoints
+	assert.NoError(t, restarted.LoadOperations(context.Background()))
+	records := restarted.Operations()
+	assert.Equal(t, []string{"op1", "op2"}, lo.Map(records, func(r OperationRecord, _ int) string { return r.OperationID }))
+	assert.Equal(t, "QuotaExceeded", records[0].Error)
+
+	expired := newOperationIndexProvider("gpu-provisioner")
+	expired.checkpoints = p.checkpoints
+	expired.operationRetention = 0
+	assert.NoError(t, expired.LoadOperations(context.Background()))
+	assert.Empty(t, expired.Operations())
+}
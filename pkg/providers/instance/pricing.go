@@ -0,0 +1,177 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/samber/lo"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+const (
+	// MaxPriceAnnotationKey caps the hourly price in USD of the instance type selected for the nodeclaim, instance
+	// types above the cap are skipped.
+	MaxPriceAnnotationKey = "karpenter.azure.com/max-price"
+
+	// RetailPriceCacheTTL is the time retail prices are served from cache.
+	RetailPriceCacheTTL = 24 * time.Hour
+
+	retailPricesEndpoint   = "https://prices.azure.com/api/retail/prices"
+	retailPricesModuleName = "gpu-provisioner/retailprices"
+)
+
+// RetailPricesAPI queries the Azure retail prices API, which requires no authentication.
+type RetailPricesAPI interface {
+	RetailPrices(ctx context.Context, filter string) ([]RetailPrice, error)
+}
+
+type RetailPrice struct {
+	ArmRegionName string  `json:"armRegionName"`
+	ArmSkuName    string  `json:"armSkuName"`
+	SkuName       string  `json:"skuName"`
+	ProductName   string  `json:"productName"`
+	Type          string  `json:"type"`
+	RetailPrice   float64 `json:"retailPrice"`
+}
+
+type retailPricesResponse struct {
+	Items        []RetailPrice `json:"Items"`
+	NextPageLink string        `json:"NextPageLink"`
+}
+
+type retailPricesClient struct {
+	pipeline runtime.Pipeline
+}
+
+func NewRetailPricesClient(options *arm.ClientOptions) RetailPricesAPI {
+	return &retailPricesClient{
+		pipeline: runtime.NewPipeline(retailPricesModuleName, "v0.0.1", runtime.PipelineOptions{}, &options.ClientOptions),
+	}
+}
+
+func (c *retailPricesClient) RetailPrices(ctx context.Context, filter string) ([]RetailPrice, error) {
+	var prices []RetailPrice
+	req, err := runtime.NewRequest(ctx, http.MethodGet, retailPricesEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	reqQP := req.Raw().URL.Query()
+	reqQP.Set("$filter", filter)
+	req.Raw().URL.RawQuery = reqQP.Encode()
+
+	for {
+		resp, err := c.pipeline.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if !runtime.HasStatusCode(resp, http.StatusOK) {
+			return nil, runtime.NewResponseError(resp)
+		}
+		page := retailPricesResponse{}
+		if err := runtime.UnmarshalAsJSON(resp, &page); err != nil {
+			return nil, err
+		}
+		prices = append(prices, page.Items...)
+		if page.NextPageLink == "" {
+			return prices, nil
+		}
+		if req, err = runtime.NewRequest(ctx, http.MethodGet, page.NextPageLink); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// PriceCapError is returned when no instance type allowed by the nodeclaim requirements is under the price cap.
+type PriceCapError struct {
+	MaxPrice      float64
+	InstanceTypes []string
+}
+
+func (e *PriceCapError) Error() string {
+	return fmt.Sprintf("no SKU under price cap %g USD/hour, candidates %v", e.MaxPrice, e.InstanceTypes)
+}
+
+func IsPriceCapError(err error) bool {
+	var pcErr *PriceCapError
+	return errors.As(err, &pcErr)
+}
+
+// instanceTypesUnderPriceCap filters the instance types by the MaxPriceAnnotationKey annotation of nodeclaim, the
+// order of instance types is kept. instance types are returned as is if the nodeclaim has no price cap.
+func (p *Provider) instanceTypesUnderPriceCap(ctx context.Context, nodeClaim *karpenterv1.NodeClaim, instanceTypes []string, capacityType string) ([]string, error) {
+	value, ok := nodeClaim.Annotations[MaxPriceAnnotationKey]
+	if !ok {
+		return instanceTypes, nil
+	}
+	maxPrice, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s annotation %q, %w", MaxPriceAnnotationKey, value, err)
+	}
+
+	var underCap []string
+	for _, instanceType := range instanceTypes {
+		price, err := p.retailPrice(ctx, instanceType, capacityType)
+		if err != nil {
+			return nil, fmt.Errorf("getting retail price of %s, %w", instanceType, err)
+		}
+		if price <= maxPrice {
+			underCap = append(underCap, instanceType)
+		}
+	}
+	if len(underCap) == 0 {
+		return nil, withRemediation(&PriceCapError{MaxPrice: maxPrice, InstanceTypes: instanceTypes},
+			fmt.Sprintf("raise the %s annotation or allow cheaper instance types", MaxPriceAnnotationKey))
+	}
+	return underCap, nil
+}
+
+// retailPrice returns the hourly linux retail price in USD of the instance type for the capacity type in the region
+// of the cluster.
+func (p *Provider) retailPrice(ctx context.Context, instanceType string, capacityType string) (float64, error) {
+	key := instanceType + "/" + capacityType
+	if price, ok := p.retailPriceCache.Get(key); ok {
+		return price.(float64), nil
+	}
+	if p.azClient.retailPricesClient == nil {
+		return 0, fmt.Errorf("retail prices client is not configured")
+	}
+
+	filter := fmt.Sprintf("serviceName eq 'Virtual Machines' and priceType eq 'Consumption' and armRegionName eq '%s' and armSkuName eq '%s'", p.azClient.location, instanceType)
+	prices, err := p.azClient.retailPricesClient.RetailPrices(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	price, ok := lo.Find(prices, func(price RetailPrice) bool {
+		if strings.Contains(price.ProductName, "Windows") || strings.Contains(price.SkuName, "Low Priority") {
+			return false
+		}
+		return strings.Contains(price.SkuName, "Spot") == (capacityType == karpenterv1.CapacityTypeSpot)
+	})
+	if !ok {
+		return 0, fmt.Errorf("no %s retail price found in region %q", capacityType, p.azClient.location)
+	}
+	p.retailPriceCache.SetDefault(key, price.RetailPrice)
+	return price.RetailPrice, nil
+}
@@ -0,0 +1,131 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/stretchr/testify/assert"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// fakeRetailPrices returns the prices of the instance type in the filter.
+type fakeRetailPrices struct {
+	prices map[string][]RetailPrice
+	calls  int
+}
+
+func (f *fakeRetailPrices) RetailPrices(_ context.Context, filter string) ([]RetailPrice, error) {
+	f.calls++
+	for sku, prices := range f.prices {
+		if strings.Contains(filter, "armSkuName eq '"+sku+"'") {
+			return prices, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestInstanceTypesUnderPriceCap(t *testing.T) {
+	retailPrices := map[string][]RetailPrice{
+		"Standard_NC6s_v3": {
+			{ArmSkuName: "Standard_NC6s_v3", SkuName: "NC6s v3", ProductName: "Virtual Machines NCSv3 Series Windows", RetailPrice: 4.0},
+			{ArmSkuName: "Standard_NC6s_v3", SkuName: "NC6s v3 Spot", ProductName: "Virtual Machines NCSv3 Series", RetailPrice: 0.5},
+			{ArmSkuName: "Standard_NC6s_v3", SkuName: "NC6s v3", ProductName: "Virtual Machines NCSv3 Series", RetailPrice: 3.0},
+		},
+		"Standard_NC24ads_A100_v4": {
+			{ArmSkuName: "Standard_NC24ads_A100_v4", SkuName: "NC24ads A100 v4", ProductName: "Virtual Machines NCadsA100v4 Series", RetailPrice: 3.7},
+		},
+	}
+	instanceTypes := []string{"Standard_NC24ads_A100_v4", "Standard_NC6s_v3"}
+
+	testCases := []struct {
+		name                  string
+		maxPrice              string
+		capacityType          string
+		expectedInstanceTypes []string
+		expectedCalls         int
+		expectedPriceCapError bool
+	}{
+		{
+			name:                  "Return all instance types without price cap",
+			capacityType:          karpenterv1.CapacityTypeOnDemand,
+			expectedInstanceTypes: instanceTypes,
+		},
+		{
+			name:                  "Filter instance types by on-demand linux price",
+			maxPrice:              "3.5",
+			capacityType:          karpenterv1.CapacityTypeOnDemand,
+			expectedInstanceTypes: []string{"Standard_NC6s_v3"},
+			expectedCalls:         2,
+		},
+		{
+			name:                  "Fail because no instance type is under price cap",
+			maxPrice:              "1",
+			capacityType:          karpenterv1.CapacityTypeOnDemand,
+			expectedCalls:         2,
+			expectedPriceCapError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClaim := fixture.NodeClaim().WithName("nodeclaim-test").Build()
+			if tc.maxPrice != "" {
+				nodeClaim.Annotations = map[string]string{MaxPriceAnnotationKey: tc.maxPrice}
+			}
+			pricing := &fakeRetailPrices{prices: retailPrices}
+			p := createTestProvider(nil, nil)
+			p.azClient.retailPricesClient = pricing
+
+			result, err := p.instanceTypesUnderPriceCap(context.Background(), nodeClaim, instanceTypes, tc.capacityType)
+			assert.Equal(t, tc.expectedCalls, pricing.calls)
+			if tc.expectedPriceCapError {
+				assert.True(t, IsPriceCapError(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedInstanceTypes, result)
+
+			// retail prices are served from cache afterwards.
+			_, err = p.instanceTypesUnderPriceCap(context.Background(), nodeClaim, instanceTypes, tc.capacityType)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCalls, pricing.calls)
+		})
+	}
+}
+
+func TestRetailPriceOfCapacityType(t *testing.T) {
+	pricing := &fakeRetailPrices{prices: map[string][]RetailPrice{
+		"Standard_NC6s_v3": {
+			{ArmSkuName: "Standard_NC6s_v3", SkuName: "NC6s v3 Low Priority", ProductName: "Virtual Machines NCSv3 Series", RetailPrice: 0.6},
+			{ArmSkuName: "Standard_NC6s_v3", SkuName: "NC6s v3 Spot", ProductName: "Virtual Machines NCSv3 Series", RetailPrice: 0.5},
+			{ArmSkuName: "Standard_NC6s_v3", SkuName: "NC6s v3", ProductName: "Virtual Machines NCSv3 Series", RetailPrice: 3.0},
+		},
+	}}
+	p := createTestProvider(nil, nil)
+	p.azClient.retailPricesClient = pricing
+
+	price, err := p.retailPrice(context.Background(), "Standard_NC6s_v3", karpenterv1.CapacityTypeSpot)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, price)
+
+	price, err = p.retailPrice(context.Background(), "Standard_NC6s_v3", karpenterv1.CapacityTypeOnDemand)
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, price)
+}
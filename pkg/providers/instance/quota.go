@@ -0,0 +1,133 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	sdkerrors "github.com/Azure/azure-sdk-for-go-extensions/pkg/errors"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+const (
+	computeUsagesAPIVersion = "2023-07-01"
+	computeUsagesModuleName = "gpu-provisioner/computeusages"
+)
+
+// ComputeUsagesAPI lists the vCPU quota usages of Microsoft.Compute in a region.
+type ComputeUsagesAPI interface {
+	ComputeUsages(ctx context.Context, location string) ([]ComputeUsage, error)
+}
+
+type ComputeUsage struct {
+	Name         ComputeUsageName `json:"name"`
+	CurrentValue int64            `json:"currentValue"`
+	Limit        int64            `json:"limit"`
+}
+
+type ComputeUsageName struct {
+	// Value is the quota family, e.g. standardNCSv3Family.
+	Value string `json:"value"`
+}
+
+type computeUsagesResponse struct {
+	Value    []ComputeUsage `json:"value"`
+	NextLink string         `json:"nextLink"`
+}
+
+type computeUsagesClient struct {
+	internal       *arm.Client
+	subscriptionID string
+}
+
+func NewComputeUsagesClient(subscriptionID string, credential azcore.TokenCredential, options *arm.ClientOptions) (ComputeUsagesAPI, error) {
+	cl, err := arm.NewClient(computeUsagesModuleName, "v0.0.1", credential, options)
+	if err != nil {
+		return nil, err
+	}
+	return &computeUsagesClient{internal: cl, subscriptionID: subscriptionID}, nil
+}
+
+func (c *computeUsagesClient) ComputeUsages(ctx context.Context, location string) ([]ComputeUsage, error) {
+	var usages []ComputeUsage
+	req, err := runtime.NewRequest(ctx, http.MethodGet, runtime.JoinPaths(c.internal.Endpoint(), fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Compute/locations/%s/usages", c.subscriptionID, location)))
+	if err != nil {
+		return nil, err
+	}
+	reqQP := req.Raw().URL.Query()
+	reqQP.Set("api-version", computeUsagesAPIVersion)
+	req.Raw().URL.RawQuery = reqQP.Encode()
+
+	for {
+		req.Raw().Header["Accept"] = []string{"application/json"}
+		resp, err := c.internal.Pipeline().Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if !runtime.HasStatusCode(resp, http.StatusOK) {
+			return nil, runtime.NewResponseError(resp)
+		}
+		page := computeUsagesResponse{}
+		if err := runtime.UnmarshalAsJSON(resp, &page); err != nil {
+			return nil, err
+		}
+		usages = append(usages, page.Value...)
+		if page.NextLink == "" {
+			return usages, nil
+		}
+		if req, err = runtime.NewRequest(ctx, http.MethodGet, page.NextLink); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// quotaHeadroom returns the vCPUs which can still be created in the region by quota family.
+func (p *Provider) quotaHeadroom(ctx context.Context) (map[string]int64, error) {
+	if p.azClient.computeUsagesClient == nil {
+		return nil, fmt.Errorf("compute usages client is not configured")
+	}
+	usages, err := p.azClient.computeUsagesClient.ComputeUsages(ctx, p.azClient.location)
+	if err != nil {
+		return nil, fmt.Errorf("listing compute usages, %w", err)
+	}
+	headroom := map[string]int64{}
+	for _, usage := range usages {
+		headroom[usage.Name.Value] = max(usage.Limit-usage.CurrentValue, 0)
+	}
+	return headroom, nil
+}
+
+// quotaExceededErrorCodes are the error codes of ARM when the vCPU quota of the instance type family is insufficient.
+var quotaExceededErrorCodes = []string{"QuotaExceeded", "InsufficientVCPUQuota"}
+
+// IsQuotaExceededError returns true if creating an agent pool failed because of insufficient vCPU quota.
+func IsQuotaExceededError(err error) bool {
+	azErr := sdkerrors.IsResponseError(err)
+	if azErr == nil {
+		return false
+	}
+	for _, code := range quotaExceededErrorCodes {
+		if strings.EqualFold(azErr.ErrorCode, code) {
+			return true
+		}
+	}
+	return azErr.ErrorCode == "OperationNotAllowed" && strings.Contains(strings.ToLower(azErr.Error()), "quota")
+}
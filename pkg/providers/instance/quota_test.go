@@ -0,0 +1,54 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsQuotaExceededError(t *testing.T) {
+	testcases := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"nil error": {},
+		"plain error": {
+			err: fmt.Errorf("quota exceeded"),
+		},
+		"quota exceeded": {
+			err:      fmt.Errorf("agentPool.BeginCreateOrUpdate failed: %w", &azcore.ResponseError{ErrorCode: "QuotaExceeded", StatusCode: http.StatusBadRequest}),
+			expected: true,
+		},
+		"insufficient vcpu quota": {
+			err:      &azcore.ResponseError{ErrorCode: "InsufficientVCPUQuota", StatusCode: http.StatusBadRequest},
+			expected: true,
+		},
+		"other response error": {
+			err: &azcore.ResponseError{ErrorCode: "InternalServerError", StatusCode: http.StatusInternalServerError},
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsQuotaExceededError(tc.err))
+		})
+	}
+}
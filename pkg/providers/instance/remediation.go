@@ -0,0 +1,60 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// RemediationError attaches a remediation hint to an error which can't be resolved without an action of the user.
+// the hint is part of the error message, so it's surfaced in the LaunchFailed message of the nodeclaim and in events.
+type RemediationError struct {
+	Err  error
+	Hint string
+}
+
+func (e *RemediationError) Error() string {
+	return fmt.Sprintf("%s, remediation: %s", e.Err, e.Hint)
+}
+
+func (e *RemediationError) Unwrap() error {
+	return e.Err
+}
+
+// RemediationOf returns the remediation hint of err, or empty if err has no hint.
+func RemediationOf(err error) string {
+	var rErr *RemediationError
+	if errors.As(err, &rErr) {
+		return rErr.Hint
+	}
+	return ""
+}
+
+func withRemediation(err error, hint string) error {
+	return &RemediationError{Err: err, Hint: hint}
+}
+
+// quotaRemediation returns the hint of a quota exceeded error when creating an agent pool of vmSize. the quota family
+// is looked up from the skus, the vm size is used if skus are unavailable.
+func (p *Provider) quotaRemediation(ctx context.Context, vmSize string) string {
+	family := vmSize
+	if skus, err := p.SKUs(ctx); err == nil && skus[vmSize].Family != "" {
+		family = skus[vmSize].Family
+	}
+	return fmt.Sprintf("request quota increase for %s family in %s", family, p.azClient.location)
+}
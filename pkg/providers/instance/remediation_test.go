@@ -0,0 +1,69 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemediationOf(t *testing.T) {
+	testcases := map[string]struct {
+		err          error
+		expectedHint string
+	}{
+		"no error": {},
+		"error without hint": {
+			err: errors.New("failed"),
+		},
+		"wrapped error with hint": {
+			err:          fmt.Errorf("creating instance, %w", withRemediation(&PriceCapError{MaxPrice: 1}, "raise the cap")),
+			expectedHint: "raise the cap",
+		},
+	}
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, tc.expectedHint, RemediationOf(tc.err))
+		})
+	}
+}
+
+func TestRemediationKeepsErrorType(t *testing.T) {
+	err := fmt.Errorf("creating instance, %w", withRemediation(&PriceCapError{MaxPrice: 1}, "raise the cap"))
+	assert.True(t, IsPriceCapError(err))
+	assert.Contains(t, err.Error(), "remediation: raise the cap")
+
+	quotaErr := withRemediation(&azcore.ResponseError{ErrorCode: "QuotaExceeded", StatusCode: http.StatusBadRequest}, "request quota")
+	assert.True(t, IsQuotaExceededError(quotaErr))
+}
+
+func TestQuotaRemediation(t *testing.T) {
+	p := &Provider{
+		azClient: &AZClient{location: "eastus", resourceSKUsClient: &fakeResourceSKUs{skus: []ResourceSKU{
+			{Name: "Standard_NC24ads_A100_v4", ResourceType: "virtualMachines", Family: "StandardNCADSA100v4Family"},
+		}}},
+		skuCache: cache.New(SKUCacheTTL, SKUCacheTTL),
+	}
+	assert.Equal(t, "request quota increase for StandardNCADSA100v4Family family in eastus", p.quotaRemediation(context.Background(), "Standard_NC24ads_A100_v4"))
+	assert.Equal(t, "request quota increase for Standard_ND96asr_v4 family in eastus", p.quotaRemediation(context.Background(), "Standard_ND96asr_v4"))
+}
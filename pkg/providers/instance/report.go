@@ -0,0 +1,108 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"sort"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+)
+
+// InstanceTypeReport describes a gpu instance type which nodeclaims with the requirements could be created with.
+type InstanceTypeReport struct {
+	Name          string `json:"name"`
+	Family        string `json:"family"`
+	GPUGeneration string `json:"gpuGeneration,omitempty"`
+	GPUCount      int64  `json:"gpuCount"`
+	GPUMemoryGiB  int64  `json:"gpuMemoryGiB,omitempty"`
+	VCPUs         int64  `json:"vcpus"`
+	// Available is false if the instance type is restricted for the subscription in the region.
+	Available bool `json:"available"`
+	// QuotaHeadroomVCPUs is the vCPUs left in the quota of the instance type family, nil if it's unknown.
+	QuotaHeadroomVCPUs *int64 `json:"quotaHeadroomVCPUs,omitempty"`
+	// QuotaHeadroomInstances is the number of instances which fit in QuotaHeadroomVCPUs.
+	QuotaHeadroomInstances *int64 `json:"quotaHeadroomInstances,omitempty"`
+	CapacityType           string `json:"capacityType"`
+	// HourlyPrice is the linux retail price in USD of the capacity type, nil if it's unknown.
+	HourlyPrice *float64 `json:"hourlyPrice,omitempty"`
+}
+
+// InstanceTypeReports returns the gpu instance types in the region of the cluster allowed by the requirements on the
+// instance type and SKULabelKeys, sorted by name. it helps authoring nodeclaim requirements, so quota headroom and
+// prices are best effort and left empty if they can't be fetched.
+func (p *Provider) InstanceTypeReports(ctx context.Context, nodeSelectorRequirements []karpenterv1.NodeSelectorRequirementWithMinValues) ([]InstanceTypeReport, error) {
+	nodeClaim := &karpenterv1.NodeClaim{Spec: karpenterv1.NodeClaimSpec{Requirements: nodeSelectorRequirements}}
+	capacityType, err := capacityTypeOf(nodeClaim, p.spotAgentPools)
+	if err != nil {
+		return nil, err
+	}
+	skus, err := p.SKUs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	headroom, err := p.quotaHeadroom(ctx)
+	if err != nil {
+		klog.V(1).InfoS("skip reporting quota headroom", "error", err)
+	}
+
+	requirements := scheduling.NewNodeSelectorRequirementsWithMinValues(nodeSelectorRequirements...)
+	reports := []InstanceTypeReport{}
+	for _, sku := range skus {
+		if sku.GPUCount == 0 || !allowsSKU(requirements, sku) {
+			continue
+		}
+		report := InstanceTypeReport{
+			Name:          sku.Name,
+			Family:        sku.Family,
+			GPUGeneration: sku.GPUGeneration,
+			GPUCount:      sku.GPUCount,
+			GPUMemoryGiB:  sku.GPUMemoryGiB,
+			VCPUs:         sku.VCPUs,
+			Available:     !sku.Restricted,
+			CapacityType:  capacityType,
+		}
+		if vcpus, ok := headroom[sku.Family]; ok {
+			report.QuotaHeadroomVCPUs = &vcpus
+			if sku.VCPUs > 0 {
+				instances := vcpus / sku.VCPUs
+				report.QuotaHeadroomInstances = &instances
+			}
+		}
+		if price, err := p.retailPrice(ctx, sku.Name, capacityType); err == nil {
+			report.HourlyPrice = &price
+		} else {
+			klog.V(1).InfoS("skip reporting retail price", "instanceType", sku.Name, "error", err)
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+	return reports, nil
+}
+
+// allowsSKU returns true if the requirements on the instance type and SKULabelKeys allow the sku, the same way
+// instanceTypesWithSKURequirements filters instance types.
+func allowsSKU(requirements scheduling.Requirements, sku SKU) bool {
+	labels := sku.Labels()
+	labels[corev1.LabelInstanceTypeStable] = sku.Name
+	return lo.EveryBy(append([]string{corev1.LabelInstanceTypeStable}, SKULabelKeys...), func(key string) bool {
+		return !requirements.Has(key) || requirements.Get(key).Has(labels[key])
+	})
+}
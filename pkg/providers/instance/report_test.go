@@ -0,0 +1,129 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+type fakeComputeUsages struct {
+	usages []ComputeUsage
+}
+
+func (f *fakeComputeUsages) ComputeUsages(_ context.Context, _ string) ([]ComputeUsage, error) {
+	return f.usages, nil
+}
+
+func TestInstanceTypeReports(t *testing.T) {
+	resourceSKUs := []ResourceSKU{
+		{
+			Name:         "Standard_NC6s_v3",
+			Family:       "standardNCSv3Family",
+			ResourceType: "virtualMachines",
+			Capabilities: []ResourceSKUCapability{{Name: "vCPUs", Value: "6"}, {Name: "GPUs", Value: "1"}},
+		},
+		{
+			Name:         "Standard_NC24ads_A100_v4",
+			Family:       "StandardNCADSA100v4Family",
+			ResourceType: "virtualMachines",
+			Capabilities: []ResourceSKUCapability{{Name: "vCPUs", Value: "24"}, {Name: "GPUs", Value: "1"}},
+			Restrictions: []ResourceSKURestriction{{Type: "Location", ReasonCode: "NotAvailableForSubscription"}},
+		},
+		{
+			Name:         "Standard_D4s_v3",
+			Family:       "standardDSv3Family",
+			ResourceType: "virtualMachines",
+			Capabilities: []ResourceSKUCapability{{Name: "vCPUs", Value: "4"}},
+		},
+	}
+	retailPrices := map[string][]RetailPrice{
+		"Standard_NC6s_v3": {
+			{ArmSkuName: "Standard_NC6s_v3", SkuName: "NC6s v3", ProductName: "Virtual Machines NCSv3 Series", RetailPrice: 3.0},
+		},
+	}
+	usages := []ComputeUsage{
+		{Name: ComputeUsageName{Value: "standardNCSv3Family"}, CurrentValue: 12, Limit: 24},
+	}
+	nc6sReport := InstanceTypeReport{
+		Name:                   "Standard_NC6s_v3",
+		Family:                 "standardNCSv3Family",
+		GPUGeneration:          GPUGenerationVolta,
+		GPUCount:               1,
+		GPUMemoryGiB:           16,
+		VCPUs:                  6,
+		Available:              true,
+		QuotaHeadroomVCPUs:     lo.ToPtr[int64](12),
+		QuotaHeadroomInstances: lo.ToPtr[int64](2),
+		CapacityType:           karpenterv1.CapacityTypeOnDemand,
+		HourlyPrice:            lo.ToPtr(3.0),
+	}
+	a100Report := InstanceTypeReport{
+		Name:          "Standard_NC24ads_A100_v4",
+		Family:        "StandardNCADSA100v4Family",
+		GPUGeneration: GPUGenerationAmpere,
+		GPUCount:      1,
+		GPUMemoryGiB:  80,
+		VCPUs:         24,
+		CapacityType:  karpenterv1.CapacityTypeOnDemand,
+	}
+
+	testCases := []struct {
+		name            string
+		requirements    []v1.NodeSelectorRequirement
+		expectedReports []InstanceTypeReport
+	}{
+		{
+			name:            "Report all gpu instance types without requirements",
+			expectedReports: []InstanceTypeReport{a100Report, nc6sReport},
+		},
+		{
+			name: "Report instance types allowed by instance type requirement",
+			requirements: []v1.NodeSelectorRequirement{
+				{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"Standard_NC6s_v3", "Standard_D4s_v3"}},
+			},
+			expectedReports: []InstanceTypeReport{nc6sReport},
+		},
+		{
+			name: "Report instance types allowed by sku label requirement",
+			requirements: []v1.NodeSelectorRequirement{
+				{Key: LabelGPUGeneration, Operator: v1.NodeSelectorOpIn, Values: []string{GPUGenerationAmpere}},
+			},
+			expectedReports: []InstanceTypeReport{a100Report},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := createTestProvider(nil, nil)
+			p.azClient.resourceSKUsClient = &fakeResourceSKUs{skus: resourceSKUs}
+			p.azClient.retailPricesClient = &fakeRetailPrices{prices: retailPrices}
+			p.azClient.computeUsagesClient = &fakeComputeUsages{usages: usages}
+
+			requirements := lo.Map(tc.requirements, func(r v1.NodeSelectorRequirement, _ int) karpenterv1.NodeSelectorRequirementWithMinValues {
+				return karpenterv1.NodeSelectorRequirementWithMinValues{NodeSelectorRequirement: r}
+			})
+			reports, err := p.InstanceTypeReports(context.Background(), requirements)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedReports, reports)
+		})
+	}
+}
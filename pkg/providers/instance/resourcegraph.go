@@ -0,0 +1,241 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/samber/lo"
+)
+
+const (
+	resourceGraphAPIVersion = "2021-03-01"
+	resourceGraphModuleName = "gpu-provisioner/resourcegraph"
+)
+
+// ResourceGraphAPI queries azure resource graph, the request and response follow the Resources API of
+// Microsoft.ResourceGraph.
+type ResourceGraphAPI interface {
+	Resources(ctx context.Context, query ResourceGraphQuery) (ResourceGraphQueryResponse, error)
+}
+
+type ResourceGraphQuery struct {
+	Subscriptions []string                   `json:"subscriptions"`
+	Query         string                     `json:"query"`
+	Options       *ResourceGraphQueryOptions `json:"options,omitempty"`
+}
+
+type ResourceGraphQueryOptions struct {
+	SkipToken    *string `json:"$skipToken,omitempty"`
+	ResultFormat string  `json:"resultFormat,omitempty"`
+}
+
+type ResourceGraphQueryResponse struct {
+	Data      []json.RawMessage `json:"data"`
+	SkipToken *string           `json:"$skipToken,omitempty"`
+}
+
+type resourceGraphClient struct {
+	internal *arm.Client
+}
+
+func NewResourceGraphClient(credential azcore.TokenCredential, options *arm.ClientOptions) (ResourceGraphAPI, error) {
+	cl, err := arm.NewClient(resourceGraphModuleName, "v0.0.1", credential, options)
+	if err != nil {
+		return nil, err
+	}
+	return &resourceGraphClient{internal: cl}, nil
+}
+
+func (c *resourceGraphClient) Resources(ctx context.Context, query ResourceGraphQuery) (ResourceGraphQueryResponse, error) {
+	req, err := runtime.NewRequest(ctx, http.MethodPost, runtime.JoinPaths(c.internal.Endpoint(), "/providers/Microsoft.ResourceGraph/resources"))
+	if err != nil {
+		return ResourceGraphQueryResponse{}, err
+	}
+	reqQP := req.Raw().URL.Query()
+	reqQP.Set("api-version", resourceGraphAPIVersion)
+	req.Raw().URL.RawQuery = reqQP.Encode()
+	req.Raw().Header["Accept"] = []string{"application/json"}
+	if err := runtime.MarshalAsJSON(req, query); err != nil {
+		return ResourceGraphQueryResponse{}, err
+	}
+
+	resp, err := c.internal.Pipeline().Do(req)
+	if err != nil {
+		return ResourceGraphQueryResponse{}, err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return ResourceGraphQueryResponse{}, runtime.NewResponseError(resp)
+	}
+	result := ResourceGraphQueryResponse{}
+	if err := runtime.UnmarshalAsJSON(resp, &result); err != nil {
+		return ResourceGraphQueryResponse{}, err
+	}
+	return result, nil
+}
+
+// ForeignAgentPoolError is returned when an agent pool returned by azure doesn't belong to the cluster managed by
+// gpu-provisioner, or isn't tagged as owned by gpu-provisioner. it is never deleted, and listing fails instead of
+// garbage collecting a partial view of the cluster.
+type ForeignAgentPoolError struct {
+	AgentPool string
+	Reason    string
+}
+
+func (e *ForeignAgentPoolError) Error() string {
+	return fmt.Sprintf("agent pool %q is not owned by this cluster, %s", e.AgentPool, e.Reason)
+}
+
+func IsForeignAgentPoolError(err error) bool {
+	var faErr *ForeignAgentPoolError
+	return errors.As(err, &faErr)
+}
+
+// ownedAgentPoolsQuery selects the agent pools tagged as managed by gpu-provisioner in the cluster, so
+// pools not owned by gpu-provisioner are never returned by azure. the cluster identity is projected with each pool,
+// so rows can be verified against the cluster before they are used.
+func ownedAgentPoolsQuery(rg, clusterName string) string {
+	return fmt.Sprintf(`resources
+| where type =~ 'microsoft.containerservice/managedclusters' and resourceGroup =~ '%s' and name =~ '%s'
+| mv-expand pool = properties.agentPoolProfiles
+| where pool.tags['%s'] == '%s'
+| project pool, clusterId = id, clusterName = name, clusterResourceGroup = resourceGroup, subscriptionId`, rg, clusterName, ManagedByTagKey, ManagedByTagValue)
+}
+
+// forEachOwnedAgentPool queries agent pools owned by gpu-provisioner from azure resource graph page by page and
+// calls fn for each of them. iteration stops when fn returns false or an error. a row of another cluster or without
+// the ownership tag fails the iteration with a ForeignAgentPoolError, since the query is subscription wide.
+func forEachOwnedAgentPool(ctx context.Context, client ResourceGraphAPI, subscriptionID, rg, clusterName string, fn func(ap *armcontainerservice.AgentPool) (bool, error)) error {
+	query := ResourceGraphQuery{
+		Subscriptions: []string{subscriptionID},
+		Query:         ownedAgentPoolsQuery(rg, clusterName),
+		Options:       &ResourceGraphQueryOptions{ResultFormat: "objectArray"},
+	}
+	for {
+		resp, err := client.Resources(ctx, query)
+		if err != nil {
+			return err
+		}
+		for i := range resp.Data {
+			ap, err := agentPoolFromProfile(resp.Data[i], subscriptionID, rg, clusterName)
+			if err != nil {
+				return err
+			}
+			next, err := fn(ap)
+			if err != nil || !next {
+				return err
+			}
+		}
+		if len(lo.FromPtr(resp.SkipToken)) == 0 {
+			return nil
+		}
+		query.Options.SkipToken = resp.SkipToken
+	}
+}
+
+// agentPoolFromProfile converts a row of ownedAgentPoolsQuery, which is an agent pool profile of the managed
+// cluster, to an agent pool after verifying the row belongs to the cluster.
+func agentPoolFromProfile(row json.RawMessage, subscriptionID, rg, clusterName string) (*armcontainerservice.AgentPool, error) {
+	var r struct {
+		Pool                 json.RawMessage `json:"pool"`
+		ClusterID            string          `json:"clusterId"`
+		ClusterName          string          `json:"clusterName"`
+		ClusterResourceGroup string          `json:"clusterResourceGroup"`
+		SubscriptionID       string          `json:"subscriptionId"`
+	}
+	if err := json.Unmarshal(row, &r); err != nil {
+		return nil, fmt.Errorf("unmarshalling resource graph row, %w", err)
+	}
+	var name struct {
+		Name *string `json:"name"`
+	}
+	if err := json.Unmarshal(r.Pool, &name); err != nil {
+		return nil, fmt.Errorf("unmarshalling agent pool profile, %w", err)
+	}
+	properties := &armcontainerservice.ManagedClusterAgentPoolProfileProperties{}
+	if err := json.Unmarshal(r.Pool, properties); err != nil {
+		return nil, fmt.Errorf("unmarshalling agent pool profile, %w", err)
+	}
+	ap := &armcontainerservice.AgentPool{
+		ID:         to.Ptr(fmt.Sprintf("%s/agentPools/%s", r.ClusterID, lo.FromPtr(name.Name))),
+		Name:       name.Name,
+		Properties: properties,
+	}
+
+	switch {
+	case !strings.EqualFold(r.SubscriptionID, subscriptionID):
+		return nil, &ForeignAgentPoolError{AgentPool: lo.FromPtr(ap.Name), Reason: fmt.Sprintf("subscription %q", r.SubscriptionID)}
+	case !strings.EqualFold(r.ClusterResourceGroup, rg) || !strings.EqualFold(r.ClusterName, clusterName):
+		return nil, &ForeignAgentPoolError{AgentPool: lo.FromPtr(ap.Name), Reason: fmt.Sprintf("cluster %s/%s", r.ClusterResourceGroup, r.ClusterName)}
+	}
+	if err := verifyAgentPoolOwnership(ap, subscriptionID, rg, clusterName); err != nil {
+		return nil, err
+	}
+	return ap, nil
+}
+
+// verifyAgentPoolOwnership returns a ForeignAgentPoolError unless the agent pool is tagged as owned by
+// gpu-provisioner and, when its resource ID is known, the ID is under the managed cluster.
+func verifyAgentPoolOwnership(ap *armcontainerservice.AgentPool, subscriptionID, rg, clusterName string) error {
+	apName := lo.FromPtr(ap.Name)
+	if !agentPoolIsTaggedAsManaged(ap) {
+		return &ForeignAgentPoolError{AgentPool: apName, Reason: fmt.Sprintf("missing tag %s=%s", ManagedByTagKey, ManagedByTagValue)}
+	}
+	if id := lo.FromPtr(ap.ID); id != "" {
+		expected := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s/agentPools/%s", subscriptionID, rg, clusterName, apName)
+		if !strings.EqualFold(id, expected) {
+			return &ForeignAgentPoolError{AgentPool: apName, Reason: fmt.Sprintf("resource ID %q", id)}
+		}
+	}
+	return nil
+}
+
+func agentPoolIsTaggedAsManaged(ap *armcontainerservice.AgentPool) bool {
+	return ap.Properties != nil && lo.FromPtr(ap.Properties.Tags[ManagedByTagKey]) == ManagedByTagValue
+}
+
+// checkAgentPoolOwnership verifies the agent pool is owned by gpu-provisioner before it's deleted when agent pools
+// are listed across the subscription with resource graph, so a mis-scoped listing can never delete an agent pool
+// gpu-provisioner doesn't own. the agent pool is fetched from the managed cluster so only its ownership is verified,
+// legacy agent pools created before the ownership tag pass the check by their kaito labels until the migration
+// backfills the tag. agent pools which are already gone pass the check. the agent pool fetched for the check is
+// returned, it's nil if the check doesn't fetch it.
+func (p *Provider) checkAgentPoolOwnership(ctx context.Context, apName string) (*armcontainerservice.AgentPool, error) {
+	if p.azClient.resourceGraphClient == nil {
+		return nil, nil
+	}
+	ap, err := p.getAgentPool(ctx, apName)
+	if err != nil {
+		if isNotFoundAzError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting agentpool %q for ownership check, %w", apName, err)
+	}
+	if !agentPoolIsTaggedAsManaged(ap) && !agentPoolIsOwnedByKaito(ap) {
+		return ap, &ForeignAgentPoolError{AgentPool: apName, Reason: fmt.Sprintf("missing tag %s=%s", ManagedByTagKey, ManagedByTagValue)}
+	}
+	return ap, nil
+}
@@ -0,0 +1,256 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// fakeResourceGraph returns one page of query results for each call.
+type fakeResourceGraph struct {
+	pages   []ResourceGraphQueryResponse
+	err     error
+	queries []ResourceGraphQuery
+}
+
+func (f *fakeResourceGraph) Resources(_ context.Context, query ResourceGraphQuery) (ResourceGraphQueryResponse, error) {
+	f.queries = append(f.queries, query)
+	if f.err != nil {
+		return ResourceGraphQueryResponse{}, f.err
+	}
+	page := f.pages[0]
+	f.pages = f.pages[1:]
+	return page, nil
+}
+
+func agentPoolProfileRow(t *testing.T, name string) json.RawMessage {
+	return agentPoolProfileRowOf(t, name, "testRG", "testCluster", true)
+}
+
+// agentPoolProfileRowOf returns a row of the agent pool in the cluster testCluster of the resource group testRG
+// with the given cluster identity, and the ownership tag if owned.
+func agentPoolProfileRowOf(t *testing.T, name, rg, clusterName string, owned bool) json.RawMessage {
+	tags := map[string]string{}
+	if owned {
+		tags[ManagedByTagKey] = ManagedByTagValue
+	}
+	row, err := json.Marshal(map[string]any{
+		"clusterId":            "/subscriptions/testSub/resourceGroups/" + rg + "/providers/Microsoft.ContainerService/managedClusters/" + clusterName,
+		"clusterName":          clusterName,
+		"clusterResourceGroup": rg,
+		"subscriptionId":       "testSub",
+		"pool": map[string]any{
+			"name":   name,
+			"vmSize": "Standard_NC6s_v3",
+			"nodeLabels": map[string]string{
+				"kaito.sh/workspace":    "none",
+				"karpenter.sh/nodepool": "kaito",
+				NodeClaimCreationLabel:  "2024-01-01T00-00-00Z",
+			},
+			"tags":              tags,
+			"provisioningState": "Succeeded",
+		},
+	})
+	assert.NoError(t, err)
+	return row
+}
+
+func TestListPageWithResourceGraph(t *testing.T) {
+	testCases := []struct {
+		name              string
+		pages             func(t *testing.T) []ResourceGraphQueryResponse
+		err               error
+		expectedInstances []string
+		expectedQueries   int
+		expectedError     string
+	}{
+		{
+			name: "list owned agent pools from multiple pages",
+			pages: func(t *testing.T) []ResourceGraphQueryResponse {
+				return []ResourceGraphQueryResponse{
+					{Data: []json.RawMessage{agentPoolProfileRow(t, "agentpool0")}, SkipToken: to.Ptr("token")},
+					{Data: []json.RawMessage{agentPoolProfileRow(t, "agentpool1")}},
+				}
+			},
+			expectedInstances: []string{"agentpool0", "agentpool1"},
+			expectedQueries:   2,
+		},
+		{
+			name: "no owned agent pools",
+			pages: func(t *testing.T) []ResourceGraphQueryResponse {
+				return []ResourceGraphQueryResponse{{}}
+			},
+			expectedInstances: []string{},
+			expectedQueries:   1,
+		},
+		{
+			name: "cluster name is matched case-insensitively",
+			pages: func(t *testing.T) []ResourceGraphQueryResponse {
+				return []ResourceGraphQueryResponse{{Data: []json.RawMessage{agentPoolProfileRowOf(t, "agentpool0", "TESTRG", "TestCluster", true)}}}
+			},
+			expectedInstances: []string{"agentpool0"},
+			expectedQueries:   1,
+		},
+		{
+			name: "agent pool of another cluster fails listing",
+			pages: func(t *testing.T) []ResourceGraphQueryResponse {
+				return []ResourceGraphQueryResponse{{Data: []json.RawMessage{
+					agentPoolProfileRow(t, "agentpool0"),
+					agentPoolProfileRowOf(t, "agentpool1", "testRG", "otherCluster", true),
+				}}}
+			},
+			expectedQueries: 1,
+			expectedError:   `agent pool "agentpool1" is not owned by this cluster, cluster testRG/otherCluster`,
+		},
+		{
+			name: "agent pool in another resource group fails listing",
+			pages: func(t *testing.T) []ResourceGraphQueryResponse {
+				return []ResourceGraphQueryResponse{{Data: []json.RawMessage{agentPoolProfileRowOf(t, "agentpool0", "otherRG", "testCluster", true)}}}
+			},
+			expectedQueries: 1,
+			expectedError:   "cluster otherRG/testCluster",
+		},
+		{
+			name: "agent pool without ownership tag fails listing",
+			pages: func(t *testing.T) []ResourceGraphQueryResponse {
+				return []ResourceGraphQueryResponse{{Data: []json.RawMessage{agentPoolProfileRowOf(t, "agentpool0", "testRG", "testCluster", false)}}}
+			},
+			expectedQueries: 1,
+			expectedError:   "missing tag " + ManagedByTagKey,
+		},
+		{
+			name:            "fail to query resource graph",
+			pages:           func(t *testing.T) []ResourceGraphQueryResponse { return nil },
+			err:             errors.New("resource graph throttled"),
+			expectedQueries: 1,
+			expectedError:   "resource graph throttled",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			// agent pools are never listed from ARM when resource graph is used
+			agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+			mockK8sClient := fake.NewClient()
+			mockK8sClient.On("List", mock.IsType(context.Background()), mock.IsType(&v1.NodeList{}), mock.Anything).Return(nil)
+
+			graph := &fakeResourceGraph{pages: tc.pages(t), err: tc.err}
+			p := createTestProvider(agentPoolMocks, mockK8sClient)
+			p.azClient.resourceGraphClient = graph
+			p.azClient.subscriptionID = "testSub"
+
+			instances, _, err := p.ListPage(context.Background(), ListOptions{})
+			assert.Equal(t, tc.expectedQueries, len(graph.queries))
+			assert.Equal(t, []string{"testSub"}, graph.queries[0].Subscriptions)
+			assert.True(t, strings.Contains(graph.queries[0].Query, ManagedByTagKey), "query should filter by ownership tag")
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedInstances, lo.Map(instances, func(ins *Instance, _ int) string {
+				return lo.FromPtr(ins.Name)
+			}))
+			if tc.expectedQueries > 1 {
+				assert.Equal(t, "token", lo.FromPtr(graph.queries[1].Options.SkipToken))
+			}
+		})
+	}
+}
+
+func TestDeleteChecksOwnershipWithResourceGraph(t *testing.T) {
+	ownedID := "/subscriptions/testSub/resourceGroups/testRG/providers/Microsoft.ContainerService/managedClusters/testCluster/agentPools/agentpool0"
+	testCases := []struct {
+		name          string
+		id            string
+		tags          map[string]*string
+		labels        map[string]*string
+		expectDelete  bool
+		expectedError string
+	}{
+		{
+			name:         "owned agent pool is deleted",
+			id:           ownedID,
+			tags:         map[string]*string{ManagedByTagKey: to.Ptr(ManagedByTagValue)},
+			expectDelete: true,
+		},
+		{
+			name:         "legacy kaito agent pool without ownership tag is deleted",
+			id:           ownedID,
+			tags:         map[string]*string{},
+			labels:       map[string]*string{"kaito.sh/workspace": to.Ptr("none")},
+			expectDelete: true,
+		},
+		{
+			name:          "agent pool without ownership tag is not deleted",
+			id:            ownedID,
+			tags:          map[string]*string{},
+			labels:        map[string]*string{"test": to.Ptr("test")},
+			expectedError: "missing tag " + ManagedByTagKey,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			ap := GetAgentPoolObjWithName("agentpool0", tc.id, "Standard_NC6s_v3")
+			ap.Properties.Tags = tc.tags
+			if tc.labels != nil {
+				ap.Properties.NodeLabels = tc.labels
+			}
+			agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+			agentPoolMocks.EXPECT().Get(gomock.Any(), "testRG", "testCluster", "agentpool0", gomock.Any()).
+				Return(armcontainerservice.AgentPoolsClientGetResponse{AgentPool: ap}, nil)
+			if tc.expectDelete {
+				agentPoolMocks.EXPECT().BeginDelete(gomock.Any(), "testRG", "testCluster", "agentpool0", gomock.Any()).
+					Return(nil, NotFoundAzError())
+			}
+			mockK8sClient := fake.NewClient()
+			mockK8sClient.On("Get", mock.IsType(context.Background()), mock.Anything, mock.IsType(&karpenterv1.NodeClaim{}), mock.Anything).Return(NotFoundNodeClaimError())
+
+			p := createTestProvider(agentPoolMocks, mockK8sClient)
+			p.azClient.resourceGraphClient = &fakeResourceGraph{}
+			p.azClient.subscriptionID = "testSub"
+
+			err := p.Delete(context.Background(), "agentpool0")
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				assert.True(t, IsForeignAgentPoolError(err))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+const (
+	resourceProvidersAPIVersion = "2021-04-01"
+	resourceProvidersModuleName = "gpu-provisioner/resourceproviders"
+)
+
+// RequiredResourceProviders are the resource providers the subscription must be registered with to create agent pools.
+var RequiredResourceProviders = []string{"Microsoft.ContainerService", "Microsoft.Compute"}
+
+// ResourceProvidersAPI gets the registration state of resource providers in the subscription.
+type ResourceProvidersAPI interface {
+	RegistrationState(ctx context.Context, namespace string) (string, error)
+}
+
+type resourceProvider struct {
+	Namespace         string `json:"namespace"`
+	RegistrationState string `json:"registrationState"`
+}
+
+type resourceProvidersClient struct {
+	internal       *arm.Client
+	subscriptionID string
+}
+
+func NewResourceProvidersClient(subscriptionID string, credential azcore.TokenCredential, options *arm.ClientOptions) (ResourceProvidersAPI, error) {
+	cl, err := arm.NewClient(resourceProvidersModuleName, "v0.0.1", credential, options)
+	if err != nil {
+		return nil, err
+	}
+	return &resourceProvidersClient{internal: cl, subscriptionID: subscriptionID}, nil
+}
+
+func (c *resourceProvidersClient) RegistrationState(ctx context.Context, namespace string) (string, error) {
+	req, err := runtime.NewRequest(ctx, http.MethodGet, runtime.JoinPaths(c.internal.Endpoint(), fmt.Sprintf("/subscriptions/%s/providers/%s", c.subscriptionID, namespace)))
+	if err != nil {
+		return "", err
+	}
+	reqQP := req.Raw().URL.Query()
+	reqQP.Set("api-version", resourceProvidersAPIVersion)
+	req.Raw().URL.RawQuery = reqQP.Encode()
+	req.Raw().Header["Accept"] = []string{"application/json"}
+
+	resp, err := c.internal.Pipeline().Do(req)
+	if err != nil {
+		return "", err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return "", runtime.NewResponseError(resp)
+	}
+	provider := resourceProvider{}
+	if err := runtime.UnmarshalAsJSON(resp, &provider); err != nil {
+		return "", err
+	}
+	return provider.RegistrationState, nil
+}
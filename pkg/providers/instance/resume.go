@@ -0,0 +1,64 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"fmt"
+
+	sdkerrors "github.com/Azure/azure-sdk-for-go-extensions/pkg/errors"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/samber/lo"
+)
+
+// resumableAgentPool returns the agent pool of the nodeclaim if it's deallocated and can serve the nodeclaim, i.e.
+// it's owned by kaito, stopped, and its vm size and capacity type are allowed by the nodeclaim. nil is returned if
+// there is no such agent pool, then a new agent pool is created as usual.
+func (p *Provider) resumableAgentPool(ctx context.Context, apName string, instanceTypes []string, capacityType string) (*armcontainerservice.AgentPool, error) {
+	apObj, err := getAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, p.clusterName, apName)
+	if err != nil {
+		if azErr := sdkerrors.IsResponseError(err); azErr != nil && azErr.ErrorCode == "NotFound" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("agentPool.Get for %q failed: %w", apName, err)
+	}
+	if !agentPoolIsDeallocated(apObj) || !agentPoolIsOwnedByKaito(apObj) {
+		return nil, nil
+	}
+	if !lo.Contains(instanceTypes, lo.FromPtr(apObj.Properties.VMSize)) || agentPoolCapacityType(apObj) != capacityType {
+		return nil, nil
+	}
+	return apObj, nil
+}
+
+// agentPoolIsDeallocated returns true if the VMs of the agent pool are stopped and deallocated.
+func agentPoolIsDeallocated(ap *armcontainerservice.AgentPool) bool {
+	if ap == nil || ap.Properties == nil || ap.Properties.PowerState == nil {
+		return false
+	}
+	return lo.FromPtr(ap.Properties.PowerState.Code) == armcontainerservice.CodeStopped
+}
+
+// resumeAgentPool starts the deallocated VMs of the agent pool, which is much faster than creating an agent pool
+// since the VMs keep their OS disks.
+func resumeAgentPool(ctx context.Context, client AgentPoolsAPI, rg, apName, clusterName string, apObj *armcontainerservice.AgentPool) (*armcontainerservice.AgentPool, string, error) {
+	properties := *apObj.Properties
+	properties.PowerState = &armcontainerservice.PowerState{Code: to.Ptr(armcontainerservice.CodeRunning)}
+	resumed := *apObj
+	resumed.Properties = &properties
+	return createAgentPool(ctx, client, rg, apName, clusterName, resumed)
+}
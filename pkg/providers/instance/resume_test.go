@@ -0,0 +1,144 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func deallocatedAgentPool(name, vmSize string, powerState armcontainerservice.Code) *armcontainerservice.AgentPool {
+	ap := GetAgentPoolObjWithName(name, "", vmSize)
+	ap.Properties.PowerState = &armcontainerservice.PowerState{Code: to.Ptr(powerState)}
+	return &ap
+}
+
+func TestResumableAgentPool(t *testing.T) {
+	notOwned := deallocatedAgentPool("agentpool0", "Standard_NC6s_v3", armcontainerservice.CodeStopped)
+	delete(notOwned.Properties.NodeLabels, "kaito.sh/workspace")
+
+	testCases := []struct {
+		name              string
+		agentPool         *armcontainerservice.AgentPool
+		getErr            error
+		instanceTypes     []string
+		expectedResumable bool
+		expectedError     bool
+	}{
+		{
+			name:              "Resume deallocated agent pool of allowed instance type",
+			agentPool:         deallocatedAgentPool("agentpool0", "Standard_NC6s_v3", armcontainerservice.CodeStopped),
+			instanceTypes:     []string{"Standard_NC12s_v3", "Standard_NC6s_v3"},
+			expectedResumable: true,
+		},
+		{
+			name:          "Don't resume running agent pool",
+			agentPool:     deallocatedAgentPool("agentpool0", "Standard_NC6s_v3", armcontainerservice.CodeRunning),
+			instanceTypes: []string{"Standard_NC6s_v3"},
+		},
+		{
+			name:          "Don't resume agent pool of other instance type",
+			agentPool:     deallocatedAgentPool("agentpool0", "Standard_NC6s_v3", armcontainerservice.CodeStopped),
+			instanceTypes: []string{"Standard_NC12s_v3"},
+		},
+		{
+			name:          "Don't resume agent pool not owned by kaito",
+			agentPool:     notOwned,
+			instanceTypes: []string{"Standard_NC6s_v3"},
+		},
+		{
+			name:          "Don't resume agent pool which is not found",
+			getErr:        &azcore.ResponseError{ErrorCode: "NotFound", StatusCode: http.StatusNotFound},
+			instanceTypes: []string{"Standard_NC6s_v3"},
+		},
+		{
+			name:          "Fail to resume because agentPool.Get returns a failure",
+			getErr:        &azcore.ResponseError{ErrorCode: "InternalServerError", StatusCode: http.StatusInternalServerError},
+			instanceTypes: []string{"Standard_NC6s_v3"},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+			resp := armcontainerservice.AgentPoolsClientGetResponse{}
+			if tc.agentPool != nil {
+				resp.AgentPool = *tc.agentPool
+			}
+			agentPoolMocks.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool0", gomock.Any()).Return(resp, tc.getErr)
+			p := createTestProvider(agentPoolMocks, nil)
+
+			resumable, err := p.resumableAgentPool(context.Background(), "agentpool0", tc.instanceTypes, karpenterv1.CapacityTypeOnDemand)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedResumable, resumable != nil)
+		})
+	}
+}
+
+func TestCreateResumesDeallocatedAgentPool(t *testing.T) {
+	t.Setenv("ENABLE_AGENTPOOL_RESUME", "true")
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var resumed armcontainerservice.AgentPool
+	agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+	agentPoolMocks.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool0", gomock.Any()).
+		Return(armcontainerservice.AgentPoolsClientGetResponse{AgentPool: *deallocatedAgentPool("agentpool0", "Standard_NC6s_v3", armcontainerservice.CodeStopped)}, nil)
+	agentPoolMocks.EXPECT().BeginCreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), "agentpool0", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _, _ string, parameters armcontainerservice.AgentPool, _ *armcontainerservice.AgentPoolsClientBeginCreateOrUpdateOptions) (*runtime.Poller[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse], error) {
+			resumed = parameters
+			mockHandler := fake.NewMockPollingHandler[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse](mockCtrl)
+			mockHandler.EXPECT().Done().Return(true).AnyTimes()
+			mockHandler.EXPECT().Result(gomock.Any(), gomock.Any()).Return(nil)
+			return runtime.NewPoller(&http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}, runtime.NewPipeline("", "", runtime.PipelineOptions{}, nil),
+				&runtime.NewPollerOptions[armcontainerservice.AgentPoolsClientCreateOrUpdateResponse]{
+					Handler:  mockHandler,
+					Response: &armcontainerservice.AgentPoolsClientCreateOrUpdateResponse{AgentPool: parameters},
+				})
+		})
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(agentPoolNode("agentpool0")).Build()
+	p := NewProvider(NewAZClientFromAPI(agentPoolMocks), kubeClient, "testRG", "testCluster")
+
+	nodeClaim := fixture.NodeClaim().WithName("agentpool0").WithStorage(30).WithInstanceType("Standard_NC6s_v3").Build()
+	result, err := p.Create(context.Background(), nodeClaim)
+	assert.NoError(t, err)
+	assert.True(t, result.Resumed)
+	assert.Equal(t, "Standard_NC6s_v3", result.VMSize)
+	assert.Equal(t, armcontainerservice.CodeRunning, lo.FromPtr(resumed.Properties.PowerState.Code))
+	assert.Equal(t, agentPoolNode("agentpool0").Spec.ProviderID, lo.FromPtr(result.ID))
+}
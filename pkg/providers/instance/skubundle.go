@@ -0,0 +1,98 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// SKUBundle is a static snapshot of the resource SKUs and retail prices of a region. air-gapped deployments which
+// can't reach the resource SKUs and retail prices APIs read SKUs and prices from the bundle instead, the bundle is
+// generated by cmd/skugen where the APIs are reachable.
+type SKUBundle struct {
+	Location    string        `json:"location"`
+	GeneratedAt time.Time     `json:"generatedAt"`
+	SKUs        []ResourceSKU `json:"skus"`
+	Prices      []RetailPrice `json:"prices"`
+}
+
+// GenerateSKUBundle queries the virtual machine SKUs and their retail prices in the location, the location of the
+// cluster is used if location is empty.
+func GenerateSKUBundle(ctx context.Context, azClient *AZClient, location string) (*SKUBundle, error) {
+	if location == "" {
+		location = azClient.location
+	}
+	resourceSKUs, err := azClient.resourceSKUsClient.ResourceSKUs(ctx, location)
+	if err != nil {
+		return nil, fmt.Errorf("listing resource skus, %w", err)
+	}
+	filter := fmt.Sprintf("serviceName eq 'Virtual Machines' and priceType eq 'Consumption' and armRegionName eq '%s'", location)
+	prices, err := azClient.retailPricesClient.RetailPrices(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("listing retail prices, %w", err)
+	}
+	return &SKUBundle{
+		Location:    location,
+		GeneratedAt: time.Now().UTC(),
+		SKUs: lo.Filter(resourceSKUs, func(sku ResourceSKU, _ int) bool {
+			return strings.EqualFold(sku.ResourceType, "virtualMachines")
+		}),
+		Prices: prices,
+	}, nil
+}
+
+// LoadSKUBundle reads the bundle generated by cmd/skugen from path.
+func LoadSKUBundle(path string) (*SKUBundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &SKUBundle{}
+	if err := json.Unmarshal(data, bundle); err != nil {
+		return nil, fmt.Errorf("parsing sku bundle %s, %w", path, err)
+	}
+	return bundle, nil
+}
+
+// ResourceSKUs returns the SKUs of the bundle, so the bundle serves as the ResourceSKUsAPI of air-gapped deployments.
+func (b *SKUBundle) ResourceSKUs(_ context.Context, location string) ([]ResourceSKU, error) {
+	if !strings.EqualFold(location, b.Location) {
+		return nil, fmt.Errorf("sku bundle is generated for location %q, not %q", b.Location, location)
+	}
+	return b.SKUs, nil
+}
+
+var armSkuNameFilter = regexp.MustCompile(`armSkuName eq '([^']+)'`)
+
+// RetailPrices returns the prices of the bundle, so the bundle serves as the RetailPricesAPI of air-gapped
+// deployments. only the armSkuName condition of filter is evaluated, all prices of the bundle are in its location.
+func (b *SKUBundle) RetailPrices(_ context.Context, filter string) ([]RetailPrice, error) {
+	match := armSkuNameFilter.FindStringSubmatch(filter)
+	if match == nil {
+		return b.Prices, nil
+	}
+	return lo.Filter(b.Prices, func(price RetailPrice, _ int) bool {
+		return strings.EqualFold(price.ArmSkuName, match[1])
+	}), nil
+}
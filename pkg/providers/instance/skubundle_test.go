@@ -0,0 +1,76 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func TestSKUBundle(t *testing.T) {
+	bundle := &SKUBundle{
+		Location: "eastus",
+		SKUs: []ResourceSKU{
+			{Name: "Standard_NC6s_v3", ResourceType: "virtualMachines", Family: "standardNCSv3Family"},
+		},
+		Prices: []RetailPrice{
+			{ArmSkuName: "Standard_NC6s_v3", SkuName: "NC6s v3", ProductName: "Virtual Machines NCSv3 Series", RetailPrice: 3.06},
+			{ArmSkuName: "Standard_NC6s_v3", SkuName: "NC6s v3 Spot", ProductName: "Virtual Machines NCSv3 Series", RetailPrice: 0.3},
+			{ArmSkuName: "Standard_NC12s_v3", SkuName: "NC12s v3", ProductName: "Virtual Machines NCSv3 Series", RetailPrice: 6.12},
+		},
+	}
+	data, err := json.Marshal(bundle)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "skus.json")
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+
+	loaded, err := LoadSKUBundle(path)
+	assert.NoError(t, err)
+	assert.Equal(t, bundle.SKUs, loaded.SKUs)
+
+	_, err = loaded.ResourceSKUs(context.Background(), "westus")
+	assert.ErrorContains(t, err, "eastus")
+
+	p := &Provider{
+		azClient:         &AZClient{location: "eastus", resourceSKUsClient: loaded, retailPricesClient: loaded},
+		skuCache:         cache.New(SKUCacheTTL, SKUCacheTTL),
+		retailPriceCache: cache.New(RetailPriceCacheTTL, RetailPriceCacheTTL),
+	}
+	skus, err := p.SKUs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "standardNCSv3Family", skus["Standard_NC6s_v3"].Family)
+
+	price, err := p.retailPrice(context.Background(), "Standard_NC6s_v3", karpenterv1.CapacityTypeOnDemand)
+	assert.NoError(t, err)
+	assert.Equal(t, 3.06, price)
+	price, err = p.retailPrice(context.Background(), "Standard_NC6s_v3", karpenterv1.CapacityTypeSpot)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.3, price)
+}
+
+func TestLoadSKUBundleInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "skus.json")
+	assert.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+	_, err := LoadSKUBundle(path)
+	assert.ErrorContains(t, err, "parsing sku bundle")
+}
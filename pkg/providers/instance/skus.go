@@ -0,0 +1,285 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+)
+
+const (
+	// LabelSKUTempDiskSize is the size in GiB of the local temp disk of the instance type.
+	LabelSKUTempDiskSize = "karpenter.azure.com/sku-storage-temp-disk-size"
+	// LabelSKUNVMeSize is the total size in GiB of the local NVMe disks of the instance type.
+	LabelSKUNVMeSize = "karpenter.azure.com/sku-storage-nvme-size"
+	// LabelSKURDMACapable is "true" if the instance type has an InfiniBand RDMA network.
+	LabelSKURDMACapable = "karpenter.azure.com/sku-rdma-capable"
+
+	// ResourceRDMAIB is the extended resource advertised by the RDMA device plugin on nodes with InfiniBand.
+	ResourceRDMAIB v1.ResourceName = "rdma/ib"
+
+	// SKUCacheTTL is the time resource SKUs of the region are served from cache.
+	SKUCacheTTL = 24 * time.Hour
+
+	resourceSKUsAPIVersion = "2021-07-01"
+	resourceSKUsModuleName = "gpu-provisioner/resourceskus"
+	skusCacheKey           = "skus"
+)
+
+// SKULabelKeys are the labels of instance types which nodeclaims can select instance types by.
+var SKULabelKeys = []string{LabelSKUTempDiskSize, LabelSKUNVMeSize, LabelSKURDMACapable, LabelGPUGeneration}
+
+// ResourceSKUsAPI lists the virtual machine SKUs of Microsoft.Compute available in a region.
+type ResourceSKUsAPI interface {
+	ResourceSKUs(ctx context.Context, location string) ([]ResourceSKU, error)
+}
+
+type ResourceSKU struct {
+	Name         string                   `json:"name"`
+	ResourceType string                   `json:"resourceType"`
+	Family       string                   `json:"family"`
+	Capabilities []ResourceSKUCapability  `json:"capabilities"`
+	Restrictions []ResourceSKURestriction `json:"restrictions"`
+}
+
+type ResourceSKURestriction struct {
+	// Type is "Location" if the SKU can't be created in the region, or "Zone" if it can't be created in some zones.
+	Type       string `json:"type"`
+	ReasonCode string `json:"reasonCode"`
+}
+
+type ResourceSKUCapability struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type resourceSKUsResponse struct {
+	Value    []ResourceSKU `json:"value"`
+	NextLink string        `json:"nextLink"`
+}
+
+type resourceSKUsClient struct {
+	internal       *arm.Client
+	subscriptionID string
+}
+
+func NewResourceSKUsClient(subscriptionID string, credential azcore.TokenCredential, options *arm.ClientOptions) (ResourceSKUsAPI, error) {
+	cl, err := arm.NewClient(resourceSKUsModuleName, "v0.0.1", credential, options)
+	if err != nil {
+		return nil, err
+	}
+	return &resourceSKUsClient{internal: cl, subscriptionID: subscriptionID}, nil
+}
+
+func (c *resourceSKUsClient) ResourceSKUs(ctx context.Context, location string) ([]ResourceSKU, error) {
+	var skus []ResourceSKU
+	req, err := runtime.NewRequest(ctx, http.MethodGet, runtime.JoinPaths(c.internal.Endpoint(), fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Compute/skus", c.subscriptionID)))
+	if err != nil {
+		return nil, err
+	}
+	reqQP := req.Raw().URL.Query()
+	reqQP.Set("api-version", resourceSKUsAPIVersion)
+	reqQP.Set("$filter", fmt.Sprintf("location eq '%s'", location))
+	req.Raw().URL.RawQuery = reqQP.Encode()
+
+	for {
+		req.Raw().Header["Accept"] = []string{"application/json"}
+		resp, err := c.internal.Pipeline().Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if !runtime.HasStatusCode(resp, http.StatusOK) {
+			return nil, runtime.NewResponseError(resp)
+		}
+		page := resourceSKUsResponse{}
+		if err := runtime.UnmarshalAsJSON(resp, &page); err != nil {
+			return nil, err
+		}
+		skus = append(skus, page.Value...)
+		if page.NextLink == "" {
+			return skus, nil
+		}
+		if req, err = runtime.NewRequest(ctx, http.MethodGet, page.NextLink); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// SKU is the instance type information which instance types are selected by.
+type SKU struct {
+	Name string
+	// Family is the quota family of the instance type, e.g. standardNCSv3Family.
+	Family      string
+	VCPUs       int64
+	GPUCount    int64
+	TempDiskGiB int64
+	NVMeGiB     int64
+	RDMAEnabled bool
+	// GPUGeneration is empty for instance types without a known nvidia gpu.
+	GPUGeneration string
+	// GPUMemoryGiB is the total memory of all gpus, 0 if it's unknown.
+	GPUMemoryGiB int64
+	// Restricted is true if the instance type is not available for the subscription in the region.
+	Restricted bool
+}
+
+// Labels returns the SKULabelKeys labels of the instance type.
+func (s SKU) Labels() map[string]string {
+	labels := map[string]string{
+		LabelSKUTempDiskSize: strconv.FormatInt(s.TempDiskGiB, 10),
+		LabelSKUNVMeSize:     strconv.FormatInt(s.NVMeGiB, 10),
+		LabelSKURDMACapable:  strconv.FormatBool(s.RDMAEnabled),
+	}
+	if s.GPUGeneration != "" {
+		labels[LabelGPUGeneration] = s.GPUGeneration
+	}
+	return labels
+}
+
+func newSKU(resourceSKU ResourceSKU) SKU {
+	capability := func(name string) string {
+		c, _ := lo.Find(resourceSKU.Capabilities, func(c ResourceSKUCapability) bool { return c.Name == name })
+		return c.Value
+	}
+	size := func(name string) int64 {
+		value, _ := strconv.ParseInt(capability(name), 10, 64)
+		return value
+	}
+	return SKU{
+		Name:        resourceSKU.Name,
+		Family:      resourceSKU.Family,
+		VCPUs:       size("vCPUs"),
+		GPUCount:    size("GPUs"),
+		TempDiskGiB: size("MaxResourceVolumeMB") / 1024,
+		NVMeGiB:     size("NvmeDiskSizeInMiB") / 1024,
+		RDMAEnabled: strings.EqualFold(capability("RdmaEnabled"), "True"),
+
+		GPUGeneration: gpuGeneration(resourceSKU.Name),
+		GPUMemoryGiB:  gpuMemoryGiB(resourceSKU.Name, size("GPUs")),
+		Restricted: lo.ContainsBy(resourceSKU.Restrictions, func(r ResourceSKURestriction) bool {
+			return strings.EqualFold(r.Type, "Location")
+		}),
+	}
+}
+
+// SKUs returns the virtual machine SKUs in the region of the cluster by name.
+func (p *Provider) SKUs(ctx context.Context) (map[string]SKU, error) {
+	if skus, ok := p.skuCache.Get(skusCacheKey); ok {
+		return skus.(map[string]SKU), nil
+	}
+	if p.azClient.resourceSKUsClient == nil {
+		return nil, fmt.Errorf("resource skus client is not configured")
+	}
+	resourceSKUs, err := p.azClient.resourceSKUsClient.ResourceSKUs(ctx, p.azClient.location)
+	if err != nil {
+		return nil, fmt.Errorf("listing resource skus, %w", err)
+	}
+	skus := map[string]SKU{}
+	for i := range resourceSKUs {
+		if strings.EqualFold(resourceSKUs[i].ResourceType, "virtualMachines") {
+			skus[resourceSKUs[i].Name] = newSKU(resourceSKUs[i])
+		}
+	}
+	p.skuCache.SetDefault(skusCacheKey, skus)
+	return skus, nil
+}
+
+// SKURequirementsNotMetError is returned when no instance type allowed by the nodeclaim requirements meets the
+// requirements on SKULabelKeys or has enough gpu memory for the model.
+type SKURequirementsNotMetError struct {
+	InstanceTypes []string
+}
+
+func (e *SKURequirementsNotMetError) Error() string {
+	return fmt.Sprintf("no SKU meets the sku requirements, candidates %v", e.InstanceTypes)
+}
+
+func IsSKURequirementsNotMetError(err error) bool {
+	var skuErr *SKURequirementsNotMetError
+	return errors.As(err, &skuErr)
+}
+
+// instanceTypesWithSKURequirements filters the instance types by the nodeclaim requirements on SKULabelKeys and the
+// model gpu memory, the order of instance types is kept. instance types restricted in the region are dropped, the
+// nodeclaim is rejected with alternatives if none is left. the labels of the first instance type are returned in order
+// to be set on the agent pool, e.g. daemonsets like the RDMA device plugin select nodes by them. if the nodeclaim has
+// no such requirement, instance types are returned as is and failing to get skus only means nodes are not labeled.
+func (p *Provider) instanceTypesWithSKURequirements(ctx context.Context, nodeClaim *karpenterv1.NodeClaim, instanceTypes []string) ([]string, map[string]string, error) {
+	requirements := scheduling.NewNodeSelectorRequirementsWithMinValues(nodeClaim.Spec.Requirements...)
+	keys := lo.Filter(SKULabelKeys, func(key string, _ int) bool { return requirements.Has(key) })
+	memory, err := modelGPUMemory(nodeClaim)
+	if err != nil {
+		return nil, nil, err
+	}
+	skus, err := p.SKUs(ctx)
+	if err != nil {
+		if len(keys) == 0 && memory == nil {
+			klog.V(1).InfoS("skip labeling nodes with sku labels", "nodeClaim", klog.KObj(nodeClaim), "error", err)
+			return instanceTypes, nil, nil
+		}
+		return nil, nil, err
+	}
+	if instanceTypes, err = p.availableInstanceTypes(skus, instanceTypes); err != nil {
+		return nil, nil, err
+	}
+	if len(keys) == 0 && memory == nil {
+		if sku, ok := skus[instanceTypes[0]]; ok {
+			return instanceTypes, sku.Labels(), nil
+		}
+		return instanceTypes, nil, nil
+	}
+
+	matched := lo.Filter(instanceTypes, func(instanceType string, _ int) bool {
+		sku, ok := skus[instanceType]
+		if !ok {
+			return false
+		}
+		labels := sku.Labels()
+		return fitsGPUMemory(sku, memory) && lo.EveryBy(keys, func(key string) bool { return requirements.Get(key).Has(labels[key]) })
+	})
+	if len(matched) == 0 {
+		return nil, nil, withRemediation(&SKURequirementsNotMetError{InstanceTypes: instanceTypes},
+			"relax the sku requirements or allow instance types which meet them")
+	}
+	return matched, skus[matched[0]].Labels(), nil
+}
+
+// addNodeLabels adds the labels to the agent pool and refreshes its spec hash.
+func addNodeLabels(ap *armcontainerservice.AgentPool, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	for key, value := range labels {
+		ap.Properties.NodeLabels[key] = to.Ptr(value)
+	}
+	ap.Properties.Tags[SpecHashTagKey] = to.Ptr(agentPoolSpecHash(ap))
+}
@@ -0,0 +1,185 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+// fakeResourceSKUs returns the same resource skus for every location.
+type fakeResourceSKUs struct {
+	skus  []ResourceSKU
+	calls int
+}
+
+func (f *fakeResourceSKUs) ResourceSKUs(_ context.Context, _ string) ([]ResourceSKU, error) {
+	f.calls++
+	return f.skus, nil
+}
+
+var testResourceSKUs = []ResourceSKU{
+	{
+		Name:         "Standard_NC6s_v3",
+		ResourceType: "virtualMachines",
+		Capabilities: []ResourceSKUCapability{{Name: "MaxResourceVolumeMB", Value: "344064"}, {Name: "GPUs", Value: "1"}},
+	},
+	{
+		Name:         "Standard_NC24ads_A100_v4",
+		ResourceType: "virtualMachines",
+		Capabilities: []ResourceSKUCapability{{Name: "MaxResourceVolumeMB", Value: "65536"}, {Name: "NvmeDiskSizeInMiB", Value: "915456"}, {Name: "GPUs", Value: "1"}},
+	},
+	{
+		Name:         "Standard_ND96asr_v4",
+		ResourceType: "virtualMachines",
+		Capabilities: []ResourceSKUCapability{{Name: "MaxResourceVolumeMB", Value: "2969600"}, {Name: "RdmaEnabled", Value: "True"}, {Name: "GPUs", Value: "8"}},
+	},
+	{
+		Name:         "Standard_NC24ads_A100_v4",
+		ResourceType: "hostGroups/hosts",
+	},
+}
+
+func TestSKUs(t *testing.T) {
+	resourceSKUs := &fakeResourceSKUs{skus: testResourceSKUs}
+	p := createTestProvider(nil, nil)
+	p.azClient.resourceSKUsClient = resourceSKUs
+
+	skus, err := p.SKUs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]SKU{
+		"Standard_NC6s_v3":         {Name: "Standard_NC6s_v3", TempDiskGiB: 336, GPUCount: 1, GPUGeneration: GPUGenerationVolta, GPUMemoryGiB: 16},
+		"Standard_NC24ads_A100_v4": {Name: "Standard_NC24ads_A100_v4", TempDiskGiB: 64, NVMeGiB: 894, GPUCount: 1, GPUGeneration: GPUGenerationAmpere, GPUMemoryGiB: 80},
+		"Standard_ND96asr_v4":      {Name: "Standard_ND96asr_v4", TempDiskGiB: 2900, RDMAEnabled: true, GPUCount: 8, GPUGeneration: GPUGenerationAmpere, GPUMemoryGiB: 320},
+	}, skus)
+
+	// skus are served from cache afterwards.
+	_, err = p.SKUs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, resourceSKUs.calls)
+}
+
+func TestInstanceTypesWithSKURequirements(t *testing.T) {
+	instanceTypes := []string{"Standard_NC6s_v3", "Standard_NC24ads_A100_v4", "Standard_ND96asr_v4"}
+
+	testCases := []struct {
+		name                  string
+		requirements          []v1.NodeSelectorRequirement
+		annotations           map[string]string
+		expectedInstanceTypes []string
+		expectedLabels        map[string]string
+		expectedCalls         int
+		expectedError         bool
+		skusNotConfigured     bool
+	}{
+		{
+			name:                  "Return all instance types without sku requirements",
+			expectedInstanceTypes: instanceTypes,
+			expectedLabels:        map[string]string{LabelSKUTempDiskSize: "336", LabelSKUNVMeSize: "0", LabelSKURDMACapable: "false", LabelGPUGeneration: GPUGenerationVolta},
+			expectedCalls:         1,
+		},
+		{
+			name:                  "Return all instance types without labels if skus are not available",
+			expectedInstanceTypes: instanceTypes,
+			skusNotConfigured:     true,
+		},
+		{
+			name: "Filter instance types by rdma capability",
+			requirements: []v1.NodeSelectorRequirement{
+				{Key: LabelSKURDMACapable, Operator: v1.NodeSelectorOpIn, Values: []string{"true"}},
+			},
+			expectedInstanceTypes: []string{"Standard_ND96asr_v4"},
+			expectedLabels:        map[string]string{LabelSKUTempDiskSize: "2900", LabelSKUNVMeSize: "0", LabelSKURDMACapable: "true", LabelGPUGeneration: GPUGenerationAmpere},
+			expectedCalls:         1,
+		},
+		{
+			name: "Filter instance types by nvme size",
+			requirements: []v1.NodeSelectorRequirement{
+				{Key: LabelSKUNVMeSize, Operator: v1.NodeSelectorOpGt, Values: []string{"500"}},
+			},
+			expectedInstanceTypes: []string{"Standard_NC24ads_A100_v4"},
+			expectedLabels:        map[string]string{LabelSKUTempDiskSize: "64", LabelSKUNVMeSize: "894", LabelSKURDMACapable: "false", LabelGPUGeneration: GPUGenerationAmpere},
+			expectedCalls:         1,
+		},
+		{
+			name: "Filter instance types by gpu generation",
+			requirements: []v1.NodeSelectorRequirement{
+				{Key: LabelGPUGeneration, Operator: v1.NodeSelectorOpIn, Values: []string{GPUGenerationAmpere, GPUGenerationHopper}},
+			},
+			expectedInstanceTypes: []string{"Standard_NC24ads_A100_v4", "Standard_ND96asr_v4"},
+			expectedLabels:        map[string]string{LabelSKUTempDiskSize: "64", LabelSKUNVMeSize: "894", LabelSKURDMACapable: "false", LabelGPUGeneration: GPUGenerationAmpere},
+			expectedCalls:         1,
+		},
+		{
+			name: "Filter instance types by temp disk size",
+			requirements: []v1.NodeSelectorRequirement{
+				{Key: LabelSKUTempDiskSize, Operator: v1.NodeSelectorOpGt, Values: []string{"100"}},
+			},
+			expectedInstanceTypes: []string{"Standard_NC6s_v3", "Standard_ND96asr_v4"},
+			expectedLabels:        map[string]string{LabelSKUTempDiskSize: "336", LabelSKUNVMeSize: "0", LabelSKURDMACapable: "false", LabelGPUGeneration: GPUGenerationVolta},
+			expectedCalls:         1,
+		},
+		{
+			name:                  "Skip instance types without enough gpu memory for the model",
+			annotations:           map[string]string{ModelGPUMemoryAnnotationKey: "64Gi"},
+			expectedInstanceTypes: []string{"Standard_NC24ads_A100_v4", "Standard_ND96asr_v4"},
+			expectedLabels:        map[string]string{LabelSKUTempDiskSize: "64", LabelSKUNVMeSize: "894", LabelSKURDMACapable: "false", LabelGPUGeneration: GPUGenerationAmpere},
+			expectedCalls:         1,
+		},
+		{
+			name:          "Fail because no instance type has enough gpu memory for the model",
+			annotations:   map[string]string{ModelGPUMemoryAnnotationKey: "640Gi"},
+			expectedCalls: 1,
+			expectedError: true,
+		},
+		{
+			name: "Fail because no instance type meets the sku requirements",
+			requirements: []v1.NodeSelectorRequirement{
+				{Key: LabelSKUTempDiskSize, Operator: v1.NodeSelectorOpGt, Values: []string{"100"}},
+				{Key: LabelSKUNVMeSize, Operator: v1.NodeSelectorOpGt, Values: []string{"500"}},
+				{Key: LabelSKURDMACapable, Operator: v1.NodeSelectorOpIn, Values: []string{"true"}},
+			},
+			expectedCalls: 1,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClaim := fixture.NodeClaim().WithName("nodeclaim-test").WithNodeSelectorRequirements(tc.requirements...).Build()
+			nodeClaim.Annotations = tc.annotations
+			resourceSKUs := &fakeResourceSKUs{skus: testResourceSKUs}
+			p := createTestProvider(nil, nil)
+			if !tc.skusNotConfigured {
+				p.azClient.resourceSKUsClient = resourceSKUs
+			}
+
+			result, labels, err := p.instanceTypesWithSKURequirements(context.Background(), nodeClaim, instanceTypes)
+			assert.Equal(t, tc.expectedCalls, resourceSKUs.calls)
+			if tc.expectedError {
+				assert.True(t, IsSKURequirementsNotMetError(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedInstanceTypes, result)
+			assert.Equal(t, tc.expectedLabels, labels)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// SpecDiff returns the changes from the current agent pool of the nodeclaim to the agent pool gpu-provisioner
+// generates for the nodeclaim, which explain why the agent pool is spec drifted. the agent pool is regenerated with
+// the current vm size and sku labels, as the instance type chosen at creation is not recorded.
+func (p *Provider) SpecDiff(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) ([]string, error) {
+	apName := AgentPoolName(nodeClaim)
+	apObj, err := p.getAgentPool(ctx, apName)
+	if err != nil {
+		return nil, fmt.Errorf("agentPool.Get for %q failed: %w", apName, err)
+	}
+	if apObj.Properties == nil {
+		return nil, fmt.Errorf("agent pool %q has no properties", apName)
+	}
+	desired, err := newAgentPoolObject(lo.FromPtr(apObj.Properties.VMSize), agentPoolCapacityType(apObj), nodeClaim)
+	if err != nil {
+		return nil, err
+	}
+	addNodeLabels(&desired, lo.MapValues(lo.PickByKeys(apObj.Properties.NodeLabels, SKULabelKeys), func(v *string, _ string) string {
+		return lo.FromPtr(v)
+	}))
+	return agentPoolSpecDiff(apObj, &desired), nil
+}
+
+// logSpecDiff logs the changes from the current to the desired agent pool when LOG_SPEC_DIFF is enabled.
+func (p *Provider) logSpecDiff(decision, apName string, current, desired *armcontainerservice.AgentPool) {
+	if !p.logSpecDiffs {
+		return
+	}
+	klog.InfoS("agent pool spec diff", "decision", decision, "agentpool", apName, "diff", agentPoolSpecDiff(current, desired))
+}
+
+// agentPoolSpecDiff returns the changes of the spec hashed fields from current to desired, one per line, sorted
+// so the output is stable.
+func agentPoolSpecDiff(current, desired *armcontainerservice.AgentPool) []string {
+	currentSpec, desiredSpec := specOf(current), specOf(desired)
+	var diff []string
+	if currentSpec.VMSize != desiredSpec.VMSize {
+		diff = append(diff, fmt.Sprintf("vmSize: %q -> %q", currentSpec.VMSize, desiredSpec.VMSize))
+	}
+	if currentSpec.Type != desiredSpec.Type {
+		diff = append(diff, fmt.Sprintf("type: %q -> %q", currentSpec.Type, desiredSpec.Type))
+	}
+	if currentSpec.OSType != desiredSpec.OSType {
+		diff = append(diff, fmt.Sprintf("osType: %q -> %q", currentSpec.OSType, desiredSpec.OSType))
+	}
+	if currentSpec.OSDiskSizeGB != desiredSpec.OSDiskSizeGB {
+		diff = append(diff, fmt.Sprintf("osDiskSizeGB: %d -> %d", currentSpec.OSDiskSizeGB, desiredSpec.OSDiskSizeGB))
+	}
+
+	var labels []string
+	for _, key := range lo.Union(lo.Keys(currentSpec.Labels), lo.Keys(desiredSpec.Labels)) {
+		currentValue, inCurrent := currentSpec.Labels[key]
+		desiredValue, inDesired := desiredSpec.Labels[key]
+		switch {
+		case !inCurrent:
+			labels = append(labels, fmt.Sprintf("label %s: added %q", key, desiredValue))
+		case !inDesired:
+			labels = append(labels, fmt.Sprintf("label %s: removed %q", key, currentValue))
+		case currentValue != desiredValue:
+			labels = append(labels, fmt.Sprintf("label %s: %q -> %q", key, currentValue, desiredValue))
+		}
+	}
+	slices.Sort(labels)
+
+	currentTaints, desiredTaints := sets.New(currentSpec.Taints...), sets.New(desiredSpec.Taints...)
+	taints := append(
+		lo.Map(sets.List(desiredTaints.Difference(currentTaints)), func(t string, _ int) string { return fmt.Sprintf("taint %s: added", t) }),
+		lo.Map(sets.List(currentTaints.Difference(desiredTaints)), func(t string, _ int) string { return fmt.Sprintf("taint %s: removed", t) })...,
+	)
+	return append(append(diff, labels...), taints...)
+}
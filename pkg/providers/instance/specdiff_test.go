@@ -0,0 +1,83 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/fake"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestAgentPoolSpecDiff(t *testing.T) {
+	testcases := map[string]struct {
+		desired  armcontainerservice.AgentPool
+		expected []string
+	}{
+		"no diff": {
+			desired: fixture.AgentPool().WithTaints("sku=gpu:NoSchedule").Build(),
+		},
+		"creation timestamp label is ignored": {
+			desired: fixture.AgentPool().WithTaints("sku=gpu:NoSchedule").WithLabels(map[string]string{NodeClaimCreationLabel: "2024-01-01T00-00-00Z"}).Build(),
+		},
+		"vm size and disk size": {
+			desired:  fixture.AgentPool().WithTaints("sku=gpu:NoSchedule").WithVMSize("Standard_NC12s_v3").WithOSDiskSizeGB(120).Build(),
+			expected: []string{`vmSize: "Standard_NC6s_v3" -> "Standard_NC12s_v3"`, "osDiskSizeGB: 0 -> 120"},
+		},
+		"labels and taints": {
+			desired: fixture.AgentPool().WithTaints("dedicated=infer:NoExecute").WithLabels(map[string]string{"team": "ml", "kaito.sh/workspace": "ws1"}).Build(),
+			expected: []string{
+				`label kaito.sh/workspace: "none" -> "ws1"`,
+				`label team: added "ml"`,
+				"taint dedicated=infer:NoExecute: added",
+				"taint sku=gpu:NoSchedule: removed",
+			},
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			current := fixture.AgentPool().WithTaints("sku=gpu:NoSchedule").Build()
+			assert.Equal(t, tc.expected, agentPoolSpecDiff(&current, &tc.desired))
+		})
+	}
+}
+
+func TestSpecDiff(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	nodeClaim := fixture.NodeClaim().WithStorage(120).WithTaints(v1.Taint{Key: "sku", Value: "gpu", Effect: v1.TaintEffectNoSchedule}).Build()
+	ap, err := newAgentPoolObject(fixture.DefaultInstanceType, "", nodeClaim)
+	assert.NoError(t, err)
+	// sku labels and the labels added out of band are kept on the agent pool
+	ap.Properties.NodeLabels[LabelGPUGeneration] = to.Ptr("volta")
+	ap.Properties.NodeLabels["team"] = to.Ptr("ml")
+
+	agentPoolMocks := fake.NewMockAgentPoolsAPI(mockCtrl)
+	agentPoolMocks.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), nodeClaim.Name, gomock.Any()).Return(armcontainerservice.AgentPoolsClientGetResponse{AgentPool: ap}, nil)
+	p := createTestProvider(agentPoolMocks, fake.NewClient())
+
+	diff, err := p.SpecDiff(context.Background(), nodeClaim)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`label team: removed "ml"`}, diff)
+}
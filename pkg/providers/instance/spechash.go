@@ -0,0 +1,76 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/utils/validation"
+	"github.com/mitchellh/hashstructure/v2"
+	"github.com/samber/lo"
+)
+
+const (
+	// SpecHashTagKey records the hash of the generated agent pool spec on the agent pool.
+	SpecHashTagKey = "kaito.sh_spec-hash"
+	// SpecHashAnnotationKey records the hash of the agent pool spec on the nodeclaim.
+	SpecHashAnnotationKey = "kaito.sh/spec-hash"
+)
+
+// agentPoolSpec is the part of agent pool which is generated from nodeclaim and compared for drift detection.
+type agentPoolSpec struct {
+	VMSize       string
+	Type         string
+	OSType       string
+	OSDiskSizeGB int32
+	Labels       map[string]string
+	Taints       []string
+}
+
+// agentPoolSpecHash returns a deterministic hash of labels, taints, vm size and os settings of the agent pool.
+// NodeClaimCreationLabel is excluded because it differs between agent pools generated from the same spec, and
+// labels and taints managed by AKS are excluded because they're not generated.
+func agentPoolSpecHash(ap *armcontainerservice.AgentPool) string {
+	if ap == nil || ap.Properties == nil {
+		return ""
+	}
+	return fmt.Sprint(lo.Must(hashstructure.Hash(specOf(ap), hashstructure.FormatV2, &hashstructure.HashOptions{
+		SlicesAsSets:    true,
+		IgnoreZeroValue: true,
+		ZeroNil:         true,
+	})))
+}
+
+// specOf returns the part of the agent pool covered by the spec hash, ap must have properties. labels and taints in
+// restricted domains are added by AKS after the agent pool is generated(e.g. the spot label and taint), so they are
+// excluded as well.
+func specOf(ap *armcontainerservice.AgentPool) agentPoolSpec {
+	return agentPoolSpec{
+		VMSize:       lo.FromPtr(ap.Properties.VMSize),
+		Type:         string(lo.FromPtr(ap.Properties.Type)),
+		OSType:       string(lo.FromPtr(ap.Properties.OSType)),
+		OSDiskSizeGB: lo.FromPtr(ap.Properties.OSDiskSizeGB),
+		Labels: lo.OmitBy(lo.MapValues(ap.Properties.NodeLabels, func(v *string, _ string) string {
+			return lo.FromPtr(v)
+		}), func(key string, _ string) bool {
+			return key == NodeClaimCreationLabel || validation.IsRestrictedKey(key)
+		}),
+		Taints: lo.FilterMap(ap.Properties.NodeTaints, func(t *string, _ int) (string, bool) {
+			return lo.FromPtr(t), !validation.IsRestrictedKey(taintKey(lo.FromPtr(t)))
+		}),
+	}
+}
@@ -0,0 +1,107 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/stretchr/testify/assert"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func TestAgentPoolSpecHash(t *testing.T) {
+	base := func() *armcontainerservice.AgentPool {
+		ap := GetAgentPoolObj(armcontainerservice.AgentPoolTypeVirtualMachineScaleSets, armcontainerservice.ScaleSetPriorityRegular,
+			map[string]*string{"kaito.sh/workspace": to.Ptr("none"), NodeClaimCreationLabel: to.Ptr("2024-01-01T00-00-00Z")},
+			[]*string{to.Ptr("sku=gpu:NoSchedule"), to.Ptr("test=test:NoExecute")}, 30, "Standard_NC6s_v3")
+		return &ap
+	}
+
+	testCases := []struct {
+		name         string
+		mutate       func(ap *armcontainerservice.AgentPool)
+		expectedSame bool
+	}{
+		{
+			name: "creation timestamp label is ignored",
+			mutate: func(ap *armcontainerservice.AgentPool) {
+				ap.Properties.NodeLabels[NodeClaimCreationLabel] = to.Ptr("2025-01-01T00-00-00Z")
+			},
+			expectedSame: true,
+		},
+		{
+			name: "taint order is ignored",
+			mutate: func(ap *armcontainerservice.AgentPool) {
+				ap.Properties.NodeTaints = []*string{to.Ptr("test=test:NoExecute"), to.Ptr("sku=gpu:NoSchedule")}
+			},
+			expectedSame: true,
+		},
+		{
+			name: "tags are ignored",
+			mutate: func(ap *armcontainerservice.AgentPool) {
+				ap.Properties.Tags = map[string]*string{ManagedByTagKey: to.Ptr(ManagedByTagValue)}
+			},
+			expectedSame: true,
+		},
+		{
+			name: "spot label and taint added by AKS are ignored",
+			mutate: func(ap *armcontainerservice.AgentPool) {
+				ap.Properties.NodeLabels["kubernetes.azure.com/scalesetpriority"] = to.Ptr("spot")
+				ap.Properties.NodeTaints = append(ap.Properties.NodeTaints, to.Ptr("kubernetes.azure.com/scalesetpriority=spot:NoSchedule"))
+			},
+			expectedSame: true,
+		},
+		{
+			name:   "vm size is changed",
+			mutate: func(ap *armcontainerservice.AgentPool) { ap.Properties.VMSize = to.Ptr("Standard_NC12s_v3") },
+		},
+		{
+			name: "label is changed",
+			mutate: func(ap *armcontainerservice.AgentPool) {
+				ap.Properties.NodeLabels["kaito.sh/workspace"] = to.Ptr("other")
+			},
+		},
+		{
+			name:   "taint is removed",
+			mutate: func(ap *armcontainerservice.AgentPool) { ap.Properties.NodeTaints = ap.Properties.NodeTaints[:1] },
+		},
+		{
+			name:   "os disk size is changed",
+			mutate: func(ap *armcontainerservice.AgentPool) { ap.Properties.OSDiskSizeGB = to.Ptr(int32(100)) },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ap := base()
+			tc.mutate(ap)
+			assert.Equal(t, tc.expectedSame, agentPoolSpecHash(base()) == agentPoolSpecHash(ap))
+		})
+	}
+}
+
+func TestSpotAgentPoolIsNotDriftedByAKSTaint(t *testing.T) {
+	ap, err := newAgentPoolObject("Standard_NC6s_v3", karpenterv1.CapacityTypeSpot, fixture.NodeClaim().WithSpot().WithStorage(30).Build())
+	assert.NoError(t, err)
+
+	// AKS adds the spot label and taint to the live agent pool after it's created from the generated spec.
+	ap.Properties.NodeLabels["kubernetes.azure.com/scalesetpriority"] = to.Ptr("spot")
+	ap.Properties.NodeTaints = append(ap.Properties.NodeTaints, to.Ptr("kubernetes.azure.com/scalesetpriority=spot:NoSchedule"))
+	assert.Equal(t, *ap.Properties.Tags[SpecHashTagKey], agentPoolSpecHash(&ap))
+}
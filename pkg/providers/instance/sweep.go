@@ -0,0 +1,49 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"fmt"
+
+	sdkerrors "github.com/Azure/azure-sdk-for-go-extensions/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// AbortOperation aborts the in-progress operation(e.g. a stuck creation) of the agent pool.
+func (p *Provider) AbortOperation(ctx context.Context, apName string) error {
+	klog.InfoS("Instance.AbortOperation", "agentpool name", apName)
+	defer p.agentPoolCache.Delete(apName)
+	if err := abortAgentPoolOperation(ctx, p.azClient.agentPoolsClient, p.resourceGroup, p.clusterName, apName); err != nil {
+		return fmt.Errorf("agentPool.BeginAbortLatestOperation for %q failed: %w", apName, err)
+	}
+	return nil
+}
+
+// RetryDeletion starts a new deletion of the agent pool without waiting for it, so a deletion which got lost(e.g.
+// the LRO failed silently) is retried. an error is returned if the deletion can't be retried, e.g. because the
+// previous deletion is still in progress.
+func (p *Provider) RetryDeletion(ctx context.Context, apName string) error {
+	klog.InfoS("Instance.RetryDeletion", "agentpool name", apName)
+	defer p.agentPoolCache.Delete(apName)
+	if _, err := p.azClient.agentPoolsClient.BeginDelete(ctx, p.resourceGroup, p.clusterName, apName, nil); err != nil {
+		if azErr := sdkerrors.IsResponseError(err); azErr != nil && azErr.ErrorCode == "NotFound" {
+			return nil
+		}
+		return fmt.Errorf("agentPool.BeginDelete for %q failed: %w", apName, err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// invalidTagKeyChars can not be used in azure tag keys.
+const invalidTagKeyChars = `<>%&\?/`
+
+// tagTemplateNodeClaim exposes the nodeclaim to tag templates, e.g. {{ .NodeClaim.Labels "kaito.sh/workspace" }}.
+type tagTemplateNodeClaim struct {
+	Name        string
+	labels      map[string]string
+	annotations map[string]string
+}
+
+// Labels returns the value of the label key of the nodeclaim, or "" if the label is not set.
+func (n tagTemplateNodeClaim) Labels(key string) string {
+	return n.labels[key]
+}
+
+// Annotations returns the value of the annotation key of the nodeclaim, or "" if the annotation is not set.
+func (n tagTemplateNodeClaim) Annotations(key string) string {
+	return n.annotations[key]
+}
+
+// parseTagTemplates parses the default agent pool tags in the form of "key1=value1,key2=value2", values are go
+// templates rendered with the nodeclaim of the agent pool.
+func parseTagTemplates(value string) (map[string]*template.Template, error) {
+	templates := map[string]*template.Template{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, tmpl, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("tag %q is not in the form of key=value", pair)
+		}
+		if strings.ContainsAny(key, invalidTagKeyChars) {
+			return nil, fmt.Errorf("tag key %q contains one of the invalid characters %s", key, invalidTagKeyChars)
+		}
+		if key == ManagedByTagKey || key == SpecHashTagKey || key == NodeClaimTagKey {
+			return nil, fmt.Errorf("tag key %q is reserved by gpu-provisioner", key)
+		}
+		t, err := template.New(key).Option("missingkey=zero").Parse(strings.TrimSpace(tmpl))
+		if err != nil {
+			return nil, fmt.Errorf("parsing template of tag %q, %w", key, err)
+		}
+		templates[key] = t
+	}
+	return templates, nil
+}
+
+// addTags renders the tag templates with the nodeclaim and adds the tags to the agent pool.
+func addTags(ap *armcontainerservice.AgentPool, templates map[string]*template.Template, nodeClaim *karpenterv1.NodeClaim) error {
+	data := struct{ NodeClaim tagTemplateNodeClaim }{
+		NodeClaim: tagTemplateNodeClaim{
+			Name:        nodeClaim.Name,
+			labels:      nodeClaim.Labels,
+			annotations: nodeClaim.Annotations,
+		},
+	}
+	for key, t := range templates {
+		var value strings.Builder
+		if err := t.Execute(&value, data); err != nil {
+			return fmt.Errorf("rendering tag %q for nodeclaim(%s), %w", key, nodeClaim.Name, err)
+		}
+		ap.Properties.Tags[key] = to.Ptr(value.String())
+	}
+	return nil
+}
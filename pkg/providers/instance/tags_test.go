@@ -0,0 +1,90 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/fixture"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddTags(t *testing.T) {
+	testCases := []struct {
+		name          string
+		tags          string
+		expectedTags  map[string]*string
+		expectedError bool
+	}{
+		{
+			name:         "No default tags",
+			expectedTags: map[string]*string{ManagedByTagKey: to.Ptr(ManagedByTagValue)},
+		},
+		{
+			name: "Render tags with nodeclaim fields",
+			tags: `team=ml, workspace={{ .NodeClaim.Labels "kaito.sh/workspace" }},nodeclaim={{ .NodeClaim.Name }},owner={{ .NodeClaim.Annotations "owner" }}`,
+			expectedTags: map[string]*string{
+				ManagedByTagKey: to.Ptr(ManagedByTagValue),
+				"team":          to.Ptr("ml"),
+				"workspace":     to.Ptr("none"),
+				"nodeclaim":     to.Ptr("nodeclaim-test"),
+				"owner":         to.Ptr(""),
+			},
+		},
+		{
+			name:          "Fail because the tag is not in the form of key=value",
+			tags:          "team",
+			expectedError: true,
+		},
+		{
+			name:          "Fail because the tag key is invalid",
+			tags:          "team/name=ml",
+			expectedError: true,
+		},
+		{
+			name:          "Fail because the tag key is reserved",
+			tags:          ManagedByTagKey + "=me",
+			expectedError: true,
+		},
+		{
+			name:          "Fail because the tag template is invalid",
+			tags:          "workspace={{ .NodeClaim.Labels ",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			templates, err := parseTagTemplates(tc.tags)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			nodeClaim := fixture.NodeClaim().WithName("nodeclaim-test").Build()
+			ap := &armcontainerservice.AgentPool{
+				Properties: &armcontainerservice.ManagedClusterAgentPoolProfileProperties{
+					Tags: map[string]*string{ManagedByTagKey: to.Ptr(ManagedByTagValue)},
+				},
+			}
+			assert.NoError(t, addTags(ap, templates, nodeClaim))
+			assert.Equal(t, tc.expectedTags, ap.Properties.Tags)
+		})
+	}
+}
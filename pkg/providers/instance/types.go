@@ -15,6 +15,28 @@ limitations under the License.
 
 package instance
 
+import (
+	"context"
+	"time"
+
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// InstanceProvider manages the instances backing nodeclaims. all operations identify an instance by its provider
+// ID, which is translated to the backend resource(e.g. agent pool name) internally.
+type InstanceProvider interface {
+	Create(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (*CreateResult, error)
+	Get(ctx context.Context, id string) (*Instance, error)
+	List(ctx context.Context) ([]*Instance, error)
+	// ListPage returns a page of instances sorted by name and the continuation token of the next page.
+	ListPage(ctx context.Context, opts ListOptions) ([]*Instance, string, error)
+	Delete(ctx context.Context, id string) error
+	// SKUs returns the instance types which can be created by name.
+	SKUs(ctx context.Context) (map[string]SKU, error)
+	// SpecDiff returns the changes from the current agent pool of the nodeclaim to the one generated for it.
+	SpecDiff(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) ([]string, error)
+}
+
 // Instance a struct to isolate weather vm or vmss
 type Instance struct {
 	Name         *string // agentPoolName or instance/vmName
@@ -24,6 +46,36 @@ type Instance struct {
 	Type         *string
 	CapacityType *string
 	SubnetID     *string
-	Tags         map[string]*string
-	Labels       map[string]string
+	// Tags are the tags of the agent pool(e.g. ownership and billing metadata), it's never nil.
+	Tags   map[string]*string
+	Labels map[string]string
+	// SpecHash is the hash of the current agent pool spec, it differs from the SpecHashTagKey tag if the agent pool is drifted.
+	SpecHash string
+	// Zone is the availability zone of the agent pool, nil if the agent pool is not pinned to exactly one zone.
+	Zone *string
+	// AgentPoolID is the ARM resource ID of the agent pool, ID is the provider ID of the node.
+	AgentPoolID *string
+}
+
+// CreateResult is the outcome of creating the instance of a nodeclaim, it's used for events, metrics and annotations
+// of the nodeclaim.
+type CreateResult struct {
+	*Instance
+	// AgentPoolName is the name of the created agent pool.
+	AgentPoolName string
+	// VMSize is the instance type chosen among the instance types allowed by the nodeclaim.
+	VMSize       string
+	CapacityType string
+	// Zone is empty if the agent pool is not pinned to exactly one zone.
+	Zone string
+	// OperationID is the id of the ARM operation creating the agent pool, it's empty if an agent pool creation
+	// in progress was adopted.
+	OperationID string
+	// Resumed is true if a deallocated agent pool was started instead of creating one, CreateDuration is the time
+	// the start LRO took then.
+	Resumed bool
+	// CreateDuration is the time the agent pool creation LRO took, RegistrationDuration is the time waited for the
+	// node of the agent pool afterwards.
+	CreateDuration       time.Duration
+	RegistrationDuration time.Duration
 }
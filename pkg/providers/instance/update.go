@@ -0,0 +1,121 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/azure/gpu-provisioner/pkg/utils/validation"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// UpdateLabelsAndTaints updates the node labels and taints of the agent pool in place when the labels or taints of
+// nodeclaim have been changed, instead of recreating the agent pool. the spec hash of the agent pool is returned and
+// updated is true if the agent pool has been changed.
+//
+// only labels which can be set on agent pools are synced, and labels removed from the nodeclaim are kept on the agent
+// pool. well-known labels, labels in restricted domains and taints added by AKS are never changed.
+func (p *Provider) UpdateLabelsAndTaints(ctx context.Context, nodeClaim *karpenterv1.NodeClaim) (string, bool, error) {
+	apName := AgentPoolName(nodeClaim)
+	apObj, err := p.getAgentPool(ctx, apName)
+	if err != nil {
+		return "", false, fmt.Errorf("agentPool.Get for %q failed: %w", apName, err)
+	}
+	if apObj.Properties == nil {
+		return "", false, fmt.Errorf("agent pool %q has no properties", apName)
+	}
+
+	labels := lo.PickBy(nodeClaim.Labels, func(key string, _ string) bool {
+		return !karpenterv1.IsRestrictedNodeLabel(key) && !validation.IsRestrictedKey(key)
+	})
+	if err := multierr.Combine(validation.ValidateLabels(labels), validation.ValidateTaints(nodeClaim.Spec.Taints)); err != nil {
+		return "", false, fmt.Errorf("validating nodeclaim(%s), %w", nodeClaim.Name, err)
+	}
+
+	updated := updatedAgentPool(apObj, labels, lo.Map(nodeClaim.Spec.Taints, func(t v1.Taint, _ int) string {
+		return fmt.Sprintf("%s=%s:%s", t.Key, t.Value, t.Effect)
+	}))
+	if updated == nil {
+		return agentPoolSpecHash(apObj), false, nil
+	}
+	if err := p.checkClusterUpgrading(ctx); err != nil {
+		return "", false, err
+	}
+
+	klog.InfoS("Instance.UpdateLabelsAndTaints", "agentpool name", apName)
+	p.logSpecDiff("update", apName, apObj, updated)
+	defer p.agentPoolCache.Delete(apName)
+	if _, _, err := createAgentPool(ctx, p.azClient.agentPoolsClient, p.resourceGroup, apName, p.clusterName, *updated); err != nil {
+		return "", false, fmt.Errorf("agentPool.BeginCreateOrUpdate for %q failed: %w", apName, err)
+	}
+	return lo.FromPtr(updated.Properties.Tags[SpecHashTagKey]), true, nil
+}
+
+// updatedAgentPool returns a copy of apObj with the labels and taints applied, or nil if nothing is changed.
+func updatedAgentPool(apObj *armcontainerservice.AgentPool, labels map[string]string, taints []string) *armcontainerservice.AgentPool {
+	nodeLabels := maps.Clone(apObj.Properties.NodeLabels)
+	if nodeLabels == nil {
+		nodeLabels = map[string]*string{}
+	}
+	labelsChanged := false
+	for key, value := range labels {
+		if current, ok := nodeLabels[key]; !ok || lo.FromPtr(current) != value {
+			nodeLabels[key] = to.Ptr(value)
+			labelsChanged = true
+		}
+	}
+
+	// taints in restricted domains are added by AKS(e.g. the spot taint), they are kept as is.
+	aksTaints := lo.Filter(apObj.Properties.NodeTaints, func(t *string, _ int) bool {
+		return validation.IsRestrictedKey(taintKey(lo.FromPtr(t)))
+	})
+	currentTaints := sets.New(lo.FilterMap(apObj.Properties.NodeTaints, func(t *string, _ int) (string, bool) {
+		return lo.FromPtr(t), !validation.IsRestrictedKey(taintKey(lo.FromPtr(t)))
+	})...)
+	taintsChanged := !currentTaints.Equal(sets.New(taints...))
+
+	if !labelsChanged && !taintsChanged {
+		return nil
+	}
+	properties := *apObj.Properties
+	properties.NodeLabels = nodeLabels
+	properties.NodeTaints = append(aksTaints, lo.ToSlicePtr(taints)...)
+	properties.Tags = maps.Clone(apObj.Properties.Tags)
+	if properties.Tags == nil {
+		properties.Tags = map[string]*string{}
+	}
+	updated := *apObj
+	updated.Properties = &properties
+	properties.Tags[SpecHashTagKey] = to.Ptr(agentPoolSpecHash(&updated))
+	return &updated
+}
+
+// taintKey returns the key of a taint in the agent pool format key=value:effect.
+func taintKey(taint string) string {
+	key, _, _ := strings.Cut(taint, "=")
+	key, _, _ = strings.Cut(key, ":")
+	return key
+}
@@ -0,0 +1,97 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdatedAgentPool(t *testing.T) {
+	spotTaint := "kubernetes.azure.com/scalesetpriority=spot:NoSchedule"
+	testCases := []struct {
+		name           string
+		labels         map[string]string
+		taints         []string
+		expectedLabels map[string]string
+		expectedTaints []string
+		expectedUpdate bool
+	}{
+		{
+			name:   "No change",
+			labels: map[string]string{"test": "test"},
+			taints: []string{"sku=gpu:NoSchedule"},
+		},
+		{
+			name:           "Add label",
+			labels:         map[string]string{"team": "a"},
+			taints:         []string{"sku=gpu:NoSchedule"},
+			expectedLabels: map[string]string{"test": "test", "team": "a"},
+			expectedTaints: []string{spotTaint, "sku=gpu:NoSchedule"},
+			expectedUpdate: true,
+		},
+		{
+			name:           "Change label value",
+			labels:         map[string]string{"test": "changed"},
+			taints:         []string{"sku=gpu:NoSchedule"},
+			expectedLabels: map[string]string{"test": "changed"},
+			expectedTaints: []string{spotTaint, "sku=gpu:NoSchedule"},
+			expectedUpdate: true,
+		},
+		{
+			name:           "Replace taints and keep taints added by AKS",
+			labels:         map[string]string{"test": "test"},
+			taints:         []string{"dedicated=infer:NoExecute"},
+			expectedLabels: map[string]string{"test": "test"},
+			expectedTaints: []string{spotTaint, "dedicated=infer:NoExecute"},
+			expectedUpdate: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ap := &armcontainerservice.AgentPool{
+				Name: to.Ptr("agentpool0"),
+				Properties: &armcontainerservice.ManagedClusterAgentPoolProfileProperties{
+					VMSize:     to.Ptr("Standard_NC6s_v3"),
+					NodeLabels: map[string]*string{"test": to.Ptr("test")},
+					NodeTaints: []*string{to.Ptr(spotTaint), to.Ptr("sku=gpu:NoSchedule")},
+					Tags:       map[string]*string{ManagedByTagKey: to.Ptr(ManagedByTagValue)},
+				},
+			}
+			ap.Properties.Tags[SpecHashTagKey] = to.Ptr(agentPoolSpecHash(ap))
+			specHash := *ap.Properties.Tags[SpecHashTagKey]
+
+			updated := updatedAgentPool(ap, tc.labels, tc.taints)
+			if !tc.expectedUpdate {
+				assert.Nil(t, updated)
+				return
+			}
+			assert.NotNil(t, updated)
+			assert.Equal(t, tc.expectedLabels, lo.MapValues(updated.Properties.NodeLabels, func(v *string, _ string) string { return *v }))
+			assert.Equal(t, tc.expectedTaints, lo.Map(updated.Properties.NodeTaints, func(t *string, _ int) string { return *t }))
+			assert.Equal(t, agentPoolSpecHash(updated), *updated.Properties.Tags[SpecHashTagKey])
+			assert.NotEqual(t, specHash, *updated.Properties.Tags[SpecHashTagKey])
+			// the original agent pool is not changed
+			assert.Equal(t, specHash, *ap.Properties.Tags[SpecHashTagKey])
+			assert.Equal(t, map[string]*string{"test": to.Ptr("test")}, ap.Properties.NodeLabels)
+		})
+	}
+}
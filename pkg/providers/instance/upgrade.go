@@ -0,0 +1,82 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/samber/lo"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ClusterStateCacheTTL is the time the provisioning state of the managed cluster is served from cache.
+	ClusterStateCacheTTL = 30 * time.Second
+
+	clusterStateCacheKey = "provisioningState"
+)
+
+// upgradingProvisioningStates are the provisioning states of the managed cluster in which agent pool operations
+// fail with Conflict errors.
+var upgradingProvisioningStates = []string{"Upgrading", "Updating"}
+
+type ManagedClustersAPI interface {
+	Get(ctx context.Context, resourceGroupName string, resourceName string, options *armcontainerservice.ManagedClustersClientGetOptions) (armcontainerservice.ManagedClustersClientGetResponse, error)
+}
+
+// ClusterUpgradingError is returned when an agent pool operation is deferred because the managed cluster is being
+// upgraded, the operation should be retried later.
+type ClusterUpgradingError struct {
+	ProvisioningState string
+}
+
+func (e *ClusterUpgradingError) Error() string {
+	return fmt.Sprintf("managed cluster is in provisioning state %q", e.ProvisioningState)
+}
+
+func IsClusterUpgradingError(err error) bool {
+	var cuErr *ClusterUpgradingError
+	return errors.As(err, &cuErr)
+}
+
+// checkClusterUpgrading returns a ClusterUpgradingError if the managed cluster is being upgraded. failing to get the
+// managed cluster doesn't block agent pool operations, they are attempted as usual.
+func (p *Provider) checkClusterUpgrading(ctx context.Context) error {
+	if p.azClient.managedClustersClient == nil {
+		return nil
+	}
+	state, ok := p.clusterStateCache.Get(clusterStateCacheKey)
+	if !ok {
+		resp, err := p.azClient.managedClustersClient.Get(ctx, p.resourceGroup, p.clusterName, nil)
+		if err != nil {
+			klog.ErrorS(err, "failed to get managed cluster provisioning state", "cluster", p.clusterName)
+			return nil
+		}
+		state = ""
+		if resp.Properties != nil {
+			state = lo.FromPtr(resp.Properties.ProvisioningState)
+		}
+		p.clusterStateCache.SetDefault(clusterStateCacheKey, state)
+	}
+	if lo.Contains(upgradingProvisioningStates, state.(string)) {
+		return &ClusterUpgradingError{ProvisioningState: state.(string)}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeManagedClusters struct {
+	provisioningState string
+	err               error
+	calls             int
+}
+
+func (f *fakeManagedClusters) Get(_ context.Context, _ string, _ string, _ *armcontainerservice.ManagedClustersClientGetOptions) (armcontainerservice.ManagedClustersClientGetResponse, error) {
+	f.calls++
+	return armcontainerservice.ManagedClustersClientGetResponse{
+		ManagedCluster: armcontainerservice.ManagedCluster{
+			Properties: &armcontainerservice.ManagedClusterProperties{ProvisioningState: lo.ToPtr(f.provisioningState)},
+		},
+	}, f.err
+}
+
+func TestCheckClusterUpgrading(t *testing.T) {
+	testcases := map[string]struct {
+		managedClusters *fakeManagedClusters
+		expectedError   bool
+	}{
+		"managed clusters client is not configured": {},
+		"cluster is upgrading": {
+			managedClusters: &fakeManagedClusters{provisioningState: "Upgrading"},
+			expectedError:   true,
+		},
+		"cluster is updating": {
+			managedClusters: &fakeManagedClusters{provisioningState: "Updating"},
+			expectedError:   true,
+		},
+		"cluster is succeeded": {
+			managedClusters: &fakeManagedClusters{provisioningState: "Succeeded"},
+		},
+		"failed to get cluster": {
+			managedClusters: &fakeManagedClusters{err: errors.New("internal server error")},
+		},
+	}
+
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			p := NewProvider(NewAZClientFromAPI(nil), nil, "testRG", "testCluster")
+			if tc.managedClusters != nil {
+				p.azClient.managedClustersClient = tc.managedClusters
+			}
+
+			for range 2 {
+				err := p.checkClusterUpgrading(context.Background())
+				assert.Equal(t, tc.expectedError, IsClusterUpgradingError(err), "unexpected error %v", err)
+			}
+			if tc.managedClusters != nil && tc.managedClusters.err == nil {
+				assert.Equal(t, 1, tc.managedClusters.calls, "expect provisioning state is cached")
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+)
+
+// maxSurgeRegex matches the max surge of agent pool upgrades, a node count(e.g. "1") or a percentage(e.g. "33%").
+var maxSurgeRegex = regexp.MustCompile(`^[1-9][0-9]*%?$`)
+
+// newUpgradeSettings returns the upgrade settings of created agent pools, or nil to use the AKS defaults if none is
+// set. a drain timeout keeps node image upgrades from evicting pods of running jobs whose PDBs block the eviction,
+// the upgrade of the agent pool fails instead.
+func newUpgradeSettings(maxSurge string, drainTimeout, nodeSoakDuration time.Duration) (*armcontainerservice.AgentPoolUpgradeSettings, error) {
+	if maxSurge == "" && drainTimeout == 0 && nodeSoakDuration == 0 {
+		return nil, nil
+	}
+	settings := &armcontainerservice.AgentPoolUpgradeSettings{}
+	if maxSurge != "" {
+		if !maxSurgeRegex.MatchString(maxSurge) {
+			return nil, fmt.Errorf("max surge %q is neither a node count nor a percentage", maxSurge)
+		}
+		settings.MaxSurge = to.Ptr(maxSurge)
+	}
+	// AKS accepts drain timeouts between 1 and 1440 minutes, and node soak durations up to 30 minutes.
+	if drainTimeout != 0 {
+		if drainTimeout < time.Minute || drainTimeout > 24*time.Hour {
+			return nil, fmt.Errorf("drain timeout %s is not between 1m and 24h", drainTimeout)
+		}
+		settings.DrainTimeoutInMinutes = to.Ptr(int32(drainTimeout / time.Minute))
+	}
+	if nodeSoakDuration != 0 {
+		if nodeSoakDuration < 0 || nodeSoakDuration > 30*time.Minute {
+			return nil, fmt.Errorf("node soak duration %s is not between 0 and 30m", nodeSoakDuration)
+		}
+		settings.NodeSoakDurationInMinutes = to.Ptr(int32(nodeSoakDuration / time.Minute))
+	}
+	return settings, nil
+}
@@ -0,0 +1,83 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUpgradeSettings(t *testing.T) {
+	testCases := []struct {
+		name             string
+		maxSurge         string
+		drainTimeout     time.Duration
+		nodeSoakDuration time.Duration
+		expectedSettings *armcontainerservice.AgentPoolUpgradeSettings
+		expectedError    bool
+	}{
+		{
+			name: "Use AKS defaults without upgrade settings",
+		},
+		{
+			name:             "Set all upgrade settings",
+			maxSurge:         "33%",
+			drainTimeout:     2 * time.Hour,
+			nodeSoakDuration: 5 * time.Minute,
+			expectedSettings: &armcontainerservice.AgentPoolUpgradeSettings{
+				MaxSurge:                  to.Ptr("33%"),
+				DrainTimeoutInMinutes:     to.Ptr(int32(120)),
+				NodeSoakDurationInMinutes: to.Ptr(int32(5)),
+			},
+		},
+		{
+			name:             "Set drain timeout only",
+			drainTimeout:     30 * time.Minute,
+			expectedSettings: &armcontainerservice.AgentPoolUpgradeSettings{DrainTimeoutInMinutes: to.Ptr(int32(30))},
+		},
+		{
+			name:          "Fail because max surge is invalid",
+			maxSurge:      "one",
+			expectedError: true,
+		},
+		{
+			name:          "Fail because drain timeout is too long",
+			drainTimeout:  48 * time.Hour,
+			expectedError: true,
+		},
+		{
+			name:             "Fail because node soak duration is too long",
+			nodeSoakDuration: time.Hour,
+			expectedError:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			settings, err := newUpgradeSettings(tc.maxSurge, tc.drainTimeout, tc.nodeSoakDuration)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedSettings, settings)
+		})
+	}
+}
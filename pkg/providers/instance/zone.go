@@ -0,0 +1,37 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+)
+
+// agentPoolZone returns the availability zone of the agent pool, nil if the agent pool is not pinned to exactly
+// one zone.
+func agentPoolZone(ap *armcontainerservice.AgentPool) *string {
+	if ap.Properties == nil || len(ap.Properties.AvailabilityZones) != 1 {
+		return nil
+	}
+	return ap.Properties.AvailabilityZones[0]
+}
+
+// ZoneMatches returns true if the topology.kubernetes.io/zone label of the node matches the availability zone of
+// the agent pool. AKS nodes are labeled with zone in "<location>-<zone>" format, e.g. "eastus-1".
+func ZoneMatches(nodeZone string, zone string) bool {
+	return nodeZone == zone || strings.HasSuffix(nodeZone, "-"+zone)
+}
@@ -0,0 +1,44 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SetNodeCondition sets the condition of the node, the transition time is only changed when the status changes.
+// It returns false if the node already has the condition with the same status and reason.
+func SetNodeCondition(node *corev1.Node, condition corev1.NodeCondition) bool {
+	now := metav1.Now()
+	for i := range node.Status.Conditions {
+		existing := &node.Status.Conditions[i]
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status && existing.Reason == condition.Reason {
+			return false
+		}
+		if existing.Status != condition.Status {
+			existing.LastTransitionTime = now
+		}
+		existing.Status, existing.Reason, existing.Message, existing.LastHeartbeatTime = condition.Status, condition.Reason, condition.Message, now
+		return true
+	}
+	condition.LastTransitionTime, condition.LastHeartbeatTime = now, now
+	node.Status.Conditions = append(node.Status.Conditions, condition)
+	return true
+}
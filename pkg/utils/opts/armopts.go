@@ -16,18 +16,40 @@ limitations under the License.
 package opts
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/azure/gpu-provisioner/pkg/auth"
+	"github.com/azure/gpu-provisioner/pkg/utils"
+	"golang.org/x/time/rate"
 )
 
+// armRequestLimiter is the budget of ARM requests shared by all ARM clients of the process, so the combined traffic
+// of the instance provider and the controllers stays under the subscription level limits. ARM requests are not
+// limited if ARM_QPS is not set.
+var armRequestLimiter = newRequestLimiter(utils.WithDefaultFloat64("ARM_QPS", 0), utils.WithDefaultInt("ARM_BURST", 10))
+
+func newRequestLimiter(qps float64, burst int) *rate.Limiter {
+	if qps <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(qps), max(burst, 1))
+}
+
 func DefaultArmOpts() *arm.ClientOptions {
 	opts := &arm.ClientOptions{}
 	opts.Telemetry = DefaultTelemetryOpts()
 	opts.Retry = DefaultRetryOpts()
 	opts.Transport = defaultHTTPClient
+	opts.PerRetryPolicies = append(opts.PerRetryPolicies, requestTimeoutPolicy{})
+	if armRequestLimiter != nil {
+		opts.PerRetryPolicies = append(opts.PerRetryPolicies, requestBudgetPolicy{limiter: armRequestLimiter})
+	}
 	return opts
 }
 
@@ -36,11 +58,41 @@ func DefaultRetryOpts() policy.RetryOptions {
 		MaxRetries: 20,
 		// Note the default retry behavior is exponential backoff
 		RetryDelay: time.Second * 5,
+		// a hung connection can't stall the caller indefinitely, the timed out try is retried.
+		TryTimeout: utils.WithDefaultDuration("ARM_REQUEST_TIMEOUT", time.Minute),
 	}
 }
 
+// DefaultTelemetryOpts returns the telemetry options of Azure clients, the telemetry data in the user agent
+// header can be opted out by the DISABLE_TELEMETRY env.
 func DefaultTelemetryOpts() policy.TelemetryOptions {
 	return policy.TelemetryOptions{
 		ApplicationID: auth.GetUserAgentExtension(),
+		Disabled:      utils.WithDefaultBool("DISABLE_TELEMETRY", false),
+	}
+}
+
+// requestTimeoutPolicy counts the tries of ARM requests that exceeded the request timeout.
+type requestTimeoutPolicy struct{}
+
+func (requestTimeoutPolicy) Do(req *policy.Request) (*http.Response, error) {
+	resp, err := req.Next()
+	if err != nil && errors.Is(req.Raw().Context().Err(), context.DeadlineExceeded) {
+		RequestTimeoutsTotal.WithLabelValues(ARMClient, req.Raw().Method).Inc()
+	}
+	return resp, err
+}
+
+// requestBudgetPolicy waits for the shared request budget before every try of ARM requests, retries included.
+type requestBudgetPolicy struct {
+	limiter *rate.Limiter
+}
+
+func (p requestBudgetPolicy) Do(req *policy.Request) (*http.Response, error) {
+	start := time.Now()
+	if err := p.limiter.Wait(req.Raw().Context()); err != nil {
+		return nil, fmt.Errorf("waiting for ARM request budget, %w", err)
 	}
+	RequestBudgetWaitSeconds.WithLabelValues(ARMClient).Observe(time.Since(start).Seconds())
+	return req.Next()
 }
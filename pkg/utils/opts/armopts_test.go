@@ -0,0 +1,42 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestNewRequestLimiter(t *testing.T) {
+	assert.Nil(t, newRequestLimiter(0, 10))
+
+	limiter := newRequestLimiter(2.5, 0)
+	assert.Equal(t, rate.Limit(2.5), limiter.Limit())
+	assert.Equal(t, 1, limiter.Burst())
+}
+
+func TestDefaultArmOptsRequestBudget(t *testing.T) {
+	defer func(limiter *rate.Limiter) { armRequestLimiter = limiter }(armRequestLimiter)
+
+	armRequestLimiter = nil
+	assert.NotContains(t, DefaultArmOpts().PerRetryPolicies, requestBudgetPolicy{})
+
+	armRequestLimiter = newRequestLimiter(10, 10)
+	// all clients share the same budget.
+	assert.Contains(t, DefaultArmOpts().PerRetryPolicies, requestBudgetPolicy{limiter: armRequestLimiter})
+}
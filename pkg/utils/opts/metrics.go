@@ -0,0 +1,55 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opts
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+const (
+	clientLabel = "client"
+	methodLabel = "method"
+
+	ARMClient  = "arm"
+	KubeClient = "kube"
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(RequestTimeoutsTotal, RequestBudgetWaitSeconds)
+}
+
+var RequestTimeoutsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "client",
+		Name:      "request_timeouts_total",
+		Help:      "Number of ARM and kube requests that exceeded the request timeout. Labeled by client and method.",
+	},
+	[]string{clientLabel, methodLabel},
+)
+
+var RequestBudgetWaitSeconds = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "client",
+		Name:      "request_budget_wait_duration_seconds",
+		Help:      "Time requests waited for the shared request budget before being sent. Labeled by client.",
+		Buckets:   metrics.DurationBuckets(),
+	},
+	[]string{clientLabel},
+)
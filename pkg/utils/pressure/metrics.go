@@ -0,0 +1,35 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pressure
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(Pressure)
+}
+
+var Pressure = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "apiserver",
+		Name:      "pressure",
+		Help:      "1 while the apiserver throttles gpu-provisioner and low priority loops are paused, 0 otherwise.",
+	},
+)
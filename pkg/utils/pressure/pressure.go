@@ -0,0 +1,121 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pressure detects that the apiserver throttles gpu-provisioner, either by the client-side rate limiter of
+// client-go or by 429 responses, so low priority loops can back off while provisioning keeps its share of requests.
+package pressure
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/azure/gpu-provisioner/pkg/utils"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+	"k8s.io/utils/clock"
+)
+
+// Default is the detector fed by the kube clients of the process once Install is called.
+var Default = NewDetector(
+	utils.WithDefaultDuration("APISERVER_THROTTLE_THRESHOLD", time.Second),
+	utils.WithDefaultDuration("APISERVER_PRESSURE_COOLDOWN", time.Minute),
+	clock.RealClock{},
+)
+
+// Detector reports pressure from the last throttled request until the cooldown passes.
+type Detector struct {
+	// threshold is the client-side rate limiter wait which counts as throttling, client-go logs waits above 1s.
+	threshold time.Duration
+	cooldown  time.Duration
+	clock     clock.PassiveClock
+
+	mu    sync.Mutex
+	until time.Time
+}
+
+func NewDetector(threshold, cooldown time.Duration, clk clock.PassiveClock) *Detector {
+	return &Detector{threshold: threshold, cooldown: cooldown, clock: clk}
+}
+
+// ObserveRateLimiterWait records the time a request waited for the client-side rate limiter.
+func (d *Detector) ObserveRateLimiterWait(wait time.Duration) {
+	if wait >= d.threshold {
+		d.throttled()
+	}
+}
+
+// ObserveResult records the status code of a response, 429 means the apiserver throttles the client.
+func (d *Detector) ObserveResult(code string) {
+	if code == "429" {
+		d.throttled()
+	}
+}
+
+func (d *Detector) throttled() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if wasUnderPressure := d.clock.Now().Before(d.until); !wasUnderPressure {
+		Pressure.Set(1)
+	}
+	d.until = d.clock.Now().Add(d.cooldown)
+}
+
+// UnderPressure returns true if a request was throttled within the cooldown.
+func (d *Detector) UnderPressure() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	underPressure := d.clock.Now().Before(d.until)
+	if !underPressure {
+		Pressure.Set(0)
+	}
+	return underPressure
+}
+
+// Cooldown is how long pressure lasts after the last throttled request.
+func (d *Detector) Cooldown() time.Duration {
+	return d.cooldown
+}
+
+var installOnce sync.Once
+
+// Install feeds Default with the rate limiter latency and the response codes of client-go. controller-runtime has
+// already registered its client-go metrics, so they are wrapped instead of registered.
+func Install() {
+	installOnce.Do(func() {
+		clientmetrics.RateLimiterLatency = &rateLimiterLatency{next: clientmetrics.RateLimiterLatency, detector: Default}
+		clientmetrics.RequestResult = &requestResult{next: clientmetrics.RequestResult, detector: Default}
+	})
+}
+
+type rateLimiterLatency struct {
+	next     clientmetrics.LatencyMetric
+	detector *Detector
+}
+
+func (r *rateLimiterLatency) Observe(ctx context.Context, verb string, u url.URL, latency time.Duration) {
+	r.detector.ObserveRateLimiterWait(latency)
+	r.next.Observe(ctx, verb, u, latency)
+}
+
+type requestResult struct {
+	next     clientmetrics.ResultMetric
+	detector *Detector
+}
+
+func (r *requestResult) Increment(ctx context.Context, code, method, host string) {
+	r.detector.ObserveResult(code)
+	r.next.Increment(ctx, code, method, host)
+}
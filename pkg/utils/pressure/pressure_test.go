@@ -0,0 +1,93 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pressure
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestDetector(t *testing.T) {
+	testcases := map[string]struct {
+		observe          func(d *Detector)
+		elapsed          time.Duration
+		expectedPressure bool
+	}{
+		"no throttling": {
+			observe: func(d *Detector) {
+				d.ObserveRateLimiterWait(100 * time.Millisecond)
+				d.ObserveResult("200")
+			},
+		},
+		"client-side throttling": {
+			observe:          func(d *Detector) { d.ObserveRateLimiterWait(2 * time.Second) },
+			expectedPressure: true,
+		},
+		"apiserver throttling": {
+			observe:          func(d *Detector) { d.ObserveResult("429") },
+			elapsed:          59 * time.Second,
+			expectedPressure: true,
+		},
+		"pressure ends after the cooldown": {
+			observe: func(d *Detector) { d.ObserveResult("429") },
+			elapsed: time.Minute,
+		},
+	}
+	for k, tc := range testcases {
+		t.Run(k, func(t *testing.T) {
+			clk := clocktesting.NewFakePassiveClock(time.Now())
+			d := NewDetector(time.Second, time.Minute, clk)
+			tc.observe(d)
+			clk.SetTime(clk.Now().Add(tc.elapsed))
+			assert.Equal(t, tc.expectedPressure, d.UnderPressure())
+		})
+	}
+}
+
+type countingResult struct {
+	codes []string
+}
+
+func (c *countingResult) Increment(_ context.Context, code, _, _ string) {
+	c.codes = append(c.codes, code)
+}
+
+type countingLatency struct {
+	observed int
+}
+
+func (c *countingLatency) Observe(_ context.Context, _ string, _ url.URL, _ time.Duration) {
+	c.observed++
+}
+
+func TestAdapters(t *testing.T) {
+	d := NewDetector(time.Second, time.Minute, clocktesting.NewFakePassiveClock(time.Now()))
+	next := &countingResult{}
+	(&requestResult{next: next, detector: d}).Increment(context.Background(), "429", "GET", "apiserver")
+	assert.Equal(t, []string{"429"}, next.codes, "the response code is passed to the next metric")
+	assert.True(t, d.UnderPressure())
+
+	d = NewDetector(time.Second, time.Minute, clocktesting.NewFakePassiveClock(time.Now()))
+	latency := &countingLatency{}
+	(&rateLimiterLatency{next: latency, detector: d}).Observe(context.Background(), "GET", url.URL{}, 3*time.Second)
+	assert.Equal(t, 1, latency.observed)
+	assert.True(t, d.UnderPressure())
+}
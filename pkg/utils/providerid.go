@@ -0,0 +1,104 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+const providerIDPrefix = "azure://"
+
+// ProviderIDBackend is the compute resource type backing the nodes of an agent pool.
+type ProviderIDBackend string
+
+const (
+	// ProviderIDBackendVMSS is used by agent pools of VirtualMachineScaleSets type.
+	ProviderIDBackendVMSS ProviderIDBackend = "virtualMachineScaleSets"
+	// ProviderIDBackendVM is used by agent pools of VirtualMachines type.
+	ProviderIDBackendVM ProviderIDBackend = "virtualMachines"
+)
+
+// ProviderID is the parsed provider ID of an AKS node, e.g.
+// azure:///subscriptions/<sub>/resourceGroups/<node rg>/providers/Microsoft.Compute/virtualMachineScaleSets/aks-<pool>-<hash>-vmss/virtualMachines/0
+type ProviderID struct {
+	SubscriptionID string
+	// ResourceGroup is the node resource group of the cluster.
+	ResourceGroup string
+	Backend       ProviderIDBackend
+	// Name is the scale set name for the VMSS backend or the VM name for the VM backend, both start with
+	// aks-<agent pool name>-.
+	Name string
+	// InstanceID is the instance ID of the VM in the scale set, it's empty for the VM backend.
+	InstanceID string
+}
+
+// AgentPoolName returns the name of the agent pool the node belongs to.
+func (id ProviderID) AgentPoolName() (string, error) {
+	tokens := strings.Split(id.Name, "-") // agentpool name is the second substring
+	if len(tokens) < 3 || tokens[0] != "aks" || tokens[1] == "" {
+		return "", fmt.Errorf("cannot parse agentpool name from %s %q", id.Backend, id.Name)
+	}
+	return tokens[1], nil
+}
+
+// ParseProviderID parses the provider ID of an AKS node, resource types are matched case-insensitively as ARM does.
+func ParseProviderID(s string) (ProviderID, error) {
+	rest, ok := strings.CutPrefix(s, providerIDPrefix+"/")
+	if !ok {
+		return ProviderID{}, fmt.Errorf("provider id %q does not start with %s/", s, providerIDPrefix)
+	}
+	tokens := strings.Split(rest, "/")
+	invalid := fmt.Errorf("provider id %q is not the id of a virtual machine scale set VM or virtual machine", s)
+	if len(tokens) != 8 && len(tokens) != 10 {
+		return ProviderID{}, invalid
+	}
+	if !strings.EqualFold(tokens[0], "subscriptions") || !strings.EqualFold(tokens[2], "resourceGroups") ||
+		!strings.EqualFold(tokens[4], "providers") || !strings.EqualFold(tokens[5], "Microsoft.Compute") {
+		return ProviderID{}, invalid
+	}
+	id := ProviderID{SubscriptionID: tokens[1], ResourceGroup: tokens[3], Name: tokens[7]}
+	switch {
+	case len(tokens) == 10 && strings.EqualFold(tokens[6], string(ProviderIDBackendVMSS)) && strings.EqualFold(tokens[8], "virtualMachines"):
+		id.Backend = ProviderIDBackendVMSS
+		id.InstanceID = tokens[9]
+	case len(tokens) == 8 && strings.EqualFold(tokens[6], string(ProviderIDBackendVM)):
+		id.Backend = ProviderIDBackendVM
+	default:
+		return ProviderID{}, invalid
+	}
+	if id.SubscriptionID == "" || id.ResourceGroup == "" || id.Name == "" || (id.Backend == ProviderIDBackendVMSS && id.InstanceID == "") {
+		return ProviderID{}, invalid
+	}
+	return id, nil
+}
+
+// ValidateProviderID returns an error unless the provider ID is the id of a node of the agent pool, it guards
+// adopting nodes of other agent pools or clusters as instances.
+func ValidateProviderID(s string, agentPoolName string) error {
+	id, err := ParseProviderID(s)
+	if err != nil {
+		return err
+	}
+	name, err := id.AgentPoolName()
+	if err != nil {
+		return err
+	}
+	if name != agentPoolName {
+		return fmt.Errorf("provider id %q belongs to agent pool %s instead of %s", s, name, agentPoolName)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProviderID(t *testing.T) {
+	testCases := []struct {
+		name              string
+		id                string
+		expectedID        ProviderID
+		expectedAgentPool string
+		expectedError     bool
+	}{
+		{
+			name: "Successfully parse provider id of scale set VM",
+			id:   "azure:///subscriptions/sub/resourceGroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool0-20562481-vmss/virtualMachines/0",
+			expectedID: ProviderID{
+				SubscriptionID: "sub",
+				ResourceGroup:  "nodeRG",
+				Backend:        ProviderIDBackendVMSS,
+				Name:           "aks-agentpool0-20562481-vmss",
+				InstanceID:     "0",
+			},
+			expectedAgentPool: "agentpool0",
+		},
+		{
+			name: "Successfully parse provider id of virtual machine",
+			id:   "azure:///subscriptions/sub/resourcegroups/nodeRG/providers/microsoft.compute/virtualmachines/aks-agentpool0-20562481-vm",
+			expectedID: ProviderID{
+				SubscriptionID: "sub",
+				ResourceGroup:  "nodeRG",
+				Backend:        ProviderIDBackendVM,
+				Name:           "aks-agentpool0-20562481-vm",
+			},
+			expectedAgentPool: "agentpool0",
+		},
+		{
+			name:          "Fail to parse provider id without azure prefix",
+			id:            "aws:///us-west-2a/i-0123456789",
+			expectedError: true,
+		},
+		{
+			name:          "Fail to parse provider id without scale set name",
+			id:            "azure:///subscriptions/sub/resourceGroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/virtualMachines/0",
+			expectedError: true,
+		},
+		{
+			name:          "Fail to parse provider id of other resource type",
+			id:            "azure:///subscriptions/sub/resourceGroups/nodeRG/providers/Microsoft.Network/networkInterfaces/aks-agentpool0-nic",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			id, err := ParseProviderID(tc.id)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedID, id)
+			agentPool, err := id.AgentPoolName()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedAgentPool, agentPool)
+		})
+	}
+}
+
+func TestValidateProviderID(t *testing.T) {
+	id := "azure:///subscriptions/sub/resourceGroups/nodeRG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-agentpool0-20562481-vmss/virtualMachines/0"
+	assert.NoError(t, ValidateProviderID(id, "agentpool0"))
+	assert.Error(t, ValidateProviderID(id, "agentpool1"))
+	assert.Error(t, ValidateProviderID("azure:///subscriptions/sub", "agentpool0"))
+}
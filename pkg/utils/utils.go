@@ -16,33 +16,18 @@ limitations under the License.
 package utils
 
 import (
-	"fmt"
 	"os"
-	"regexp"
 	"strconv"
-	"strings"
+	"time"
 )
 
 // ParseAgentPoolNameFromID parses the id stored on the instance ID
 func ParseAgentPoolNameFromID(id string) (string, error) {
-	///subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/virtualMachineScaleSets/<VMSSName>/virtualMachines/0
-	r := regexp.MustCompile(`azure:///subscriptions/.*/resourceGroups/.*/providers/Microsoft.Compute/virtualMachineScaleSets/(?P<VMSSName>.*)/virtualMachines/.*`)
-	matches := r.FindStringSubmatch(id)
-	if matches == nil {
-		return "", fmt.Errorf("id does not match the regxp for ParseAgentPoolNameFromID %s", id)
-	}
-
-	for i, name := range r.SubexpNames() {
-		if name == "VMSSName" {
-			nodeName := matches[i]
-			agentPoolName := strings.Split(nodeName, "-") // agentpool name is the second substring
-			if len(agentPoolName) == 0 {
-				return "", fmt.Errorf("cannot parse agentpool name for ParseAgentPoolNameFromID %s", id)
-			}
-			return agentPoolName[1], nil
-		}
-	}
-	return "", fmt.Errorf("error while parsing id %s", id)
+	providerID, err := ParseProviderID(id)
+	if err != nil {
+		return "", err
+	}
+	return providerID.AgentPoolName()
 }
 
 // WithDefaultBool returns the boolean value of the supplied environment variable or, if not present,
@@ -58,3 +43,55 @@ func WithDefaultBool(key string, def bool) bool {
 	}
 	return parsedVal
 }
+
+// WithDefaultDuration returns the duration value of the supplied environment variable or, if not present,
+// the supplied default value.
+func WithDefaultDuration(key string, def time.Duration) time.Duration {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	parsedVal, err := time.ParseDuration(val)
+	if err != nil {
+		return def
+	}
+	return parsedVal
+}
+
+// WithDefaultString returns the string value of the supplied environment variable or, if not present,
+// the supplied default value.
+func WithDefaultString(key string, def string) string {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	return val
+}
+
+// WithDefaultFloat64 returns the float64 value of the supplied environment variable or, if not present,
+// the supplied default value.
+func WithDefaultFloat64(key string, def float64) float64 {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	parsedVal, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return def
+	}
+	return parsedVal
+}
+
+// WithDefaultInt returns the int value of the supplied environment variable or, if not present,
+// the supplied default value.
+func WithDefaultInt(key string, def int) int {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	parsedVal, err := strconv.Atoi(val)
+	if err != nil {
+		return def
+	}
+	return parsedVal
+}
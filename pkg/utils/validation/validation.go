@@ -0,0 +1,102 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package validation holds the label and taint restriction rules of agent pools. the rules are shared by every
+// path creating agent pools from nodeclaims, so nodeclaims are rejected consistently whether or not an admission
+// webhook is installed.
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/multierr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// RestrictedDomains are label and taint domains owned by AKS, agent pools can't be created with them.
+var RestrictedDomains = []string{"kubernetes.azure.com"}
+
+var supportedTaintEffects = []corev1.TaintEffect{corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute}
+
+// ValidateLabels returns an error for each label which can't be set on agent pools.
+func ValidateLabels(labels map[string]string) error {
+	var errs error
+	for key, value := range labels {
+		if err := validateKey(key); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("invalid label %q, %w", key, err))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			errs = multierr.Append(errs, fmt.Errorf("invalid value %q of label %q, %s", value, key, msg))
+		}
+	}
+	return errs
+}
+
+// ValidateTaints returns an error for each taint which can't be set on agent pools.
+func ValidateTaints(taints []corev1.Taint) error {
+	var errs error
+	for _, taint := range taints {
+		if err := validateKey(taint.Key); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("invalid taint %q, %w", taint.Key, err))
+		}
+		for _, msg := range validation.IsValidLabelValue(taint.Value) {
+			errs = multierr.Append(errs, fmt.Errorf("invalid value %q of taint %q, %s", taint.Value, taint.Key, msg))
+		}
+		if !isSupportedTaintEffect(taint.Effect) {
+			errs = multierr.Append(errs, fmt.Errorf("invalid effect %q of taint %q", taint.Effect, taint.Key))
+		}
+	}
+	return errs
+}
+
+func validateKey(key string) error {
+	if msgs := validation.IsQualifiedName(key); len(msgs) != 0 {
+		return fmt.Errorf("%s", strings.Join(msgs, ", "))
+	}
+	if restricted, ok := restrictedDomainOf(key); ok {
+		return fmt.Errorf("domain %q is restricted", restricted)
+	}
+	return nil
+}
+
+// IsRestrictedKey returns true if the label or taint key is in one of the RestrictedDomains.
+func IsRestrictedKey(key string) bool {
+	_, ok := restrictedDomainOf(key)
+	return ok
+}
+
+func restrictedDomainOf(key string) (string, bool) {
+	domain, _, found := strings.Cut(key, "/")
+	if !found {
+		return "", false
+	}
+	for _, restricted := range RestrictedDomains {
+		if domain == restricted || strings.HasSuffix(domain, "."+restricted) {
+			return restricted, true
+		}
+	}
+	return "", false
+}
+
+func isSupportedTaintEffect(effect corev1.TaintEffect) bool {
+	for _, supported := range supportedTaintEffects {
+		if effect == supported {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateLabels(t *testing.T) {
+	testCases := []struct {
+		name          string
+		labels        map[string]string
+		expectedError bool
+	}{
+		{
+			name:   "Valid labels",
+			labels: map[string]string{"kaito.sh/workspace": "ws", "karpenter.sh/nodepool": "kaito", "app": "test"},
+		},
+		{
+			name:          "Label in restricted domain",
+			labels:        map[string]string{"kubernetes.azure.com/mode": "user"},
+			expectedError: true,
+		},
+		{
+			name:          "Label in subdomain of restricted domain",
+			labels:        map[string]string{"node.kubernetes.azure.com/test": "test"},
+			expectedError: true,
+		},
+		{
+			name:          "Label with invalid value",
+			labels:        map[string]string{"app": "invalid value"},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateLabels(tc.labels)
+			assert.Equal(t, tc.expectedError, err != nil, "unexpected error: %v", err)
+		})
+	}
+}
+
+func TestValidateTaints(t *testing.T) {
+	testCases := []struct {
+		name          string
+		taints        []corev1.Taint
+		expectedError bool
+	}{
+		{
+			name:   "Valid taints",
+			taints: []corev1.Taint{{Key: "sku", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}},
+		},
+		{
+			name:          "Taint in restricted domain",
+			taints:        []corev1.Taint{{Key: "kubernetes.azure.com/scalesetpriority", Value: "spot", Effect: corev1.TaintEffectNoSchedule}},
+			expectedError: true,
+		},
+		{
+			name:          "Taint with unsupported effect",
+			taints:        []corev1.Taint{{Key: "sku", Value: "gpu", Effect: "Unknown"}},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateTaints(tc.taints)
+			assert.Equal(t, tc.expectedError, err != nil, "unexpected error: %v", err)
+		})
+	}
+}
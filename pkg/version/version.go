@@ -0,0 +1,26 @@
+/*
+       Copyright (c) Microsoft Corporation.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package version holds the build information set by the linker flags of the Makefile.
+package version
+
+var (
+	// BuildVersion is the image version of gpu-provisioner.
+	BuildVersion = "unknown"
+	// BuildDate is the date gpu-provisioner was built.
+	BuildDate = "unknown"
+	// GitCommit is the short hash of the commit gpu-provisioner was built from.
+	GitCommit = "unknown"
+)
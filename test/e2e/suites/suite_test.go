@@ -18,15 +18,12 @@ package suites
 import (
 	"testing"
 
+	"github.com/azure/gpu-provisioner/pkg/fixture"
 	"github.com/azure/gpu-provisioner/test/e2e/pkg/environment/common"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	karpenterv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
-	"sigs.k8s.io/karpenter/pkg/test"
 )
 
 var env *common.Environment
@@ -44,61 +41,24 @@ var _ = AfterEach(func() {
 	env.AfterEach()
 })
 
+// gpuNodeClaim is a kaito workspace nodeclaim for a Standard_NC12s_v3 linux node with a 120Gi os disk.
+func gpuNodeClaim(name string) *fixture.NodeClaimBuilder {
+	return fixture.NodeClaim().
+		WithName(name).
+		WithoutProviderID().
+		WithLabels(map[string]string{"karpenter.sh/provisioner-name": "default"}).
+		WithNodeClassRef("AKSNodeClass", "default").
+		WithStorage(120).
+		WithInstanceType("Standard_NC12s_v3").
+		WithRequirement(karpenterv1.NodePoolLabelKey, v1.NodeSelectorOpIn, "kaito").
+		WithRequirement(v1.LabelOSStable, v1.NodeSelectorOpIn, "linux").
+		WithTaints(v1.Taint{Key: "sku", Value: "gpu", Effect: v1.TaintEffectNoSchedule})
+}
+
 var _ = Describe("GPU NodeClaim", func() {
 
 	It("should provision one GPU node for v1.NodeClaim", func() {
-		nodeClaimLabels := map[string]string{
-			"karpenter.sh/provisioner-name": "default",
-			"kaito.sh/workspace":            "none",
-		}
-
-		nc := test.NodeClaim(karpenterv1.NodeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:   "wctestnc1",
-				Labels: nodeClaimLabels,
-			},
-			Spec: karpenterv1.NodeClaimSpec{
-				NodeClassRef: &karpenterv1.NodeClassReference{
-					Name: "default",
-					Kind: "AKSNodeClass",
-				},
-				Resources: karpenterv1.ResourceRequirements{
-					Requests: v1.ResourceList{
-						v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(120*1024*1024*1024, resource.DecimalSI)),
-					},
-				},
-				Requirements: []karpenterv1.NodeSelectorRequirementWithMinValues{
-					{
-						NodeSelectorRequirement: v1.NodeSelectorRequirement{
-							Key:      v1.LabelInstanceTypeStable,
-							Operator: v1.NodeSelectorOpIn,
-							Values:   []string{"Standard_NC12s_v3"},
-						},
-					},
-					{
-						NodeSelectorRequirement: v1.NodeSelectorRequirement{
-							Key:      karpenterv1.NodePoolLabelKey,
-							Operator: v1.NodeSelectorOpIn,
-							Values:   []string{"kaito"},
-						},
-					},
-					{
-						NodeSelectorRequirement: v1.NodeSelectorRequirement{
-							Key:      v1.LabelOSStable,
-							Operator: v1.NodeSelectorOpIn,
-							Values:   []string{"linux"},
-						},
-					},
-				},
-				Taints: []v1.Taint{
-					{
-						Key:    "sku",
-						Value:  "gpu",
-						Effect: v1.TaintEffectNoSchedule,
-					},
-				},
-			},
-		})
+		nc := gpuNodeClaim("wctestnc1").Build()
 
 		DeferCleanup(func() {
 			env.ExpectDeleted(nc)
@@ -114,58 +74,10 @@ var _ = Describe("GPU NodeClaim", func() {
 	})
 
 	It("should provision one GPU node with RAGEngine label ", func() {
-		nodeClaimLabels := map[string]string{
-			"karpenter.sh/provisioner-name": "default",
-			"kaito.sh/ragengine":            "none",
-		}
-
-		nc := test.NodeClaim(karpenterv1.NodeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:   "ragtestnc",
-				Labels: nodeClaimLabels,
-			},
-			Spec: karpenterv1.NodeClaimSpec{
-				NodeClassRef: &karpenterv1.NodeClassReference{
-					Name: "default",
-					Kind: "AKSNodeClass",
-				},
-				Resources: karpenterv1.ResourceRequirements{
-					Requests: v1.ResourceList{
-						v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(120*1024*1024*1024, resource.DecimalSI)),
-					},
-				},
-				Requirements: []karpenterv1.NodeSelectorRequirementWithMinValues{
-					{
-						NodeSelectorRequirement: v1.NodeSelectorRequirement{
-							Key:      v1.LabelInstanceTypeStable,
-							Operator: v1.NodeSelectorOpIn,
-							Values:   []string{"Standard_NC12s_v3"},
-						},
-					},
-					{
-						NodeSelectorRequirement: v1.NodeSelectorRequirement{
-							Key:      karpenterv1.NodePoolLabelKey,
-							Operator: v1.NodeSelectorOpIn,
-							Values:   []string{"kaito"},
-						},
-					},
-					{
-						NodeSelectorRequirement: v1.NodeSelectorRequirement{
-							Key:      v1.LabelOSStable,
-							Operator: v1.NodeSelectorOpIn,
-							Values:   []string{"linux"},
-						},
-					},
-				},
-				Taints: []v1.Taint{
-					{
-						Key:    "sku",
-						Value:  "gpu",
-						Effect: v1.TaintEffectNoSchedule,
-					},
-				},
-			},
-		})
+		nc := gpuNodeClaim("ragtestnc").
+			WithLabels(map[string]string{"kaito.sh/ragengine": "none"}).
+			WithoutLabels("kaito.sh/workspace").
+			Build()
 		DeferCleanup(func() {
 			env.ExpectDeleted(nc)
 			env.EventuallyExpectCreatedNodeClaimCount("==", 0)
@@ -179,58 +91,7 @@ var _ = Describe("GPU NodeClaim", func() {
 		_ = env.EventuallyExpectInitializedNodeCount("==", 1)[0]
 	})
 	It("terminate all resources by deleting nodeclaim", func() {
-		nodeClaimLabels := map[string]string{
-			"karpenter.sh/provisioner-name": "default",
-			"kaito.sh/workspace":            "none",
-		}
-
-		nc := test.NodeClaim(karpenterv1.NodeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:   "wctestnc3",
-				Labels: nodeClaimLabels,
-			},
-			Spec: karpenterv1.NodeClaimSpec{
-				NodeClassRef: &karpenterv1.NodeClassReference{
-					Name: "default",
-					Kind: "AKSNodeClass",
-				},
-				Resources: karpenterv1.ResourceRequirements{
-					Requests: v1.ResourceList{
-						v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(120*1024*1024*1024, resource.DecimalSI)),
-					},
-				},
-				Requirements: []karpenterv1.NodeSelectorRequirementWithMinValues{
-					{
-						NodeSelectorRequirement: v1.NodeSelectorRequirement{
-							Key:      v1.LabelInstanceTypeStable,
-							Operator: v1.NodeSelectorOpIn,
-							Values:   []string{"Standard_NC12s_v3"},
-						},
-					},
-					{
-						NodeSelectorRequirement: v1.NodeSelectorRequirement{
-							Key:      karpenterv1.NodePoolLabelKey,
-							Operator: v1.NodeSelectorOpIn,
-							Values:   []string{"kaito"},
-						},
-					},
-					{
-						NodeSelectorRequirement: v1.NodeSelectorRequirement{
-							Key:      v1.LabelOSStable,
-							Operator: v1.NodeSelectorOpIn,
-							Values:   []string{"linux"},
-						},
-					},
-				},
-				Taints: []v1.Taint{
-					{
-						Key:    "sku",
-						Value:  "gpu",
-						Effect: v1.TaintEffectNoSchedule,
-					},
-				},
-			},
-		})
+		nc := gpuNodeClaim("wctestnc3").Build()
 
 		DeferCleanup(func() {
 			env.EventuallyExpectCreatedNodeClaimCount("==", 0)
@@ -247,58 +108,7 @@ var _ = Describe("GPU NodeClaim", func() {
 		env.ExpectDeleted(nc)
 	})
 	It("terminate all resources by deleting node", func() {
-		nodeClaimLabels := map[string]string{
-			"karpenter.sh/provisioner-name": "default",
-			"kaito.sh/workspace":            "none",
-		}
-
-		nc := test.NodeClaim(karpenterv1.NodeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:   "wctestnc4",
-				Labels: nodeClaimLabels,
-			},
-			Spec: karpenterv1.NodeClaimSpec{
-				NodeClassRef: &karpenterv1.NodeClassReference{
-					Name: "default",
-					Kind: "AKSNodeClass",
-				},
-				Resources: karpenterv1.ResourceRequirements{
-					Requests: v1.ResourceList{
-						v1.ResourceStorage: lo.FromPtr(resource.NewQuantity(120*1024*1024*1024, resource.DecimalSI)),
-					},
-				},
-				Requirements: []karpenterv1.NodeSelectorRequirementWithMinValues{
-					{
-						NodeSelectorRequirement: v1.NodeSelectorRequirement{
-							Key:      v1.LabelInstanceTypeStable,
-							Operator: v1.NodeSelectorOpIn,
-							Values:   []string{"Standard_NC12s_v3"},
-						},
-					},
-					{
-						NodeSelectorRequirement: v1.NodeSelectorRequirement{
-							Key:      karpenterv1.NodePoolLabelKey,
-							Operator: v1.NodeSelectorOpIn,
-							Values:   []string{"kaito"},
-						},
-					},
-					{
-						NodeSelectorRequirement: v1.NodeSelectorRequirement{
-							Key:      v1.LabelOSStable,
-							Operator: v1.NodeSelectorOpIn,
-							Values:   []string{"linux"},
-						},
-					},
-				},
-				Taints: []v1.Taint{
-					{
-						Key:    "sku",
-						Value:  "gpu",
-						Effect: v1.TaintEffectNoSchedule,
-					},
-				},
-			},
-		})
+		nc := gpuNodeClaim("wctestnc4").Build()
 
 		DeferCleanup(func() {
 			env.EventuallyExpectCreatedNodeClaimCount("==", 0)